@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// defaultBackfillStep is about a day of Gnosis Chain blocks, one every 5s.
+const defaultBackfillStep = 17280
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Reconstruct historical balances from past blocks",
+	Long: `Read the balance of every configured wallet and token at past blocks and store
+them with source "backfill". Blocks are sampled every --step blocks from
+--from-block to --to-block (default: the latest block); the default step is
+about a day of Gnosis Chain blocks.
+
+Blocks that already have rows are skipped, so an interrupted backfill can be
+run again to resume it. Reading old state requires an archive node.`,
+	Example: `  rmm-tracker backfill --from-block 38000000
+  rmm-tracker backfill --from-block 38000000 --to-block 39000000 --step 720`,
+	Args: cobra.NoArgs,
+	RunE: runBackfill,
+}
+
+var (
+	backfillFrom          uint64
+	backfillTo            uint64
+	backfillStep          uint64
+	backfillProgressEvery int
+)
+
+func init() {
+	rootCmd.AddCommand(backfillCmd)
+
+	backfillCmd.Flags().Uint64Var(&backfillFrom, "from-block", 0, "first block to read (required)")
+	backfillCmd.Flags().Uint64Var(&backfillTo, "to-block", 0, "last block to read (default: latest block)")
+	backfillCmd.Flags().Uint64Var(&backfillStep, "step", defaultBackfillStep, "blocks between two reads")
+	backfillCmd.Flags().IntVar(&backfillProgressEvery, "progress-every", 10, "log progress every N blocks read")
+	_ = backfillCmd.MarkFlagRequired("from-block")
+}
+
+func runBackfill(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	if backfillStep == 0 {
+		return fmt.Errorf("--step must be positive")
+	}
+	if backfillProgressEvery <= 0 {
+		return fmt.Errorf("--progress-every must be positive")
+	}
+
+	cfg, err := config.LoadLayered(cfgFile, cfgOverlay)
+	if err != nil {
+		slog.Error("Configuration error", "error", err)
+		return err
+	}
+
+	dsn, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	opts, err := getDatabaseOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := connectRPC(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := discoverTokens(ctx, cfg, client); err != nil {
+		return err
+	}
+
+	to := backfillTo
+	if to == 0 {
+		if to, err = client.LatestBlockNumber(ctx); err != nil {
+			return fmt.Errorf("latest block: %w", err)
+		}
+	}
+	if backfillFrom > to {
+		return fmt.Errorf("--from-block %d is after --to-block %d", backfillFrom, to)
+	}
+
+	store, err := storage.NewStore(ctx, dsn, opts)
+	if err != nil {
+		slog.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("database connection failed")
+	}
+	defer store.Close()
+
+	stored, err := store.GetStoredBlocks(ctx, backfillFrom, to)
+	if err != nil {
+		return fmt.Errorf("list stored blocks: %w", err)
+	}
+
+	b := backfiller{
+		cfg:           cfg,
+		fetch:         client.GetTokenBalanceAtBlock,
+		insert:        store.BatchInsertBalances,
+		progressEvery: backfillProgressEvery,
+	}
+	stats, err := b.run(ctx, backfillBlocks(backfillFrom, to, backfillStep), stored)
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Backfilled %d blocks (%d rows, %d failed reads), skipped %d already stored\n",
+		stats.Blocks, stats.Rows, stats.Failed, stats.Skipped)
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("backfill interrupted: run again to resume")
+	}
+	return err
+}
+
+// backfillBlocks returns the blocks from from to to, inclusive, every step
+// blocks.
+func backfillBlocks(from, to, step uint64) []uint64 {
+	var blocks []uint64
+	for block := from; block <= to; block += step {
+		blocks = append(blocks, block)
+		if to-block < step {
+			break
+		}
+	}
+	return blocks
+}
+
+// backfillStats counts the work done by a backfill.
+type backfillStats struct {
+	Blocks  int // blocks read
+	Skipped int // blocks that already had rows
+	Rows    int // balances stored, not counting those already in the table
+	Failed  int // balance reads that failed
+}
+
+// backfiller reads the configured wallets and tokens at past blocks.
+type backfiller struct {
+	cfg           *config.Config
+	fetch         func(ctx context.Context, wallet common.Address, token blockchain.TokenInfo, block *big.Int) (storage.TokenBalance, error)
+	insert        func(ctx context.Context, balances []storage.TokenBalance) ([]storage.TokenBalance, error)
+	progressEvery int
+}
+
+// run reads and stores the balances at each block not in stored, one insert
+// per block. A failed read is logged and the block is stored without it; a
+// block whose reads all failed stores nothing and is read again next run.
+// It stops at the first insert error or when ctx is done.
+func (b backfiller) run(ctx context.Context, blocks []uint64, stored map[uint64]bool) (backfillStats, error) {
+	var stats backfillStats
+	for i, block := range blocks {
+		if stored[block] {
+			stats.Skipped++
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		var balances []storage.TokenBalance
+		for _, w := range b.cfg.Wallets {
+			wallet := common.HexToAddress(w)
+			for _, token := range b.cfg.Tokens {
+				if token.Address == "" {
+					continue
+				}
+				balance, err := b.fetch(ctx, wallet, blockchain.TokenInfo{
+					Label:            token.Label,
+					Address:          token.Address,
+					FallbackDecimals: token.FallbackDecimals,
+				}, new(big.Int).SetUint64(block))
+				if err != nil {
+					if ctx.Err() != nil {
+						return stats, ctx.Err()
+					}
+					stats.Failed++
+					slog.Warn("Historical balance read failed",
+						"block", block,
+						"wallet", wallet.Hex(),
+						"token_address", token.Address,
+						"error", err)
+					continue
+				}
+				balance.Source = storage.SourceBackfill
+				balance.Label = token.Label
+				balance.Tags = b.cfg.WalletTags(w)
+				balance.WalletLabel = b.cfg.WalletLabel(w)
+				balances = append(balances, balance)
+			}
+		}
+
+		if len(balances) > 0 {
+			written, err := b.insert(ctx, balances)
+			if err != nil {
+				return stats, fmt.Errorf("block %d: insert: %w", block, err)
+			}
+			stats.Rows += len(written)
+		}
+		stats.Blocks++
+
+		if stats.Blocks%b.progressEvery == 0 {
+			slog.Info("Backfill progress",
+				"block", block,
+				"done", i+1,
+				"total", len(blocks),
+				"rows", stats.Rows)
+		}
+	}
+	return stats, nil
+}
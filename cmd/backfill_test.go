@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillBlocks(t *testing.T) {
+	assert.Equal(t, []uint64{100, 110, 120}, backfillBlocks(100, 125, 10))
+	assert.Equal(t, []uint64{100, 110, 120}, backfillBlocks(100, 120, 10))
+	assert.Equal(t, []uint64{100}, backfillBlocks(100, 100, defaultBackfillStep))
+	assert.Len(t, backfillBlocks(0, ^uint64(0), 1<<62), 4, "no overflow at the end of the range")
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	cfg := &config.Config{
+		Wallets:        []string{"0x1234567890123456789012345678901234567890"},
+		LabeledWallets: []config.WalletConfig{{Address: "0x1234567890123456789012345678901234567890", Tags: map[string]string{"owner": "alice"}}},
+		Tokens: []config.TokenConfig{
+			{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"},
+			{Label: "broken", Address: "0x0000000000000000000000000000000000000042"},
+		},
+	}
+	var inserted [][]storage.TokenBalance
+	b := backfiller{
+		cfg: cfg,
+		fetch: func(_ context.Context, wallet common.Address, token blockchain.TokenInfo, block *big.Int) (storage.TokenBalance, error) {
+			if token.Label == "broken" {
+				return storage.TokenBalance{}, errors.New("missing trie node")
+			}
+			return storage.TokenBalance{
+				Wallet:       wallet.Hex(),
+				TokenAddress: token.Address,
+				Symbol:       "armmUSDC",
+				RawBalance:   block,
+				Balance:      decimal.NewFromBigInt(block, 0),
+				BlockNumber:  block.Uint64(),
+			}, nil
+		},
+		insert: func(_ context.Context, balances []storage.TokenBalance) ([]storage.TokenBalance, error) {
+			inserted = append(inserted, balances)
+			return balances, nil
+		},
+		progressEvery: 1,
+	}
+
+	stats, err := b.run(context.Background(), []uint64{100, 200, 300}, map[uint64]bool{200: true})
+	require.NoError(t, err)
+	assert.Equal(t, backfillStats{Blocks: 2, Skipped: 1, Rows: 2, Failed: 2}, stats)
+	require.Len(t, inserted, 2, "one insert per block")
+	got := inserted[1][0]
+	assert.Equal(t, uint64(300), got.BlockNumber)
+	assert.Equal(t, storage.SourceBackfill, got.Source)
+	assert.Equal(t, "armmUSDC", got.Label)
+	assert.Equal(t, map[string]string{"owner": "alice"}, got.Tags)
+}
+
+func TestBackfiller_Run_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := &config.Config{
+		Wallets: []string{"0x1234567890123456789012345678901234567890"},
+		Tokens:  []config.TokenConfig{{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"}},
+	}
+	inserts := 0
+	b := backfiller{
+		cfg: cfg,
+		fetch: func(_ context.Context, _ common.Address, _ blockchain.TokenInfo, block *big.Int) (storage.TokenBalance, error) {
+			return storage.TokenBalance{RawBalance: block}, nil
+		},
+		insert: func(_ context.Context, balances []storage.TokenBalance) ([]storage.TokenBalance, error) {
+			inserts++
+			cancel() // Ctrl-C after the first block
+			return balances, nil
+		},
+		progressEvery: 10,
+	}
+
+	stats, err := b.run(ctx, []uint64{100, 200, 300}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, inserts)
+	assert.Equal(t, 1, stats.Blocks)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/matrixise/rmm-tracker/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "List pool tokens a wallet holds but does not track",
+	Long: `Enumerate the reserve tokens of an RMM lending pool and report those in which a
+wallet has a non-zero balance but that are missing from [[tokens]], printed as
+config entries ready to paste.
+
+--wallet defaults to every configured wallet and --pool to token_discovery_pool.`,
+	Example: `  rmm-tracker discover --wallet 0x1234... --pool 0x5B8D36De471880Ee21936f328AAB2383a280CB2A`,
+	RunE:    runDiscover,
+}
+
+var (
+	discoverWallets []string
+	discoverPool    string
+)
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+
+	discoverCmd.Flags().StringSliceVar(&discoverWallets, "wallet", nil, "wallet to inspect (repeatable, default: configured wallets)")
+	discoverCmd.Flags().StringVar(&discoverPool, "pool", "", "RMM lending pool address (default: token_discovery_pool)")
+}
+
+func runDiscover(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	cfg, err := config.LoadLayered(cfgFile, cfgOverlay)
+	if err != nil {
+		slog.Error("Configuration error", "error", err)
+		return err
+	}
+
+	pool := discoverPool
+	if pool == "" {
+		pool = cfg.TokenDiscoveryPool
+	}
+	if pool == "" {
+		return fmt.Errorf("no pool to discover: set --pool or token_discovery_pool")
+	}
+	if !common.IsHexAddress(pool) {
+		return fmt.Errorf("invalid pool address %q", pool)
+	}
+
+	wallets := discoverWallets
+	if len(wallets) == 0 {
+		wallets = cfg.Wallets
+	}
+	for _, w := range wallets {
+		if !common.IsHexAddress(w) {
+			return fmt.Errorf("invalid wallet address %q", w)
+		}
+	}
+
+	client, err := connectRPC(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	out := cmd.OutOrStdout()
+	for _, w := range wallets {
+		wallet := common.HexToAddress(w)
+		untracked, err := tracker.FindUntracked(cmd.Context(), client, client, wallet, common.HexToAddress(pool), cfg.Tokens)
+		if err != nil {
+			slog.Error("Discovery failed", "wallet", wallet.Hex(), "pool", pool, "error", err)
+			return err
+		}
+		writeUntracked(out, wallet, untracked)
+	}
+	return nil
+}
+
+// writeUntracked prints the untracked holdings of wallet as [[tokens]]
+// entries, or a note that every held pool token is tracked.
+func writeUntracked(w io.Writer, wallet common.Address, untracked []storage.TokenBalance) {
+	if len(untracked) == 0 {
+		_, _ = fmt.Fprintf(w, "# %s: every pool token held is tracked\n", wallet.Hex())
+		return
+	}
+	_, _ = fmt.Fprintf(w, "# %s: %d untracked token(s)\n", wallet.Hex(), len(untracked))
+	for _, b := range untracked {
+		_, _ = fmt.Fprintf(w, "\n# balance: %s\n[[tokens]]\nlabel = %q\naddress = %q\nfallback_decimals = %d\n",
+			b.Balance.String(), b.Symbol, b.TokenAddress, b.Decimals)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Dump stored balances as CSV or JSON",
+	Long: `Dump the balances of token_balances queried in [--from, --to), oldest first,
+streaming them from the database without loading them in memory.
+
+--format csv (the default) writes the columns queried_at, wallet, symbol,
+token_address, decimals and balance. --format json writes a balance archive:
+newline-delimited JSON rows behind a versioned header, which import loads back.
+
+--from and --to take a date (2024-01-01, midnight UTC) or an RFC 3339 time;
+either may be left out. Use --out - (the default) to write to stdout.`,
+	Example: `  rmm-tracker export --format csv --out balances.csv --from 2024-01-01 --to 2024-02-01
+  rmm-tracker export --format json --wallet 0x... > balances.ndjson`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+var (
+	exportFormat string
+	exportOut    string
+	exportFrom   string
+	exportTo     string
+	exportWallet string
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "csv", "output format (csv, json)")
+	exportCmd.Flags().StringVar(&exportOut, "out", "-", "output file, - for stdout")
+	exportCmd.Flags().StringVar(&exportFrom, "from", "", "first day or time exported (inclusive)")
+	exportCmd.Flags().StringVar(&exportTo, "to", "", "day or time the export stops at (exclusive)")
+	exportCmd.Flags().StringVar(&exportWallet, "wallet", "", "export only this wallet")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	if exportFormat != "csv" && exportFormat != "json" {
+		return fmt.Errorf("--format must be csv or json, got %q", exportFormat)
+	}
+	if exportWallet != "" && !common.IsHexAddress(exportWallet) {
+		return fmt.Errorf("invalid address %q", exportWallet)
+	}
+	filter := storage.ExportFilter{Wallet: exportWallet}
+	var err error
+	if filter.From, err = parseExportTime(exportFrom); err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	if filter.To, err = parseExportTime(exportTo); err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return fmt.Errorf("--from must be before --to")
+	}
+
+	dsn, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	opts, err := getDatabaseOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewStore(ctx, dsn, opts)
+	if err != nil {
+		slog.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("database connection failed")
+	}
+	defer store.Close()
+
+	out := cmd.OutOrStdout()
+	var file *os.File
+	if exportOut != "-" {
+		if file, err = os.Create(exportOut); err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	exported, err := writeExport(out, exportFormat, func(fn func(storage.TokenBalance) error) error {
+		return store.ExportBalances(ctx, filter, fn)
+	})
+	if err != nil {
+		slog.Error("Export failed", "exported", exported, "error", err)
+		return err
+	}
+	if file != nil {
+		// Written data may only be flushed on close
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Export completed", "balances", exported, "format", exportFormat, "out", exportOut)
+	return nil
+}
+
+// parseExportTime parses a date at midnight UTC or an RFC 3339 time. An
+// empty value is the zero time, which does not bound the export.
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date (2024-01-01) or an RFC 3339 time, got %q", value)
+	}
+	return t, nil
+}
+
+// exportColumns are the CSV columns written by export.
+var exportColumns = []string{"queried_at", "wallet", "symbol", "token_address", "decimals", "balance"}
+
+// writeExport writes the balances passed by stream to w as CSV or as a
+// balance archive, returning the number of balances written.
+func writeExport(w io.Writer, format string, stream func(fn func(storage.TokenBalance) error) error) (int64, error) {
+	var exported int64
+	if format == "json" {
+		archive, err := storage.NewArchiveWriter(w)
+		if err != nil {
+			return 0, err
+		}
+		err = stream(func(b storage.TokenBalance) error {
+			if err := archive.Write(b); err != nil {
+				return err
+			}
+			exported++
+			return nil
+		})
+		return exported, err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return 0, err
+	}
+	err := stream(func(b storage.TokenBalance) error {
+		if err := cw.Write([]string{
+			b.QueriedAt.UTC().Format(time.RFC3339),
+			b.Wallet,
+			b.Symbol,
+			b.TokenAddress,
+			strconv.Itoa(int(b.Decimals)),
+			b.Balance.String(),
+		}); err != nil {
+			return err
+		}
+		exported++
+		return nil
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return exported, err
+}
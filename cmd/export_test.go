@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportTime(t *testing.T) {
+	got, err := parseExportTime("2024-01-01")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), got)
+
+	got, err = parseExportTime("2024-01-01T12:30:00+02:00")
+	require.NoError(t, err)
+	assert.True(t, time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC).Equal(got))
+
+	got, err = parseExportTime("")
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+
+	_, err = parseExportTime("01/02/2024")
+	assert.ErrorContains(t, err, "expected a date")
+}
+
+func TestWriteExport(t *testing.T) {
+	balances := []storage.TokenBalance{{
+		QueriedAt:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Wallet:       "0x1234567890123456789012345678901234567890",
+		TokenAddress: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1",
+		Symbol:       "armmUSDC",
+		Decimals:     6,
+		RawBalance:   big.NewInt(1_500_000),
+		Balance:      decimal.RequireFromString("1.5"),
+	}}
+	stream := func(fn func(storage.TokenBalance) error) error {
+		for _, b := range balances {
+			if err := fn(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	n, err := writeExport(&out, "csv", stream)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	assert.Equal(t, `queried_at,wallet,symbol,token_address,decimals,balance
+2024-01-01T12:00:00Z,0x1234567890123456789012345678901234567890,armmUSDC,0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1,6,1.5
+`, out.String())
+
+	out.Reset()
+	n, err = writeExport(&out, "json", stream)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	archive, err := storage.NewArchiveReader(&out)
+	require.NoError(t, err, "JSON exports are importable archives")
+	b, err := archive.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "1500000", b.RawBalance.String())
+
+	errBroken := errors.New("connection reset")
+	_, err = writeExport(&out, "csv", func(func(storage.TokenBalance) error) error { return errBroken })
+	assert.ErrorIs(t, err, errBroken)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/health"
+	"github.com/spf13/cobra"
+)
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Show the health status of a running daemon",
+	Long: `Fetch the /health endpoint of a running rmm-tracker and print the overall
+status and each check. With --watch the status is fetched again every
+--interval and redrawn until interrupted; an unreachable daemon is shown as
+such and polling goes on.
+
+Without --watch the command exits non-zero when the daemon reports an error
+or cannot be reached.`,
+	Example: `  rmm-tracker health
+  rmm-tracker health --watch --url http://tracker.internal:8080`,
+	Args: cobra.NoArgs,
+	RunE: runHealth,
+}
+
+var (
+	healthURL      string
+	healthWatch    bool
+	healthInterval time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(healthCmd)
+
+	healthCmd.Flags().StringVar(&healthURL, "url", "http://localhost:8080", "base URL of the daemon's HTTP server")
+	healthCmd.Flags().BoolVar(&healthWatch, "watch", false, "refresh the status until interrupted")
+	healthCmd.Flags().DurationVar(&healthInterval, "interval", 5*time.Second, "refresh interval with --watch")
+}
+
+func runHealth(cmd *cobra.Command, args []string) error {
+	if healthInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	out := cmd.OutOrStdout()
+	f, ok := out.(*os.File)
+	color := ok && isTerminal(f)
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	if !healthWatch {
+		h, err := fetchHealth(cmd.Context(), client, healthURL)
+		if err != nil {
+			return err
+		}
+		writeHealth(out, h, color)
+		if h.Status == health.StatusError {
+			return fmt.Errorf("daemon reports status %s", h.Status)
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		if color {
+			// Clear the screen and move the cursor home before redrawing
+			_, _ = fmt.Fprint(out, "\033[H\033[2J")
+		}
+		h, err := fetchHealth(ctx, client, healthURL)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "%s %s\n", paint(color, health.StatusError, "unreachable"), err)
+		} else {
+			writeHealth(out, h, color)
+		}
+		_, _ = fmt.Fprintf(out, "\nRefreshed %s, every %s (Ctrl-C to stop)\n", time.Now().Format(time.TimeOnly), healthInterval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchHealth reads the health status served under baseURL. A 503 still
+// carries the status, so only an undecodable answer is an error.
+func fetchHealth(ctx context.Context, client *http.Client, baseURL string) (health.HealthResponse, error) {
+	url := strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(url, "/health") {
+		url += "/health"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return health.HealthResponse{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return health.HealthResponse{}, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var h health.HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
+		return health.HealthResponse{}, fmt.Errorf("fetch %s: %s: invalid health response: %w", url, resp.Status, err)
+	}
+	return h, nil
+}
+
+// writeHealth prints the overall status, then each check sorted by name.
+// With color, statuses are green, yellow or red.
+func writeHealth(w io.Writer, h health.HealthResponse, color bool) {
+	_, _ = fmt.Fprintf(w, "Status: %s", paint(color, h.Status, string(h.Status)))
+	if h.Build.Version != "" {
+		_, _ = fmt.Fprintf(w, "  version %s", h.Build.Version)
+	}
+	if h.Uptime != "" {
+		_, _ = fmt.Fprintf(w, "  up %s", h.Uptime)
+	}
+	_, _ = fmt.Fprintln(w)
+	if h.LastRunAt != nil {
+		outcome := "unknown"
+		if h.LastRunOK != nil {
+			outcome = map[bool]string{true: "succeeded", false: "failed"}[*h.LastRunOK]
+		}
+		_, _ = fmt.Fprintf(w, "Last run: %s (%s)\n", h.LastRunAt.Local().Format(time.DateTime), outcome)
+	}
+
+	names := make([]string, 0, len(h.Checks))
+	width := 0
+	for name := range h.Checks {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		check := h.Checks[name]
+		line := fmt.Sprintf("  %-*s  %s", width, name, paint(color, check.Status, fmt.Sprintf("%-8s", check.Status)))
+		if check.Message != "" {
+			line += "  " + check.Message
+		}
+		_, _ = fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
+
+// paint wraps text in the ANSI color of status when color is on.
+func paint(color bool, status health.CheckStatus, text string) string {
+	if !color {
+		return text
+	}
+	code := "31" // red
+	switch status {
+	case health.StatusOK:
+		code = "32"
+	case health.StatusDegraded:
+		code = "33"
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
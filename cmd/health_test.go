@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cannedHealth = `{
+	"status": "degraded",
+	"timestamp": "2026-03-01T12:00:00Z",
+	"checks": {
+		"scheduler": {"status": "ok"},
+		"database": {"status": "ok"},
+		"rpc": {"status": "degraded", "message": "backup endpoint in use"}
+	},
+	"uptime": "3h0m0s",
+	"build": {"version": "1.2.3", "git_branch": "main", "git_commit": "abc", "build_time": "now"}
+}`
+
+func healthServer(t *testing.T, code int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchHealth(t *testing.T) {
+	srv := healthServer(t, http.StatusOK, cannedHealth)
+
+	for _, url := range []string{srv.URL, srv.URL + "/", srv.URL + "/health"} {
+		h, err := fetchHealth(context.Background(), srv.Client(), url)
+		require.NoError(t, err)
+		assert.Equal(t, health.StatusDegraded, h.Status)
+		assert.Len(t, h.Checks, 3)
+		assert.Equal(t, "backup endpoint in use", h.Checks["rpc"].Message)
+	}
+}
+
+func TestFetchHealth_ServiceUnavailable(t *testing.T) {
+	// The daemon answers 503 with the status when a check fails
+	srv := healthServer(t, http.StatusServiceUnavailable, `{"status":"error","checks":{"database":{"status":"error","message":"connection refused"}}}`)
+
+	h, err := fetchHealth(context.Background(), srv.Client(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, health.StatusError, h.Status)
+}
+
+func TestFetchHealth_InvalidResponse(t *testing.T) {
+	srv := healthServer(t, http.StatusNotFound, "404 page not found")
+
+	_, err := fetchHealth(context.Background(), srv.Client(), srv.URL)
+	assert.ErrorContains(t, err, "invalid health response")
+}
+
+func TestWriteHealth(t *testing.T) {
+	srv := healthServer(t, http.StatusOK, cannedHealth)
+	h, err := fetchHealth(context.Background(), srv.Client(), srv.URL)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	writeHealth(&out, h, false)
+	assert.Equal(t, `Status: degraded  version 1.2.3  up 3h0m0s
+  database   ok
+  rpc        degraded  backup endpoint in use
+  scheduler  ok
+`, out.String())
+
+	out.Reset()
+	writeHealth(&out, h, true)
+	assert.Contains(t, out.String(), "\033[33mdegraded\033[0m")
+	assert.Contains(t, out.String(), "\033[32mok      \033[0m")
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// importChunk is the number of balances inserted per batch.
+const importChunk = 5000
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Load a balance archive into the database",
+	Long: `Load a balance archive (newline-delimited JSON behind a versioned header) into
+token_balances, tagging every row with source "import". Older archive versions
+are upgraded on the fly; archives written by a newer rmm-tracker are rejected.
+
+Rows are inserted in chunks of 5000: on failure, the chunks already inserted
+stay. Rows already stored for their wallet, token and time are skipped, so an
+interrupted import can be run again. Use - to read the archive from stdin.
+
+--fast loads the chunks with COPY, several times faster for large archives,
+but a row already stored fails its whole chunk instead of being skipped: use
+it to fill an empty database, not to resume an import.`,
+	Example: `  rmm-tracker import balances.ndjson
+  rmm-tracker import --fast balances.ndjson`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+var importFast bool
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().BoolVar(&importFast, "fast", false, "load with COPY, failing on rows already stored")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	var in io.Reader = cmd.InOrStdin()
+	if path := args[0]; path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	archive, err := storage.NewArchiveReader(in)
+	if err != nil {
+		return err
+	}
+
+	dsn, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	opts, err := getDatabaseOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewStore(ctx, dsn, opts)
+	if err != nil {
+		slog.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("database connection failed")
+	}
+	defer store.Close()
+
+	insert := store.InsertBalancesIdempotent
+	if importFast {
+		insert = store.CopyInsertBalances
+	}
+	imported, err := importArchive(ctx, archive, insert)
+	if err != nil {
+		slog.Error("Import failed", "imported", imported, "error", err)
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Imported %d balances (archive version %d)\n", imported, archive.Header.Version)
+	return nil
+}
+
+// importArchive reads every balance of archive and inserts them in chunks,
+// returning the number of rows inserted.
+func importArchive(ctx context.Context, archive *storage.ArchiveReader, insert func(context.Context, []storage.TokenBalance) (int64, error)) (int64, error) {
+	var imported int64
+	chunk := make([]storage.TokenBalance, 0, importChunk)
+	flush := func() error {
+		n, err := insert(ctx, chunk)
+		imported += n
+		chunk = chunk[:0]
+		return err
+	}
+
+	for {
+		b, err := archive.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+		b.ID = 0
+		b.Source = storage.SourceImport
+		chunk = append(chunk, b)
+		if len(chunk) == importChunk {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportArchive(t *testing.T) {
+	file := `{"format":"rmm-tracker-balances","version":1}
+{"id":7,"queried_at":"2025-01-01T00:00:00Z","wallet":"0xabc","token_address":"0x01","symbol":"armmXDAI","decimals":18,"raw_balance":"1","balance":"0.000000000000000001"}
+{"id":8,"queried_at":"2025-01-01T00:00:00Z","wallet":"0xabc","token_address":"0x02","symbol":"armmUSDC","decimals":6,"raw_balance":"2","balance":"0.000002"}
+`
+	archive, err := storage.NewArchiveReader(strings.NewReader(file))
+	require.NoError(t, err)
+
+	var copied []storage.TokenBalance
+	n, err := importArchive(context.Background(), archive, func(_ context.Context, balances []storage.TokenBalance) (int64, error) {
+		copied = append(copied, balances...)
+		return int64(len(balances)), nil
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	require.Len(t, copied, 2)
+	for _, b := range copied {
+		assert.Equal(t, storage.SourceImport, b.Source)
+		assert.Zero(t, b.ID, "ids are assigned by the database")
+	}
+}
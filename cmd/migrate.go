@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/matrixise/rmm-tracker/internal/logger"
 	"github.com/matrixise/rmm-tracker/internal/storage"
@@ -26,20 +32,46 @@ var migrateUpCmd = &cobra.Command{
 var migrateDownCmd = &cobra.Command{
 	Use:   "down",
 	Short: "Rollback the last migration",
-	RunE:  runMigrateDown,
+	Long: `Rollback the last applied migration. The migration and whether rolling it
+back loses data are printed first; confirmation is asked interactively on a
+terminal, and --yes is required otherwise.`,
+	RunE: runMigrateDown,
 }
 
+var migrateDownConfirmed bool
+
+// errRollbackNotConfirmed is returned when migrate down is not confirmed.
+var errRollbackNotConfirmed = errors.New("rollback not confirmed (pass --yes to proceed)")
+
 var migrateStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show migration status",
 	RunE:  runMigrateStatus,
 }
 
+var migratePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "List the migrations migrate up would apply",
+	Long: `List the embedded migrations not yet applied to the database, in the order
+migrate up would apply them, without applying anything.`,
+	Example: `  rmm-tracker migrate plan
+  rmm-tracker migrate plan --json`,
+	Args: cobra.NoArgs,
+	RunE: runMigratePlan,
+}
+
+var migratePlanJSON bool
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 	migrateCmd.AddCommand(migrateUpCmd)
 	migrateCmd.AddCommand(migrateDownCmd)
 	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migratePlanCmd)
+
+	migrateDownCmd.Flags().BoolVarP(&migrateDownConfirmed, "yes", "y", false, "roll back without asking for confirmation")
+	migrateDownCmd.Flags().BoolVar(&migrateDownConfirmed, "force", false, "alias for --yes")
+	migratePlanCmd.Flags().BoolVar(&migratePlanJSON, "json", false, "print the plan as JSON")
 }
 
 func getDatabaseURL() (string, error) {
@@ -63,7 +95,7 @@ func runMigrateUp(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := context.Background()
-	if err := storage.RunMigrations(ctx, dsn); err != nil {
+	if err := storage.RunMigrations(ctx, dsn, storage.MigrationOptions{}); err != nil {
 		slog.Error("Migration failed", "error", err)
 		return err
 	}
@@ -81,6 +113,15 @@ func runMigrateDown(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := context.Background()
+	plan, err := storage.PlanMigrateDown(ctx, dsn)
+	if err != nil {
+		slog.Error("Rollback failed", "error", err)
+		return err
+	}
+	if err := confirmRollback(cmd.InOrStdin(), cmd.OutOrStdout(), isTerminal(os.Stdin), migrateDownConfirmed, plan); err != nil {
+		return err
+	}
+
 	if err := storage.MigrateDown(ctx, dsn); err != nil {
 		slog.Error("Rollback failed", "error", err)
 		return err
@@ -90,6 +131,38 @@ func runMigrateDown(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// confirmRollback describes plan on out, then proceeds when confirmed is set
+// or, on an interactive terminal, when the answer read from in is yes.
+func confirmRollback(in io.Reader, out io.Writer, interactive, confirmed bool, plan storage.RollbackPlan) error {
+	_, _ = fmt.Fprintf(out, "Rolling back migration %s\n", plan.Migration)
+	if plan.Destructive {
+		_, _ = fmt.Fprintln(out, "This rollback is DESTRUCTIVE: it drops or deletes data.")
+	} else {
+		_, _ = fmt.Fprintln(out, "This rollback does not drop data.")
+	}
+
+	if confirmed {
+		return nil
+	}
+	if !interactive {
+		return errRollbackNotConfirmed
+	}
+
+	_, _ = fmt.Fprint(out, "Proceed? [y/N] ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errRollbackNotConfirmed
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func runMigrateStatus(cmd *cobra.Command, args []string) error {
 	logger.Setup(logLevel, logFormat)
 
@@ -99,10 +172,44 @@ func runMigrateStatus(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := context.Background()
-	if err := storage.MigrateStatus(ctx, dsn); err != nil {
+	if err := storage.MigrateStatus(ctx, dsn, cmd.OutOrStdout()); err != nil {
 		slog.Error("Failed to get migration status", "error", err)
 		return err
 	}
 
 	return nil
 }
+
+func runMigratePlan(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	dsn, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+
+	plan, err := storage.PlanMigrateUp(context.Background(), dsn)
+	if err != nil {
+		slog.Error("Failed to plan migrations", "error", err)
+		return err
+	}
+	return writeMigrationPlan(cmd.OutOrStdout(), plan, migratePlanJSON)
+}
+
+// writeMigrationPlan prints plan as a list of pending migrations, or as JSON.
+func writeMigrationPlan(w io.Writer, plan storage.MigrationPlan, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	if len(plan.Pending) == 0 {
+		_, _ = fmt.Fprintf(w, "Database is up to date (version %d)\n", plan.CurrentVersion)
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "Database at version %d, %d pending migration(s):\n", plan.CurrentVersion, len(plan.Pending))
+	for _, m := range plan.Pending {
+		_, _ = fmt.Fprintf(w, "  %d  %s\n", m.Version, m.Migration)
+	}
+	return nil
+}
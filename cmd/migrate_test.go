@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmRollback(t *testing.T) {
+	plan := storage.RollbackPlan{Version: 8, Migration: "008_add_source.sql", Destructive: true}
+
+	tests := []struct {
+		name        string
+		interactive bool
+		confirmed   bool
+		answer      string
+		wantErr     bool
+	}{
+		{name: "non-interactive without flag refuses", wantErr: true},
+		{name: "non-interactive with flag proceeds", confirmed: true},
+		{name: "interactive yes proceeds", interactive: true, answer: "y\n"},
+		{name: "interactive default refuses", interactive: true, answer: "\n", wantErr: true},
+		{name: "interactive no refuses", interactive: true, answer: "no\n", wantErr: true},
+		{name: "interactive with flag skips prompt", interactive: true, confirmed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			err := confirmRollback(strings.NewReader(tt.answer), &out, tt.interactive, tt.confirmed, plan)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errRollbackNotConfirmed)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Contains(t, out.String(), "008_add_source.sql")
+			assert.Contains(t, out.String(), "DESTRUCTIVE")
+		})
+	}
+}
+
+func TestConfirmRollback_NonDestructive(t *testing.T) {
+	var out strings.Builder
+	plan := storage.RollbackPlan{Version: 7, Migration: "007_lowercase_wallet_addresses.sql"}
+
+	assert.NoError(t, confirmRollback(strings.NewReader(""), &out, false, true, plan))
+	assert.Contains(t, out.String(), "does not drop data")
+}
+
+func TestWriteMigrationPlan(t *testing.T) {
+	plan := storage.MigrationPlan{
+		CurrentVersion: 13,
+		Pending: []storage.PendingMigration{
+			{Version: 14, Migration: "014_add_carried_forward.sql"},
+			{Version: 15, Migration: "015_add_block_number.sql"},
+		},
+	}
+
+	var out strings.Builder
+	require.NoError(t, writeMigrationPlan(&out, plan, false))
+	assert.Equal(t, `Database at version 13, 2 pending migration(s):
+  14  014_add_carried_forward.sql
+  15  015_add_block_number.sql
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeMigrationPlan(&out, plan, true))
+	assert.JSONEq(t, `{"current_version":13,"pending":[
+		{"version":14,"migration":"014_add_carried_forward.sql"},
+		{"version":15,"migration":"015_add_block_number.sql"}]}`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeMigrationPlan(&out, storage.MigrationPlan{CurrentVersion: 15, Pending: []storage.PendingMigration{}}, false))
+	assert.Equal(t, "Database is up to date (version 15)\n", out.String())
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Print stored balances of a wallet and token",
+	Long: `Print the stored balances of each --wallet and --token pair, newest first,
+without opening psql. --limit caps the rows printed per pair; --latest prints
+only the most recent row of each pair.
+
+The database is the one the tracker writes to (DATABASE_URL).`,
+	Example: `  rmm-tracker query --wallet 0x... --token 0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1
+  rmm-tracker query --wallet 0x... --wallet 0x... --token 0x... --latest --json`,
+	Args: cobra.NoArgs,
+	RunE: runQuery,
+}
+
+var (
+	queryWallets []string
+	queryTokens  []string
+	queryLimit   int
+	queryLatest  bool
+	queryJSON    bool
+)
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().StringSliceVar(&queryWallets, "wallet", nil, "wallet address (repeatable, required)")
+	queryCmd.Flags().StringSliceVar(&queryTokens, "token", nil, "token address (repeatable, required)")
+	queryCmd.Flags().IntVar(&queryLimit, "limit", 20, "rows printed per wallet and token")
+	queryCmd.Flags().BoolVar(&queryLatest, "latest", false, "print only the most recent row per wallet and token")
+	queryCmd.Flags().BoolVar(&queryJSON, "json", false, "print the rows as JSON")
+	_ = queryCmd.MarkFlagRequired("wallet")
+	_ = queryCmd.MarkFlagRequired("token")
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	if queryLimit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+	for _, addr := range append(slices.Clone(queryWallets), queryTokens...) {
+		if !common.IsHexAddress(addr) {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+	}
+
+	cfg, databaseURL, err := config.LoadWithDefaults(cfgFile, cfgOverlay)
+	if err != nil {
+		slog.Error("Configuration error", "error", err)
+		return err
+	}
+
+	ctx := cmd.Context()
+	store, err := storage.NewStore(ctx, databaseURL, storage.Options{
+		ConnectTimeout:   cfg.DBConnectTimeout,
+		StatementTimeout: cfg.DBStatementTimeout,
+		SeriesKey:        cfg.SeriesKey,
+	})
+	if err != nil {
+		slog.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("database connection failed")
+	}
+	defer store.Close()
+
+	balances, err := queryBalances(ctx, store, queryWallets, queryTokens, queryLimit, queryLatest)
+	if err != nil {
+		return err
+	}
+	return writeQuery(cmd.OutOrStdout(), balances, queryJSON)
+}
+
+// balanceReader is the part of the store read by the query command.
+type balanceReader interface {
+	GetLatestBalance(ctx context.Context, wallet, tokenAddress string) (storage.TokenBalance, error)
+	GetBalanceHistory(ctx context.Context, wallet, tokenAddress string, from, to time.Time) ([]storage.TokenBalance, error)
+}
+
+// queryBalances reads the rows of each wallet and token pair, newest first:
+// the most recent one with latest, otherwise up to limit. Pairs without rows
+// are left out.
+func queryBalances(ctx context.Context, store balanceReader, wallets, tokens []string, limit int, latest bool) ([]storage.TokenBalance, error) {
+	var balances []storage.TokenBalance
+	for _, wallet := range wallets {
+		for _, token := range tokens {
+			// Polled rows store checksummed token addresses
+			token = common.HexToAddress(token).Hex()
+			if latest {
+				b, err := store.GetLatestBalance(ctx, wallet, token)
+				if errors.Is(err, storage.ErrNoBalance) {
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("latest balance of %s for %s: %w", token, wallet, err)
+				}
+				balances = append(balances, b)
+				continue
+			}
+
+			history, err := store.GetBalanceHistory(ctx, wallet, token, time.Time{}, time.Time{})
+			if err != nil {
+				return nil, fmt.Errorf("balance history of %s for %s: %w", token, wallet, err)
+			}
+			history = history[max(0, len(history)-limit):]
+			slices.Reverse(history)
+			balances = append(balances, history...)
+		}
+	}
+	return balances, nil
+}
+
+// writeQuery prints balances as a table, or as a JSON array with asJSON.
+func writeQuery(w io.Writer, balances []storage.TokenBalance, asJSON bool) error {
+	if asJSON {
+		if balances == nil {
+			balances = []storage.TokenBalance{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(balances)
+	}
+	if len(balances) == 0 {
+		_, _ = fmt.Fprintln(w, "No balances found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "WALLET\tSYMBOL\tBALANCE\tQUERIED_AT")
+	for _, b := range balances {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", storage.WalletDisplayName(b.WalletLabel, b.Wallet), b.Symbol, b.Balance.String(), b.QueriedAt.UTC().Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBalanceReader serves rows keyed by wallet and token address, oldest
+// first.
+type fakeBalanceReader map[string][]storage.TokenBalance
+
+func (f fakeBalanceReader) GetLatestBalance(_ context.Context, wallet, token string) (storage.TokenBalance, error) {
+	rows := f[wallet+"/"+token]
+	if len(rows) == 0 {
+		return storage.TokenBalance{}, fmt.Errorf("%w for wallet %s and token %s", storage.ErrNoBalance, wallet, token)
+	}
+	return rows[len(rows)-1], nil
+}
+
+func (f fakeBalanceReader) GetBalanceHistory(_ context.Context, wallet, token string, _, _ time.Time) ([]storage.TokenBalance, error) {
+	return append([]storage.TokenBalance(nil), f[wallet+"/"+token]...), nil
+}
+
+func queryFixture() fakeBalanceReader {
+	const (
+		wallet = "0x1234567890123456789012345678901234567890"
+		token  = "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"
+	)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var rows []storage.TokenBalance
+	for i := range 3 {
+		rows = append(rows, storage.TokenBalance{
+			QueriedAt:    start.Add(time.Duration(i) * time.Hour),
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       "armmUSDC",
+			Balance:      decimal.NewFromInt(int64(i + 1)),
+		})
+	}
+	return fakeBalanceReader{wallet + "/" + token: rows}
+}
+
+func TestQueryBalances(t *testing.T) {
+	store := queryFixture()
+	wallets := []string{"0x1234567890123456789012345678901234567890", "0x0000000000000000000000000000000000000001"}
+	// The lowercase token is matched against the checksummed stored address
+	tokens := []string{"0xed56f76e9cbc6a64b821e9c016eafbd3db5436d1"}
+
+	history, err := queryBalances(context.Background(), store, wallets, tokens, 2, false)
+	require.NoError(t, err)
+	require.Len(t, history, 2, "limit applies per pair")
+	assert.Equal(t, "3", history[0].Balance.String(), "newest first")
+	assert.Equal(t, "2", history[1].Balance.String())
+
+	latest, err := queryBalances(context.Background(), store, wallets, tokens, 2, true)
+	require.NoError(t, err)
+	require.Len(t, latest, 1, "the wallet without rows is left out")
+	assert.Equal(t, "3", latest[0].Balance.String())
+}
+
+func TestWriteQuery(t *testing.T) {
+	balances, err := queryBalances(context.Background(), queryFixture(),
+		[]string{"0x1234567890123456789012345678901234567890"}, []string{"0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"}, 20, true)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, writeQuery(&out, balances, false))
+	assert.Equal(t, `WALLET                                      SYMBOL    BALANCE  QUERIED_AT
+0x1234567890123456789012345678901234567890  armmUSDC  3        2026-03-01T14:00:00Z
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeQuery(&out, balances, true))
+	var rows []map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	assert.Equal(t, "armmUSDC", rows[0]["symbol"])
+
+	balances[0].WalletLabel = "Savings"
+	out.Reset()
+	require.NoError(t, writeQuery(&out, balances, false))
+	assert.Contains(t, out.String(), "Savings (0x1234…)  armmUSDC", "labeled wallets are named")
+
+	out.Reset()
+	require.NoError(t, writeQuery(&out, nil, true))
+	assert.JSONEq(t, `[]`, out.String())
+	out.Reset()
+	require.NoError(t, writeQuery(&out, nil, false))
+	assert.Equal(t, "No balances found\n", out.String())
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Compare the configured wallets and tokens with those in the database",
+	Long: `Report the wallets and tokens of the configuration that were never stored in
+token_balances (misconfigured, or added since the last poll) and those stored
+in token_balances that are no longer configured (orphaned history).
+
+Addresses are compared case-insensitively. Tokens found through
+token_discovery_pool are not part of the configuration and show up as
+orphaned.`,
+	Example: `  rmm-tracker reconcile`,
+	Args:    cobra.NoArgs,
+	RunE:    runReconcile,
+}
+
+func init() {
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+func runReconcile(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	cfg, err := config.LoadLayered(cfgFile, cfgOverlay)
+	if err != nil {
+		slog.Error("Configuration error", "error", err)
+		return err
+	}
+
+	dsn, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	opts, err := getDatabaseOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewStore(ctx, dsn, opts)
+	if err != nil {
+		slog.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("database connection failed")
+	}
+	defer store.Close()
+
+	wallets, err := store.GetWallets(ctx)
+	if err != nil {
+		return fmt.Errorf("list stored wallets: %w", err)
+	}
+	tokens, err := store.GetTokens(ctx)
+	if err != nil {
+		return fmt.Errorf("list stored tokens: %w", err)
+	}
+
+	writeReconcile(cmd.OutOrStdout(), reconcile(cfg, wallets, tokens))
+	return nil
+}
+
+// reconcileReport lists the discrepancies between the configuration and the
+// stored balances.
+type reconcileReport struct {
+	UnseenWallets   []string              // configured, never stored
+	OrphanedWallets []string              // stored, no longer configured
+	UnseenTokens    []config.TokenConfig  // configured, never stored
+	OrphanedTokens  []storage.StoredToken // stored, no longer configured
+}
+
+// empty reports whether the configuration and the database agree.
+func (r reconcileReport) empty() bool {
+	return len(r.UnseenWallets)+len(r.OrphanedWallets)+len(r.UnseenTokens)+len(r.OrphanedTokens) == 0
+}
+
+// reconcile compares the configured wallets and tokens with the stored ones,
+// keeping the order of each list.
+func reconcile(cfg *config.Config, storedWallets []string, storedTokens []storage.StoredToken) reconcileReport {
+	var r reconcileReport
+
+	stored := make(map[string]bool, len(storedWallets))
+	for _, w := range storedWallets {
+		stored[strings.ToLower(w)] = true
+	}
+	configured := make(map[string]bool, len(cfg.Wallets))
+	for _, w := range cfg.Wallets {
+		configured[strings.ToLower(w)] = true
+		if !stored[strings.ToLower(w)] {
+			r.UnseenWallets = append(r.UnseenWallets, w)
+		}
+	}
+	for _, w := range storedWallets {
+		if !configured[strings.ToLower(w)] {
+			r.OrphanedWallets = append(r.OrphanedWallets, w)
+		}
+	}
+
+	stored = make(map[string]bool, len(storedTokens))
+	for _, t := range storedTokens {
+		stored[strings.ToLower(t.Address)] = true
+	}
+	configured = make(map[string]bool, len(cfg.Tokens))
+	for _, t := range cfg.Tokens {
+		configured[strings.ToLower(t.Address)] = true
+		if !stored[strings.ToLower(t.Address)] {
+			r.UnseenTokens = append(r.UnseenTokens, t)
+		}
+	}
+	for _, t := range storedTokens {
+		if !configured[strings.ToLower(t.Address)] {
+			r.OrphanedTokens = append(r.OrphanedTokens, t)
+		}
+	}
+
+	return r
+}
+
+// writeReconcile prints each non-empty category of the report, or a note
+// that the configuration and the database agree.
+func writeReconcile(w io.Writer, r reconcileReport) {
+	if r.empty() {
+		_, _ = fmt.Fprintln(w, "Configuration and database agree")
+		return
+	}
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s (%d):\n", title, len(lines))
+		for _, line := range lines {
+			_, _ = fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+
+	section("Wallets configured but never stored", r.UnseenWallets)
+	section("Wallets stored but no longer configured", r.OrphanedWallets)
+	lines := make([]string, len(r.UnseenTokens))
+	for i, t := range r.UnseenTokens {
+		lines[i] = t.Address + " " + t.Label
+	}
+	section("Tokens configured but never stored", lines)
+	lines = make([]string, len(r.OrphanedTokens))
+	for i, t := range r.OrphanedTokens {
+		lines[i] = t.Address + " " + t.Symbol
+	}
+	section("Tokens stored but no longer configured", lines)
+}
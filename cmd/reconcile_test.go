@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	cfg := &config.Config{
+		Wallets: []string{
+			"0x1111111111111111111111111111111111111111",
+			"0xAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaa",
+		},
+		Tokens: []config.TokenConfig{
+			{Label: "armmXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b"},
+			{Label: "armmNEW", Address: "0x0000000000000000000000000000000000000009"},
+		},
+	}
+	storedWallets := []string{
+		"0x1111111111111111111111111111111111111111",
+		"0x2222222222222222222222222222222222222222",
+	}
+	storedTokens := []storage.StoredToken{
+		{Address: "0x0CA4F5554DD9DA6217D62D8DF2816C82BBA4157B", Symbol: "armmXDAI"},
+		{Address: "0x0000000000000000000000000000000000000008", Symbol: "armmOLD"},
+	}
+
+	r := reconcile(cfg, storedWallets, storedTokens)
+
+	assert.Equal(t, []string{"0xAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaa"}, r.UnseenWallets)
+	assert.Equal(t, []string{"0x2222222222222222222222222222222222222222"}, r.OrphanedWallets)
+	assert.Equal(t, []config.TokenConfig{cfg.Tokens[1]}, r.UnseenTokens)
+	assert.Equal(t, []storage.StoredToken{storedTokens[1]}, r.OrphanedTokens)
+
+	var out bytes.Buffer
+	writeReconcile(&out, r)
+	assert.Equal(t, `Wallets configured but never stored (1):
+  0xAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaa
+Wallets stored but no longer configured (1):
+  0x2222222222222222222222222222222222222222
+Tokens configured but never stored (1):
+  0x0000000000000000000000000000000000000009 armmNEW
+Tokens stored but no longer configured (1):
+  0x0000000000000000000000000000000000000008 armmOLD
+`, out.String())
+}
+
+func TestReconcile_Agree(t *testing.T) {
+	cfg := &config.Config{
+		Wallets: []string{"0x1111111111111111111111111111111111111111"},
+		Tokens:  []config.TokenConfig{{Label: "armmXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b"}},
+	}
+	r := reconcile(cfg,
+		[]string{"0x1111111111111111111111111111111111111111"},
+		[]storage.StoredToken{{Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b", Symbol: "armmXDAI"}})
+
+	assert.True(t, r.empty())
+	var out bytes.Buffer
+	writeReconcile(&out, r)
+	assert.Equal(t, "Configuration and database agree\n", out.String())
+}
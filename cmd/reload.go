@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/tracker"
+)
+
+// reloader applies the configuration reloaded on SIGHUP to the running
+// daemon. Wallets, tokens and the other polling settings take effect at the
+// next cycle, a changed list of RPC endpoints gets a new blockchain client and
+// a changed interval reschedules the job. The database connections, HTTP
+// server and scheduler alignment are kept; settings read only at startup
+// (databases, HTTP, metrics) still need a restart.
+type reloader struct {
+	load    func() (*config.Config, error)
+	connect func(*config.Config) (*blockchain.Client, error)
+	flags   runFlags
+	poller  *tracker.Tracker
+	sched   interface{ Reschedule(interval string) error }
+	health  interface {
+		SetClient(client *blockchain.Client)
+	}
+
+	mu       sync.Mutex
+	cfg      *config.Config
+	client   *blockchain.Client
+	interval string
+	retired  []*blockchain.Client // replaced clients, closed after the next cycle
+}
+
+// watch reloads the configuration on every SIGHUP until ctx is done.
+func (r *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP received, reloading configuration")
+			r.reload(ctx)
+		}
+	}
+}
+
+// reload loads and applies the configuration. Nothing changes when it is
+// invalid, the new RPC endpoints are unreachable or the new interval cannot
+// be scheduled: the daemon keeps running with the current configuration.
+func (r *reloader) reload(ctx context.Context) {
+	next, err := r.load()
+	if err != nil {
+		slog.Error("Configuration reload failed, keeping the current configuration", "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client := r.client
+	reconnect := !slices.Equal(r.cfg.Endpoints(), next.Endpoints())
+	if reconnect {
+		if client, err = r.connect(next); err != nil {
+			slog.Error("Configuration reload failed, keeping the current configuration", "error", err)
+			return
+		}
+	}
+	// A failed step leaves the new client unused
+	abort := func(err error) {
+		slog.Error("Configuration reload failed, keeping the current configuration", "error", err)
+		if reconnect {
+			client.Close()
+		}
+	}
+
+	if err := discoverTokens(ctx, next, client); err != nil {
+		abort(err)
+		return
+	}
+	interval, err := resolveRunInterval(r.flags, next.Interval)
+	if err != nil {
+		abort(err)
+		return
+	}
+	if interval == "" {
+		// Removing the interval does not stop a running daemon
+		interval = r.interval
+	}
+	if interval != r.interval {
+		if err := r.sched.Reschedule(interval); err != nil {
+			abort(err)
+			return
+		}
+	}
+
+	var fetcher tracker.BalanceFetcher
+	if reconnect {
+		fetcher = client
+		r.health.SetClient(client)
+		r.retired = append(r.retired, r.client)
+	}
+	r.poller.Reload(next, fetcher)
+	r.poller.SetStatusInfo(statusInfo(next))
+	r.cfg, r.client, r.interval = next, client, interval
+
+	slog.Info("Configuration reloaded, applied from the next cycle",
+		"wallets", len(next.Wallets),
+		"tokens", len(next.Tokens),
+		"interval", interval,
+		"rpc_reconnected", reconnect)
+}
+
+// closeRetired closes the clients replaced before the cycle that just ran,
+// which no longer uses them.
+func (r *reloader) closeRetired() {
+	r.mu.Lock()
+	retired := r.retired
+	r.retired = nil
+	r.mu.Unlock()
+	for _, c := range retired {
+		c.Close()
+	}
+}
+
+// healthyEndpoints counts the healthy RPC endpoints of the current client.
+func (r *reloader) healthyEndpoints() int {
+	r.mu.Lock()
+	client := r.client
+	r.mu.Unlock()
+	healthy := 0
+	for _, ok := range client.GetEndpointsHealth() {
+		if ok {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// close closes the current and replaced clients on shutdown.
+func (r *reloader) close() {
+	r.closeRetired()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client.Close()
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/tracker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newChainClient returns a client on a JSON-RPC server answering eth_chainId.
+func newChainClient(t *testing.T) *blockchain.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0x64"})
+	}))
+	t.Cleanup(srv.Close)
+	client, err := blockchain.NewClient(blockchain.EndpointsFromURLs([]string{srv.URL}), 0, blockchain.DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return client
+}
+
+type fakeRescheduler struct {
+	intervals []string
+	err       error
+}
+
+func (f *fakeRescheduler) Reschedule(interval string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.intervals = append(f.intervals, interval)
+	return nil
+}
+
+type fakeClientSetter struct{ client *blockchain.Client }
+
+func (f *fakeClientSetter) SetClient(client *blockchain.Client) { f.client = client }
+
+func TestReloader_Reload(t *testing.T) {
+	reloadConfig := func(rpc, interval string) *config.Config {
+		return &config.Config{
+			RPCUrls:  []string{rpc},
+			Wallets:  []string{"0x1234567890123456789012345678901234567890"},
+			Tokens:   []config.TokenConfig{{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6}},
+			Interval: interval,
+		}
+	}
+	newReloader := func(t *testing.T) (*reloader, *fakeRescheduler, *fakeClientSetter) {
+		cfg := reloadConfig("https://rpc.example.com", "5m")
+		sched, health := &fakeRescheduler{}, &fakeClientSetter{}
+		r := &reloader{
+			connect:  func(*config.Config) (*blockchain.Client, error) { return newChainClient(t), nil },
+			poller:   tracker.New(cfg, nil, nil),
+			sched:    sched,
+			health:   health,
+			cfg:      cfg,
+			client:   newChainClient(t),
+			interval: "5m",
+		}
+		return r, sched, health
+	}
+
+	t.Run("invalid config is ignored", func(t *testing.T) {
+		r, sched, health := newReloader(t)
+		before := r.cfg
+		r.load = func() (*config.Config, error) { return nil, errors.New("config validation failed") }
+		r.reload(context.Background())
+		assert.Same(t, before, r.cfg)
+		assert.Empty(t, sched.intervals)
+		assert.Nil(t, health.client)
+	})
+
+	t.Run("interval change reschedules", func(t *testing.T) {
+		r, sched, health := newReloader(t)
+		client := r.client
+		r.load = func() (*config.Config, error) { return reloadConfig("https://rpc.example.com", "10m"), nil }
+		r.reload(context.Background())
+		assert.Equal(t, []string{"10m"}, sched.intervals)
+		assert.Same(t, client, r.client, "same endpoints keep the client")
+		assert.Nil(t, health.client)
+		assert.Equal(t, "10m", r.interval)
+	})
+
+	t.Run("interval flag wins over the config", func(t *testing.T) {
+		r, sched, _ := newReloader(t)
+		r.flags = runFlags{interval: "5m"}
+		r.load = func() (*config.Config, error) { return reloadConfig("https://rpc.example.com", "10m"), nil }
+		r.reload(context.Background())
+		assert.Empty(t, sched.intervals)
+	})
+
+	t.Run("endpoint change reconnects", func(t *testing.T) {
+		r, _, health := newReloader(t)
+		old := r.client
+		r.load = func() (*config.Config, error) { return reloadConfig("https://other.example.com", "5m"), nil }
+		r.reload(context.Background())
+		assert.NotSame(t, old, r.client)
+		assert.Same(t, r.client, health.client)
+		assert.Equal(t, []*blockchain.Client{old}, r.retired)
+		r.closeRetired()
+		assert.Empty(t, r.retired)
+	})
+
+	t.Run("failed reschedule keeps everything", func(t *testing.T) {
+		r, sched, health := newReloader(t)
+		sched.err = errors.New("invalid interval")
+		before, client := r.cfg, r.client
+		r.load = func() (*config.Config, error) { return reloadConfig("https://other.example.com", "7m"), nil }
+		r.reload(context.Background())
+		assert.Same(t, before, r.cfg)
+		assert.Same(t, client, r.client)
+		assert.Nil(t, health.client)
+		assert.Empty(t, r.retired)
+		assert.Equal(t, "5m", r.interval)
+	})
+}
@@ -5,9 +5,10 @@ import (
 )
 
 var (
-	cfgFile   string
-	logLevel  string
-	logFormat string
+	cfgFile    string
+	cfgOverlay string
+	logLevel   string
+	logFormat  string
 )
 
 // rootCmd represents the base command
@@ -26,7 +27,8 @@ func Execute() error {
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ./config.toml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file, TOML, YAML or JSON (default: config.toml, config.yaml or config.json in ., $XDG_CONFIG_HOME/rmm-tracker, ~/.config/rmm-tracker or /etc/rmm-tracker)")
+	rootCmd.PersistentFlags().StringVar(&cfgOverlay, "config-overlay", "", "config file merged over --config (default: derived from RMM_TRACKER_ENV)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log output format (text, json)")
 }
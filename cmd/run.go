@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"sort"
-	"sync"
 	"syscall"
 	"time"
 
@@ -19,8 +19,12 @@ import (
 	"github.com/matrixise/rmm-tracker/internal/config"
 	"github.com/matrixise/rmm-tracker/internal/health"
 	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/metrics"
 	"github.com/matrixise/rmm-tracker/internal/scheduler"
 	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/matrixise/rmm-tracker/internal/stream"
+	"github.com/matrixise/rmm-tracker/internal/tracker"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 )
 
@@ -30,6 +34,9 @@ var (
 	httpAddr     string
 	enableDaemon bool
 	enableWeb    bool
+	runOnce      bool
+	walletsFlag  string
+	tokensFlag   string
 )
 
 var runCmd = &cobra.Command{
@@ -48,6 +55,9 @@ func init() {
 	runCmd.Flags().Lookup("http").NoOptDefVal = ":8080"
 	runCmd.Flags().BoolVar(&enableDaemon, "daemon", false, "start scheduler (requires --interval or --cron)")
 	runCmd.Flags().BoolVar(&enableWeb, "web", false, "serve web UI (implies --http :8080 if not set)")
+	runCmd.Flags().BoolVar(&runOnce, "once", false, "poll exactly once and exit, ignoring the configured interval")
+	runCmd.Flags().StringVar(&walletsFlag, "wallets", "", "replace configured wallets (0x...,0x...)")
+	runCmd.Flags().StringVar(&tokensFlag, "tokens", "", "replace configured tokens and skip discovery (label:address:decimals,...)")
 }
 
 func runTracker(cmd *cobra.Command, args []string) error {
@@ -76,14 +86,23 @@ func runTracker(cmd *cobra.Command, args []string) error {
 	}()
 
 	// Load config
-	cfg, databaseURL, err := config.LoadWithDefaults(cfgFile)
+	cfg, databaseURL, err := config.LoadWithDefaults(cfgFile, cfgOverlay)
 	if err != nil {
 		slog.Error("Configuration error", "error", err)
 		return err
 	}
 
-	// Override log level/format if set in config
-	if cfg.LogLevel != "" || cfg.LogFormat != "" {
+	// Command-line wallet/token lists replace the configured ones
+	overrideTokens, err := config.ParseTokensFlag(tokensFlag)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ApplyOverrides(config.ParseWalletsFlag(walletsFlag), overrideTokens); err != nil {
+		return err
+	}
+
+	// Override log level/format/output if set in config
+	if cfg.LogLevel != "" || cfg.LogFormat != "" || cfg.LogFile != "" {
 		level := cfg.LogLevel
 		if level == "" {
 			level = logLevel
@@ -92,30 +111,33 @@ func runTracker(cmd *cobra.Command, args []string) error {
 		if format == "" {
 			format = logFormat
 		}
-		logger.Setup(level, format)
+		logFile, err := logger.SetupOutput(level, format, logger.Output{
+			Path:       cfg.LogFile,
+			MaxSizeMB:  cfg.LogMaxSizeMB,
+			MaxBackups: cfg.LogMaxBackups,
+		})
+		if err != nil {
+			slog.Error("Configuration error", "error", err)
+			return err
+		}
+		defer func() { _ = logFile.Close() }()
 	}
 
-	// Resolve effective run interval: flag > config
-	runInterval := interval
-	if runInterval == "" && cronExpr != "" {
-		runInterval = cronExpr
-	}
-	// Read interval from config only when not in HTTP/web mode.
-	// In HTTP/web mode the scheduler must be explicitly requested via
-	// --interval, --cron, or --daemon to avoid surprising cron activations
-	// when config.toml has an interval set.
-	if runInterval == "" && cfg.Interval != "" && !enableWeb && httpAddr == "" {
-		runInterval = cfg.Interval
+	runInterval, err := resolveRunInterval(runFlags{
+		interval: interval,
+		cron:     cronExpr,
+		httpAddr: httpAddr,
+		daemon:   enableDaemon,
+		once:     runOnce,
+	}, cfg.Interval)
+	if err != nil {
+		return err
 	}
-
 	// An interval alone is sufficient to activate daemon mode
-	if runInterval != "" {
-		enableDaemon = true
-	}
+	enableDaemon = runInterval != ""
 
-	// --daemon requires an interval
-	if enableDaemon && runInterval == "" {
-		return fmt.Errorf("daemon mode requires --interval or --cron")
+	if enableDaemon {
+		scheduler.WarnIfTooFast(slog.Default(), runInterval, cfg.IKnowThisIsFast)
 	}
 
 	slog.Info("Configuration loaded",
@@ -126,14 +148,24 @@ func runTracker(cmd *cobra.Command, args []string) error {
 	)
 
 	// Run database migrations
-	if err := storage.RunMigrations(ctx, databaseURL); err != nil {
+	migrationOpts := storage.MigrationOptions{
+		MaxAttempts: cfg.MigrationMaxAttempts,
+		RetryDelay:  cfg.MigrationRetryDelay,
+	}
+	if err := storage.RunMigrations(ctx, databaseURL, migrationOpts); err != nil {
 		slog.Error("Failed to run migrations", "error", err)
 		return fmt.Errorf("database connection failed")
 	}
 	slog.Info("Database migrations applied")
 
 	// Connect to PostgreSQL
-	store, err := storage.NewStore(ctx, databaseURL)
+	store, err := storage.NewStore(ctx, databaseURL, storage.Options{
+		ConnectTimeout:   cfg.DBConnectTimeout,
+		StatementTimeout: cfg.DBStatementTimeout,
+		SeriesKey:        cfg.SeriesKey,
+		InsertBatchSize:  cfg.DBInsertBatchSize,
+		DedupUnchanged:   cfg.DedupUnchanged,
+	})
 	if err != nil {
 		slog.Error("Failed to connect to PostgreSQL", "error", err)
 		return fmt.Errorf("database connection failed")
@@ -145,28 +177,88 @@ func runTracker(cmd *cobra.Command, args []string) error {
 	var writer storage.Commander = store
 	var reader storage.Querier = store
 
+	// Keep unpersisted balances across daemon cycles while a database is down.
+	// Each target gets its own buffer so a write replayed on one target is
+	// never duplicated on the others.
+	var buffers []*storage.BufferedStore
+	buffered := func(c storage.Commander) storage.Commander {
+		if !enableDaemon || cfg.DBBufferSize <= 0 {
+			return c
+		}
+		b := storage.NewBufferedStore(c, cfg.DBBufferSize)
+		buffers = append(buffers, b)
+		return b
+	}
+	writer = buffered(store)
+
+	// Fan writes out to additional database targets when configured
+	if len(cfg.Databases) > 0 {
+		var secondaries []storage.WriteTarget
+		for _, db := range cfg.Databases {
+			if err := storage.RunMigrations(ctx, db.URL, migrationOpts); err != nil {
+				slog.Error("Failed to run migrations", "database", db.Name, "error", err)
+				return fmt.Errorf("database connection failed")
+			}
+			extra, err := storage.NewStore(ctx, db.URL, storage.Options{
+				ConnectTimeout:   cfg.DBConnectTimeout,
+				StatementTimeout: cfg.DBStatementTimeout,
+				SeriesKey:        cfg.SeriesKey,
+				InsertBatchSize:  cfg.DBInsertBatchSize,
+				DedupUnchanged:   cfg.DedupUnchanged,
+			})
+			if err != nil {
+				slog.Error("Failed to connect to PostgreSQL", "database", db.Name, "error", err)
+				return fmt.Errorf("database connection failed")
+			}
+			defer extra.Close()
+			secondaries = append(secondaries, storage.WriteTarget{Name: db.Name, Commander: buffered(extra)})
+		}
+		primary := storage.WriteTarget{Name: "primary", Commander: writer}
+		writer = storage.NewMultiStore(cfg.WriteAllOrNothing(), primary, secondaries...)
+		slog.Info("Writing to multiple databases",
+			"targets", len(secondaries)+1,
+			"mode", cfg.DatabaseWriteMode)
+	}
+	if len(buffers) > 0 {
+		slog.Info("Write buffering enabled", "capacity", cfg.DBBufferSize, "targets", len(buffers))
+	}
+
 	// One-shot mode: neither --http nor --daemon
 	if httpAddr == "" && !enableDaemon {
-		client, err := blockchain.NewClient(cfg.RPCUrls)
+		client, err := connectRPC(cfg)
 		if err != nil {
-			slog.Error("Failed to connect to RPC", "error", err)
 			return err
 		}
 		defer client.Close()
-		logRPCConnection(cfg.RPCUrls)
-		return processAllWallets(ctx, cfg, client, writer)
+		if err := discoverTokens(ctx, cfg, client); err != nil {
+			return err
+		}
+		if err := tracker.New(cfg, client, writer).ProcessAllWallets(ctx); err != nil {
+			return err
+		}
+		refreshSnapshotSummary(ctx, store)
+		return nil
 	}
 
 	// Connect to blockchain only when daemon mode is active
 	var client *blockchain.Client
+	var reload *reloader
 	if enableDaemon {
-		client, err = blockchain.NewClient(cfg.RPCUrls)
+		client, err = connectRPC(cfg)
 		if err != nil {
-			slog.Error("Failed to connect to RPC", "error", err)
 			return err
 		}
-		defer client.Close()
-		logRPCConnection(cfg.RPCUrls)
+		// The client may be replaced on SIGHUP: the reloader closes it
+		reload = &reloader{cfg: cfg, client: client, interval: runInterval}
+		defer reload.close()
+		if err := discoverTokens(ctx, cfg, client); err != nil {
+			return err
+		}
+		if cfg.MaxClockSkew > 0 {
+			if _, err := tracker.CheckClockSkew(ctx, client, time.Now(), cfg.MaxClockSkew); err != nil {
+				slog.Warn("Clock skew check skipped, latest block time unavailable", "error", err)
+			}
+		}
 	}
 
 	buildInfo := health.BuildInfo{
@@ -178,6 +270,13 @@ func runTracker(cmd *cobra.Command, args []string) error {
 
 	var healthChecker *health.Checker
 
+	// Live balance stream, Prometheus metrics and cycle progress, fed by the
+	// poller in daemon mode
+	var poller *tracker.Tracker
+	var broker *stream.Broker
+	var registry *prometheus.Registry
+	var trackerMetrics *metrics.Metrics
+
 	if enableDaemon {
 		slog.Info("Starting daemon mode with scheduler",
 			"interval", runInterval,
@@ -188,17 +287,49 @@ func runTracker(cmd *cobra.Command, args []string) error {
 			Interval:       runInterval,
 			Timezone:       cfg.GetTimezone(),
 			RunImmediately: cfg.ShouldRunImmediately(),
+			FailOnFirstRun: cfg.FailOnFirstRun,
+			Jitter:         cfg.Jitter,
+			RunTimeout:     cfg.RunTimeout,
 			Logger:         slog.Default(),
 		}
 
+		poller = tracker.New(cfg, client, writer)
+		broker = stream.NewBroker(stream.DefaultBufferSize)
+		poller.OnPersist(broker.Publish)
+		if cfg.ShouldServeMetrics() {
+			registry = prometheus.NewRegistry()
+			trackerMetrics = metrics.New(registry, metrics.Options{Exemplars: cfg.MetricsExemplars})
+			trackerMetrics.SetHealthyEndpoints(reload.healthyEndpoints)
+			poller.OnPersist(trackerMetrics.ObserveBalances)
+			poller.SetRowRecorder(trackerMetrics)
+			client.SetRetryRecorder(trackerMetrics)
+			client.SetFailoverRecorder(trackerMetrics)
+		}
+		poller.SetStatusInfo(statusInfo(cfg))
+		go client.StartHealthChecker(ctx, cfg.RPCProbeInterval)
+
 		// jobFunc references healthChecker which is set after scheduler creation
 		jobFunc := func(jobCtx context.Context) error {
-			err := processAllWallets(jobCtx, cfg, client, writer)
-			succeeded := err == nil
+			// Catch up on balances buffered while a database was down
+			for _, b := range buffers {
+				if err := b.Flush(jobCtx); err != nil {
+					slog.Warn("Buffered balances not flushed", "pending", b.Pending(), "error", err)
+				}
+			}
+			err := poller.ProcessAllWallets(jobCtx)
+			reload.closeRetired()
+			// A run cut short by run_timeout failed even if its wallets returned
+			succeeded := jobCtx.Err() == nil && pollSucceeded(err, poller.Progress())
+			// The status is recorded past the run timeout
+			jobCtx = context.WithoutCancel(jobCtx)
+			refreshSnapshotSummary(jobCtx, store)
 			_ = writer.SetLastRunStatus(jobCtx, succeeded) // best-effort
 			if healthChecker != nil {
 				healthChecker.UpdateLastRun(succeeded)
 			}
+			if trackerMetrics != nil {
+				trackerMetrics.PollCompleted(succeeded, time.Now())
+			}
 			return err
 		}
 
@@ -217,6 +348,10 @@ func runTracker(cmd *cobra.Command, args []string) error {
 		}
 
 		healthChecker = health.NewChecker(store, client, sched, expectedInterval, buildInfo)
+		healthChecker.SetDaemonGrace(cfg.DaemonGrace)
+		healthChecker.SetRPCHealthTTL(cfg.RPCHealthTTL)
+		// Unchanged balances are not inserted, so the newest row may be old
+		healthChecker.SetDataCheck(!cfg.DedupUnchanged)
 
 		if err := sched.Start(); err != nil {
 			slog.Error("Failed to start scheduler", "error", err)
@@ -224,6 +359,37 @@ func runTracker(cmd *cobra.Command, args []string) error {
 		}
 
 		slog.Info("Daemon mode started with clock-aligned scheduling")
+
+		reload.load = func() (*config.Config, error) {
+			next, _, err := config.LoadWithDefaults(cfgFile, cfgOverlay)
+			if err != nil {
+				return nil, err
+			}
+			if err := next.ApplyOverrides(config.ParseWalletsFlag(walletsFlag), overrideTokens); err != nil {
+				return nil, err
+			}
+			return next, nil
+		}
+		reload.connect = func(next *config.Config) (*blockchain.Client, error) {
+			c, err := connectRPC(next)
+			if err != nil {
+				return nil, err
+			}
+			if trackerMetrics != nil {
+				c.SetRetryRecorder(trackerMetrics)
+				c.SetFailoverRecorder(trackerMetrics)
+			}
+			// Stops once the client is retired and closed
+			go c.StartHealthChecker(ctx, next.RPCProbeInterval)
+			return c, nil
+		}
+		reload.flags = runFlags{interval: interval, cron: cronExpr, httpAddr: httpAddr}
+		reload.poller, reload.sched, reload.health = poller, sched, healthChecker
+		go reload.watch(ctx)
+
+		if retention := cfg.RetentionPeriod(); retention > 0 {
+			go pruneBalances(ctx, store, retention)
+		}
 	}
 
 	if httpAddr != "" && !enableDaemon {
@@ -234,11 +400,25 @@ func runTracker(cmd *cobra.Command, args []string) error {
 	if httpAddr != "" {
 		apiHandler := api.NewHandler(reader, healthChecker)
 		router := api.NewRouter(healthChecker.Handler(), apiHandler, healthChecker, enableWeb, reader, Version, ChangelogMD)
+		// Kubernetes probes: /health stays the aggregate of both
+		router.Get("/livez", healthChecker.LivenessHandler())
+		router.Get("/readyz", healthChecker.ReadinessHandler())
+		if broker != nil {
+			router.Get("/stream", broker.ServeHTTP)
+		}
+		if poller != nil {
+			router.Get("/status", poller.StatusHandler)
+		}
+		if trackerMetrics != nil {
+			router.Handle("/metrics", trackerMetrics.Handler(registry))
+		}
 
 		httpServer := &http.Server{
 			Addr:              httpAddr,
 			Handler:           router,
 			ReadHeaderTimeout: 10 * time.Second,
+			// Cancel in-flight requests (notably /stream) on shutdown
+			BaseContext: func(net.Listener) context.Context { return ctx },
 		}
 
 		go func() {
@@ -288,91 +468,192 @@ func logEndpoints(r *chi.Mux, addr string) {
 	}
 }
 
-func logRPCConnection(rpcURLs []string) {
-	if len(rpcURLs) == 1 {
-		slog.Info("RPC connection established", "endpoint", rpcURLs[0])
-	} else {
-		slog.Info("RPC connection established with failover",
-			"endpoints", len(rpcURLs),
-			"primary", rpcURLs[0])
+// pollSucceeded reports whether a polling cycle that returned err and ended
+// with progress p succeeded: a cycle with a failed balance query or batch
+// insert did not, even when the rest of it was stored.
+func pollSucceeded(err error, p tracker.Progress) bool {
+	return err == nil && p.TokensFailed == 0 && p.InsertsFailed == 0
+}
+
+// statusInfo returns what /status reports about the instance running cfg,
+// nil unless status_build_info is set.
+func statusInfo(cfg *config.Config) *tracker.StatusInfo {
+	if !cfg.StatusBuildInfo {
+		return nil
+	}
+	return &tracker.StatusInfo{
+		Version:           Version,
+		Commit:            GitCommit,
+		ConfigFingerprint: cfg.Fingerprint(),
 	}
 }
 
-func processAllWallets(ctx context.Context, cfg *config.Config, client *blockchain.Client, store storage.Commander) error {
-	for _, walletAddr := range cfg.Wallets {
-		// Check for cancellation
-		select {
-		case <-ctx.Done():
-			slog.Info("Shutdown requested, stopping processing")
-			return ctx.Err()
-		default:
-		}
+// discoverTokens merges the reserve tokens of the configured discovery pool
+// into cfg.Tokens. A discovery failure is fatal only when it would leave the
+// tracker with nothing to poll; otherwise the configured tokens are used.
+func discoverTokens(ctx context.Context, cfg *config.Config, client *blockchain.Client) error {
+	if cfg.TokenDiscoveryPool == "" {
+		return nil
+	}
 
-		wallet := common.HexToAddress(walletAddr)
-		slog.Info("Processing wallet", "wallet", wallet.Hex())
+	discovered, err := client.DiscoverTokens(ctx, common.HexToAddress(cfg.TokenDiscoveryPool))
+	if err != nil {
+		if len(cfg.Tokens) == 0 {
+			return err
+		}
+		slog.Warn("Token discovery failed, polling configured tokens only",
+			"pool", cfg.TokenDiscoveryPool, "error", err)
+		return nil
+	}
 
-		// Process tokens in parallel
-		results := make(chan storage.TokenBalance, len(cfg.Tokens))
-		var wg sync.WaitGroup
+	before := len(cfg.Tokens)
+	cfg.Tokens = tracker.MergeDiscovered(cfg.Tokens, discovered)
+	if len(cfg.Tokens) == 0 {
+		return fmt.Errorf("no tokens configured or discovered from pool %s", cfg.TokenDiscoveryPool)
+	}
+	slog.Info("Tokens discovered from pool",
+		"pool", cfg.TokenDiscoveryPool,
+		"discovered", len(discovered),
+		"added", len(cfg.Tokens)-before)
+	return nil
+}
 
-		for _, tok := range cfg.Tokens {
-			if tok.Address == "" {
-				slog.Warn("Token without address ignored", "label", tok.Label)
-				continue
-			}
+// runFlags are the run command flags that decide between a single poll and
+// daemon mode.
+type runFlags struct {
+	interval string
+	cron     string
+	httpAddr string // also set by --web
+	daemon   bool
+	once     bool
+}
 
-			wg.Add(1)
-			go func(token config.TokenConfig) {
-				defer wg.Done()
+// resolveRunInterval returns the schedule for daemon mode, or "" for a single
+// poll. --once always means one poll and exit, whatever the config interval;
+// otherwise --interval/--cron win over the config interval, which is only
+// used when no HTTP server is requested.
+func resolveRunInterval(f runFlags, cfgInterval string) (string, error) {
+	if f.once {
+		if f.interval != "" || f.cron != "" || f.daemon || f.httpAddr != "" {
+			return "", fmt.Errorf("--once cannot be combined with --interval, --cron, --daemon, --http or --web")
+		}
+		return "", nil
+	}
 
-				tokenInfo := blockchain.TokenInfo{
-					Label:            token.Label,
-					Address:          token.Address,
-					FallbackDecimals: token.FallbackDecimals,
-				}
+	runInterval := f.interval
+	if runInterval == "" && f.cron != "" {
+		runInterval = f.cron
+	}
+	// Read interval from config only when not in HTTP/web mode.
+	// In HTTP/web mode the scheduler must be explicitly requested via
+	// --interval, --cron, or --daemon to avoid surprising cron activations
+	// when config.toml has an interval set.
+	if runInterval == "" && f.httpAddr == "" {
+		runInterval = cfgInterval
+	}
 
-				result, err := client.GetTokenBalance(ctx, wallet, tokenInfo)
-				if err != nil {
-					slog.Error("Token query error", "token_address", token.Address, "error", err)
-					return
-				}
+	// --daemon requires an interval
+	if f.daemon && runInterval == "" {
+		return "", fmt.Errorf("daemon mode requires --interval or --cron")
+	}
+	return runInterval, nil
+}
 
-				slog.Info("Balance retrieved",
-					"wallet", result.Wallet,
-					"symbol", result.Symbol,
-					"balance", result.Balance.String(),
-					"decimals", result.Decimals,
-				)
+// refreshSnapshotSummary folds the cycle's balances into the per-snapshot
+// totals read by dashboards. A failure is logged: the next cycle catches up.
+func refreshSnapshotSummary(ctx context.Context, store *storage.Store) {
+	n, err := store.RefreshSnapshotSummary(ctx)
+	if err != nil {
+		slog.Warn("Snapshot summary not refreshed", "error", err)
+		return
+	}
+	slog.Debug("Snapshot summary refreshed", "snapshots", n)
+}
 
-				results <- result
-			}(tok)
+// pruneInterval is how often pruneBalances deletes expired balances.
+const pruneInterval = time.Hour
+
+// pruneBalances deletes the balances older than retention now and then every
+// pruneInterval until ctx is done. A failure is logged: the next run catches
+// up.
+func pruneBalances(ctx context.Context, store *storage.Store, retention time.Duration) {
+	slog.Info("Balance retention enabled", "retention", retention)
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().Add(-retention)
+		n, err := store.PruneOlderThan(ctx, cutoff)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("Expired balances not pruned", "deleted", n, "error", err)
+			}
+		} else if n > 0 {
+			slog.Info("Expired balances pruned", "deleted", n, "cutoff", cutoff.UTC().Format(time.RFC3339))
 		}
 
-		// Wait and collect results
-		go func() {
-			wg.Wait()
-			close(results)
-		}()
-
-		var successResults []storage.TokenBalance
-		for result := range results {
-			successResults = append(successResults, result)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
+	}
+}
 
-		// Batch insert
-		if len(successResults) > 0 {
-			if err := store.BatchInsertBalances(ctx, successResults); err != nil {
-				slog.Error("Batch insert error", "error", err)
-				continue
-			}
+// connectRPC creates the blockchain client configured by cfg.
+func connectRPC(cfg *config.Config) (*blockchain.Client, error) {
+	var endpoints []blockchain.Endpoint
+	for _, ep := range cfg.Endpoints() {
+		endpoints = append(endpoints, blockchain.Endpoint{URL: ep.URL, Priority: ep.Priority})
+	}
+	rpc := blockchain.DefaultClientConfig()
+	if cfg.RPCTimeout > 0 {
+		rpc.Timeout = cfg.RPCTimeout
+	}
+	if cfg.RPCMaxRetries != nil {
+		rpc.MaxRetries = *cfg.RPCMaxRetries
+	}
+	client, err := blockchain.NewClient(endpoints, cfg.ChainID, rpc)
+	if err != nil {
+		slog.Error("Failed to connect to RPC", "error", err)
+		return nil, err
+	}
+	if cfg.RPCSelection != "" {
+		client.SetEndpointSelection(blockchain.Selection(cfg.RPCSelection))
+	}
+	client.SetCircuitBreaker(cfg.RPCFailureThreshold, cfg.RPCFailureWindow)
+	client.SetRateLimit(cfg.RPCRateLimit)
+	client.SetRaceEndpoints(cfg.RPCRace)
+	if cfg.DecimalsPolicy != "" {
+		client.SetDecimalsPolicy(blockchain.DecimalsPolicy(cfg.DecimalsPolicy))
+	}
+	maxDecimals, maxDecimalsPolicy := blockchain.DefaultMaxDecimals, blockchain.MaxDecimalsWarn
+	if cfg.MaxDecimals != 0 {
+		maxDecimals = uint8(cfg.MaxDecimals)
+	}
+	if cfg.MaxDecimalsPolicy != "" {
+		maxDecimalsPolicy = blockchain.MaxDecimalsPolicy(cfg.MaxDecimalsPolicy)
+	}
+	client.SetMaxDecimals(maxDecimals, maxDecimalsPolicy)
+	client.SetRecordFetchLatency(cfg.RecordFetchLatency)
+	if cfg.MulticallAddress != "" {
+		client.SetMulticall(common.HexToAddress(cfg.MulticallAddress))
+	}
+	logRPCConnection(endpoints)
+	return client, nil
+}
 
-			slog.Info("Records inserted successfully",
-				"wallet", wallet.Hex(),
-				"count", len(successResults),
-			)
+func logRPCConnection(endpoints []blockchain.Endpoint) {
+	if len(endpoints) == 1 {
+		slog.Info("RPC connection established", "endpoint", endpoints[0].URL)
+		return
+	}
+	primary := endpoints[0]
+	for _, ep := range endpoints[1:] {
+		if ep.Priority < primary.Priority {
+			primary = ep
 		}
 	}
-
-	slog.Info("Processing completed successfully")
-	return nil
+	slog.Info("RPC connection established with failover",
+		"endpoints", len(endpoints),
+		"primary", primary.URL)
 }
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/tracker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRunInterval(t *testing.T) {
+	tests := []struct {
+		name        string
+		flags       runFlags
+		cfgInterval string
+		want        string // "" means a single poll
+		wantErr     bool
+	}{
+		{name: "no interval", want: ""},
+		{name: "no interval, once", flags: runFlags{once: true}, want: ""},
+		{name: "config interval", cfgInterval: "5m", want: "5m"},
+		{name: "config interval, once", flags: runFlags{once: true}, cfgInterval: "5m", want: ""},
+		{name: "flag interval", flags: runFlags{interval: "1h"}, cfgInterval: "5m", want: "1h"},
+		{name: "flag interval, once", flags: runFlags{interval: "1h", once: true}, wantErr: true},
+		{name: "cron flag", flags: runFlags{cron: "*/5 * * * *"}, want: "*/5 * * * *"},
+		{name: "cron flag, once", flags: runFlags{cron: "*/5 * * * *", once: true}, wantErr: true},
+		{name: "http ignores config interval", flags: runFlags{httpAddr: ":8080"}, cfgInterval: "5m", want: ""},
+		{name: "http, once", flags: runFlags{httpAddr: ":8080", once: true}, wantErr: true},
+		{name: "daemon without interval", flags: runFlags{daemon: true, httpAddr: ":8080"}, wantErr: true},
+		{name: "daemon, once", flags: runFlags{daemon: true, once: true}, cfgInterval: "5m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRunInterval(tt.flags, tt.cfgInterval)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPollSucceeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		progress tracker.Progress
+		want     bool
+	}{
+		{name: "clean cycle", progress: tracker.Progress{TokensDone: 4}, want: true},
+		{name: "nothing due", want: true},
+		{name: "cycle error", err: errors.New("all 4 balance queries failed"), progress: tracker.Progress{TokensDone: 4, TokensFailed: 4}},
+		{name: "one query failed", progress: tracker.Progress{TokensDone: 4, TokensFailed: 1}},
+		{name: "one insert failed", progress: tracker.Progress{TokensDone: 4, InsertsFailed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pollSucceeded(tt.err, tt.progress))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Inspect the database schema",
+}
+
+var schemaDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the live token_balances table with the embedded migrations",
+	Long: `Introspect the live token_balances table (columns, types, indexes) and compare
+it with the schema the embedded migrations produce. Exits non-zero when drift is found.`,
+	RunE: runSchemaDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.AddCommand(schemaDiffCmd)
+}
+
+func runSchemaDiff(cmd *cobra.Command, args []string) error {
+	logger.Setup(logLevel, logFormat)
+
+	dsn, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+
+	opts, err := getDatabaseOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewStore(ctx, dsn, opts)
+	if err != nil {
+		slog.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("database connection failed")
+	}
+	defer store.Close()
+
+	expected, err := storage.ExpectedTokenBalancesSchema()
+	if err != nil {
+		return fmt.Errorf("failed to derive schema from migrations: %w", err)
+	}
+	actual, err := store.IntrospectTable(ctx, expected.Table)
+	if err != nil {
+		slog.Error("Schema introspection failed", "error", err)
+		return err
+	}
+
+	diffs := storage.DiffSchema(expected, actual)
+	out := cmd.OutOrStdout()
+	if len(diffs) == 0 {
+		_, _ = fmt.Fprintf(out, "%s matches the embedded migrations\n", expected.Table)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(out, "%s differs from the embedded migrations:\n", expected.Table)
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(out, "  - %s\n", d)
+	}
+	return fmt.Errorf("schema drift detected (%d differences)", len(diffs))
+}
+
+// getDatabaseOptions reads db_connect_timeout and db_statement_timeout from the
+// config file and RMM_TRACKER_* env vars without requiring a full, valid config.
+func getDatabaseOptions() (storage.Options, error) {
+	v := viper.New()
+	basePath := config.SetConfigFile(v, cfgFile)
+	v.SetEnvPrefix("RMM_TRACKER")
+	for _, key := range []string{"db_connect_timeout", "db_statement_timeout"} {
+		if err := v.BindEnv(key); err != nil {
+			return storage.Options{}, fmt.Errorf("failed to bind env: %w", err)
+		}
+	}
+	if basePath != "" {
+		if err := v.ReadInConfig(); err != nil {
+			return storage.Options{}, fmt.Errorf("failed to read config: %w", err)
+		}
+	}
+	if overlay := config.OverlayPath(basePath, cfgOverlay); overlay != "" {
+		v.SetConfigFile(overlay)
+		if err := v.MergeInConfig(); err != nil {
+			return storage.Options{}, fmt.Errorf("failed to read config overlay %s: %w", overlay, err)
+		}
+	}
+
+	opts := storage.Options{
+		ConnectTimeout:   v.GetDuration("db_connect_timeout"),
+		StatementTimeout: v.GetDuration("db_statement_timeout"),
+	}
+	if opts.ConnectTimeout < 0 || opts.StatementTimeout < 0 {
+		return storage.Options{}, fmt.Errorf("db_connect_timeout and db_statement_timeout must be positive")
+	}
+	return opts, nil
+}
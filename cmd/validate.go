@@ -1,22 +1,34 @@
 package cmd
 
 import (
+	"context"
 	"log/slog"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/matrixise/rmm-tracker/internal/config"
 	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/scheduler"
 	"github.com/spf13/cobra"
 )
 
 var validateCmd = &cobra.Command{
 	Use:   "validate-config",
 	Short: "Validate configuration file",
-	Long:  `Validate the configuration file syntax and values without running the application.`,
-	RunE:  validateConfig,
+	Long: `Validate the configuration file syntax and values without running the application.
+The next scheduled run times are printed, to check irregular cron expressions.
+
+With --check-connectivity, also connect to the RPC endpoints and report whether
+each wallet is an externally owned account or a contract (e.g. a Safe).`,
+	RunE: validateConfig,
 }
 
+var checkConnectivity bool
+
 func init() {
 	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().BoolVar(&checkConnectivity, "check-connectivity", false, "connect to the RPC endpoints and classify each wallet")
 }
 
 func validateConfig(cmd *cobra.Command, args []string) error {
@@ -24,7 +36,7 @@ func validateConfig(cmd *cobra.Command, args []string) error {
 	logger.Setup(logLevel, logFormat)
 
 	// Load config
-	cfg, databaseURL, err := config.LoadWithDefaults(cfgFile)
+	cfg, databaseURL, err := config.LoadWithDefaults(cfgFile, cfgOverlay)
 	if err != nil {
 		slog.Error("Configuration validation failed", "error", err)
 		return err
@@ -39,5 +51,52 @@ func validateConfig(cmd *cobra.Command, args []string) error {
 		"database_url_set", databaseURL != "",
 	)
 
+	if cfg.Interval != "" {
+		logUpcomingRuns(cfg)
+	}
+
+	if checkConnectivity {
+		return checkWallets(cmd.Context(), cfg)
+	}
+	return nil
+}
+
+// upcomingRunCount is the number of next run times printed by validate-config.
+const upcomingRunCount = 5
+
+// logUpcomingRuns logs the next run times of the configured schedule, useful
+// to check irregular cron expressions such as "0 9,17 * * 1-5".
+func logUpcomingRuns(cfg *config.Config) {
+	runs, err := scheduler.NextRunTimes(cfg.Interval, cfg.GetTimezone(), time.Now(), upcomingRunCount)
+	if err != nil {
+		slog.Warn("Could not compute the next runs", "interval", cfg.Interval, "error", err)
+		return
+	}
+	times := make([]string, len(runs))
+	for i, run := range runs {
+		times[i] = run.Format(time.RFC3339)
+	}
+	slog.Info("Next scheduled runs", "schedule", scheduler.DescribeSchedule(cfg.Interval, cfg.GetTimezone()), "runs", times)
+}
+
+// checkWallets logs whether each configured wallet has code, so a contract
+// address pasted as a wallet is noticed. It is informational only: balances
+// are read the same way for EOAs and contracts.
+func checkWallets(ctx context.Context, cfg *config.Config) error {
+	client, err := connectRPC(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, w := range cfg.Wallets {
+		wallet := common.HexToAddress(w)
+		kind, err := client.ClassifyWallet(ctx, wallet)
+		if err != nil {
+			slog.Error("Wallet check failed", "wallet", wallet.Hex(), "error", err)
+			return err
+		}
+		slog.Info("✓ Wallet checked", "wallet", wallet.Hex(), "kind", kind)
+	}
 	return nil
 }
@@ -73,11 +73,17 @@ func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetBalances handles GET /api/v1/balances
-// Query params: wallet, symbol, limit (default 100)
+// Query params: wallet, symbol, limit (default 100), only (raw or human)
 func (h *Handler) GetBalances(w http.ResponseWriter, r *http.Request) {
 	wallet := strings.ToLower(r.URL.Query().Get("wallet"))
 	symbol := r.URL.Query().Get("symbol")
 	limitStr := r.URL.Query().Get("limit")
+	only := r.URL.Query().Get("only")
+
+	if only != "" && only != "raw" && only != "human" {
+		http.Error(w, "invalid only parameter (raw or human)", http.StatusBadRequest)
+		return
+	}
 
 	limit := 100
 	if limitStr != "" {
@@ -100,12 +106,45 @@ func (h *Handler) GetBalances(w http.ResponseWriter, r *http.Request) {
 		balances = []storage.TokenBalance{}
 	}
 
+	var resp any = balances
+	if only != "" {
+		resp, err = onlyAmount(balances, only)
+		if err != nil {
+			slog.Error("GetBalances encode failed", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(balances); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		slog.Error("GetBalances encode failed", "error", err)
 	}
 }
 
+// onlyAmount renders balances keeping a single amount field: raw_balance for
+// "raw", balance for "human".
+func onlyAmount(balances []storage.TokenBalance, only string) ([]map[string]json.RawMessage, error) {
+	drop := "raw_balance"
+	if only == "raw" {
+		drop = "balance"
+	}
+	out := make([]map[string]json.RawMessage, 0, len(balances))
+	for _, b := range balances {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		delete(fields, drop)
+		out = append(out, fields)
+	}
+	return out, nil
+}
+
 // GetLatestBalances handles GET /api/v1/wallets/{wallet}/balances/latest
 func (h *Handler) GetLatestBalances(w http.ResponseWriter, r *http.Request) {
 	wallet := strings.ToLower(chi.URLParam(r, "wallet"))
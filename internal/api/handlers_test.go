@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -104,11 +105,13 @@ func (m *mockStore) GetWallets(ctx context.Context) ([]string, error) {
 	return []string{}, nil
 }
 
-func (m *mockStore) BatchInsertBalances(ctx context.Context, balances []storage.TokenBalance) error {
+func (m *mockStore) BatchInsertBalances(ctx context.Context, balances []storage.TokenBalance) ([]storage.TokenBalance, error) {
 	if m.batchInsertFn != nil {
-		return m.batchInsertFn(ctx, balances)
+		if err := m.batchInsertFn(ctx, balances); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return balances, nil
 }
 
 func (m *mockStore) Ping(ctx context.Context) error {
@@ -289,6 +292,56 @@ func TestGetBalances_InvalidLimit_Returns400(t *testing.T) {
 	}
 }
 
+func TestGetBalances_RawAndHumanAmounts(t *testing.T) {
+	raw18, _ := new(big.Int).SetString("1234567890123456789012", 10)
+	ms := &mockStore{
+		getBalancesFn: func(_ context.Context, _, _ string, _ int) ([]storage.TokenBalance, error) {
+			usdc := sampleBalance()
+			usdc.RawBalance = big.NewInt(10_000_500_000)
+			usdc.Balance = decimal.RequireFromString("10000.5")
+			xdai := sampleBalance()
+			xdai.Symbol = "armmXDAI"
+			xdai.Decimals = 18
+			xdai.RawBalance = raw18
+			xdai.Balance = decimal.RequireFromString("1234.567890123456789012")
+			return []storage.TokenBalance{usdc, xdai}, nil
+		},
+	}
+
+	decode := func(t *testing.T, query string) []map[string]any {
+		rec := get(t, newRouter(ms), "/api/v1/balances"+query)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var result []map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		require.Len(t, result, 2)
+		return result
+	}
+
+	result := decode(t, "")
+	assert.Equal(t, "10000500000", result[0]["raw_balance"])
+	assert.Equal(t, "10000.5", result[0]["balance"])
+	assert.Equal(t, "1234567890123456789012", result[1]["raw_balance"])
+	assert.Equal(t, "1234.567890123456789012", result[1]["balance"])
+
+	result = decode(t, "?only=raw")
+	for _, r := range result {
+		assert.Contains(t, r, "raw_balance")
+		assert.NotContains(t, r, "balance")
+		assert.Contains(t, r, "decimals")
+	}
+	assert.Equal(t, "1234567890123456789012", result[1]["raw_balance"])
+
+	result = decode(t, "?only=human")
+	for _, r := range result {
+		assert.NotContains(t, r, "raw_balance")
+		assert.Contains(t, r, "balance")
+	}
+	assert.Equal(t, "1234.567890123456789012", result[1]["balance"])
+
+	rec := get(t, newRouter(ms), "/api/v1/balances?only=both")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
 func TestGetBalances_StoreError_Returns500(t *testing.T) {
 	ms := &mockStore{
 		getBalancesFn: func(_ context.Context, _, _ string, _ int) ([]storage.TokenBalance, error) {
@@ -2,31 +2,90 @@ package blockchain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/shopspring/decimal"
+	"golang.org/x/time/rate"
 )
 
+// Defaults of ClientConfig.
 const (
-	rpcTimeout    = 10 * time.Second
-	maxRetries    = 3
-	retryInterval = 500 * time.Millisecond
+	DefaultRPCTimeout    = 10 * time.Second
+	DefaultMaxRetries    = 2
+	DefaultRetryInterval = 500 * time.Millisecond
 )
 
+// ClientConfig tunes the RPC calls of a Client.
+type ClientConfig struct {
+	// Timeout bounds a call, its retries included
+	Timeout time.Duration
+	// MaxRetries is the number of retries after a failed first attempt
+	MaxRetries int
+	// RetryInterval is the backoff before the first retry, doubled for each
+	// following one
+	RetryInterval time.Duration
+}
+
+// DefaultClientConfig returns the configuration used unless tuned: a 10s
+// timeout and 2 retries, 500ms then 1s apart.
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:       DefaultRPCTimeout,
+		MaxRetries:    DefaultMaxRetries,
+		RetryInterval: DefaultRetryInterval,
+	}
+}
+
+// RetryRecorder receives the outcome of RPC calls made through
+// retryWithBackoff, labelled by endpoint URL. It is implemented by
+// *metrics.Metrics.
+type RetryRecorder interface {
+	// RPCAttempt is called for every attempt, including the first.
+	RPCAttempt(endpoint string)
+	// RPCRetrySucceeded is called when a call succeeds after a failed attempt.
+	RPCRetrySucceeded(endpoint string)
+	// RPCRetriesExhausted is called when every attempt of a call failed.
+	RPCRetriesExhausted(endpoint string)
+}
+
+type nopRetryRecorder struct{}
+
+func (nopRetryRecorder) RPCAttempt(string)          {}
+func (nopRetryRecorder) RPCRetrySucceeded(string)   {}
+func (nopRetryRecorder) RPCRetriesExhausted(string) {}
+
 // Client wraps Ethereum RPC client functionality with failover support
 type Client struct {
-	failoverClient *FailoverClient
-	parsedABI      abi.ABI
+	failoverClient    *FailoverClient
+	parsedABI         abi.ABI
+	poolABI           abi.ABI
+	multicallABI      abi.ABI
+	multicall         common.Address // Multicall3 contract, zero when disabled
+	metadata          *metadataCache
+	decimalsPolicy    DecimalsPolicy
+	maxDecimals       uint8
+	maxDecimalsPolicy MaxDecimalsPolicy
+	retries           RetryRecorder
+	recordLatency     bool
+	race              bool          // balanceOf raced across healthy endpoints
+	rpc               ClientConfig  // zero in Clients built without NewClient
+	limiter           *rate.Limiter // nil when RPC calls are not rate limited
 }
 
-// NewClient creates a new blockchain client with failover support
-func NewClient(rpcURLs []string) (*Client, error) {
-	failoverClient, err := NewFailoverClient(rpcURLs)
+// NewClient creates a new blockchain client with failover support. chainID
+// is the chain every endpoint must serve, 0 to only require that they agree.
+// A zero Timeout or RetryInterval in cfg, or a negative MaxRetries, is
+// replaced by its default.
+func NewClient(endpoints []Endpoint, chainID uint64, cfg ClientConfig) (*Client, error) {
+	failoverClient, err := NewFailoverClient(endpoints, chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -36,17 +95,139 @@ func NewClient(rpcURLs []string) (*Client, error) {
 		return nil, fmt.Errorf("failed to parse ABI: %w", err)
 	}
 
+	parsedPoolABI, err := abi.JSON(strings.NewReader(poolABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pool ABI: %w", err)
+	}
+
+	parsedMulticallABI, err := abi.JSON(strings.NewReader(multicallABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse multicall ABI: %w", err)
+	}
+
 	return &Client{
-		failoverClient: failoverClient,
-		parsedABI:      parsedABI,
+		failoverClient:    failoverClient,
+		parsedABI:         parsedABI,
+		poolABI:           parsedPoolABI,
+		multicallABI:      parsedMulticallABI,
+		metadata:          newMetadataCache(),
+		decimalsPolicy:    DecimalsCanonical,
+		maxDecimals:       DefaultMaxDecimals,
+		maxDecimalsPolicy: MaxDecimalsWarn,
+		retries:           nopRetryRecorder{},
+		rpc:               withClientDefaults(cfg),
 	}, nil
 }
 
+// withClientDefaults fills the unset fields of cfg with their defaults.
+func withClientDefaults(cfg ClientConfig) ClientConfig {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultRPCTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = DefaultRetryInterval
+	}
+	return cfg
+}
+
+// settings returns the RPC call settings, the defaults for Clients built
+// without NewClient (as in tests).
+func (c *Client) settings() ClientConfig {
+	if c.rpc == (ClientConfig{}) {
+		return DefaultClientConfig()
+	}
+	return c.rpc
+}
+
+// SetDecimalsPolicy sets how decimals are chosen for stored balances.
+// The default is DecimalsCanonical.
+func (c *Client) SetDecimalsPolicy(policy DecimalsPolicy) {
+	c.decimalsPolicy = policy
+}
+
+// SetMaxDecimals sets the largest accepted decimals() value and what to do
+// with tokens exceeding it. The default is DefaultMaxDecimals with
+// MaxDecimalsWarn.
+func (c *Client) SetMaxDecimals(limit uint8, policy MaxDecimalsPolicy) {
+	c.maxDecimals = limit
+	c.maxDecimalsPolicy = policy
+}
+
+// SetRecordFetchLatency sets whether balances carry the duration of their
+// balanceOf read. It is off by default.
+func (c *Client) SetRecordFetchLatency(on bool) {
+	c.recordLatency = on
+}
+
+// SetRaceEndpoints sets whether GetTokenBalance reads balanceOf on every
+// healthy endpoint at once and keeps the first answer; see
+// GetTokenBalanceRaced. It is off by default, as it multiplies the calls.
+func (c *Client) SetRaceEndpoints(on bool) {
+	c.race = on
+}
+
+// SetRateLimit caps the RPC calls, retries included, at perSecond across all
+// endpoints; calls beyond it wait for their turn. A perSecond that is not
+// positive removes the limit, which is the default.
+func (c *Client) SetRateLimit(perSecond float64) {
+	if perSecond <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = rate.NewLimiter(rate.Limit(perSecond), 1)
+}
+
+// SetRetryRecorder sets where retry outcomes are reported. By default they
+// are not recorded.
+func (c *Client) SetRetryRecorder(r RetryRecorder) {
+	c.retries = r
+}
+
+// SetFailoverRecorder sets where switches of the active endpoint are
+// reported. They are always logged.
+func (c *Client) SetFailoverRecorder(r FailoverRecorder) {
+	c.failoverClient.SetRecorder(r)
+}
+
 // Close closes all RPC client connections
 func (c *Client) Close() {
 	c.failoverClient.Close()
 }
 
+// SetEndpointSelection sets how RPC endpoints sharing a priority are chosen
+// (SelectionPriority by default).
+func (c *Client) SetEndpointSelection(s Selection) {
+	c.failoverClient.SetSelection(s)
+}
+
+// SetCircuitBreaker sets how many consecutive failed calls, within window of
+// the first one, take an RPC endpoint down; see
+// FailoverClient.SetCircuitBreaker.
+func (c *Client) SetCircuitBreaker(threshold int, window time.Duration) {
+	c.failoverClient.SetCircuitBreaker(threshold, window)
+}
+
+// StartHealthChecker probes the unhealthy RPC endpoints every interval until
+// ctx is done or the client is closed; see FailoverClient.StartHealthChecker.
+func (c *Client) StartHealthChecker(ctx context.Context, interval time.Duration) {
+	c.failoverClient.StartHealthChecker(ctx, interval)
+}
+
+// EndpointStats returns the health, latency and failures of each RPC
+// endpoint; see FailoverClient.Stats.
+func (c *Client) EndpointStats() []EndpointStat {
+	return c.failoverClient.Stats()
+}
+
+// Ping calls eth_chainId on the active RPC endpoint, recording its latency in
+// EndpointStats, and returns the endpoint URL.
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	return c.failoverClient.Ping(ctx)
+}
+
 // GetHealthyEndpoint returns a healthy RPC client and its URL
 func (c *Client) GetHealthyEndpoint() (*ethclient.Client, string, error) {
 	return c.failoverClient.GetClient()
@@ -57,16 +238,26 @@ func (c *Client) GetEndpointsHealth() map[string]bool {
 	return c.failoverClient.GetEndpointsHealth()
 }
 
-// retryWithBackoff executes a function with exponential backoff and automatic failover
+// retryWithBackoff executes a function with exponential backoff and automatic
+// failover. An endpoint throttling calls (HTTP 429) is not held responsible:
+// the call is retried on it after its Retry-After hint, when longer than the
+// backoff.
 func (c *Client) retryWithBackoff(ctx context.Context, fn func() error) error {
 	var lastErr error
 	var currentURL string
-	var previousURL string
+	var lastFailedURL string
+	var retryAfter time.Duration
 
-	for attempt := range maxRetries {
+	settings := c.settings()
+	attempts := settings.MaxRetries + 1
+	for attempt := range attempts {
 		if attempt > 0 {
 			shift := uint(attempt - 1) //nolint:gosec // attempt > 0 here, so attempt-1 >= 0
-			backoff := retryInterval << shift
+			backoff := max(settings.RetryInterval<<shift, retryAfter)
+			if deadline, ok := ctx.Deadline(); ok && retryAfter > 0 && time.Until(deadline) < backoff {
+				return fmt.Errorf("rate limited, retry after %s exceeds the call deadline: %w", retryAfter, lastErr)
+			}
+			retryAfter = 0
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
@@ -74,16 +265,48 @@ func (c *Client) retryWithBackoff(ctx context.Context, fn func() error) error {
 			}
 		}
 
+		// Wait for the rate limit, or give up with ctx
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("waiting for the RPC rate limit: %w", err)
+			}
+		}
+
 		// Get current RPC URL
 		_, currentURL, _ = c.failoverClient.GetClient() //nolint:errcheck // best-effort URL refresh; error handled via MarkUnhealthy
+		c.recorder().RPCAttempt(currentURL)
 
 		if err := fn(); err != nil {
 			lastErr = err
 
-			// Mark endpoint unhealthy after first failure
-			if previousURL != currentURL {
-				previousURL = currentURL
+			// A cancelled context is a shutdown, not an endpoint failure:
+			// stop without marking the endpoint unhealthy
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+
+			// Remember the endpoint, as later attempts may find no
+			// endpoint left to blame
+			if currentURL != "" {
+				lastFailedURL = currentURL
 			}
+
+			// A throttled call is retried on the same endpoint, which is
+			// healthy but busy
+			if delay, ok := rateLimited(err); ok {
+				retryAfter = delay
+				slog.Warn("RPC endpoint rate limited, backing off", "url", currentURL, "retry_after", delay)
+				continue
+			}
+
+			// A JSON-RPC error or a revert is an answer, which no other
+			// endpoint would change: return it without blaming this one
+			if !endpointFailure(err) {
+				return err
+			}
+
+			// Count the failure against the endpoint, which goes down at
+			// the failure threshold
 			c.failoverClient.MarkUnhealthy(currentURL, err)
 
 			// Try to get a different healthy endpoint
@@ -98,10 +321,70 @@ func (c *Client) retryWithBackoff(ctx context.Context, fn func() error) error {
 			// No healthy endpoints available or still on same endpoint
 			continue
 		}
+		c.failoverClient.MarkSucceeded(currentURL)
+		if attempt > 0 {
+			c.recorder().RPCRetrySucceeded(currentURL)
+		}
 		return nil
 	}
 
-	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+	c.recorder().RPCRetriesExhausted(lastFailedURL)
+	return fmt.Errorf("failed after %d attempts: %w", attempts, lastErr)
+}
+
+// recorder returns the retry recorder, which Clients built without NewClient
+// (as in tests) may lack.
+func (c *Client) recorder() RetryRecorder {
+	if c.retries == nil {
+		return nopRetryRecorder{}
+	}
+	return c.retries
+}
+
+// LatestBlockTime returns the timestamp of the latest block served by the
+// current endpoint. Comparing it to the wall clock reveals a lagging RPC.
+func (c *Client) LatestBlockTime(ctx context.Context) (time.Time, error) {
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+
+	var blockTime time.Time
+	err := c.retryWithBackoff(rpcCtx, func() error {
+		ethClient, _, err := c.failoverClient.GetClient()
+		if err != nil {
+			return fmt.Errorf("no RPC endpoint available: %w", err)
+		}
+		header, err := ethClient.HeaderByNumber(rpcCtx, nil)
+		if err != nil {
+			return err
+		}
+		blockTime = time.Unix(int64(header.Time), 0).UTC()
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("latest block header: %w", err)
+	}
+	return blockTime, nil
+}
+
+// LatestBlockNumber returns the number of the latest block served by the
+// current endpoint.
+func (c *Client) LatestBlockNumber(ctx context.Context) (uint64, error) {
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+
+	var number uint64
+	err := c.retryWithBackoff(rpcCtx, func() error {
+		ethClient, _, err := c.failoverClient.GetClient()
+		if err != nil {
+			return fmt.Errorf("no RPC endpoint available: %w", err)
+		}
+		number, err = ethClient.BlockNumber(rpcCtx)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("latest block number: %w", err)
+	}
+	return number, nil
 }
 
 // HumanBalance converts raw balance to human-readable decimal
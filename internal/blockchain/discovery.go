@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// discoveryTimeout bounds a full discovery pass, which issues several calls
+// per reserve.
+const discoveryTimeout = 60 * time.Second
+
+// poolABI covers the Aave v3 Pool functions used by the RMM lending pool to
+// enumerate reserves. getReserveData returns the legacy ReserveData struct.
+const poolABI = `[
+	{"inputs":[],"name":"getReservesList","outputs":[{"internalType":"address[]","name":"","type":"address[]"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"internalType":"address","name":"asset","type":"address"}],"name":"getReserveData","outputs":[{"components":[
+		{"components":[{"internalType":"uint256","name":"data","type":"uint256"}],"internalType":"struct DataTypes.ReserveConfigurationMap","name":"configuration","type":"tuple"},
+		{"internalType":"uint128","name":"liquidityIndex","type":"uint128"},
+		{"internalType":"uint128","name":"currentLiquidityRate","type":"uint128"},
+		{"internalType":"uint128","name":"variableBorrowIndex","type":"uint128"},
+		{"internalType":"uint128","name":"currentVariableBorrowRate","type":"uint128"},
+		{"internalType":"uint128","name":"currentStableBorrowRate","type":"uint128"},
+		{"internalType":"uint40","name":"lastUpdateTimestamp","type":"uint40"},
+		{"internalType":"uint16","name":"id","type":"uint16"},
+		{"internalType":"address","name":"aTokenAddress","type":"address"},
+		{"internalType":"address","name":"stableDebtTokenAddress","type":"address"},
+		{"internalType":"address","name":"variableDebtTokenAddress","type":"address"},
+		{"internalType":"address","name":"interestRateStrategyAddress","type":"address"},
+		{"internalType":"uint128","name":"accruedToTreasury","type":"uint128"},
+		{"internalType":"uint128","name":"unbacked","type":"uint128"},
+		{"internalType":"uint128","name":"isolationModeTotalDebt","type":"uint128"}
+	],"internalType":"struct DataTypes.ReserveData","name":"","type":"tuple"}],"stateMutability":"view","type":"function"}
+]`
+
+// DiscoverTokens enumerates the reserves of an RMM (Aave v3) lending pool
+// and returns the aToken and variable debt token of each reserve, labelled
+// with their on-chain symbol.
+func (c *Client) DiscoverTokens(ctx context.Context, poolAddr common.Address) ([]TokenInfo, error) {
+	rpcCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	var tokens []TokenInfo
+	err := c.retryWithBackoff(rpcCtx, func() error {
+		ethClient, _, err := c.failoverClient.GetClient()
+		if err != nil {
+			return fmt.Errorf("no RPC endpoint available: %w", err)
+		}
+		tokens, err = discoverTokens(rpcCtx, ethClient, poolAddr, c.poolABI, c.parsedABI)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discover tokens from pool %s: %w", poolAddr.Hex(), err)
+	}
+	return tokens, nil
+}
+
+// discoverTokens performs the discovery calls against caller.
+func discoverTokens(ctx context.Context, caller bind.ContractCaller, poolAddr common.Address, poolABI, tokenABI abi.ABI) ([]TokenInfo, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	pool := bind.NewBoundContract(poolAddr, poolABI, caller, nil, nil)
+
+	var listResult []any
+	if err := pool.Call(opts, &listResult, "getReservesList"); err != nil {
+		return nil, fmt.Errorf("getReservesList: %w", err)
+	}
+	reserves := listResult[0].([]common.Address)
+
+	var tokens []TokenInfo
+	for _, asset := range reserves {
+		var dataResult []any
+		if err := pool.Call(opts, &dataResult, "getReserveData", asset); err != nil {
+			return nil, fmt.Errorf("getReserveData(%s): %w", asset.Hex(), err)
+		}
+		data := reflect.ValueOf(dataResult[0])
+
+		for _, field := range []string{"ATokenAddress", "VariableDebtTokenAddress"} {
+			addr := data.FieldByName(field).Interface().(common.Address)
+			if addr == (common.Address{}) {
+				continue
+			}
+			token, err := describeToken(opts, caller, addr, tokenABI)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// describeToken reads the symbol and decimals of an ERC-20 token.
+func describeToken(opts *bind.CallOpts, caller bind.ContractCaller, addr common.Address, tokenABI abi.ABI) (TokenInfo, error) {
+	contract := bind.NewBoundContract(addr, tokenABI, caller, nil, nil)
+
+	var symbolResult []any
+	if err := contract.Call(opts, &symbolResult, "symbol"); err != nil {
+		return TokenInfo{}, fmt.Errorf("symbol(%s): %w", addr.Hex(), err)
+	}
+	var decimalsResult []any
+	if err := contract.Call(opts, &decimalsResult, "decimals"); err != nil {
+		return TokenInfo{}, fmt.Errorf("decimals(%s): %w", addr.Hex(), err)
+	}
+
+	return TokenInfo{
+		Label:            symbolResult[0].(string),
+		Address:          addr.Hex(),
+		FallbackDecimals: decimalsResult[0].(uint8),
+	}, nil
+}
@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reserveData mirrors the tuple returned by getReserveData, for packing.
+type reserveData struct {
+	Configuration               struct{ Data *big.Int }
+	LiquidityIndex              *big.Int
+	CurrentLiquidityRate        *big.Int
+	VariableBorrowIndex         *big.Int
+	CurrentVariableBorrowRate   *big.Int
+	CurrentStableBorrowRate     *big.Int
+	LastUpdateTimestamp         *big.Int
+	ID                          uint16 `abi:"id"`
+	ATokenAddress               common.Address
+	StableDebtTokenAddress      common.Address
+	VariableDebtTokenAddress    common.Address
+	InterestRateStrategyAddress common.Address
+	AccruedToTreasury           *big.Int
+	Unbacked                    *big.Int
+	IsolationModeTotalDebt      *big.Int
+}
+
+func newReserveData(aToken, stableDebt, variableDebt common.Address) reserveData {
+	zero := big.NewInt(0)
+	rd := reserveData{
+		LiquidityIndex:            zero,
+		CurrentLiquidityRate:      zero,
+		VariableBorrowIndex:       zero,
+		CurrentVariableBorrowRate: zero,
+		CurrentStableBorrowRate:   zero,
+		LastUpdateTimestamp:       zero,
+		ATokenAddress:             aToken,
+		StableDebtTokenAddress:    stableDebt,
+		VariableDebtTokenAddress:  variableDebt,
+		AccruedToTreasury:         zero,
+		Unbacked:                  zero,
+		IsolationModeTotalDebt:    zero,
+	}
+	rd.Configuration.Data = zero
+	return rd
+}
+
+type erc20Meta struct {
+	symbol   string
+	decimals uint8
+	name     string
+}
+
+// fakePool answers eth_call requests for a lending pool and its tokens.
+type fakePool struct {
+	t        *testing.T
+	pool     common.Address
+	poolABI  abi.ABI
+	tokenABI abi.ABI
+	reserves map[common.Address]reserveData
+	order    []common.Address
+	tokens   map[common.Address]erc20Meta
+	failList bool
+}
+
+func (f *fakePool) CodeAt(_ context.Context, _ common.Address, _ *big.Int) ([]byte, error) {
+	return []byte{0x1}, nil
+}
+
+func (f *fakePool) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	if *call.To == f.pool {
+		method, err := f.poolABI.MethodById(call.Data[:4])
+		require.NoError(f.t, err)
+		switch method.Name {
+		case "getReservesList":
+			if f.failList {
+				return nil, errors.New("execution reverted")
+			}
+			return method.Outputs.Pack(f.order)
+		case "getReserveData":
+			args, err := method.Inputs.Unpack(call.Data[4:])
+			require.NoError(f.t, err)
+			return method.Outputs.Pack(f.reserves[args[0].(common.Address)])
+		}
+	}
+
+	meta, ok := f.tokens[*call.To]
+	if !ok {
+		return nil, fmt.Errorf("unexpected call to %s", call.To.Hex())
+	}
+	method, err := f.tokenABI.MethodById(call.Data[:4])
+	require.NoError(f.t, err)
+	switch method.Name {
+	case "symbol":
+		return method.Outputs.Pack(meta.symbol)
+	case "decimals":
+		return method.Outputs.Pack(meta.decimals)
+	}
+	return nil, fmt.Errorf("unexpected method %s", method.Name)
+}
+
+func newFakePool(t *testing.T) *fakePool {
+	t.Helper()
+	parsedPool, err := abi.JSON(strings.NewReader(poolABI))
+	require.NoError(t, err)
+	parsedToken, err := abi.JSON(strings.NewReader(erc20ABI))
+	require.NoError(t, err)
+
+	wxdai := common.HexToAddress("0xe91D153E0b41518A2Ce8Dd3D7944Fa863463a97d")
+	usdc := common.HexToAddress("0xDDAfbb505ad214D7b80b1f830fcCc89B60fb7A83")
+	aXDAI := common.HexToAddress("0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b")
+	dXDAI := common.HexToAddress("0x9908801dF7902675C3FEDD6Fea0294D18D5d5d34")
+	aUSDC := common.HexToAddress("0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1")
+	dUSDC := common.HexToAddress("0x69c731aE5f5356a779f44C355aBB685d84e5E9e6")
+	sUSDC := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	return &fakePool{
+		t:        t,
+		pool:     common.HexToAddress("0x5B8D36De471880Ee21936f328AAB2383a280CB2A"),
+		poolABI:  parsedPool,
+		tokenABI: parsedToken,
+		order:    []common.Address{wxdai, usdc},
+		reserves: map[common.Address]reserveData{
+			wxdai: newReserveData(aXDAI, common.Address{}, dXDAI),
+			usdc:  newReserveData(aUSDC, sUSDC, dUSDC),
+		},
+		tokens: map[common.Address]erc20Meta{
+			aXDAI: {symbol: "armmWXDAI", decimals: 18},
+			dXDAI: {symbol: "debtrmmWXDAI", decimals: 18},
+			aUSDC: {symbol: "armmUSDC", decimals: 6},
+			dUSDC: {symbol: "debtrmmUSDC", decimals: 6},
+		},
+	}
+}
+
+func TestDiscoverTokens(t *testing.T) {
+	fake := newFakePool(t)
+
+	tokens, err := discoverTokens(context.Background(), fake, fake.pool, fake.poolABI, fake.tokenABI)
+	require.NoError(t, err)
+
+	assert.Equal(t, []TokenInfo{
+		{Label: "armmWXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b", FallbackDecimals: 18},
+		{Label: "debtrmmWXDAI", Address: "0x9908801dF7902675C3FEDD6Fea0294D18D5d5d34", FallbackDecimals: 18},
+		{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+		{Label: "debtrmmUSDC", Address: "0x69c731aE5f5356a779f44C355aBB685d84e5E9e6", FallbackDecimals: 6},
+	}, tokens)
+}
+
+func TestDiscoverTokens_EmptyPool(t *testing.T) {
+	fake := newFakePool(t)
+	fake.order = nil
+
+	tokens, err := discoverTokens(context.Background(), fake, fake.pool, fake.poolABI, fake.tokenABI)
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+}
+
+func TestDiscoverTokens_ReserveListError(t *testing.T) {
+	fake := newFakePool(t)
+	fake.failList = true
+
+	_, err := discoverTokens(context.Background(), fake, fake.pool, fake.poolABI, fake.tokenABI)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "getReservesList")
+}
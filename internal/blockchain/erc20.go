@@ -3,18 +3,23 @@ package blockchain
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/matrixise/rmm-tracker/internal/storage"
 )
 
 const erc20ABI = `[
 	{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
 	{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"payable":false,"stateMutability":"view","type":"function"},
-	{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"}
+	{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}
 ]`
 
 // TokenInfo represents basic token configuration
@@ -22,18 +27,75 @@ type TokenInfo struct {
 	Label            string
 	Address          string
 	FallbackDecimals uint8
+	// ForceRefreshMetadata reads decimals and symbol on-chain again instead
+	// of reusing the cached values, for tokens behind an upgradeable proxy.
+	ForceRefreshMetadata bool
 }
 
 // GetTokenBalance retrieves balance for a specific token and wallet
 func (c *Client) GetTokenBalance(ctx context.Context, wallet common.Address, token TokenInfo) (storage.TokenBalance, error) {
+	if c.race {
+		return c.GetTokenBalanceRaced(ctx, wallet, token)
+	}
 	// Get healthy client with automatic failover
 	ethClient, _, err := c.failoverClient.GetClient()
 	if err != nil {
 		return storage.TokenBalance{}, fmt.Errorf("no RPC endpoint available: %w", err)
 	}
+	return c.tokenBalance(ctx, ethClient, wallet, token, nil)
+}
+
+// GetTokenBalanceAtBlock retrieves the balance of token for wallet as of
+// blockNumber, for backfilling history. QueriedAt, BlockTimestamp and
+// BlockNumber are those of the block. Reading state this old requires an
+// archive node; other endpoints fail with a missing trie node error.
+func (c *Client) GetTokenBalanceAtBlock(ctx context.Context, wallet common.Address, token TokenInfo, blockNumber *big.Int) (storage.TokenBalance, error) {
+	if blockNumber == nil || blockNumber.Sign() < 0 {
+		return storage.TokenBalance{}, fmt.Errorf("invalid block number %v", blockNumber)
+	}
+	ethClient, _, err := c.failoverClient.GetClient()
+	if err != nil {
+		return storage.TokenBalance{}, fmt.Errorf("no RPC endpoint available: %w", err)
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+	var header *types.Header
+	err = c.retryWithBackoff(rpcCtx, func() error {
+		header, err = ethClient.HeaderByNumber(rpcCtx, blockNumber)
+		return err
+	})
+	if err != nil {
+		return storage.TokenBalance{}, fmt.Errorf("block %s header: %w", blockNumber, err)
+	}
 
+	result, err := c.tokenBalance(ctx, ethClient, wallet, token, header.Number)
+	if err != nil {
+		return result, fmt.Errorf("block %s: %w", blockNumber, err)
+	}
+	blockTime := time.Unix(int64(header.Time), 0).UTC()
+	result.QueriedAt = blockTime
+	result.BlockTimestamp = &blockTime
+	result.BlockNumber = header.Number.Uint64()
+	return result, nil
+}
+
+// VerifyTokenBalance reads a token balance again, on another healthy endpoint
+// than the active one when there is one, to confirm a suspicious result.
+func (c *Client) VerifyTokenBalance(ctx context.Context, wallet common.Address, token TokenInfo) (storage.TokenBalance, error) {
+	ethClient, url, err := c.failoverClient.GetAlternateClient()
+	if err != nil {
+		return storage.TokenBalance{}, fmt.Errorf("no RPC endpoint available: %w", err)
+	}
+	slog.Debug("Verifying token balance", "token_address", token.Address, "url", url)
+	return c.tokenBalance(ctx, ethClient, wallet, token, nil)
+}
+
+// tokenBalance reads the balance, decimals and symbol of token through
+// ethClient, at block or at the latest block when block is nil.
+func (c *Client) tokenBalance(ctx context.Context, ethClient *ethclient.Client, wallet common.Address, token TokenInfo, block *big.Int) (storage.TokenBalance, error) {
 	// Context with timeout
-	rpcCtx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
 	defer cancel()
 
 	tokenAddr := common.HexToAddress(token.Address)
@@ -47,36 +109,215 @@ func (c *Client) GetTokenBalance(ctx context.Context, wallet common.Address, tok
 
 	// Get balanceOf with retry
 	var balanceResult []any
-	err = c.retryWithBackoff(rpcCtx, func() error {
-		return contract.Call(&bind.CallOpts{Context: rpcCtx}, &balanceResult, "balanceOf", wallet)
+	start := time.Now()
+	err := c.retryWithBackoff(rpcCtx, func() error {
+		return contract.Call(&bind.CallOpts{Context: rpcCtx, BlockNumber: block}, &balanceResult, "balanceOf", wallet)
 	})
 	if err != nil {
 		return result, fmt.Errorf("balanceOf: %w", err)
 	}
-	result.RawBalance = balanceResult[0].(*big.Int)
+	if result.RawBalance, err = bigIntResult("balanceOf", balanceResult); err != nil {
+		return result, err
+	}
+	if c.recordLatency {
+		ms := time.Since(start).Milliseconds()
+		result.FetchLatencyMS = &ms
+	}
 
-	// Get decimals with retry (use fallback if fails)
-	result.Decimals = token.FallbackDecimals
-	var decimalsResult []any
+	err = c.completeBalance(rpcCtx, contract, tokenAddr, token, block, &result)
+	return result, err
+}
+
+// completeBalance fills the decimals, symbol, name and human-readable
+// balance of result, whose raw balance is read, through contract at block.
+func (c *Client) completeBalance(rpcCtx context.Context, contract *bind.BoundContract, tokenAddr common.Address, token TokenInfo, block *big.Int, result *storage.TokenBalance) error {
+	// Decimals and symbol come from the cache once read
+	decimals, haveDecimals, symbol, haveSymbol := c.cachedMetadata(tokenAddr, token)
+
+	// Get decimals with retry; the policy decides between this read, the
+	// token's canonical decimals and the fallback
+	if haveDecimals {
+		result.Decimals = decimals
+	} else {
+		var decimalsResult []any
+		var readDecimals uint8
+		err := c.retryWithBackoff(rpcCtx, func() error {
+			return contract.Call(&bind.CallOpts{Context: rpcCtx, BlockNumber: block}, &decimalsResult, "decimals")
+		})
+		if err == nil {
+			readDecimals = decimalsResult[0].(uint8)
+		}
+		result.Decimals, err = c.decimals(tokenAddr, token, readDecimals, err)
+		if err != nil {
+			return fmt.Errorf("decimals: %w", err)
+		}
+	}
+
+	// Get symbol with retry
+	if haveSymbol {
+		result.Symbol = symbol
+	} else {
+		var symbolResult []any
+		err := c.retryWithBackoff(rpcCtx, func() error {
+			return contract.Call(&bind.CallOpts{Context: rpcCtx, BlockNumber: block}, &symbolResult, "symbol")
+		})
+		if err != nil {
+			return fmt.Errorf("symbol: %w", err)
+		}
+		result.Symbol = symbolResult[0].(string)
+		c.metadata.setSymbol(tokenAddr, result.Symbol)
+	}
+
+	// Get name with retry; it only makes reports clearer, so a failure
+	// falls back to the label
+	if name, ok := c.cachedName(tokenAddr, token); ok {
+		result.TokenName = name
+	} else {
+		var nameResult []any
+		err := c.retryWithBackoff(rpcCtx, func() error {
+			return contract.Call(&bind.CallOpts{Context: rpcCtx, BlockNumber: block}, &nameResult, "name")
+		})
+		var readName string
+		if err == nil {
+			readName = nameResult[0].(string)
+		}
+		result.TokenName = c.tokenName(tokenAddr, token, readName, err)
+	}
+
+	// Convert to human-readable balance
+	result.Balance = HumanBalance(result.RawBalance, result.Decimals)
+
+	return nil
+}
+
+// GetTotalSupply returns the raw totalSupply() of the token at tokenAddress.
+// Its decimals are read and cached along the way, so TokenDecimals returns
+// them without another call to render the supply with HumanBalance.
+func (c *Client) GetTotalSupply(ctx context.Context, tokenAddress common.Address) (*big.Int, error) {
+	ethClient, _, err := c.failoverClient.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("no RPC endpoint available: %w", err)
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+	contract := bind.NewBoundContract(tokenAddress, c.parsedABI, ethClient, ethClient, ethClient)
+	var supplyResult []any
 	err = c.retryWithBackoff(rpcCtx, func() error {
-		return contract.Call(&bind.CallOpts{Context: rpcCtx}, &decimalsResult, "decimals")
+		return contract.Call(&bind.CallOpts{Context: rpcCtx}, &supplyResult, "totalSupply")
 	})
-	if err == nil {
-		result.Decimals = decimalsResult[0].(uint8)
+	if err != nil {
+		return nil, fmt.Errorf("totalSupply: %w", err)
 	}
 
-	// Get symbol with retry
-	var symbolResult []any
+	supply, err := bigIntResult("totalSupply", supplyResult)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.TokenDecimals(ctx, tokenAddress); err != nil {
+		slog.Debug("Token decimals not cached with its total supply", "token_address", tokenAddress.Hex(), "error", err)
+	}
+	return supply, nil
+}
+
+// bigIntResult returns the uint256 returned by a contract call to method, or
+// an error when the RPC answered with an unexpected shape.
+func bigIntResult(method string, values []any) (*big.Int, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s: empty result", method)
+	}
+	v, ok := values[0].(*big.Int)
+	if !ok || v == nil {
+		return nil, fmt.Errorf("%s: unexpected result %T", method, values[0])
+	}
+	return v, nil
+}
+
+// TokenDecimals returns the decimals of the token at tokenAddress, read
+// on-chain once and then served from the metadata cache. A value above the
+// configured maximum is an error.
+func (c *Client) TokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	if decimals, ok := c.metadata.cachedDecimals(tokenAddress); ok {
+		return decimals, nil
+	}
+	ethClient, _, err := c.failoverClient.GetClient()
+	if err != nil {
+		return 0, fmt.Errorf("no RPC endpoint available: %w", err)
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+	contract := bind.NewBoundContract(tokenAddress, c.parsedABI, ethClient, ethClient, ethClient)
+	var decimalsResult []any
 	err = c.retryWithBackoff(rpcCtx, func() error {
-		return contract.Call(&bind.CallOpts{Context: rpcCtx}, &symbolResult, "symbol")
+		return contract.Call(&bind.CallOpts{Context: rpcCtx}, &decimalsResult, "decimals")
 	})
 	if err != nil {
-		return result, fmt.Errorf("symbol: %w", err)
+		return 0, fmt.Errorf("decimals: %w", err)
+	}
+	read := decimalsResult[0].(uint8)
+	if err := checkMaxDecimals(read, c.maxDecimals); err != nil {
+		return 0, err
 	}
-	result.Symbol = symbolResult[0].(string)
+	return c.metadata.resolveDecimals(DecimalsCanonical, tokenAddress, "", read, nil, 0), nil
+}
 
-	// Convert to human-readable balance
-	result.Balance = HumanBalance(result.RawBalance, result.Decimals)
+// cachedMetadata returns the cached decimals and symbol of token, each with
+// whether it can be used instead of an on-chain read. Decimals are only
+// cached under DecimalsCanonical, since DecimalsPerRow stores every read;
+// ForceRefreshMetadata bypasses the cache.
+func (c *Client) cachedMetadata(tokenAddr common.Address, token TokenInfo) (decimals uint8, haveDecimals bool, symbol string, haveSymbol bool) {
+	if token.ForceRefreshMetadata {
+		return 0, false, "", false
+	}
+	if c.decimalsPolicy != DecimalsPerRow {
+		decimals, haveDecimals = c.metadata.cachedDecimals(tokenAddr)
+	}
+	symbol, haveSymbol = c.metadata.cachedSymbol(tokenAddr)
+	return decimals, haveDecimals, symbol, haveSymbol
+}
 
-	return result, nil
+// cachedName returns the cached name of token, unless ForceRefreshMetadata
+// asks for it to be read again.
+func (c *Client) cachedName(tokenAddr common.Address, token TokenInfo) (string, bool) {
+	if token.ForceRefreshMetadata {
+		return "", false
+	}
+	return c.metadata.cachedName(tokenAddr)
+}
+
+// tokenName returns the name to store for token given this poll's name()
+// result, caching a successful read. A failed read is not cached, so it is
+// retried next poll, and falls back to the configured label.
+func (c *Client) tokenName(tokenAddr common.Address, token TokenInfo, read string, readErr error) string {
+	if readErr != nil {
+		slog.Debug("name() failed, using the token label", "label", token.Label, "token_address", tokenAddr.Hex(), "error", readErr)
+		return token.Label
+	}
+	c.metadata.setName(tokenAddr, read)
+	return read
+}
+
+// decimals returns the decimals to store for token given this poll's
+// decimals() result. A value above the configured maximum fails the query
+// under MaxDecimalsReject; otherwise it is handled like a failed read.
+func (c *Client) decimals(tokenAddr common.Address, token TokenInfo, read uint8, readErr error) (uint8, error) {
+	if readErr == nil {
+		if err := checkMaxDecimals(read, c.maxDecimals); err != nil {
+			if c.maxDecimalsPolicy == MaxDecimalsReject {
+				return 0, err
+			}
+			slog.Warn("Token decimals exceed the sanity bound, check its address",
+				"label", token.Label,
+				"token_address", tokenAddr.Hex(),
+				"decimals", read,
+				"max_decimals", c.maxDecimals)
+			readErr = err
+		}
+	}
+	if token.ForceRefreshMetadata && readErr == nil && c.decimalsPolicy != DecimalsPerRow {
+		return c.metadata.refreshDecimals(tokenAddr, token.Label, read), nil
+	}
+	return c.metadata.resolveDecimals(c.decimalsPolicy, tokenAddr, token.Label, read, readErr, token.FallbackDecimals), nil
 }
@@ -0,0 +1,194 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveServer serves a token over JSON-RPC whose balance is the block
+// number it is read at, and records the block tag of every eth_call.
+type archiveServer struct {
+	t        *testing.T
+	tokenABI abi.ABI
+	times    map[uint64]uint64 // block number to timestamp
+
+	mu     sync.Mutex
+	blocks []string
+}
+
+func (s *archiveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&req))
+
+	var result any
+	switch req.Method {
+	case "eth_chainId":
+		result = "0x64"
+	case "eth_getBlockByNumber":
+		var tag string
+		require.NoError(s.t, json.Unmarshal(req.Params[0], &tag))
+		number, err := hexutil.DecodeUint64(tag)
+		require.NoError(s.t, err)
+		result = &types.Header{
+			Number:     new(big.Int).SetUint64(number),
+			Time:       s.times[number],
+			Difficulty: big.NewInt(0),
+		}
+	case "eth_call":
+		var call struct {
+			Input hexutil.Bytes `json:"input"`
+			Data  hexutil.Bytes `json:"data"`
+		}
+		var tag string
+		require.NoError(s.t, json.Unmarshal(req.Params[0], &call))
+		require.NoError(s.t, json.Unmarshal(req.Params[1], &tag))
+		s.mu.Lock()
+		s.blocks = append(s.blocks, tag)
+		s.mu.Unlock()
+
+		input := call.Input
+		if len(input) == 0 {
+			input = call.Data
+		}
+		method, err := s.tokenABI.MethodById(input[:4])
+		require.NoError(s.t, err)
+		var out []byte
+		switch method.Name {
+		case "balanceOf":
+			number, _ := hexutil.DecodeBig(tag)
+			out, err = method.Outputs.Pack(number)
+		case "decimals":
+			out, err = method.Outputs.Pack(uint8(6))
+		case "symbol":
+			out, err = method.Outputs.Pack("armmUSDC")
+		case "name":
+			out, err = method.Outputs.Pack("RealT RMM V3 USDC")
+		case "totalSupply":
+			out, err = method.Outputs.Pack(big.NewInt(123456789))
+		}
+		require.NoError(s.t, err)
+		result = hexutil.Bytes(out)
+	default:
+		s.t.Errorf("unexpected method %s", req.Method)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func TestGetTokenBalanceAtBlock(t *testing.T) {
+	parsedToken, err := abi.JSON(strings.NewReader(erc20ABI))
+	require.NoError(t, err)
+	blockTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	srv := &archiveServer{t: t, tokenABI: parsedToken, times: map[uint64]uint64{40000000: uint64(blockTime.Unix())}}
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(httpSrv.Close)
+
+	client, err := NewClient(EndpointsFromURLs([]string{httpSrv.URL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	wallet := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	token := TokenInfo{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6}
+	b, err := client.GetTokenBalanceAtBlock(context.Background(), wallet, token, big.NewInt(40000000))
+	require.NoError(t, err)
+
+	assert.Equal(t, "40000000", b.RawBalance.String(), "balanceOf is read at the block")
+	assert.Equal(t, "40", b.Balance.String())
+	assert.Equal(t, "armmUSDC", b.Symbol)
+	assert.Equal(t, "RealT RMM V3 USDC", b.TokenName)
+	assert.True(t, blockTime.Equal(b.QueriedAt), "queried_at is the block time")
+	require.NotNil(t, b.BlockTimestamp)
+	assert.True(t, blockTime.Equal(*b.BlockTimestamp))
+	assert.Equal(t, uint64(40000000), b.BlockNumber)
+	require.Len(t, srv.blocks, 4)
+	for _, tag := range srv.blocks {
+		assert.Equal(t, "0x2625a00", tag, "every call is pinned to the block")
+	}
+
+	_, err = client.GetTokenBalanceAtBlock(context.Background(), wallet, token, nil)
+	assert.ErrorContains(t, err, "invalid block number")
+}
+
+func TestGetTokenBalance_RecordsFetchLatency(t *testing.T) {
+	parsedToken, err := abi.JSON(strings.NewReader(erc20ABI))
+	require.NoError(t, err)
+	srv := &archiveServer{t: t, tokenABI: parsedToken, times: map[uint64]uint64{40000000: 1748779200}}
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(httpSrv.Close)
+
+	client, err := NewClient(EndpointsFromURLs([]string{httpSrv.URL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	wallet := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	token := TokenInfo{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6}
+	b, err := client.GetTokenBalanceAtBlock(context.Background(), wallet, token, big.NewInt(40000000))
+	require.NoError(t, err)
+	assert.Nil(t, b.FetchLatencyMS, "latency is not recorded by default")
+
+	client.SetRecordFetchLatency(true)
+	b, err = client.GetTokenBalanceAtBlock(context.Background(), wallet, token, big.NewInt(40000000))
+	require.NoError(t, err)
+	require.NotNil(t, b.FetchLatencyMS)
+	assert.GreaterOrEqual(t, *b.FetchLatencyMS, int64(0))
+}
+
+func TestGetTotalSupply(t *testing.T) {
+	parsedToken, err := abi.JSON(strings.NewReader(erc20ABI))
+	require.NoError(t, err)
+	srv := &archiveServer{t: t, tokenABI: parsedToken}
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(httpSrv.Close)
+
+	client, err := NewClient(EndpointsFromURLs([]string{httpSrv.URL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	token := common.HexToAddress("0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1")
+	supply, err := client.GetTotalSupply(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "123456789", supply.String())
+	require.Len(t, srv.blocks, 2, "totalSupply and decimals")
+
+	decimals, err := client.TokenDecimals(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(6), decimals)
+	assert.Len(t, srv.blocks, 2, "decimals come from the cache")
+	assert.Equal(t, "123.456789", HumanBalance(supply, decimals).String())
+}
+
+func TestBigIntResult(t *testing.T) {
+	v, err := bigIntResult("balanceOf", []any{big.NewInt(42)})
+	require.NoError(t, err)
+	assert.Equal(t, "42", v.String())
+
+	for name, values := range map[string][]any{
+		"empty":       nil,
+		"nil big.Int": {(*big.Int)(nil)},
+		"wrong type":  {"42"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := bigIntResult("balanceOf", values)
+			assert.ErrorContains(t, err, "balanceOf")
+		})
+	}
+}
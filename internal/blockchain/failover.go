@@ -1,9 +1,12 @@
 package blockchain
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,58 +18,177 @@ const (
 	healthCheckTimeout = 5 * time.Second
 )
 
+// Defaults of the circuit breaker of an endpoint: a single failed call takes
+// it down, and failures count towards the threshold for a minute after the
+// first of them.
+const (
+	DefaultFailureThreshold = 1
+	DefaultFailureWindow    = time.Minute
+)
+
+// DefaultProbeInterval is how often StartHealthChecker probes the endpoints
+// that are down, unless configured otherwise.
+const DefaultProbeInterval = 30 * time.Second
+
+// Endpoint is an RPC endpoint and its priority: lower values are preferred,
+// and endpoints sharing a priority are used in list order.
+type Endpoint struct {
+	URL      string
+	Priority int
+}
+
+// Selection is how GetClient chooses among healthy endpoints sharing a
+// priority.
+type Selection string
+
+const (
+	// SelectionPriority prefers endpoints in list order: traffic returns to
+	// an earlier endpoint as soon as it is healthy again.
+	SelectionPriority Selection = "priority"
+	// SelectionRoundRobin keeps the current endpoint until it fails, then
+	// moves on to the next one in the list.
+	SelectionRoundRobin Selection = "round_robin"
+)
+
+// EndpointsFromURLs returns urls as endpoints of equal priority, so they are
+// preferred in list order.
+func EndpointsFromURLs(urls []string) []Endpoint {
+	endpoints := make([]Endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = Endpoint{URL: url}
+	}
+	return endpoints
+}
+
 type endpointStatus struct {
 	url           string
+	priority      int
 	client        *ethclient.Client
 	healthy       bool
 	lastError     error
 	lastErrorTime time.Time
+	latency       time.Duration // of the last successful eth_chainId call
+	failures      int           // consecutive failures since it last answered
+	firstFailure  time.Time     // of the failures counted in failures
+	halfOpen      bool          // reconnected, restored once a call succeeds
 	mu            sync.RWMutex
 }
 
+// succeeded records an eth_chainId call answered in latency. Callers must
+// hold ep.mu.
+func (ep *endpointStatus) succeeded(latency time.Duration) {
+	ep.latency = latency
+	ep.failures = 0
+}
+
+// failed records err as the latest failure of the endpoint, restarting its
+// cooldown. Callers must hold ep.mu.
+func (ep *endpointStatus) failed(err error) {
+	ep.lastError = err
+	ep.lastErrorTime = time.Now()
+	if ep.failures == 0 {
+		ep.firstFailure = ep.lastErrorTime
+	}
+	ep.failures++
+}
+
+// reconnect makes client the connection of the endpoint, half-open until a
+// call succeeds through it. Callers must hold ep.mu.
+func (ep *endpointStatus) reconnect(client *ethclient.Client, latency time.Duration) {
+	if ep.client != nil {
+		ep.client.Close()
+	}
+	ep.client = client
+	ep.healthy = true
+	ep.halfOpen = true
+	ep.lastError = nil
+	ep.succeeded(latency)
+}
+
+// EndpointStat is a snapshot of an RPC endpoint, as returned by Stats.
+type EndpointStat struct {
+	URL     string
+	Healthy bool
+	// Latency of the last successful eth_chainId call, 0 before the first
+	Latency time.Duration
+	// ConsecutiveFailures counts the failed calls since the endpoint last
+	// answered
+	ConsecutiveFailures int
+	// HalfOpen is set on a reconnected endpoint until a call succeeds: a
+	// failure takes it down again regardless of the failure threshold
+	HalfOpen  bool
+	LastError error
+}
+
+// FailoverRecorder is notified when the active RPC endpoint changes. It is
+// implemented by *metrics.Metrics.
+type FailoverRecorder interface {
+	RPCFailover(from, to string)
+}
+
+// ErrChainMismatch is the error of an endpoint serving another chain than
+// the expected one.
+var ErrChainMismatch = errors.New("RPC endpoint serves another chain")
+
 // FailoverClient manages multiple RPC endpoints with automatic failover
 type FailoverClient struct {
-	endpoints    []*endpointStatus
-	currentIndex int
-	mu           sync.RWMutex
+	endpoints        []*endpointStatus
+	currentIndex     int
+	active           string // URL last handed out by GetClient
+	chainID          uint64 // chain every endpoint must serve, 0 when unchecked
+	selection        Selection
+	recorder         FailoverRecorder
+	failureThreshold int           // DefaultFailureThreshold when not positive
+	failureWindow    time.Duration // DefaultFailureWindow when not positive
+	closed           bool
+	mu               sync.RWMutex
 }
 
-// NewFailoverClient creates a new failover client with multiple endpoints
-func NewFailoverClient(urls []string) (*FailoverClient, error) {
-	if len(urls) == 0 {
+// NewFailoverClient creates a new failover client with multiple endpoints.
+// Every endpoint must serve chainID, or when it is 0 the chain served by
+// most endpoints (the preferred one on a tie); endpoints serving another
+// chain are marked unhealthy with ErrChainMismatch, and reconnections are
+// checked the same way.
+func NewFailoverClient(endpoints []Endpoint, chainID uint64) (*FailoverClient, error) {
+	if len(endpoints) == 0 {
 		return nil, fmt.Errorf("at least one RPC URL is required")
 	}
 
 	fc := &FailoverClient{
-		endpoints:    make([]*endpointStatus, 0, len(urls)),
+		endpoints:    make([]*endpointStatus, 0, len(endpoints)),
 		currentIndex: 0,
 	}
 
 	// Initialize all endpoints
 	healthyCount := 0
-	for _, url := range urls {
-		client, err := ethclient.Dial(url)
+	chainIDs := make([]uint64, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		url := endpoint.URL
+		client, err := dial(url)
 
 		// Verify connection with test call
-		var chainIDErr error
+		var id uint64
+		var latency time.Duration
 		if err == nil {
-			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
-			_, chainIDErr = client.ChainID(ctx)
-			cancel()
-
-			if chainIDErr != nil {
+			id, latency, err = readChainID(client)
+			if err != nil {
 				client.Close()
 				client = nil
-				err = chainIDErr
 			}
 		}
+		chainIDs = append(chainIDs, id)
 
 		ep := &endpointStatus{
 			url:           url,
+			priority:      endpoint.Priority,
 			client:        client,
 			healthy:       err == nil,
 			lastError:     err,
 			lastErrorTime: time.Now(),
+			latency:       latency,
+		}
+		if err != nil {
+			ep.failures = 1
 		}
 
 		fc.endpoints = append(fc.endpoints, ep)
@@ -79,6 +201,25 @@ func NewFailoverClient(urls []string) (*FailoverClient, error) {
 		}
 	}
 
+	// Endpoints must all serve the same chain
+	fc.chainID = chainID
+	if fc.chainID == 0 {
+		fc.chainID = fc.majorityChainID(chainIDs)
+	}
+	for i, ep := range fc.endpoints {
+		if !ep.healthy || chainIDs[i] == fc.chainID {
+			continue
+		}
+		err := fmt.Errorf("%w: chain ID %d, expected %d", ErrChainMismatch, chainIDs[i], fc.chainID)
+		ep.client.Close()
+		ep.client = nil
+		ep.healthy = false
+		ep.lastError = err
+		ep.failures = 1
+		healthyCount--
+		slog.Error("RPC endpoint disabled", "url", ep.url, "error", err)
+	}
+
 	// At least one endpoint must be healthy
 	if healthyCount == 0 {
 		return nil, fmt.Errorf("no healthy RPC endpoints available")
@@ -87,16 +228,60 @@ func NewFailoverClient(urls []string) (*FailoverClient, error) {
 	return fc, nil
 }
 
-// GetClient returns a healthy client, automatically failing over if needed
+// readChainID returns the chain ID served by client and the latency of the
+// call.
+func readChainID(client *ethclient.Client) (uint64, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	id, err := client.ChainID(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return id.Uint64(), time.Since(start), nil
+}
+
+// majorityChainID returns the chain ID reported by most healthy endpoints,
+// ids being indexed like fc.endpoints; ties go to the preferred endpoint.
+func (fc *FailoverClient) majorityChainID(ids []uint64) uint64 {
+	counts := make(map[uint64]int)
+	for i, ep := range fc.endpoints {
+		if ep.healthy {
+			counts[ids[i]]++
+		}
+	}
+	var best uint64
+	for _, idx := range fc.candidateOrder() {
+		if id := ids[idx]; fc.endpoints[idx].healthy && counts[id] > counts[best] {
+			best = id
+		}
+	}
+	return best
+}
+
+// checkChain returns an error when client does not serve fc.chainID, or
+// else the latency of the check.
+func (fc *FailoverClient) checkChain(client *ethclient.Client) (time.Duration, error) {
+	id, latency, err := readChainID(client)
+	if err != nil {
+		return 0, err
+	}
+	if fc.chainID != 0 && id != fc.chainID {
+		return 0, fmt.Errorf("%w: chain ID %d, expected %d", ErrChainMismatch, id, fc.chainID)
+	}
+	return latency, nil
+}
+
+// GetClient returns a healthy client, automatically failing over if needed.
+// The healthy endpoint with the lowest priority wins, so traffic returns to a
+// preferred endpoint once it reconnects; among equal priorities the first
+// listed wins, or with SelectionRoundRobin the current endpoint is kept until
+// it fails.
 func (fc *FailoverClient) GetClient() (*ethclient.Client, string, error) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 
-	startIndex := fc.currentIndex
-
-	// Try all endpoints in round-robin
-	for i := 0; i < len(fc.endpoints); i++ {
-		idx := (startIndex + i) % len(fc.endpoints)
+	for _, idx := range fc.candidateOrder() {
 		ep := fc.endpoints[idx]
 
 		ep.mu.RLock()
@@ -108,64 +293,312 @@ func (fc *FailoverClient) GetClient() (*ethclient.Client, string, error) {
 
 		// Use healthy endpoint
 		if healthy && client != nil {
-			fc.currentIndex = idx
+			fc.activate(idx)
 			return client, url, nil
 		}
 
 		// Try to reconnect unhealthy endpoint if cooldown expired
 		if !healthy && canRetry {
-			if newClient, err := ethclient.Dial(ep.url); err == nil {
-				// Verify with a test call
-				ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
-				_, chainErr := newClient.ChainID(ctx)
-				cancel()
-
-				if chainErr == nil {
+			newClient, err := dial(ep.url)
+			if err == nil {
+				// Verify with a test call, still on the expected chain
+				var latency time.Duration
+				latency, err = fc.checkChain(newClient)
+				if err == nil {
 					ep.mu.Lock()
-					if ep.client != nil {
-						ep.client.Close()
-					}
-					ep.client = newClient
-					ep.healthy = true
-					ep.lastError = nil
+					ep.reconnect(newClient, latency)
 					ep.mu.Unlock()
 
-					fc.currentIndex = idx
 					slog.Info("Reconnected to RPC endpoint", "url", ep.url)
+					fc.activate(idx)
 					return newClient, url, nil
-				} else {
-					newClient.Close()
 				}
+				newClient.Close()
 			}
+
+			// Restart the cooldown so a preferred endpoint that is still
+			// down is not redialled on every call
+			ep.mu.Lock()
+			ep.failed(err)
+			ep.mu.Unlock()
 		}
 	}
 
 	return nil, "", fmt.Errorf("no healthy RPC endpoints available")
 }
 
-// MarkUnhealthy marks an endpoint as unhealthy and closes its connection
+// GetAlternateClient returns a healthy client other than the active one,
+// without making it active, so a result can be double-checked against
+// another node. It falls back to GetClient when no other endpoint is healthy.
+func (fc *FailoverClient) GetAlternateClient() (*ethclient.Client, string, error) {
+	fc.mu.RLock()
+	for _, idx := range fc.candidateOrder() {
+		ep := fc.endpoints[idx]
+		ep.mu.RLock()
+		healthy, client, url := ep.healthy, ep.client, ep.url
+		ep.mu.RUnlock()
+		if healthy && client != nil && url != fc.active {
+			fc.mu.RUnlock()
+			return client, url, nil
+		}
+	}
+	fc.mu.RUnlock()
+	return fc.GetClient()
+}
+
+// endpointClient is the client of a healthy endpoint and its URL.
+type endpointClient struct {
+	url    string
+	client *ethclient.Client
+}
+
+// healthyClients returns the clients of every healthy endpoint, in
+// selection order, without changing the active one.
+func (fc *FailoverClient) healthyClients() []endpointClient {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	var clients []endpointClient
+	for _, idx := range fc.candidateOrder() {
+		ep := fc.endpoints[idx]
+		ep.mu.RLock()
+		if ep.healthy && ep.client != nil {
+			clients = append(clients, endpointClient{url: ep.url, client: ep.client})
+		}
+		ep.mu.RUnlock()
+	}
+	return clients
+}
+
+// activate makes the endpoint at idx the current one, reporting a failover
+// when it replaces another endpoint. Callers must hold fc.mu.
+func (fc *FailoverClient) activate(idx int) {
+	fc.currentIndex = idx
+	to := fc.endpoints[idx].url
+	from := fc.active
+	fc.active = to
+	if from == "" || from == to {
+		return
+	}
+
+	slog.Warn("RPC failover", "event", "rpc_failover", "from", from, "to", to)
+	if fc.recorder != nil {
+		fc.recorder.RPCFailover(from, to)
+	}
+}
+
+// SetSelection sets how endpoints sharing a priority are chosen
+// (SelectionPriority by default).
+func (fc *FailoverClient) SetSelection(s Selection) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.selection = s
+}
+
+// SetCircuitBreaker sets how many consecutive failed calls, within window of
+// the first one, take an endpoint down. A threshold or window that is not
+// positive is replaced by its default (DefaultFailureThreshold,
+// DefaultFailureWindow).
+func (fc *FailoverClient) SetCircuitBreaker(threshold int, window time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.failureThreshold = threshold
+	fc.failureWindow = window
+}
+
+// breaker returns the circuit breaker settings. Callers must hold fc.mu.
+func (fc *FailoverClient) breaker() (int, time.Duration) {
+	threshold, window := fc.failureThreshold, fc.failureWindow
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+	if window <= 0 {
+		window = DefaultFailureWindow
+	}
+	return threshold, window
+}
+
+// SetRecorder sets where endpoint switches are reported.
+func (fc *FailoverClient) SetRecorder(r FailoverRecorder) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.recorder = r
+}
+
+// candidateOrder returns endpoint indexes in the order GetClient tries them:
+// by priority, then in list order, or round-robin from the current endpoint
+// with SelectionRoundRobin.
+func (fc *FailoverClient) candidateOrder() []int {
+	start := 0
+	if fc.selection == SelectionRoundRobin {
+		start = fc.currentIndex
+	}
+	order := make([]int, len(fc.endpoints))
+	for i := range order {
+		order[i] = (start + i) % len(fc.endpoints)
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(fc.endpoints[a].priority, fc.endpoints[b].priority)
+	})
+	return order
+}
+
+// StartHealthChecker probes the unhealthy endpoints every interval
+// (DefaultProbeInterval when not positive) and reconnects those answering on
+// the expected chain without waiting for their cooldown, so a recovered
+// endpoint is warm again, and a preferred one takes the traffic back, within
+// an interval. It returns once ctx is done or the client is closed.
+func (fc *FailoverClient) StartHealthChecker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !fc.probeUnhealthy() {
+				return
+			}
+		}
+	}
+}
+
+// probeUnhealthy redials every unhealthy endpoint once. It returns false when
+// the client is closed. Dialing happens without holding fc.mu, so calls keep
+// being served by the healthy endpoints meanwhile.
+func (fc *FailoverClient) probeUnhealthy() bool {
+	fc.mu.RLock()
+	closed := fc.closed
+	fc.mu.RUnlock()
+	if closed {
+		return false
+	}
+
+	// fc.endpoints itself never changes after construction
+	for _, ep := range fc.endpoints {
+		ep.mu.RLock()
+		healthy := ep.healthy
+		ep.mu.RUnlock()
+		if healthy {
+			continue
+		}
+
+		client, err := dial(ep.url)
+		var latency time.Duration
+		if err == nil {
+			if latency, err = fc.checkChain(client); err != nil {
+				client.Close()
+			}
+		}
+		if !fc.reconnected(ep, client, latency, err) {
+			return false
+		}
+	}
+	return true
+}
+
+// reconnected records the outcome of probing ep: client becomes its
+// connection when err is nil, otherwise its cooldown restarts. It returns
+// false, closing client, when fc was closed during the probe.
+func (fc *FailoverClient) reconnected(ep *endpointStatus, client *ethclient.Client, latency time.Duration, err error) bool {
+	// Holding fc.mu keeps Close from running until the endpoint is updated
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	if fc.closed {
+		if err == nil {
+			client.Close()
+		}
+		return false
+	}
+
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if err != nil {
+		ep.failed(err)
+		slog.Debug("RPC endpoint still down", "url", ep.url, "error", err)
+		return true
+	}
+	if ep.healthy {
+		// GetClient reconnected it during the probe
+		client.Close()
+		return true
+	}
+	ep.reconnect(client, latency)
+	slog.Info("Reconnected to RPC endpoint", "url", ep.url, "via", "health_check")
+	return true
+}
+
+// MarkUnhealthy records a failed call to an endpoint. Once the failure
+// threshold is reached within the failure window, or on the first failure of
+// a half-open endpoint, the endpoint is marked unhealthy and its connection
+// closed; until then it keeps serving calls.
 func (fc *FailoverClient) MarkUnhealthy(url string, err error) {
 	fc.mu.RLock()
 	defer fc.mu.RUnlock()
 
+	threshold, window := fc.breaker()
 	for _, ep := range fc.endpoints {
-		if ep.url == url {
-			ep.mu.Lock()
+		if ep.url != url {
+			continue
+		}
+
+		ep.mu.Lock()
+		// Failures spread over more than the window are blips, not an outage
+		if ep.healthy && ep.failures > 0 && time.Since(ep.firstFailure) > window {
+			ep.failures = 0
+		}
+		ep.failed(err)
+		failures := ep.failures
+		trip := !ep.healthy || ep.halfOpen || failures >= threshold
+		if trip {
 			ep.healthy = false
-			ep.lastError = err
-			ep.lastErrorTime = time.Now()
+			ep.halfOpen = false
 			if ep.client != nil {
 				ep.client.Close()
 				ep.client = nil
 			}
-			ep.mu.Unlock()
+		}
+		ep.mu.Unlock()
 
-			slog.Warn("Marked RPC endpoint as unhealthy, will retry after cooldown",
+		if !trip {
+			slog.Warn("RPC endpoint call failed, keeping it until the failure threshold",
 				"url", url,
 				"error", err,
-				"retry_after", unhealthyDuration)
+				"failures", failures,
+				"threshold", threshold)
 			return
 		}
+		slog.Warn("Marked RPC endpoint as unhealthy, will retry after cooldown",
+			"url", url,
+			"error", err,
+			"failures", failures,
+			"retry_after", unhealthyDuration)
+		return
+	}
+}
+
+// MarkSucceeded records a call answered by an endpoint: its consecutive
+// failures reset, and a half-open endpoint is fully restored.
+func (fc *FailoverClient) MarkSucceeded(url string) {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	for _, ep := range fc.endpoints {
+		if ep.url != url {
+			continue
+		}
+		ep.mu.Lock()
+		restored := ep.halfOpen && ep.healthy
+		ep.failures = 0
+		ep.halfOpen = false
+		ep.mu.Unlock()
+
+		if restored {
+			slog.Info("RPC endpoint restored after a successful call", "url", url)
+		}
+		return
 	}
 }
 
@@ -174,6 +607,7 @@ func (fc *FailoverClient) Close() {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 
+	fc.closed = true
 	for _, ep := range fc.endpoints {
 		ep.mu.Lock()
 		if ep.client != nil {
@@ -198,3 +632,53 @@ func (fc *FailoverClient) GetEndpointsHealth() map[string]bool {
 
 	return health
 }
+
+// Stats returns a snapshot of every endpoint, in configuration order.
+func (fc *FailoverClient) Stats() []EndpointStat {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	stats := make([]EndpointStat, 0, len(fc.endpoints))
+	for _, ep := range fc.endpoints {
+		ep.mu.RLock()
+		stats = append(stats, EndpointStat{
+			URL:                 ep.url,
+			Healthy:             ep.healthy,
+			Latency:             ep.latency,
+			ConsecutiveFailures: ep.failures,
+			HalfOpen:            ep.halfOpen,
+			LastError:           ep.lastError,
+		})
+		ep.mu.RUnlock()
+	}
+	return stats
+}
+
+// Ping calls eth_chainId on the active endpoint and records its latency, or
+// the failure, in Stats. It returns the endpoint URL. A failure does not mark
+// the endpoint unhealthy: calls made through the client decide that.
+func (fc *FailoverClient) Ping(ctx context.Context) (string, error) {
+	client, url, err := fc.GetClient()
+	if err != nil {
+		return "", err
+	}
+	start := time.Now()
+	_, err = client.ChainID(ctx)
+	latency := time.Since(start)
+
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	for _, ep := range fc.endpoints {
+		if ep.url != url {
+			continue
+		}
+		ep.mu.Lock()
+		if err != nil {
+			ep.failed(err)
+		} else {
+			ep.succeeded(latency)
+		}
+		ep.mu.Unlock()
+	}
+	return url, err
+}
@@ -1,7 +1,13 @@
 package blockchain
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
 	"testing"
 	"time"
 
@@ -247,17 +253,592 @@ func TestClose_SetsClientToNil(t *testing.T) {
 	assert.Nil(t, ep.client)
 }
 
+func withPriority(ep *endpointStatus, priority int) *endpointStatus {
+	ep.priority = priority
+	return ep
+}
+
+func TestGetClient_PrefersLowestPriority(t *testing.T) {
+	public := withPriority(healthyEP("https://public.example.com"), 10)
+	paid := withPriority(healthyEP("https://paid.example.com"), 0)
+	fc := buildFC([]*endpointStatus{public, paid})
+
+	_, url, err := fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://paid.example.com", url, "list position does not matter")
+
+	// Sticking to the current endpoint only applies within a priority
+	fc.currentIndex = 0
+	_, url, err = fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://paid.example.com", url)
+}
+
+func TestGetClient_PriorityFallbackWhenPrimaryDown(t *testing.T) {
+	primary := withPriority(unhealthyEP("https://paid.example.com"), 0)
+	backup1 := withPriority(healthyEP("https://backup1.example.com"), 5)
+	backup2 := withPriority(healthyEP("https://backup2.example.com"), 5)
+	fc := buildFC([]*endpointStatus{backup2, primary, backup1})
+
+	_, url, err := fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://backup2.example.com", url, "equal priorities keep list order")
+
+	// Even when another backup is current
+	fc.currentIndex = 2
+	_, url, err = fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://backup2.example.com", url)
+
+	// With round-robin the current backup stays in use while it is healthy
+	fc.SetSelection(SelectionRoundRobin)
+	fc.currentIndex = 2
+	_, url, err = fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://backup1.example.com", url)
+}
+
+func TestGetClient_SelectionAfterRecovery(t *testing.T) {
+	tests := []struct {
+		selection Selection
+		want      string
+	}{
+		{SelectionPriority, "https://rpc1.example.com"},
+		{SelectionRoundRobin, "https://rpc2.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.selection), func(t *testing.T) {
+			first := unhealthyEP("https://rpc1.example.com")
+			fc := buildFC([]*endpointStatus{first, healthyEP("https://rpc2.example.com")})
+			fc.SetSelection(tt.selection)
+
+			_, url, err := fc.GetClient()
+			require.NoError(t, err)
+			require.Equal(t, "https://rpc2.example.com", url)
+
+			// The first endpoint recovers, e.g. through the health checker
+			first.healthy, first.client = true, fakeEthClient()
+			_, url, err = fc.GetClient()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, url)
+		})
+	}
+}
+
+func TestGetClient_FailedReconnectRestartsCooldown(t *testing.T) {
+	primary := withPriority(unhealthyEP("http://127.0.0.1:1"), 0)
+	primary.lastErrorTime = time.Now().Add(-unhealthyDuration - time.Second)
+	backup := withPriority(healthyEP("https://backup.example.com"), 1)
+	fc := buildFC([]*endpointStatus{primary, backup})
+
+	_, url, err := fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://backup.example.com", url)
+
+	primary.mu.RLock()
+	defer primary.mu.RUnlock()
+	assert.False(t, primary.healthy)
+	assert.WithinDuration(t, time.Now(), primary.lastErrorTime, time.Minute, "cooldown restarted")
+}
+
 // --- NewFailoverClient (error paths only) ---
 
 func TestNewFailoverClient_EmptyURLs_ReturnsError(t *testing.T) {
-	_, err := NewFailoverClient([]string{})
+	_, err := NewFailoverClient([]Endpoint{}, 0)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "at least one RPC URL")
 }
 
 func TestNewFailoverClient_AllUnreachable_ReturnsError(t *testing.T) {
 	// Use addresses that will fail to connect immediately.
-	_, err := NewFailoverClient([]string{"http://127.0.0.1:1", "http://127.0.0.1:2"})
+	_, err := NewFailoverClient(EndpointsFromURLs([]string{"http://127.0.0.1:1", "http://127.0.0.1:2"}), 0)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no healthy RPC endpoints available")
 }
+
+// chainServer serves eth_chainId as chain id over JSON-RPC.
+func chainServer(t *testing.T, id uint64) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x%x"}`, req.ID, id)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestNewFailoverClient_ChainMismatch(t *testing.T) {
+	gnosis1, gnosis2, mainnet := chainServer(t, 100), chainServer(t, 100), chainServer(t, 1)
+
+	tests := []struct {
+		name        string
+		endpoints   []Endpoint
+		chainID     uint64
+		wantChain   uint64
+		wantHealthy map[string]bool
+	}{
+		{
+			name:        "outvoted endpoint disabled",
+			endpoints:   EndpointsFromURLs([]string{mainnet, gnosis1, gnosis2}),
+			wantChain:   100,
+			wantHealthy: map[string]bool{mainnet: false, gnosis1: true, gnosis2: true},
+		},
+		{
+			name:        "tie goes to the preferred endpoint",
+			endpoints:   []Endpoint{{URL: mainnet, Priority: 1}, {URL: gnosis1, Priority: 0}},
+			wantChain:   100,
+			wantHealthy: map[string]bool{mainnet: false, gnosis1: true},
+		},
+		{
+			name:        "configured chain wins over the majority",
+			endpoints:   EndpointsFromURLs([]string{gnosis1, gnosis2, mainnet}),
+			chainID:     1,
+			wantChain:   1,
+			wantHealthy: map[string]bool{mainnet: true, gnosis1: false, gnosis2: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, err := NewFailoverClient(tt.endpoints, tt.chainID)
+			require.NoError(t, err)
+			t.Cleanup(fc.Close)
+
+			assert.Equal(t, tt.wantChain, fc.chainID)
+			assert.Equal(t, tt.wantHealthy, fc.GetEndpointsHealth())
+			for _, ep := range fc.endpoints {
+				if !ep.healthy {
+					assert.ErrorIs(t, ep.lastError, ErrChainMismatch)
+				}
+			}
+			_, url, err := fc.GetClient()
+			require.NoError(t, err)
+			assert.True(t, tt.wantHealthy[url], "only endpoints on the chain serve calls")
+		})
+	}
+
+	t.Run("no endpoint on the configured chain", func(t *testing.T) {
+		_, err := NewFailoverClient(EndpointsFromURLs([]string{gnosis1, gnosis2}), 1)
+		require.Error(t, err)
+	})
+}
+
+func TestGetClient_ReconnectChecksChain(t *testing.T) {
+	mainnet := chainServer(t, 1)
+	ep := &endpointStatus{url: mainnet, lastErrorTime: time.Now().Add(-2 * unhealthyDuration)}
+	fc := buildFC([]*endpointStatus{ep})
+	fc.chainID = 100
+
+	_, _, err := fc.GetClient()
+	require.Error(t, err)
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	assert.False(t, ep.healthy)
+	assert.ErrorIs(t, ep.lastError, ErrChainMismatch)
+}
+
+//--- StartHealthChecker ---
+
+func TestProbeUnhealthy_ReconnectsDuringCooldown(t *testing.T) {
+	gnosis, mainnet := chainServer(t, 100), chainServer(t, 1)
+	recovered := &endpointStatus{url: gnosis, lastError: errors.New("connection refused"), lastErrorTime: time.Now()}
+	wrongChain := &endpointStatus{url: mainnet, lastErrorTime: time.Now()}
+	fc := buildFC([]*endpointStatus{recovered, wrongChain})
+	fc.chainID = 100
+	t.Cleanup(fc.Close)
+
+	require.True(t, fc.probeUnhealthy())
+
+	assert.Equal(t, map[string]bool{gnosis: true, mainnet: false}, fc.GetEndpointsHealth())
+	assert.NoError(t, recovered.lastError)
+	assert.ErrorIs(t, wrongChain.lastError, ErrChainMismatch)
+	_, url, err := fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, gnosis, url)
+}
+
+func TestStartHealthChecker_StopsWhenClosed(t *testing.T) {
+	ep := &endpointStatus{url: chainServer(t, 100), lastErrorTime: time.Now()}
+	fc := buildFC([]*endpointStatus{ep})
+	fc.Close()
+
+	done := make(chan struct{})
+	go func() {
+		fc.StartHealthChecker(context.Background(), time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health checker still running after Close")
+	}
+	assert.False(t, fc.GetEndpointsHealth()[ep.url], "a closed client is not reconnected")
+}
+
+func TestStartHealthChecker_StopsWithContext(t *testing.T) {
+	fc := buildFC([]*endpointStatus{healthyEP("https://rpc.example.com")})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fc.StartHealthChecker(ctx, time.Hour)
+}
+
+//--- Stats ---
+
+func TestStats_TracksLatencyAndFailures(t *testing.T) {
+	gnosis := chainServer(t, 100)
+	fc, err := NewFailoverClient(EndpointsFromURLs([]string{gnosis, "http://127.0.0.1:1"}), 0)
+	require.NoError(t, err)
+	t.Cleanup(fc.Close)
+
+	stats := fc.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, gnosis, stats[0].URL)
+	assert.True(t, stats[0].Healthy)
+	assert.Positive(t, stats[0].Latency, "measured by the startup chain ID check")
+	assert.Zero(t, stats[0].ConsecutiveFailures)
+	assert.False(t, stats[1].Healthy)
+	assert.Equal(t, 1, stats[1].ConsecutiveFailures)
+	assert.Error(t, stats[1].LastError)
+
+	fc.MarkUnhealthy(gnosis, errors.New("connection reset"))
+	fc.MarkUnhealthy(gnosis, errors.New("connection reset"))
+	assert.Equal(t, 2, fc.Stats()[0].ConsecutiveFailures)
+
+	require.True(t, fc.probeUnhealthy())
+	stats = fc.Stats()
+	assert.True(t, stats[0].Healthy)
+	assert.Zero(t, stats[0].ConsecutiveFailures, "reset once the endpoint answers")
+	assert.NoError(t, stats[0].LastError)
+	assert.Equal(t, 2, stats[1].ConsecutiveFailures, "each failed probe counts")
+
+	url, err := fc.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, gnosis, url)
+	assert.Positive(t, fc.Stats()[0].Latency)
+}
+
+//--- retryWithBackoff ---
+
+func TestRetryWithBackoff_CancellationKeepsEndpointHealthy(t *testing.T) {
+	ep := healthyEP("https://rpc.example.com")
+	c := &Client{failoverClient: buildFC([]*endpointStatus{ep})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := c.retryWithBackoff(ctx, func() error {
+		calls++
+		return fmt.Errorf("balanceOf: %w", context.Canceled)
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls, "a shutdown is not retried")
+	assert.True(t, c.failoverClient.GetEndpointsHealth()["https://rpc.example.com"])
+}
+
+func TestRetryWithBackoff_HonorsMaxRetries(t *testing.T) {
+	for _, maxRetries := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("%d retries", maxRetries), func(t *testing.T) {
+			c := &Client{
+				failoverClient: buildFC([]*endpointStatus{dialedEP(t, "http://127.0.0.1:1")}),
+				rpc:            ClientConfig{Timeout: time.Second, MaxRetries: maxRetries, RetryInterval: time.Millisecond},
+			}
+			defer c.Close()
+
+			calls := 0
+			err := c.retryWithBackoff(context.Background(), func() error {
+				calls++
+				return syscall.ECONNRESET
+			})
+
+			require.Error(t, err)
+			assert.Equal(t, maxRetries+1, calls)
+		})
+	}
+}
+
+func TestNewClient_DefaultsUnsetSettings(t *testing.T) {
+	assert.Equal(t, DefaultClientConfig(), withClientDefaults(ClientConfig{MaxRetries: -1}))
+
+	custom := ClientConfig{Timeout: 30 * time.Second, MaxRetries: 0, RetryInterval: time.Second}
+	assert.Equal(t, custom, withClientDefaults(custom), "zero retries is kept")
+}
+
+// countingRecorder is a RetryRecorder counting calls per endpoint.
+type countingRecorder struct {
+	attempts, succeeded, exhausted map[string]int
+}
+
+func newCountingRecorder() *countingRecorder {
+	return &countingRecorder{attempts: map[string]int{}, succeeded: map[string]int{}, exhausted: map[string]int{}}
+}
+
+func (r *countingRecorder) RPCAttempt(endpoint string)          { r.attempts[endpoint]++ }
+func (r *countingRecorder) RPCRetrySucceeded(endpoint string)   { r.succeeded[endpoint]++ }
+func (r *countingRecorder) RPCRetriesExhausted(endpoint string) { r.exhausted[endpoint]++ }
+
+// dialedEP builds a healthy endpoint with a lazily connected HTTP client,
+// which unlike fakeEthClient can be closed by MarkUnhealthy.
+func dialedEP(t *testing.T, url string) *endpointStatus {
+	t.Helper()
+	client, err := ethclient.Dial(url)
+	require.NoError(t, err)
+	return &endpointStatus{url: url, client: client, healthy: true}
+}
+
+func TestRetryWithBackoff_RecordsOutcome(t *testing.T) {
+	const primary, backup = "http://127.0.0.1:1", "http://127.0.0.1:2"
+
+	tests := []struct {
+		name          string
+		failures      int
+		wantAttempts  map[string]int
+		wantSucceeded map[string]int
+		wantExhausted map[string]int
+	}{
+		{
+			name:          "first attempt succeeds",
+			failures:      0,
+			wantAttempts:  map[string]int{primary: 1},
+			wantSucceeded: map[string]int{},
+			wantExhausted: map[string]int{},
+		},
+		{
+			name:          "succeeds on retry after failover",
+			failures:      1,
+			wantAttempts:  map[string]int{primary: 1, backup: 1},
+			wantSucceeded: map[string]int{backup: 1},
+			wantExhausted: map[string]int{},
+		},
+		{
+			name:     "every attempt fails",
+			failures: DefaultMaxRetries + 1,
+			// Both endpoints are down by the third attempt
+			wantAttempts:  map[string]int{primary: 1, backup: 1, "": 1},
+			wantSucceeded: map[string]int{},
+			wantExhausted: map[string]int{backup: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newCountingRecorder()
+			c := &Client{failoverClient: buildFC([]*endpointStatus{dialedEP(t, primary), dialedEP(t, backup)})}
+			c.SetRetryRecorder(rec)
+			defer c.Close()
+
+			calls := 0
+			err := c.retryWithBackoff(context.Background(), func() error {
+				calls++
+				if calls <= tt.failures {
+					return syscall.ECONNRESET
+				}
+				return nil
+			})
+
+			if tt.failures > DefaultMaxRetries {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempts, rec.attempts)
+			assert.Equal(t, tt.wantSucceeded, rec.succeeded)
+			assert.Equal(t, tt.wantExhausted, rec.exhausted)
+		})
+	}
+}
+
+// switchRecorder is a FailoverRecorder collecting switches as "from>to".
+type switchRecorder struct{ switches []string }
+
+func (r *switchRecorder) RPCFailover(from, to string) { r.switches = append(r.switches, from+">"+to) }
+
+func TestGetClient_ReportsFailoverOncePerSwitch(t *testing.T) {
+	primary := healthyEP("https://primary.example.com")
+	backup := healthyEP("https://backup.example.com")
+	fc := buildFC([]*endpointStatus{primary, backup})
+	rec := &switchRecorder{}
+	fc.SetRecorder(rec)
+
+	// Settling on the first endpoint is not a failover
+	_, url, err := fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://primary.example.com", url)
+	_, _, _ = fc.GetClient()
+	assert.Empty(t, rec.switches)
+
+	// The primary goes down: one switch, however many calls follow
+	primary.mu.Lock()
+	primary.healthy = false
+	primary.client = nil
+	primary.lastErrorTime = time.Now()
+	primary.mu.Unlock()
+	for range 3 {
+		_, url, err = fc.GetClient()
+		require.NoError(t, err)
+		assert.Equal(t, "https://backup.example.com", url)
+	}
+	assert.Equal(t, []string{"https://primary.example.com>https://backup.example.com"}, rec.switches)
+
+	// It recovers and, preferred again, takes the traffic back
+	primary.mu.Lock()
+	primary.healthy = true
+	primary.client = fakeEthClient()
+	primary.mu.Unlock()
+	primary.priority = -1
+	_, url, err = fc.GetClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://primary.example.com", url)
+	assert.Equal(t, []string{
+		"https://primary.example.com>https://backup.example.com",
+		"https://backup.example.com>https://primary.example.com",
+	}, rec.switches)
+}
+
+func TestGetAlternateClient(t *testing.T) {
+	primary := healthyEP("https://primary.example.com")
+	backup := healthyEP("https://backup.example.com")
+	fc := buildFC([]*endpointStatus{primary, backup})
+	rec := &switchRecorder{}
+	fc.SetRecorder(rec)
+
+	_, url, err := fc.GetClient()
+	require.NoError(t, err)
+	require.Equal(t, "https://primary.example.com", url)
+
+	// Another healthy endpoint is used without becoming the active one
+	client, url, err := fc.GetAlternateClient()
+	require.NoError(t, err)
+	assert.Equal(t, backup.client, client)
+	assert.Equal(t, "https://backup.example.com", url)
+	_, url, _ = fc.GetClient()
+	assert.Equal(t, "https://primary.example.com", url)
+	assert.Empty(t, rec.switches)
+
+	// With no other healthy endpoint the active one is reused
+	backup.mu.Lock()
+	backup.healthy = false
+	backup.client = nil
+	backup.lastErrorTime = time.Now()
+	backup.mu.Unlock()
+	_, url, err = fc.GetAlternateClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://primary.example.com", url)
+}
+
+// --- Circuit breaker ---
+
+func TestMarkUnhealthy_FailureThreshold(t *testing.T) {
+	ep := &endpointStatus{url: "https://rpc.example.com", healthy: true}
+	fc := buildFC([]*endpointStatus{ep})
+	fc.SetCircuitBreaker(3, time.Minute)
+
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	assert.True(t, ep.healthy, "below the threshold")
+
+	fc.MarkSucceeded(ep.url)
+	assert.Zero(t, ep.failures, "a successful call resets the count")
+
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	assert.True(t, ep.healthy)
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	assert.False(t, ep.healthy, "down at the threshold")
+	assert.Equal(t, 3, ep.failures)
+}
+
+func TestMarkUnhealthy_FailuresOutsideWindow(t *testing.T) {
+	ep := &endpointStatus{url: "https://rpc.example.com", healthy: true}
+	fc := buildFC([]*endpointStatus{ep})
+	fc.SetCircuitBreaker(2, time.Minute)
+
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	ep.firstFailure = time.Now().Add(-2 * time.Minute)
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	assert.True(t, ep.healthy, "the first failure fell out of the window")
+	assert.Equal(t, 1, ep.failures)
+
+	fc.MarkUnhealthy(ep.url, errors.New("timeout"))
+	assert.False(t, ep.healthy)
+}
+
+func TestMarkUnhealthy_HalfOpen(t *testing.T) {
+	gnosis := chainServer(t, 100)
+	fc, err := NewFailoverClient(EndpointsFromURLs([]string{gnosis}), 0)
+	require.NoError(t, err)
+	t.Cleanup(fc.Close)
+	fc.SetCircuitBreaker(3, time.Minute)
+
+	for range 3 {
+		fc.MarkUnhealthy(gnosis, errors.New("connection reset"))
+	}
+	require.False(t, fc.Stats()[0].Healthy)
+
+	require.True(t, fc.probeUnhealthy())
+	stats := fc.Stats()
+	assert.True(t, stats[0].Healthy)
+	assert.True(t, stats[0].HalfOpen, "reconnected but not yet trusted")
+
+	fc.MarkUnhealthy(gnosis, errors.New("connection reset"))
+	assert.False(t, fc.Stats()[0].Healthy, "a half-open endpoint goes down on its first failure")
+
+	require.True(t, fc.probeUnhealthy())
+	fc.MarkSucceeded(gnosis)
+	assert.False(t, fc.Stats()[0].HalfOpen, "restored by a successful call")
+	fc.MarkUnhealthy(gnosis, errors.New("connection reset"))
+	assert.True(t, fc.Stats()[0].Healthy, "back under the failure threshold")
+}
+
+func TestRetryWithBackoff_StaysOnEndpointBelowThreshold(t *testing.T) {
+	const primary, backup = "http://127.0.0.1:1", "http://127.0.0.1:2"
+	fc := buildFC([]*endpointStatus{dialedEP(t, primary), dialedEP(t, backup)})
+	fc.SetCircuitBreaker(3, time.Minute)
+	c := &Client{
+		failoverClient: fc,
+		rpc:            ClientConfig{Timeout: time.Second, MaxRetries: 2, RetryInterval: time.Millisecond},
+	}
+	defer c.Close()
+
+	var urls []string
+	calls := 0
+	err := c.retryWithBackoff(context.Background(), func() error {
+		_, url, _ := fc.GetClient()
+		urls = append(urls, url)
+		if calls++; calls < 3 {
+			return syscall.ECONNRESET
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{primary, primary, primary}, urls, "no failover on a blip")
+	assert.Zero(t, fc.Stats()[0].ConsecutiveFailures)
+}
+
+func TestRetryWithBackoff_RateLimit(t *testing.T) {
+	c := &Client{failoverClient: buildFC([]*endpointStatus{healthyEP("https://rpc.example.com")})}
+	c.SetRateLimit(20)
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, c.retryWithBackoff(context.Background(), func() error { return nil }))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "3 calls at 20/s take 100ms")
+
+	c.SetRateLimit(0.001)
+	require.NoError(t, c.retryWithBackoff(context.Background(), func() error { return nil }), "the first call is not delayed")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := c.retryWithBackoff(ctx, func() error { calls++; return nil })
+	assert.ErrorIs(t, err, context.Canceled, "waiting gives up with the context")
+	assert.Zero(t, calls)
+	assert.True(t, c.failoverClient.GetEndpointsHealth()["https://rpc.example.com"])
+
+	c.SetRateLimit(0)
+	assert.Nil(t, c.limiter, "unlimited")
+}
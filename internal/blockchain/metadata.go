@@ -0,0 +1,158 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DecimalsPolicy controls which decimals are stored with each balance.
+type DecimalsPolicy string
+
+const (
+	// DecimalsCanonical keeps the first decimals successfully read on-chain
+	// for every later row of the token, so its scale never drifts.
+	DecimalsCanonical DecimalsPolicy = "canonical"
+	// DecimalsPerRow stores whatever each poll read, or the fallback when
+	// decimals() failed.
+	DecimalsPerRow DecimalsPolicy = "per_row"
+)
+
+// DefaultMaxDecimals is the largest decimals() value accepted by default.
+// No legitimate token uses more; a larger value usually means a wrong address.
+const DefaultMaxDecimals uint8 = 36
+
+// MaxDecimalsPolicy controls what happens when decimals() exceeds the bound.
+type MaxDecimalsPolicy string
+
+const (
+	// MaxDecimalsWarn logs the value and treats it like a failed decimals()
+	// call: the canonical value or the fallback is stored instead.
+	MaxDecimalsWarn MaxDecimalsPolicy = "warn"
+	// MaxDecimalsReject fails the balance query, so no row is stored.
+	MaxDecimalsReject MaxDecimalsPolicy = "reject"
+)
+
+// ErrDecimalsOutOfBounds is returned when a token reports more decimals than
+// the configured maximum.
+var ErrDecimalsOutOfBounds = errors.New("decimals out of bounds")
+
+// checkMaxDecimals returns ErrDecimalsOutOfBounds if read exceeds limit.
+func checkMaxDecimals(read, limit uint8) error {
+	if read > limit {
+		return fmt.Errorf("%w: %d > %d", ErrDecimalsOutOfBounds, read, limit)
+	}
+	return nil
+}
+
+// metadataCache remembers token metadata that is constant on-chain, so it is
+// read once per token rather than on every poll.
+type metadataCache struct {
+	mu       sync.Mutex
+	decimals map[common.Address]uint8
+	symbols  map[common.Address]string
+	names    map[common.Address]string
+}
+
+func newMetadataCache() *metadataCache {
+	return &metadataCache{
+		decimals: make(map[common.Address]uint8),
+		symbols:  make(map[common.Address]string),
+		names:    make(map[common.Address]string),
+	}
+}
+
+// cachedDecimals returns the canonical decimals of token, if one was read.
+func (m *metadataCache) cachedDecimals(token common.Address) (uint8, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.decimals[token]
+	return d, ok
+}
+
+// cachedSymbol returns the symbol of token, if one was read.
+func (m *metadataCache) cachedSymbol(token common.Address) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.symbols[token]
+	return s, ok
+}
+
+// setSymbol records the symbol read for token.
+func (m *metadataCache) setSymbol(token common.Address, symbol string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.symbols[token] = symbol
+}
+
+// cachedName returns the name of token, if one was read.
+func (m *metadataCache) cachedName(token common.Address) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.names[token]
+	return n, ok
+}
+
+// setName records the name read for token.
+func (m *metadataCache) setName(token common.Address, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.names[token] = name
+}
+
+// refreshDecimals replaces the canonical decimals of token with a good read,
+// logging when the value changed.
+func (m *metadataCache) refreshDecimals(token common.Address, label string, read uint8) uint8 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if canonical, known := m.decimals[token]; known && canonical != read {
+		slog.Warn("Token decimals refreshed to a new on-chain value",
+			"label", label,
+			"token_address", token.Hex(),
+			"previous_decimals", canonical,
+			"onchain_decimals", read)
+	}
+	m.decimals[token] = read
+	return read
+}
+
+// resolveDecimals returns the decimals to store for token, given the result
+// of this poll's decimals() call. Under DecimalsCanonical the first good value
+// wins: later failures reuse it instead of the fallback, and a different
+// on-chain value is logged and ignored.
+func (m *metadataCache) resolveDecimals(policy DecimalsPolicy, token common.Address, label string, read uint8, readErr error, fallback uint8) uint8 {
+	if policy == DecimalsPerRow {
+		if readErr != nil {
+			return fallback
+		}
+		return read
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	canonical, known := m.decimals[token]
+	switch {
+	case readErr != nil && known:
+		slog.Debug("decimals() failed, using canonical value",
+			"label", label, "decimals", canonical, "error", readErr)
+		return canonical
+	case readErr != nil:
+		slog.Warn("decimals() failed before any successful read, using fallback",
+			"label", label, "fallback_decimals", fallback, "error", readErr)
+		return fallback
+	case known && read != canonical:
+		slog.Error("Token decimals changed on-chain, keeping canonical value",
+			"label", label,
+			"token_address", token.Hex(),
+			"canonical_decimals", canonical,
+			"onchain_decimals", read)
+		return canonical
+	default:
+		m.decimals[token] = read
+		return read
+	}
+}
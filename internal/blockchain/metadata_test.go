@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// decimalsRead is the outcome of one decimals() call.
+type decimalsRead struct {
+	value uint8
+	err   error
+}
+
+var errDecimalsCall = errors.New("execution reverted")
+
+func resolveAll(m *metadataCache, policy DecimalsPolicy, reads []decimalsRead) []uint8 {
+	token := common.HexToAddress("0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1")
+	stored := make([]uint8, len(reads))
+	for i, r := range reads {
+		stored[i] = m.resolveDecimals(policy, token, "armmUSDC", r.value, r.err, 18)
+	}
+	return stored
+}
+
+func TestResolveDecimals_CanonicalSurvivesIntermittentFailures(t *testing.T) {
+	reads := []decimalsRead{
+		{err: errDecimalsCall}, // no good value yet: fallback
+		{value: 6},
+		{err: errDecimalsCall},
+		{value: 6},
+		{err: errDecimalsCall},
+	}
+
+	stored := resolveAll(newMetadataCache(), DecimalsCanonical, reads)
+	assert.Equal(t, []uint8{18, 6, 6, 6, 6}, stored)
+}
+
+func TestResolveDecimals_CanonicalIgnoresOnchainChange(t *testing.T) {
+	reads := []decimalsRead{{value: 6}, {value: 18}, {value: 6}}
+
+	stored := resolveAll(newMetadataCache(), DecimalsCanonical, reads)
+	assert.Equal(t, []uint8{6, 6, 6}, stored)
+}
+
+func TestResolveDecimals_PerRowKeepsEachRead(t *testing.T) {
+	reads := []decimalsRead{{value: 6}, {err: errDecimalsCall}, {value: 6}}
+
+	stored := resolveAll(newMetadataCache(), DecimalsPerRow, reads)
+	assert.Equal(t, []uint8{6, 18, 6}, stored)
+}
+
+func TestResolveDecimals_CanonicalIsPerToken(t *testing.T) {
+	m := newMetadataCache()
+	usdc := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	wxdai := common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+	assert.Equal(t, uint8(6), m.resolveDecimals(DecimalsCanonical, usdc, "USDC", 6, nil, 18))
+	assert.Equal(t, uint8(18), m.resolveDecimals(DecimalsCanonical, wxdai, "WXDAI", 18, nil, 18))
+	assert.Equal(t, uint8(6), m.resolveDecimals(DecimalsCanonical, usdc, "USDC", 0, errDecimalsCall, 18))
+}
+
+func TestClientDecimals_MaxDecimalsBound(t *testing.T) {
+	tokenAddr := common.HexToAddress("0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1")
+	token := TokenInfo{Label: "armmUSDC", Address: tokenAddr.Hex(), FallbackDecimals: 6}
+
+	newClient := func(policy MaxDecimalsPolicy) *Client {
+		c := &Client{metadata: newMetadataCache(), decimalsPolicy: DecimalsCanonical}
+		c.SetMaxDecimals(DefaultMaxDecimals, policy)
+		return c
+	}
+
+	for _, policy := range []MaxDecimalsPolicy{MaxDecimalsWarn, MaxDecimalsReject} {
+		t.Run(string(policy)+" within bound", func(t *testing.T) {
+			got, err := newClient(policy).decimals(tokenAddr, token, 36, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, uint8(36), got)
+		})
+	}
+
+	t.Run("warn beyond bound falls back", func(t *testing.T) {
+		got, err := newClient(MaxDecimalsWarn).decimals(tokenAddr, token, 200, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(6), got, "fallback_decimals is stored")
+	})
+
+	t.Run("warn beyond bound keeps canonical value", func(t *testing.T) {
+		c := newClient(MaxDecimalsWarn)
+		_, err := c.decimals(tokenAddr, token, 18, nil)
+		assert.NoError(t, err)
+
+		got, err := c.decimals(tokenAddr, token, 200, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(18), got)
+	})
+
+	t.Run("reject beyond bound fails", func(t *testing.T) {
+		_, err := newClient(MaxDecimalsReject).decimals(tokenAddr, token, 200, nil)
+		assert.ErrorIs(t, err, ErrDecimalsOutOfBounds)
+	})
+
+	t.Run("custom bound", func(t *testing.T) {
+		c := newClient(MaxDecimalsReject)
+		c.SetMaxDecimals(18, MaxDecimalsReject)
+		_, err := c.decimals(tokenAddr, token, 24, nil)
+		assert.ErrorIs(t, err, ErrDecimalsOutOfBounds)
+	})
+}
+
+func TestCachedMetadata(t *testing.T) {
+	tokenAddr := common.HexToAddress("0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1")
+	token := TokenInfo{Label: "armmUSDC", Address: tokenAddr.Hex(), FallbackDecimals: 18}
+	c := &Client{metadata: newMetadataCache(), decimalsPolicy: DecimalsCanonical, maxDecimals: DefaultMaxDecimals}
+
+	_, haveDecimals, _, haveSymbol := c.cachedMetadata(tokenAddr, token)
+	assert.False(t, haveDecimals, "nothing read yet")
+	assert.False(t, haveSymbol)
+
+	_, err := c.decimals(tokenAddr, token, 6, nil)
+	assert.NoError(t, err)
+	c.metadata.setSymbol(tokenAddr, "armmUSDC")
+	decimals, haveDecimals, symbol, haveSymbol := c.cachedMetadata(tokenAddr, token)
+	assert.True(t, haveDecimals)
+	assert.Equal(t, uint8(6), decimals)
+	assert.True(t, haveSymbol)
+	assert.Equal(t, "armmUSDC", symbol)
+
+	t.Run("per_row reads decimals every poll", func(t *testing.T) {
+		perRow := &Client{metadata: c.metadata, decimalsPolicy: DecimalsPerRow}
+		_, haveDecimals, _, haveSymbol := perRow.cachedMetadata(tokenAddr, token)
+		assert.False(t, haveDecimals)
+		assert.True(t, haveSymbol)
+	})
+
+	t.Run("forced refresh bypasses and replaces the cache", func(t *testing.T) {
+		refresh := token
+		refresh.ForceRefreshMetadata = true
+		_, haveDecimals, _, haveSymbol := c.cachedMetadata(tokenAddr, refresh)
+		assert.False(t, haveDecimals)
+		assert.False(t, haveSymbol)
+
+		got, err := c.decimals(tokenAddr, refresh, 18, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(18), got, "a refreshed read replaces the canonical value")
+		decimals, _, _, _ := c.cachedMetadata(tokenAddr, token)
+		assert.Equal(t, uint8(18), decimals)
+	})
+}
@@ -0,0 +1,264 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+)
+
+// DefaultMulticallAddress is the Multicall3 deployment, at the same address
+// on Gnosis and most EVM chains.
+const DefaultMulticallAddress = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// multicallABI covers Multicall3.aggregate3, which runs calls that may fail
+// individually.
+const multicallABI = `[
+	{"inputs":[{"components":[
+		{"internalType":"address","name":"target","type":"address"},
+		{"internalType":"bool","name":"allowFailure","type":"bool"},
+		{"internalType":"bytes","name":"callData","type":"bytes"}
+	],"internalType":"struct Multicall3.Call3[]","name":"calls","type":"tuple[]"}],
+	"name":"aggregate3","outputs":[{"components":[
+		{"internalType":"bool","name":"success","type":"bool"},
+		{"internalType":"bytes","name":"returnData","type":"bytes"}
+	],"internalType":"struct Multicall3.Result[]","name":"returnData","type":"tuple[]"}],
+	"stateMutability":"payable","type":"function"}
+]`
+
+// multicallCall and multicallResult mirror the Multicall3 Call3 and Result
+// tuples.
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// tokenRead holds the balanceOf, decimals, symbol and name results of one
+// token. Cached metadata is filled in beforehand and not read again.
+type tokenRead struct {
+	balance        *big.Int
+	balanceErr     error
+	decimals       uint8
+	decimalsErr    error
+	decimalsCached bool
+	symbol         string
+	symbolErr      error
+	symbolCached   bool
+	name           string
+	nameErr        error
+	nameCached     bool
+}
+
+// SetMulticall sets the Multicall3 contract used by
+// GetTokenBalancesMulticall; the zero address disables it.
+func (c *Client) SetMulticall(addr common.Address) {
+	c.multicall = addr
+}
+
+// GetTokenBalancesMulticall reads the balances of tokens for wallet with a
+// single eth_call to the Multicall3 contract. When no contract is set, or the
+// aggregated call fails (no contract at the address, revert), each token is
+// queried with GetTokenBalance instead. Tokens whose own calls fail are left
+// out of the result and reported in the error.
+func (c *Client) GetTokenBalancesMulticall(ctx context.Context, wallet common.Address, tokens []TokenInfo) ([]storage.TokenBalance, error) {
+	if c.multicall == (common.Address{}) {
+		return c.getTokenBalances(ctx, wallet, tokens)
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+
+	reads := c.cachedReads(tokens)
+	err := c.retryWithBackoff(rpcCtx, func() error {
+		ethClient, _, err := c.failoverClient.GetClient()
+		if err != nil {
+			return fmt.Errorf("no RPC endpoint available: %w", err)
+		}
+		return multicallTokens(rpcCtx, ethClient, c.multicall, c.multicallABI, c.parsedABI, wallet, tokens, reads)
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		slog.Warn("Multicall failed, querying tokens one by one",
+			"multicall", c.multicall.Hex(),
+			"wallet", wallet.Hex(),
+			"error", err)
+		return c.getTokenBalances(ctx, wallet, tokens)
+	}
+	return c.balancesFromReads(wallet, tokens, reads, time.Now().UTC())
+}
+
+// cachedReads returns the reads of tokens with their cached metadata filled
+// in.
+func (c *Client) cachedReads(tokens []TokenInfo) []tokenRead {
+	reads := make([]tokenRead, len(tokens))
+	for i, token := range tokens {
+		read := &reads[i]
+		tokenAddr := common.HexToAddress(token.Address)
+		read.decimals, read.decimalsCached, read.symbol, read.symbolCached = c.cachedMetadata(tokenAddr, token)
+		read.name, read.nameCached = c.cachedName(tokenAddr, token)
+	}
+	return reads
+}
+
+// getTokenBalances queries tokens one by one, keeping the successful
+// balances and joining the errors.
+func (c *Client) getTokenBalances(ctx context.Context, wallet common.Address, tokens []TokenInfo) ([]storage.TokenBalance, error) {
+	var balances []storage.TokenBalance
+	var errs []error
+	for _, token := range tokens {
+		b, err := c.GetTokenBalance(ctx, wallet, token)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("token %s: %w", token.Address, err))
+			continue
+		}
+		balances = append(balances, b)
+	}
+	return balances, errors.Join(errs...)
+}
+
+// balancesFromReads turns the multicall results of tokens into balances,
+// applying the decimals policy like GetTokenBalance.
+func (c *Client) balancesFromReads(wallet common.Address, tokens []TokenInfo, reads []tokenRead, queriedAt time.Time) ([]storage.TokenBalance, error) {
+	var balances []storage.TokenBalance
+	var errs []error
+	for i, token := range tokens {
+		read := reads[i]
+		tokenAddr := common.HexToAddress(token.Address)
+		b, err := func() (storage.TokenBalance, error) {
+			if read.balanceErr != nil {
+				return storage.TokenBalance{}, fmt.Errorf("balanceOf: %w", read.balanceErr)
+			}
+			decimals := read.decimals
+			if !read.decimalsCached {
+				var err error
+				decimals, err = c.decimals(tokenAddr, token, read.decimals, read.decimalsErr)
+				if err != nil {
+					return storage.TokenBalance{}, fmt.Errorf("decimals: %w", err)
+				}
+			}
+			if read.symbolErr != nil {
+				return storage.TokenBalance{}, fmt.Errorf("symbol: %w", read.symbolErr)
+			}
+			if !read.symbolCached {
+				c.metadata.setSymbol(tokenAddr, read.symbol)
+			}
+			name := read.name
+			if !read.nameCached {
+				name = c.tokenName(tokenAddr, token, read.name, read.nameErr)
+			}
+			return storage.TokenBalance{
+				QueriedAt:    queriedAt,
+				Wallet:       wallet.Hex(),
+				TokenAddress: tokenAddr.Hex(),
+				Symbol:       read.symbol,
+				Decimals:     decimals,
+				RawBalance:   read.balance,
+				Balance:      HumanBalance(read.balance, decimals),
+				TokenName:    name,
+			}, nil
+		}()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("token %s: %w", token.Address, err))
+			continue
+		}
+		balances = append(balances, b)
+	}
+	return balances, errors.Join(errs...)
+}
+
+// multicallTokens fills reads with balanceOf(wallet) of every token, and
+// its decimals, symbol and name unless cached, through one aggregate3 call against
+// caller. Calls failing on their own are reported in the token's read; an
+// error means the aggregated call itself failed.
+func multicallTokens(ctx context.Context, caller bind.ContractCaller, multicall common.Address, multicallABI, tokenABI abi.ABI, wallet common.Address, tokens []TokenInfo, reads []tokenRead) error {
+	// Each call is unpacked into the read of its token
+	type pending struct {
+		method string
+		read   *tokenRead
+	}
+	var calls []multicallCall
+	var targets []pending
+	for i, token := range tokens {
+		read := &reads[i]
+		methods := []string{"balanceOf"}
+		if !read.decimalsCached {
+			methods = append(methods, "decimals")
+		}
+		if !read.symbolCached {
+			methods = append(methods, "symbol")
+		}
+		if !read.nameCached {
+			methods = append(methods, "name")
+		}
+		target := common.HexToAddress(token.Address)
+		for _, method := range methods {
+			var args []any
+			if method == "balanceOf" {
+				args = []any{wallet}
+			}
+			data, err := tokenABI.Pack(method, args...)
+			if err != nil {
+				return fmt.Errorf("pack %s: %w", method, err)
+			}
+			calls = append(calls, multicallCall{Target: target, AllowFailure: true, CallData: data})
+			targets = append(targets, pending{method: method, read: read})
+		}
+	}
+
+	var out []any
+	contract := bind.NewBoundContract(multicall, multicallABI, caller, nil, nil)
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "aggregate3", calls); err != nil {
+		return fmt.Errorf("aggregate3: %w", err)
+	}
+	results := *abi.ConvertType(out[0], new([]multicallResult)).(*[]multicallResult)
+	if len(results) != len(calls) {
+		return fmt.Errorf("aggregate3: %d results for %d calls", len(results), len(calls))
+	}
+
+	for i, r := range results {
+		method, read := targets[i].method, targets[i].read
+		var value any
+		err := errors.New("call reverted")
+		if r.Success {
+			var values []any
+			if values, err = tokenABI.Unpack(method, r.ReturnData); err == nil {
+				value = values[0]
+			}
+		}
+		switch method {
+		case "balanceOf":
+			if err == nil {
+				read.balance, err = bigIntResult(method, []any{value})
+			}
+			read.balanceErr = err
+		case "decimals":
+			if read.decimalsErr = err; err == nil {
+				read.decimals = value.(uint8)
+			}
+		case "symbol":
+			if read.symbolErr = err; err == nil {
+				read.symbol = value.(string)
+			}
+		case "name":
+			if read.nameErr = err; err == nil {
+				read.name = value.(string)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMulticall answers aggregate3 calls by running each inner call against
+// tokens. Calls to a token missing from tokens fail.
+type fakeMulticall struct {
+	t            *testing.T
+	multicallABI abi.ABI
+	tokenABI     abi.ABI
+	tokens       map[common.Address]erc20Meta
+	balances     map[common.Address]*big.Int
+	calls        int
+	innerCalls   map[string]int
+	revert       bool
+}
+
+func (f *fakeMulticall) CodeAt(_ context.Context, _ common.Address, _ *big.Int) ([]byte, error) {
+	return []byte{0x1}, nil
+}
+
+func (f *fakeMulticall) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.calls++
+	if f.revert {
+		return nil, errors.New("execution reverted")
+	}
+	method, err := f.multicallABI.MethodById(call.Data[:4])
+	require.NoError(f.t, err)
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	require.NoError(f.t, err)
+	calls := *abi.ConvertType(args[0], new([]multicallCall)).(*[]multicallCall)
+
+	results := make([]multicallResult, len(calls))
+	for i, c := range calls {
+		meta, ok := f.tokens[c.Target]
+		if !ok {
+			continue
+		}
+		inner, err := f.tokenABI.MethodById(c.CallData[:4])
+		require.NoError(f.t, err)
+		f.innerCalls[inner.Name]++
+		var data []byte
+		switch inner.Name {
+		case "balanceOf":
+			data, err = inner.Outputs.Pack(f.balances[c.Target])
+		case "decimals":
+			data, err = inner.Outputs.Pack(meta.decimals)
+		case "symbol":
+			data, err = inner.Outputs.Pack(meta.symbol)
+		case "name":
+			if meta.name == "" {
+				continue // no name(): the call reverts
+			}
+			data, err = inner.Outputs.Pack(meta.name)
+		}
+		require.NoError(f.t, err)
+		results[i] = multicallResult{Success: true, ReturnData: data}
+	}
+	return method.Outputs.Pack(results)
+}
+
+func newFakeMulticall(t *testing.T) *fakeMulticall {
+	t.Helper()
+	parsedMulticall, err := abi.JSON(strings.NewReader(multicallABI))
+	require.NoError(t, err)
+	parsedToken, err := abi.JSON(strings.NewReader(erc20ABI))
+	require.NoError(t, err)
+
+	aXDAI := common.HexToAddress("0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b")
+	aUSDC := common.HexToAddress("0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1")
+	return &fakeMulticall{
+		t:            t,
+		multicallABI: parsedMulticall,
+		tokenABI:     parsedToken,
+		innerCalls:   make(map[string]int),
+		tokens: map[common.Address]erc20Meta{
+			aXDAI: {symbol: "armmWXDAI", decimals: 18},
+			aUSDC: {symbol: "armmUSDC", decimals: 6, name: "RealT RMM V3 USDC"},
+		},
+		balances: map[common.Address]*big.Int{
+			aXDAI: new(big.Int).Mul(big.NewInt(15), big.NewInt(1e17)),
+			aUSDC: big.NewInt(2_500_000),
+		},
+	}
+}
+
+func TestMulticallTokens(t *testing.T) {
+	fake := newFakeMulticall(t)
+	wallet := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	tokens := []TokenInfo{
+		{Label: "armmWXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b"},
+		{Label: "missing", Address: "0x0000000000000000000000000000000000000042", FallbackDecimals: 18},
+		{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"},
+	}
+
+	reads := make([]tokenRead, len(tokens))
+	err := multicallTokens(context.Background(), fake, common.HexToAddress(DefaultMulticallAddress),
+		fake.multicallABI, fake.tokenABI, wallet, tokens, reads)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.calls, "all tokens are read in a single eth_call")
+	require.Len(t, reads, 3)
+	assert.Equal(t, "1500000000000000000", reads[0].balance.String())
+	assert.Equal(t, uint8(18), reads[0].decimals)
+	assert.Equal(t, "armmWXDAI", reads[0].symbol)
+	assert.Error(t, reads[1].balanceErr)
+	assert.Error(t, reads[1].symbolErr)
+	assert.Equal(t, "2500000", reads[2].balance.String())
+
+	c := &Client{metadata: newMetadataCache(), decimalsPolicy: DecimalsCanonical, maxDecimals: DefaultMaxDecimals}
+	queried := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	balances, err := c.balancesFromReads(wallet, tokens, reads, queried)
+	require.ErrorContains(t, err, "token 0x0000000000000000000000000000000000000042: balanceOf")
+	require.Len(t, balances, 2, "the failed token is left out")
+	assert.Equal(t, "armmWXDAI", balances[0].Symbol)
+	assert.Equal(t, "1.5", balances[0].Balance.String())
+	assert.Equal(t, wallet.Hex(), balances[0].Wallet)
+	assert.True(t, queried.Equal(balances[0].QueriedAt))
+	assert.Equal(t, "armmUSDC", balances[1].Symbol)
+	assert.Equal(t, uint8(6), balances[1].Decimals)
+	assert.Equal(t, "2.5", balances[1].Balance.String())
+	assert.Equal(t, "RealT RMM V3 USDC", balances[1].TokenName)
+	assert.Equal(t, "armmWXDAI", balances[0].TokenName, "the label stands in for a missing name()")
+}
+
+func TestMulticallTokens_Reverted(t *testing.T) {
+	fake := newFakeMulticall(t)
+	fake.revert = true
+	tokens := []TokenInfo{{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"}}
+
+	err := multicallTokens(context.Background(), fake, common.HexToAddress(DefaultMulticallAddress),
+		fake.multicallABI, fake.tokenABI, common.Address{}, tokens, make([]tokenRead, len(tokens)))
+	assert.ErrorContains(t, err, "aggregate3")
+}
+
+func TestMulticallTokens_SkipsCachedMetadata(t *testing.T) {
+	fake := newFakeMulticall(t)
+	wallet := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	tokens := []TokenInfo{{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"}}
+	c := &Client{metadata: newMetadataCache(), decimalsPolicy: DecimalsCanonical, maxDecimals: DefaultMaxDecimals}
+
+	poll := func() []storage.TokenBalance {
+		reads := c.cachedReads(tokens)
+		require.NoError(t, multicallTokens(context.Background(), fake, common.HexToAddress(DefaultMulticallAddress),
+			fake.multicallABI, fake.tokenABI, wallet, tokens, reads))
+		balances, err := c.balancesFromReads(wallet, tokens, reads, time.Now())
+		require.NoError(t, err)
+		return balances
+	}
+
+	first, second := poll(), poll()
+	assert.Equal(t, map[string]int{"balanceOf": 2, "decimals": 1, "symbol": 1, "name": 1}, fake.innerCalls)
+	assert.Equal(t, first[0].Symbol, second[0].Symbol)
+	assert.Equal(t, first[0].Decimals, second[0].Decimals)
+	assert.Equal(t, "RealT RMM V3 USDC", second[0].TokenName)
+
+	tokens[0].ForceRefreshMetadata = true
+	poll()
+	assert.Equal(t, map[string]int{"balanceOf": 3, "decimals": 2, "symbol": 2, "name": 2}, fake.innerCalls)
+}
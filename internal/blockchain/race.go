@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+)
+
+// racedAnswer is the balanceOf answer of one endpoint in a race.
+type racedAnswer struct {
+	endpoint endpointClient
+	balance  *big.Int
+	err      error
+}
+
+// GetTokenBalanceRaced retrieves balance for a specific token and wallet like
+// GetTokenBalance, but reads balanceOf on every healthy endpoint at once: the
+// first answer wins and the calls still running get a short grace period to
+// answer, their answers only being compared with the winner's. Decimals,
+// symbol and name are then read through the winning endpoint. With a single
+// healthy endpoint there is nothing to race and the read is the usual one.
+func (c *Client) GetTokenBalanceRaced(ctx context.Context, wallet common.Address, token TokenInfo) (storage.TokenBalance, error) {
+	endpoints := c.failoverClient.healthyClients()
+	if len(endpoints) < 2 {
+		ethClient, _, err := c.failoverClient.GetClient()
+		if err != nil {
+			return storage.TokenBalance{}, fmt.Errorf("no RPC endpoint available: %w", err)
+		}
+		return c.tokenBalance(ctx, ethClient, wallet, token, nil)
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+
+	tokenAddr := common.HexToAddress(token.Address)
+	result := storage.TokenBalance{
+		QueriedAt:    time.Now().UTC(),
+		Wallet:       wallet.Hex(),
+		TokenAddress: tokenAddr.Hex(),
+	}
+
+	start := time.Now()
+	winner, err := c.raceBalanceOf(rpcCtx, endpoints, tokenAddr, wallet)
+	if err != nil {
+		return result, fmt.Errorf("balanceOf: %w", err)
+	}
+	result.RawBalance = winner.balance
+	if c.recordLatency {
+		ms := time.Since(start).Milliseconds()
+		result.FetchLatencyMS = &ms
+	}
+
+	ethClient := winner.endpoint.client
+	contract := bind.NewBoundContract(tokenAddr, c.parsedABI, ethClient, ethClient, ethClient)
+	err = c.completeBalance(rpcCtx, contract, tokenAddr, token, nil, &result)
+	return result, err
+}
+
+// raceGrace is how long the calls losing a race may keep running, past the
+// winner, for their answers to be compared with it.
+const raceGrace = 2 * time.Second
+
+// raceBalanceOf reads balanceOf on endpoints concurrently and returns the
+// first successful answer. The other calls are then detached from ctx and
+// given raceGrace to answer in the background, where their answers are
+// compared with the winner. It fails when every endpoint does.
+func (c *Client) raceBalanceOf(ctx context.Context, endpoints []endpointClient, tokenAddr, wallet common.Address) (racedAnswer, error) {
+	// The calls follow ctx only until a winner is found
+	raceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	answers := make(chan racedAnswer, len(endpoints))
+	for _, ep := range endpoints {
+		go func() {
+			answers <- c.racedBalanceOf(raceCtx, ep, tokenAddr, wallet)
+		}()
+	}
+
+	var errs []error
+	for pending := len(endpoints); pending > 0; pending-- {
+		answer := <-answers
+		if answer.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", answer.endpoint.url, answer.err))
+			continue
+		}
+		stop()
+		grace := time.AfterFunc(raceGrace, cancel)
+		go func() {
+			defer cancel()
+			defer grace.Stop()
+			compareRaced(answer, answers, pending-1, tokenAddr, wallet)
+		}()
+		return answer, nil
+	}
+	stop()
+	cancel()
+	return racedAnswer{}, fmt.Errorf("every endpoint failed: %w", errors.Join(errs...))
+}
+
+// racedBalanceOf reads balanceOf through one endpoint of a race, once. A
+// connection-level failure counts against the endpoint as in
+// retryWithBackoff, unless the call lost the race or was throttled.
+func (c *Client) racedBalanceOf(ctx context.Context, ep endpointClient, tokenAddr, wallet common.Address) racedAnswer {
+	answer := racedAnswer{endpoint: ep}
+	if c.limiter != nil {
+		if answer.err = c.limiter.Wait(ctx); answer.err != nil {
+			answer.err = fmt.Errorf("waiting for the RPC rate limit: %w", answer.err)
+			return answer
+		}
+	}
+
+	c.recorder().RPCAttempt(ep.url)
+	contract := bind.NewBoundContract(tokenAddr, c.parsedABI, ep.client, ep.client, ep.client)
+	var values []any
+	err := contract.Call(&bind.CallOpts{Context: ctx}, &values, "balanceOf", wallet)
+	if err != nil {
+		if _, throttled := rateLimited(err); !throttled && !errors.Is(err, context.Canceled) && endpointFailure(err) {
+			c.failoverClient.MarkUnhealthy(ep.url, err)
+		}
+		answer.err = err
+		return answer
+	}
+	c.failoverClient.MarkSucceeded(ep.url)
+	answer.balance, answer.err = bigIntResult("balanceOf", values)
+	return answer
+}
+
+// compareRaced reads the remaining answers of a race won by winner and logs
+// those disagreeing with it, a sign of a lagging or misbehaving endpoint.
+func compareRaced(winner racedAnswer, answers <-chan racedAnswer, remaining int, tokenAddr, wallet common.Address) {
+	for range remaining {
+		answer := <-answers
+		if answer.err != nil || answer.balance.Cmp(winner.balance) == 0 {
+			continue
+		}
+		slog.Warn("RPC endpoints disagree on a balance",
+			"token_address", tokenAddr.Hex(),
+			"wallet", wallet.Hex(),
+			"url", winner.endpoint.url,
+			"balance", winner.balance.String(),
+			"other_url", answer.endpoint.url,
+			"other_balance", answer.balance.String())
+	}
+}
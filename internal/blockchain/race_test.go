@@ -0,0 +1,244 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// balanceServer serves a token over JSON-RPC on chain 100 whose balanceOf
+// answers balance after delay, or a JSON-RPC error when balance is nil, or
+// fails with the HTTP status in status when set. cancelled reports whether a
+// balanceOf call was abandoned by the client.
+type balanceServer struct {
+	t         *testing.T
+	tokenABI  abi.ABI
+	balance   *big.Int
+	delay     time.Duration
+	status    atomic.Int32
+	cancelled atomic.Bool
+}
+
+func newBalanceServer(t *testing.T, balance *big.Int, delay time.Duration) (*balanceServer, string) {
+	t.Helper()
+	parsedToken, err := abi.JSON(strings.NewReader(erc20ABI))
+	require.NoError(t, err)
+	s := &balanceServer{t: t, tokenABI: parsedToken, balance: balance, delay: delay}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+func (s *balanceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&req))
+	reply := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+
+	switch req.Method {
+	case "eth_chainId":
+		reply["result"] = "0x64"
+	case "eth_call":
+		var call struct {
+			Input hexutil.Bytes `json:"input"`
+			Data  hexutil.Bytes `json:"data"`
+		}
+		require.NoError(s.t, json.Unmarshal(req.Params[0], &call))
+		input := call.Input
+		if len(input) == 0 {
+			input = call.Data
+		}
+		method, err := s.tokenABI.MethodById(input[:4])
+		require.NoError(s.t, err)
+		var out []byte
+		switch method.Name {
+		case "balanceOf":
+			select {
+			case <-time.After(s.delay):
+			case <-r.Context().Done():
+				s.cancelled.Store(true)
+				return
+			}
+			if status := s.status.Load(); status != 0 {
+				http.Error(w, http.StatusText(int(status)), int(status))
+				return
+			}
+			if s.balance == nil {
+				reply["error"] = map[string]any{"code": -32000, "message": "header not found"}
+				break
+			}
+			out, err = method.Outputs.Pack(s.balance)
+		case "decimals":
+			out, err = method.Outputs.Pack(uint8(6))
+		case "symbol":
+			out, err = method.Outputs.Pack("armmUSDC")
+		case "name":
+			out, err = method.Outputs.Pack("RealT RMM V3 USDC")
+		}
+		require.NoError(s.t, err)
+		if _, failed := reply["error"]; !failed {
+			reply["result"] = hexutil.Bytes(out)
+		}
+	default:
+		s.t.Errorf("unexpected method %s", req.Method)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+var (
+	raceWallet = common.HexToAddress("0x1234567890123456789012345678901234567890")
+	raceToken  = TokenInfo{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6}
+)
+
+func TestGetTokenBalanceRaced_FirstAnswerWins(t *testing.T) {
+	slow, slowURL := newBalanceServer(t, big.NewInt(1000000), time.Minute)
+	_, fastURL := newBalanceServer(t, big.NewInt(2500000), 0)
+
+	client, err := NewClient(EndpointsFromURLs([]string{slowURL, fastURL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	client.SetRaceEndpoints(true)
+
+	start := time.Now()
+	b, err := client.GetTokenBalance(context.Background(), raceWallet, raceToken)
+
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second, "the slow endpoint is not waited for")
+	assert.Equal(t, "2500000", b.RawBalance.String())
+	assert.Equal(t, "2.5", b.Balance.String())
+	assert.Equal(t, "armmUSDC", b.Symbol)
+	assert.False(t, slow.cancelled.Load(), "the losing call is not cancelled at once")
+	assert.Eventually(t, slow.cancelled.Load, 2*raceGrace, 10*time.Millisecond, "the losing call is cancelled after the grace period")
+	assert.True(t, client.GetEndpointsHealth()[slowURL], "losing the race is not a failure")
+}
+
+func TestGetTokenBalanceRaced_FailingEndpoint(t *testing.T) {
+	failing, failingURL := newBalanceServer(t, big.NewInt(42), 0)
+	failing.status.Store(http.StatusBadGateway)
+	_, okURL := newBalanceServer(t, big.NewInt(42), 50*time.Millisecond)
+
+	client, err := NewClient(EndpointsFromURLs([]string{failingURL, okURL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	b, err := client.GetTokenBalanceRaced(context.Background(), raceWallet, raceToken)
+
+	require.NoError(t, err)
+	assert.Equal(t, "42", b.RawBalance.String())
+	health := client.GetEndpointsHealth()
+	assert.False(t, health[failingURL], "a failed call counts against the endpoint")
+	assert.True(t, health[okURL])
+}
+
+func TestGetTokenBalanceRaced_JSONRPCErrorKeepsEndpoint(t *testing.T) {
+	_, erroringURL := newBalanceServer(t, nil, 0)
+	_, okURL := newBalanceServer(t, big.NewInt(42), 50*time.Millisecond)
+
+	client, err := NewClient(EndpointsFromURLs([]string{erroringURL, okURL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	b, err := client.GetTokenBalanceRaced(context.Background(), raceWallet, raceToken)
+
+	require.NoError(t, err)
+	assert.Equal(t, "42", b.RawBalance.String())
+	assert.True(t, client.GetEndpointsHealth()[erroringURL], "a JSON-RPC error is an answer, not an outage")
+}
+
+func TestGetTokenBalanceRaced_AllEndpointsFail(t *testing.T) {
+	_, url1 := newBalanceServer(t, nil, 0)
+	_, url2 := newBalanceServer(t, nil, 0)
+
+	client, err := NewClient(EndpointsFromURLs([]string{url1, url2}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	_, err = client.GetTokenBalanceRaced(context.Background(), raceWallet, raceToken)
+
+	require.ErrorContains(t, err, "every endpoint failed")
+	assert.ErrorContains(t, err, "header not found")
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of background
+// logging.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestGetTokenBalanceRaced_LogsDisagreement(t *testing.T) {
+	var logs syncBuffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	lagging, laggingURL := newBalanceServer(t, big.NewInt(41), 200*time.Millisecond)
+	_, fastURL := newBalanceServer(t, big.NewInt(42), 0)
+
+	client, err := NewClient(EndpointsFromURLs([]string{laggingURL, fastURL}), 100, DefaultClientConfig())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	b, err := client.GetTokenBalanceRaced(context.Background(), raceWallet, raceToken)
+
+	require.NoError(t, err)
+	assert.Equal(t, "42", b.RawBalance.String())
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "RPC endpoints disagree on a balance")
+	}, 2*raceGrace, 10*time.Millisecond, "the losing answer is compared")
+	assert.Contains(t, logs.String(), "other_url="+laggingURL)
+	assert.Contains(t, logs.String(), "other_balance=41")
+	assert.False(t, lagging.cancelled.Load(), "the losing call finished within the grace period")
+}
+
+func TestCompareRaced(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	winner := racedAnswer{endpoint: endpointClient{url: "https://a.example.com"}, balance: big.NewInt(42)}
+	answers := make(chan racedAnswer, 3)
+	answers <- racedAnswer{endpoint: endpointClient{url: "https://b.example.com"}, balance: big.NewInt(42)}
+	answers <- racedAnswer{endpoint: endpointClient{url: "https://c.example.com"}, err: context.Canceled}
+	answers <- racedAnswer{endpoint: endpointClient{url: "https://d.example.com"}, balance: big.NewInt(41)}
+
+	compareRaced(winner, answers, 3, common.HexToAddress(raceToken.Address), raceWallet)
+
+	logged := buf.String()
+	assert.Equal(t, 1, strings.Count(logged, "RPC endpoints disagree on a balance"), logged)
+	assert.Contains(t, logged, "other_url=https://d.example.com")
+	assert.Contains(t, logged, "other_balance=41")
+}
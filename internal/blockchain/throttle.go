@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// rateLimitedError is the error of a request throttled by the endpoint with
+// HTTP 429 Too Many Requests.
+type rateLimitedError struct {
+	status     string
+	retryAfter time.Duration // Retry-After hint, 0 without one
+}
+
+func (e *rateLimitedError) Error() string {
+	return e.status
+}
+
+// throttleTransport turns HTTP 429 answers into a rateLimitedError carrying
+// their Retry-After hint, which go-ethereum drops from its HTTPError.
+type throttleTransport struct {
+	base http.RoundTripper
+}
+
+func (t throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	// Drain a bounded part of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	return nil, &rateLimitedError{
+		status:     resp.Status,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given in
+// seconds or as an HTTP date, or 0 when it is missing, invalid or past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// rateLimited reports whether err is the endpoint throttling calls rather
+// than failing, and the Retry-After delay it asked for, 0 when unknown.
+func rateLimited(err error) (time.Duration, bool) {
+	var throttled *rateLimitedError
+	if errors.As(err, &throttled) {
+		return throttled.retryAfter, true
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return 0, true
+	}
+	// Some providers answer with a JSON-RPC error instead
+	return 0, strings.Contains(strings.ToLower(err.Error()), "too many requests")
+}
+
+// endpointFailure reports whether err is the endpoint failing to answer: a
+// network error, a dropped connection or an HTTP 5xx. Answers such as a
+// JSON-RPC error or a reverted call would come the same from any endpoint and
+// are not its failure.
+func endpointFailure(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var httpErr rpc.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode >= http.StatusInternalServerError
+}
+
+// dial connects to an RPC endpoint, reporting throttled requests as
+// rateLimitedError.
+func dial(url string) (*ethclient.Client, error) {
+	return dialWith(url, http.DefaultTransport)
+}
+
+// dialWith connects to an RPC endpoint with HTTP requests sent through base.
+func dialWith(url string, base http.RoundTripper) (*ethclient.Client, error) {
+	client, err := rpc.DialOptions(context.Background(), url,
+		rpc.WithHTTPClient(&http.Client{Transport: throttleTransport{base: base}}))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
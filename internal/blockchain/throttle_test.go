@@ -0,0 +1,191 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRoundTripper answers each HTTP request with the next of its responses.
+type stubRoundTripper struct {
+	responses []func() *http.Response
+	calls     int
+}
+
+func (s *stubRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := s.responses[min(s.calls, len(s.responses)-1)]()
+	s.calls++
+	resp.Request = req
+	return resp, nil
+}
+
+func tooManyRequests(retryAfter string) func() *http.Response {
+	return func() *http.Response {
+		resp := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Status:     "429 Too Many Requests",
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("slow down")),
+		}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+}
+
+func blockNumberAnswer() *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":"0x2a"}`)),
+	}
+}
+
+// stubbedClient returns a Client whose single endpoint is served by rt.
+func stubbedClient(t *testing.T, rt http.RoundTripper, timeout time.Duration) *Client {
+	t.Helper()
+	const url = "https://rpc.example.com"
+	ethClient, err := dialWith(url, rt)
+	require.NoError(t, err)
+	c := &Client{
+		failoverClient: buildFC([]*endpointStatus{{url: url, client: ethClient, healthy: true}}),
+		rpc:            ClientConfig{Timeout: timeout, MaxRetries: 2, RetryInterval: time.Millisecond},
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestRetryWithBackoff_RateLimitedHonorsRetryAfter(t *testing.T) {
+	stub := &stubRoundTripper{responses: []func() *http.Response{tooManyRequests("1"), blockNumberAnswer}}
+	c := stubbedClient(t, stub, 5*time.Second)
+
+	start := time.Now()
+	number, err := c.LatestBlockNumber(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), number)
+	assert.Equal(t, 2, stub.calls, "retried on the same endpoint")
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "waited for Retry-After")
+	stats := c.EndpointStats()
+	assert.True(t, stats[0].Healthy, "throttling is not an outage")
+	assert.Zero(t, stats[0].ConsecutiveFailures)
+}
+
+func jsonRPCError(message string) func() *http.Response {
+	return func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"` + message + `"}}`)),
+		}
+	}
+}
+
+func TestRetryWithBackoff_JSONRPCErrorKeepsEndpoint(t *testing.T) {
+	stub := &stubRoundTripper{responses: []func() *http.Response{jsonRPCError("execution reverted")}}
+	c := stubbedClient(t, stub, 5*time.Second)
+
+	_, err := c.LatestBlockNumber(context.Background())
+
+	require.ErrorContains(t, err, "execution reverted")
+	var rpcErr rpc.Error
+	assert.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, 1, stub.calls, "an answer is not retried")
+	stats := c.EndpointStats()
+	assert.True(t, stats[0].Healthy, "a revert does not blame the endpoint")
+	assert.Zero(t, stats[0].ConsecutiveFailures)
+}
+
+func TestRetryWithBackoff_RetryAfterBeyondDeadline(t *testing.T) {
+	stub := &stubRoundTripper{responses: []func() *http.Response{tooManyRequests("60")}}
+	c := stubbedClient(t, stub, time.Second)
+
+	start := time.Now()
+	_, err := c.LatestBlockNumber(context.Background())
+
+	require.ErrorContains(t, err, "exceeds the call deadline")
+	assert.Less(t, time.Since(start), time.Second, "gives up without waiting")
+	assert.Equal(t, 1, stub.calls)
+	assert.True(t, c.GetEndpointsHealth()["https://rpc.example.com"])
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-5", 0},
+		{"Sun, 01 Mar 2026 12:00:30 GMT", 30 * time.Second},
+		{"Sun, 01 Mar 2026 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseRetryAfter(tt.value, now), tt.value)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	delay, ok := rateLimited(fmt.Errorf("call: %w", &rateLimitedError{status: "429 Too Many Requests", retryAfter: time.Second}))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+
+	_, ok = rateLimited(rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"})
+	assert.True(t, ok)
+	_, ok = rateLimited(errors.New("Too Many Requests, please retry"))
+	assert.True(t, ok, "JSON-RPC error")
+
+	_, ok = rateLimited(rpc.HTTPError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"})
+	assert.False(t, ok)
+	_, ok = rateLimited(errors.New("connection refused"))
+	assert.False(t, ok)
+}
+
+func TestEndpointFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "dial", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "connection reset", err: fmt.Errorf("call: %w", syscall.ECONNRESET), want: true},
+		{name: "timeout", err: &url.Error{Op: "Post", URL: "https://rpc.example.com", Err: context.DeadlineExceeded}, want: true},
+		{name: "EOF", err: fmt.Errorf("call: %w", io.EOF), want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "HTTP 502", err: rpc.HTTPError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}, want: true},
+		{name: "HTTP 400", err: rpc.HTTPError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}},
+		{name: "JSON-RPC error", err: &jsonError{code: -32000, message: "header not found"}},
+		{name: "revert", err: errors.New("execution reverted")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, endpointFailure(tt.err))
+		})
+	}
+}
+
+// jsonError is a JSON-RPC error answer, as rpc.Error.
+type jsonError struct {
+	code    int
+	message string
+}
+
+func (e *jsonError) Error() string  { return e.message }
+func (e *jsonError) ErrorCode() int { return e.code }
@@ -0,0 +1,57 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WalletKind tells whether an address is a plain account or a contract.
+type WalletKind string
+
+const (
+	// WalletEOA is an externally owned account (no code).
+	WalletEOA WalletKind = "eoa"
+	// WalletDelegatedEOA is an EOA delegating to a contract (EIP-7702).
+	WalletDelegatedEOA WalletKind = "delegated_eoa"
+	// WalletContract is a smart contract, e.g. a Safe multisig.
+	WalletContract WalletKind = "contract"
+)
+
+// eip7702Prefix starts the code of an EOA with an EIP-7702 delegation.
+var eip7702Prefix = []byte{0xef, 0x01, 0x00}
+
+// ClassifyWallet reports whether wallet is an EOA or a contract, from the
+// code deployed at its address.
+func (c *Client) ClassifyWallet(ctx context.Context, wallet common.Address) (WalletKind, error) {
+	rpcCtx, cancel := context.WithTimeout(ctx, c.settings().Timeout)
+	defer cancel()
+
+	var code []byte
+	err := c.retryWithBackoff(rpcCtx, func() error {
+		ethClient, _, err := c.failoverClient.GetClient()
+		if err != nil {
+			return fmt.Errorf("no RPC endpoint available: %w", err)
+		}
+		code, err = ethClient.CodeAt(rpcCtx, wallet, nil)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("get code of %s: %w", wallet.Hex(), err)
+	}
+	return classifyCode(code), nil
+}
+
+// classifyCode maps the code at an address to a WalletKind.
+func classifyCode(code []byte) WalletKind {
+	switch {
+	case len(code) == 0:
+		return WalletEOA
+	case bytes.HasPrefix(code, eip7702Prefix):
+		return WalletDelegatedEOA
+	default:
+		return WalletContract
+	}
+}
@@ -0,0 +1,29 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyCode(t *testing.T) {
+	delegation := append([]byte{0xef, 0x01, 0x00}, common.HexToAddress("0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B").Bytes()...)
+
+	tests := []struct {
+		name string
+		code []byte
+		want WalletKind
+	}{
+		{"no code", nil, WalletEOA},
+		{"empty code", []byte{}, WalletEOA},
+		{"eip-7702 delegation", delegation, WalletDelegatedEOA},
+		{"safe proxy", common.FromHex("0x608060405273ffffffffffffffffffffffffffffffffffffffff600054167fa619486e"), WalletContract},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyCode(tt.code))
+		})
+	}
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -17,18 +20,177 @@ type Config struct {
 	// Legacy: Single endpoint (for backward compatibility)
 	RPCUrl string `mapstructure:"rpc_url" validate:"omitempty,url"`
 
-	Wallets        []string      `mapstructure:"wallets" validate:"required,min=1,dive,eth_addr"`
-	Tokens         []TokenConfig `mapstructure:"tokens" validate:"required,min=1,dive"`
-	Interval       string        `mapstructure:"interval" validate:"omitempty,schedule"`
-	LogLevel       string        `mapstructure:"log_level" validate:"omitempty,oneof=debug info warn error"`
-	LogFormat      string        `mapstructure:"log_format" validate:"omitempty,oneof=text json"`
-	HTTPPort       int           `mapstructure:"http_port" validate:"omitempty,min=1024,max=65535"`
-	RunImmediately *bool         `mapstructure:"run_immediately"`
-	Timezone       string        `mapstructure:"timezone" validate:"omitempty,timezone"`
+	// Endpoints with an explicit failover priority, instead of rpc_urls
+	RPCEndpoints []RPCEndpointConfig `mapstructure:"rpc_endpoints" validate:"omitempty,dive"`
+
+	// Wallets with a label and tags stored with their balances, polled in
+	// addition to wallets
+	LabeledWallets []WalletConfig `mapstructure:"labeled_wallets" validate:"omitempty,dive"`
+
+	Wallets []string      `mapstructure:"wallets" validate:"required,min=1,dive,eth_addr"`
+	Tokens  []TokenConfig `mapstructure:"tokens" validate:"required_without=TokenDiscoveryPool,omitempty,min=1,dive"`
+	// RMM lending pool whose reserve tokens are discovered at startup and
+	// polled in addition to the configured tokens
+	TokenDiscoveryPool string        `mapstructure:"token_discovery_pool" validate:"omitempty,eth_addr"`
+	Interval           string        `mapstructure:"interval" validate:"omitempty,schedule"`
+	LogLevel           string        `mapstructure:"log_level" validate:"omitempty,oneof=debug info warn error"`
+	LogFormat          string        `mapstructure:"log_format" validate:"omitempty,oneof=text json"`
+	HTTPPort           int           `mapstructure:"http_port" validate:"omitempty,min=1024,max=65535"`
+	RunImmediately     *bool         `mapstructure:"run_immediately"`
+	Timezone           string        `mapstructure:"timezone" validate:"omitempty,timezone"`
+	DaemonGrace        time.Duration `mapstructure:"daemon_grace" validate:"omitempty,gt=0"`
+	// Delay each run by a random offset up to this bound, shorter than the interval
+	Jitter time.Duration `mapstructure:"jitter" validate:"omitempty,gt=0"`
+	// Cancel a run still going after this long, reported as a failed run
+	RunTimeout time.Duration `mapstructure:"run_timeout" validate:"omitempty,gt=0"`
+	// Exit when the immediate run on startup fails instead of waiting for the next one
+	FailOnFirstRun bool `mapstructure:"fail_on_first_run"`
+	// Reuse the /health RPC check result for this long instead of calling the endpoint on every probe
+	RPCHealthTTL time.Duration `mapstructure:"rpc_health_ttl" validate:"omitempty,gt=0"`
+	// Timeout of an RPC call, retries included (default 10s), and retries
+	// after its failed first attempt (default 2)
+	RPCTimeout    time.Duration `mapstructure:"rpc_timeout" validate:"omitempty,gt=0"`
+	RPCMaxRetries *int          `mapstructure:"rpc_max_retries" validate:"omitempty,min=0,max=10"`
+	// RPC calls per second across all endpoints, retries included (default
+	// unlimited), for providers throttling free tiers
+	RPCRateLimit float64 `mapstructure:"rpc_rate_limit" validate:"omitempty,gt=0"`
+	// Consecutive failed calls, within the window of the first one, that take
+	// an RPC endpoint down (default 1 and 1m)
+	RPCFailureThreshold int           `mapstructure:"rpc_failure_threshold" validate:"omitempty,min=1,max=100"`
+	RPCFailureWindow    time.Duration `mapstructure:"rpc_failure_window" validate:"omitempty,gt=0"`
+	// Read each balance on every healthy RPC endpoint at once and keep the
+	// first answer, trading request volume for latency (default off)
+	RPCRace bool `mapstructure:"rpc_race"`
+	// How often the daemon probes RPC endpoints that are down (default 30s)
+	RPCProbeInterval time.Duration `mapstructure:"rpc_probe_interval" validate:"omitempty,gt=0"`
+	// priority (default) sends calls to the first healthy endpoint in list
+	// order; round_robin stays on the current one until it fails
+	RPCSelection string `mapstructure:"rpc_selection" validate:"omitempty,oneof=priority round_robin"`
+	// canonical (default) reuses a token's first successfully read decimals for
+	// every row; per_row stores each poll's read, or the fallback on failure
+	DecimalsPolicy string `mapstructure:"decimals_policy" validate:"omitempty,oneof=canonical per_row"`
+	// Largest accepted decimals() value (default 36) and whether a token above
+	// it is stored with its fallback decimals (warn, default) or not at all (reject)
+	MaxDecimals       int    `mapstructure:"max_decimals" validate:"omitempty,min=1,max=255"`
+	MaxDecimalsPolicy string `mapstructure:"max_decimals_policy" validate:"omitempty,oneof=warn reject"`
+	// Acknowledges a sub-30s poll interval and silences the startup warning
+	IKnowThisIsFast bool `mapstructure:"i_know_this_is_fast"`
+	// Where run writes its logs: stdout (default), stderr or a file appended
+	// to, rotated once it reaches log_max_size_mb keeping log_max_backups
+	// rotated files (default: never rotated, all kept)
+	LogFile       string `mapstructure:"log_file"`
+	LogMaxSizeMB  int    `mapstructure:"log_max_size_mb" validate:"omitempty,gt=0"`
+	LogMaxBackups int    `mapstructure:"log_max_backups" validate:"omitempty,gt=0"`
+
+	// Database session limits, applied on top of whatever DATABASE_URL contains
+	DBConnectTimeout   time.Duration `mapstructure:"db_connect_timeout" validate:"omitempty,gt=0"`
+	DBStatementTimeout time.Duration `mapstructure:"db_statement_timeout" validate:"omitempty,gt=0"`
+
+	// Retries for schema migrations failing on a lock conflict with another instance
+	MigrationMaxAttempts int           `mapstructure:"migration_max_attempts" validate:"omitempty,min=1,max=20"`
+	MigrationRetryDelay  time.Duration `mapstructure:"migration_retry_delay" validate:"omitempty,gt=0"`
+
+	// Additional write targets; DATABASE_URL remains the primary used for reads
+	Databases         []DatabaseConfig `mapstructure:"databases" validate:"omitempty,dive"`
+	DatabaseWriteMode string           `mapstructure:"database_write_mode" validate:"omitempty,oneof=best_effort all_or_nothing"`
+
+	// Balances kept in memory while the database is unreachable; 0 disables buffering
+	DBBufferSize int `mapstructure:"db_buffer_size" validate:"omitempty,min=1"`
+
+	// Rows per INSERT statement when writing a cycle's balances; capped to the
+	// PostgreSQL bind parameter limit
+	DBInsertBatchSize int `mapstructure:"db_insert_batch_size" validate:"omitempty,min=1"`
+
+	// Skip storing a balance whose raw value equals the newest stored one
+	DedupUnchanged bool `mapstructure:"dedup_unchanged"`
+
+	// Daemon only: delete balances older than this, as a duration or a number
+	// of days ("90d"); empty keeps every row
+	Retention string `mapstructure:"retention" validate:"omitempty,retention"`
+
+	// Serve Prometheus metrics on /metrics in daemon mode (default true)
+	MetricsEnabled *bool `mapstructure:"metrics_enabled"`
+	// Attach block numbers to /metrics observations as OpenMetrics exemplars
+	MetricsExemplars bool `mapstructure:"metrics_exemplars"`
+
+	// Add the build version and commit and the config fingerprint to /status
+	StatusBuildInfo bool `mapstructure:"status_build_info"`
+
+	// Stamp every row of a cycle with the cycle start instead of its own
+	// fetch time, rounded down to a multiple of QueriedAtRounding when set
+	SharedQueriedAt   bool   `mapstructure:"shared_queried_at"`
+	QueriedAtRounding string `mapstructure:"queried_at_rounding" validate:"omitempty,positive_duration"`
+
+	// Store the latest block's timestamp with each row to measure RPC lag
+	RecordBlockTimestamp bool `mapstructure:"record_block_timestamp"`
+
+	// Store the duration of each balanceOf read, retries included
+	RecordFetchLatency bool `mapstructure:"record_fetch_latency"`
+
+	// Which retrieved balances are logged at info level: all (default),
+	// changed, none, or a number N to log every Nth poll of each token
+	LogBalanceSampling string `mapstructure:"log_balance_sampling" validate:"omitempty,balance_sampling"`
+
+	// Log the cycle progress every N token queries; 0 disables the log line
+	ProgressLogEvery int `mapstructure:"progress_log_every" validate:"omitempty,min=1"`
+
+	// Wallets processed at once in a cycle; 0 or 1 processes them one by one
+	WalletConcurrency int `mapstructure:"wallet_concurrency" validate:"omitempty,min=1"`
+
+	// Tokens of a wallet fetched and persisted together; each chunk is stored
+	// before the next is fetched. 0 processes all the tokens of a wallet at once
+	TokenChunkSize int `mapstructure:"token_chunk_size" validate:"omitempty,min=1"`
+
+	// On a failed token query, store the last known balance again flagged as
+	// carried_forward, so series have no gaps
+	CarryForwardOnFailure bool `mapstructure:"carry_forward_on_failure"`
+
+	// Re-read a zero balance once when the last persisted one was not zero,
+	// on another RPC endpoint when possible, before storing it
+	VerifyZero bool `mapstructure:"verify_zero"`
+
+	// Multicall3 contract used to read all the tokens of a wallet in one
+	// eth_call; empty queries every token separately
+	MulticallAddress string `mapstructure:"multicall_address" validate:"omitempty,eth_addr"`
+
+	// Chain every RPC endpoint must serve (100 for Gnosis); 0 only requires
+	// the endpoints to agree
+	ChainID uint64 `mapstructure:"chain_id"`
+
+	// Where token USD prices come from to fill usd_value: off (default) or
+	// static, the usd_price of each [[tokens]] entry
+	PriceSource string `mapstructure:"price_source" validate:"omitempty,oneof=off static"`
+
+	// Key of token series in aggregate reads: on-chain symbol or configured label
+	SeriesKey string `mapstructure:"series_key" validate:"omitempty,oneof=symbol label"`
+
+	// Warn at daemon startup when the local clock and the latest block time
+	// differ by more than this; 0 disables the check
+	MaxClockSkew time.Duration `mapstructure:"max_clock_skew" validate:"omitempty,gt=0"`
 }
 
-// Normalize converts single rpc_url to rpc_urls array for backward compatibility
+// Normalize converts single rpc_url to rpc_urls array for backward compatibility,
+// fills rpc_urls from rpc_endpoints and wallets from labeled_wallets
 func (cfg *Config) Normalize() error {
+	// Case 0: rpc_endpoints set -> rpc_urls lists their URLs
+	if len(cfg.RPCEndpoints) > 0 {
+		urls := make([]string, len(cfg.RPCEndpoints))
+		for i, ep := range cfg.RPCEndpoints {
+			urls[i] = ep.URL
+		}
+		if cfg.RPCUrl != "" || (len(cfg.RPCUrls) > 0 && !slices.Equal(cfg.RPCUrls, urls)) {
+			return fmt.Errorf("rpc_endpoints cannot be combined with rpc_url or rpc_urls")
+		}
+		cfg.RPCUrls = urls
+	}
+
+	// Labeled wallets are polled like the plain ones
+	for _, w := range cfg.LabeledWallets {
+		if !slices.ContainsFunc(cfg.Wallets, func(addr string) bool { return strings.EqualFold(addr, w.Address) }) {
+			cfg.Wallets = append(cfg.Wallets, w.Address)
+		}
+	}
+
 	// Case 1: Only rpc_url set -> convert to rpc_urls
 	if cfg.RPCUrl != "" && len(cfg.RPCUrls) == 0 {
 		cfg.RPCUrls = []string{cfg.RPCUrl}
@@ -48,11 +210,179 @@ func (cfg *Config) Normalize() error {
 	return nil
 }
 
+// ChecksumAddresses rewrites every wallet, token and contract address in its
+// EIP-55 checksum form, so stored rows and lookups do not depend on the case
+// the addresses were typed in. It runs once the addresses are validated.
+func (cfg *Config) ChecksumAddresses() {
+	checksum := func(addr string) string {
+		if addr == "" {
+			return ""
+		}
+		return common.HexToAddress(addr).Hex()
+	}
+
+	wallets := slices.Clone(cfg.Wallets)
+	for i := range wallets {
+		wallets[i] = checksum(wallets[i])
+	}
+	cfg.Wallets = wallets
+
+	labeled := slices.Clone(cfg.LabeledWallets)
+	for i := range labeled {
+		labeled[i].Address = checksum(labeled[i].Address)
+	}
+	cfg.LabeledWallets = labeled
+
+	tokens := slices.Clone(cfg.Tokens)
+	for i := range tokens {
+		tokens[i].Address = checksum(tokens[i].Address)
+	}
+	cfg.Tokens = tokens
+
+	cfg.TokenDiscoveryPool = checksum(cfg.TokenDiscoveryPool)
+	cfg.MulticallAddress = checksum(cfg.MulticallAddress)
+}
+
 // TokenConfig represents a single token configuration
 type TokenConfig struct {
 	Label            string `mapstructure:"label" validate:"required,min=1,max=100"`
 	Address          string `mapstructure:"address" validate:"required,eth_addr"`
 	FallbackDecimals uint8  `mapstructure:"fallback_decimals" validate:"required,min=0,max=255"`
+	// Optional per-token cadence, a duration or a cron expression: the token
+	// is skipped on cycles until this much time has elapsed, or the cron
+	// expression has fired, since its last poll
+	Interval string `mapstructure:"interval" validate:"omitempty,token_interval"`
+	// USD price used by price_source = "static", e.g. 1 for a stablecoin
+	USDPrice float64 `mapstructure:"usd_price" validate:"omitempty,gt=0"`
+	// Read decimals and symbol on every poll instead of caching them, for
+	// tokens behind an upgradeable proxy
+	RefreshMetadata bool `mapstructure:"refresh_metadata"`
+}
+
+// PollCron returns the token's own cron schedule, or "" when the token has
+// none or a duration interval.
+func (t TokenConfig) PollCron() string {
+	if t.Interval == "" {
+		return ""
+	}
+	if _, err := time.ParseDuration(t.Interval); err == nil {
+		return ""
+	}
+	return t.Interval
+}
+
+// PollInterval returns the token's own polling interval, or 0 when the token
+// follows the global schedule
+func (t TokenConfig) PollInterval() time.Duration {
+	d, err := time.ParseDuration(t.Interval)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// DatabaseConfig represents an additional database write target
+type DatabaseConfig struct {
+	Name string `mapstructure:"name" validate:"required,min=1,max=100"`
+	URL  string `mapstructure:"url" validate:"required"`
+}
+
+// RPCEndpointConfig is an RPC endpoint with its failover priority: the
+// healthy endpoint with the lowest priority serves every call.
+type RPCEndpointConfig struct {
+	URL      string `mapstructure:"url" validate:"required,url"`
+	Priority int    `mapstructure:"priority" validate:"min=0"`
+}
+
+// Modes of log_balance_sampling besides a positive number N
+const (
+	BalanceSamplingAll     = "all"
+	BalanceSamplingChanged = "changed"
+	BalanceSamplingNone    = "none"
+)
+
+// Values of price_source
+const (
+	PriceSourceOff    = "off"
+	PriceSourceStatic = "static"
+)
+
+// WalletConfig is a wallet with a label and tags, e.g. strategy = "hedge".
+// The tags are stored with every balance of the wallet so rows can be
+// selected by tag.
+type WalletConfig struct {
+	Address string            `mapstructure:"address" validate:"required,eth_addr"`
+	Label   string            `mapstructure:"label" validate:"omitempty,max=100"`
+	Tags    map[string]string `mapstructure:"tags" validate:"omitempty,dive,keys,min=1,endkeys"`
+}
+
+// WalletTags returns the tags of a labeled wallet, or nil for a plain one.
+func (cfg *Config) WalletTags(wallet string) map[string]string {
+	for _, w := range cfg.LabeledWallets {
+		if strings.EqualFold(w.Address, wallet) {
+			return w.Tags
+		}
+	}
+	return nil
+}
+
+// WalletLabel returns the label of a labeled wallet, or "" for a plain one.
+func (cfg *Config) WalletLabel(wallet string) string {
+	for _, w := range cfg.LabeledWallets {
+		if strings.EqualFold(w.Address, wallet) {
+			return w.Label
+		}
+	}
+	return ""
+}
+
+// Endpoints returns the RPC endpoints with their priorities. Endpoints listed
+// in rpc_urls share priority 0 and are preferred in list order.
+func (cfg *Config) Endpoints() []RPCEndpointConfig {
+	if len(cfg.RPCEndpoints) > 0 {
+		return cfg.RPCEndpoints
+	}
+	endpoints := make([]RPCEndpointConfig, len(cfg.RPCUrls))
+	for i, url := range cfg.RPCUrls {
+		endpoints[i] = RPCEndpointConfig{URL: url}
+	}
+	return endpoints
+}
+
+// RetentionPeriod returns how long balances are kept, 0 when forever.
+func (cfg *Config) RetentionPeriod() time.Duration {
+	d, err := ParseRetention(cfg.Retention)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ParseRetention parses a retention period: a number of days such as "90d",
+// or a Go duration such as "2160h". It must be positive.
+func ParseRetention(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid retention %q: %w", s, err)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid retention %q: %w", s, err)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid retention %q: must be positive", s)
+	}
+	return d, nil
+}
+
+// WriteAllOrNothing reports whether a write must succeed on every database target
+func (cfg *Config) WriteAllOrNothing() bool {
+	return cfg.DatabaseWriteMode == "all_or_nothing"
 }
 
 // ethAddressValidator validates Ethereum addresses
@@ -69,6 +399,12 @@ func durationValidator(fl validator.FieldLevel) bool {
 	return err == nil
 }
 
+// positiveDurationValidator validates duration strings greater than zero
+func positiveDurationValidator(fl validator.FieldLevel) bool {
+	d, err := time.ParseDuration(fl.Field().String())
+	return err == nil && d > 0
+}
+
 // scheduleValidator validates schedule intervals (duration or cron expression)
 func scheduleValidator(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
@@ -78,6 +414,35 @@ func scheduleValidator(fl validator.FieldLevel) bool {
 	return scheduler.ValidateScheduleInterval(value) == nil
 }
 
+// tokenIntervalValidator validates per-token intervals: a positive duration,
+// not necessarily clock-aligned, or a cron expression
+func tokenIntervalValidator(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if d, err := time.ParseDuration(value); err == nil {
+		return d > 0
+	}
+	_, err := scheduler.NextCronTime(value, time.Now())
+	return err == nil
+}
+
+// balanceSamplingValidator validates log_balance_sampling: a mode or a
+// positive number
+func balanceSamplingValidator(fl validator.FieldLevel) bool {
+	switch value := fl.Field().String(); value {
+	case BalanceSamplingAll, BalanceSamplingChanged, BalanceSamplingNone:
+		return true
+	default:
+		n, err := strconv.Atoi(value)
+		return err == nil && n > 0
+	}
+}
+
+// retentionValidator validates retention periods
+func retentionValidator(fl validator.FieldLevel) bool {
+	_, err := ParseRetention(fl.Field().String())
+	return err == nil
+}
+
 // timezoneValidator validates timezone strings
 func timezoneValidator(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
@@ -98,16 +463,85 @@ func NewValidator() *validator.Validate {
 	}{
 		{"eth_addr", ethAddressValidator},
 		{"duration", durationValidator},
+		{"positive_duration", positiveDurationValidator},
 		{"schedule", scheduleValidator},
 		{"timezone", timezoneValidator},
+		{"balance_sampling", balanceSamplingValidator},
+		{"retention", retentionValidator},
+		{"token_interval", tokenIntervalValidator},
 	} {
 		if err := validate.RegisterValidation(rv.tag, rv.fn); err != nil {
 			panic("config: register validator " + rv.tag + ": " + err.Error())
 		}
 	}
+	validate.RegisterStructValidation(uniqueAddressesValidator, Config{})
 	return validate
 }
 
+// uniqueAddressesValidator rejects a wallet or token address listed twice,
+// which would store every balance twice per poll. Addresses are compared
+// checksummed, so 0xAbC and 0xabc are the same; the reported field names the
+// duplicate, e.g. Wallets[0xAbC…].
+func uniqueAddressesValidator(sl validator.StructLevel) {
+	cfg, ok := sl.Current().Interface().(Config)
+	if !ok {
+		return
+	}
+	tokens := make([]string, len(cfg.Tokens))
+	for i, t := range cfg.Tokens {
+		tokens[i] = t.Address
+	}
+	labeled := make([]string, len(cfg.LabeledWallets))
+	for i, w := range cfg.LabeledWallets {
+		labeled[i] = w.Address
+	}
+	for _, list := range []struct {
+		field     string
+		addresses []string
+	}{
+		{"Wallets", cfg.Wallets},
+		{"LabeledWallets", labeled},
+		{"Tokens", tokens},
+	} {
+		for _, addr := range duplicateAddresses(list.addresses) {
+			sl.ReportError(addr, list.field+"["+addr+"]", list.field, "unique_address", addr)
+		}
+	}
+}
+
+// duplicateAddresses returns, checksummed, the addresses listed more than
+// once. Invalid addresses are left to the eth_addr validator.
+func duplicateAddresses(addresses []string) []string {
+	seen := make(map[string]int, len(addresses))
+	var duplicates []string
+	for _, a := range addresses {
+		if !common.IsHexAddress(a) {
+			continue
+		}
+		addr := common.HexToAddress(a).Hex()
+		if seen[addr]++; seen[addr] == 2 {
+			duplicates = append(duplicates, addr)
+		}
+	}
+	return duplicates
+}
+
+// validateJitter checks the jitter is shorter than the interval, which the
+// validation tags cannot express.
+func (cfg *Config) validateJitter() error {
+	if cfg.Jitter <= 0 || cfg.Interval == "" {
+		return nil
+	}
+	interval, err := scheduler.EffectiveInterval(cfg.Interval)
+	if err != nil {
+		return nil // reported by the schedule validator
+	}
+	if cfg.Jitter >= interval {
+		return fmt.Errorf("jitter %s must be shorter than the interval %s", cfg.Jitter, interval)
+	}
+	return nil
+}
+
 // IsCronExpression checks if the interval is a cron expression vs duration
 func (cfg *Config) IsCronExpression() bool {
 	if cfg.Interval == "" {
@@ -142,6 +576,15 @@ func (cfg *Config) GetTimezone() *time.Location {
 	return loc
 }
 
+// ShouldServeMetrics returns whether to collect and serve Prometheus metrics
+// Defaults to true if not explicitly set
+func (cfg *Config) ShouldServeMetrics() bool {
+	if cfg.MetricsEnabled == nil {
+		return true // default
+	}
+	return *cfg.MetricsEnabled
+}
+
 // ShouldRunImmediately returns whether to run immediately on startup
 // Defaults to true if not explicitly set
 func (cfg *Config) ShouldRunImmediately() bool {
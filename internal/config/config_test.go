@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -58,6 +59,37 @@ func TestConfigNormalize(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "rpc_endpoints fill rpc_urls in list order",
+			cfg: &Config{
+				RPCEndpoints: []RPCEndpointConfig{
+					{URL: "https://public.example.com", Priority: 10},
+					{URL: "https://paid.example.com", Priority: 0},
+				},
+			},
+			wantError: false,
+			check: func(c *Config) {
+				assert.Equal(t, []string{"https://public.example.com", "https://paid.example.com"}, c.RPCUrls)
+				assert.Equal(t, c.RPCEndpoints, c.Endpoints())
+				require.NoError(t, c.Normalize(), "normalizing twice is a no-op")
+			},
+		},
+		{
+			name: "rpc_endpoints combined with rpc_urls returns error",
+			cfg: &Config{
+				RPCUrls:      []string{"https://rpc1.example.com"},
+				RPCEndpoints: []RPCEndpointConfig{{URL: "https://rpc2.example.com"}},
+			},
+			wantError: true,
+		},
+		{
+			name: "rpc_endpoints combined with rpc_url returns error",
+			cfg: &Config{
+				RPCUrl:       "https://rpc1.example.com",
+				RPCEndpoints: []RPCEndpointConfig{{URL: "https://rpc2.example.com"}},
+			},
+			wantError: true,
+		},
 		{
 			name: "rpc_urls already set, no change",
 			cfg: &Config{
@@ -68,6 +100,7 @@ func TestConfigNormalize(t *testing.T) {
 			check: func(c *Config) {
 				assert.Empty(t, c.RPCUrl)
 				assert.Equal(t, []string{"https://rpc1.example.com"}, c.RPCUrls)
+				assert.Equal(t, []RPCEndpointConfig{{URL: "https://rpc1.example.com"}}, c.Endpoints())
 			},
 		},
 	}
@@ -117,6 +150,12 @@ func TestConfigGetTimezone(t *testing.T) {
 	}
 }
 
+func TestConfigShouldServeMetrics(t *testing.T) {
+	disabled := false
+	assert.True(t, (&Config{}).ShouldServeMetrics(), "enabled by default")
+	assert.False(t, (&Config{MetricsEnabled: &disabled}).ShouldServeMetrics())
+}
+
 func TestConfigShouldRunImmediately(t *testing.T) {
 	trueVal := true
 	falseVal := false
@@ -271,6 +310,66 @@ func TestTokenConfigValidation(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "valid per-token interval",
+			token: TokenConfig{
+				Label:            "TEST",
+				Address:          "0x0000000000000000000000000000000000000000",
+				FallbackDecimals: 18,
+				Interval:         "15m",
+			},
+			wantError: false,
+		},
+		{
+			name: "cron per-token interval",
+			token: TokenConfig{
+				Label:            "TEST",
+				Address:          "0x0000000000000000000000000000000000000000",
+				FallbackDecimals: 18,
+				Interval:         "0 */6 * * *",
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid cron per-token interval",
+			token: TokenConfig{
+				Label:            "TEST",
+				Address:          "0x0000000000000000000000000000000000000000",
+				FallbackDecimals: 18,
+				Interval:         "0 */6 * * * * *",
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid per-token interval",
+			token: TokenConfig{
+				Label:            "TEST",
+				Address:          "0x0000000000000000000000000000000000000000",
+				FallbackDecimals: 18,
+				Interval:         "often",
+			},
+			wantError: true,
+		},
+		{
+			name: "negative per-token interval",
+			token: TokenConfig{
+				Label:            "TEST",
+				Address:          "0x0000000000000000000000000000000000000000",
+				FallbackDecimals: 18,
+				Interval:         "-5m",
+			},
+			wantError: true,
+		},
+		{
+			name: "zero per-token interval",
+			token: TokenConfig{
+				Label:            "TEST",
+				Address:          "0x0000000000000000000000000000000000000000",
+				FallbackDecimals: 18,
+				Interval:         "0s",
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -290,6 +389,17 @@ func TestTokenConfigValidation(t *testing.T) {
 	}
 }
 
+func TestTokenConfigPollInterval(t *testing.T) {
+	assert.Equal(t, time.Duration(0), TokenConfig{}.PollInterval())
+	assert.Equal(t, 15*time.Minute, TokenConfig{Interval: "15m"}.PollInterval())
+	assert.Equal(t, time.Duration(0), TokenConfig{Interval: "-5m"}.PollInterval())
+
+	assert.Empty(t, TokenConfig{}.PollCron())
+	assert.Empty(t, TokenConfig{Interval: "15m"}.PollCron())
+	assert.Equal(t, "0 */6 * * *", TokenConfig{Interval: "0 */6 * * *"}.PollCron())
+	assert.Equal(t, time.Duration(0), TokenConfig{Interval: "0 */6 * * *"}.PollInterval())
+}
+
 func TestConfigHTTPPortValidation(t *testing.T) {
 	validator := NewValidator()
 
@@ -409,3 +519,367 @@ func TestConfigLogLevelValidation(t *testing.T) {
 		})
 	}
 }
+
+// newTestConfig returns a minimal valid config for validation tests.
+func newTestConfig() *Config {
+	return &Config{
+		RPCUrls: []string{"https://rpc.example.com"},
+		Wallets: []string{"0x1234567890123456789012345678901234567890"},
+		Tokens: []TokenConfig{
+			{Label: "TEST", Address: "0x0000000000000000000000000000000000000000", FallbackDecimals: 18},
+		},
+	}
+}
+
+func TestConfigDBTimeoutValidation(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name             string
+		connectTimeout   time.Duration
+		statementTimeout time.Duration
+		wantError        bool
+	}{
+		{"unset is valid", 0, 0, false},
+		{"positive timeouts", 5 * time.Second, 30 * time.Second, false},
+		{"negative connect timeout", -time.Second, 0, true},
+		{"negative statement timeout", 0, -time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.DBConnectTimeout = tt.connectTimeout
+			cfg.DBStatementTimeout = tt.statementTimeout
+			err := validator.Struct(cfg)
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigRPCRetryValidation(t *testing.T) {
+	validator := NewValidator()
+	retries := func(n int) *int { return &n }
+
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		maxRetries *int
+		wantError  bool
+	}{
+		{"unset is valid", 0, nil, false},
+		{"no retries", 5 * time.Second, retries(0), false},
+		{"maximum retries", 0, retries(10), false},
+		{"negative timeout", -time.Second, nil, true},
+		{"negative retries", 0, retries(-1), true},
+		{"too many retries", 0, retries(11), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.RPCTimeout = tt.timeout
+			cfg.RPCMaxRetries = tt.maxRetries
+			err := validator.Struct(cfg)
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigDatabasesValidation(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name      string
+		databases []DatabaseConfig
+		mode      string
+		wantError bool
+	}{
+		{"no extra databases", nil, "", false},
+		{"valid target", []DatabaseConfig{{Name: "analytics", URL: "postgres://localhost/analytics"}}, "best_effort", false},
+		{"missing name", []DatabaseConfig{{URL: "postgres://localhost/analytics"}}, "", true},
+		{"missing url", []DatabaseConfig{{Name: "analytics"}}, "", true},
+		{"invalid write mode", nil, "sometimes", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Databases = tt.databases
+			cfg.DatabaseWriteMode = tt.mode
+			err := validator.Struct(cfg)
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigUniqueAddressesValidation(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.Wallets = append(cfg.Wallets, "0x0000000000000000000000000000000000000001")
+	cfg.Tokens = append(cfg.Tokens, TokenConfig{Label: "USDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6})
+	assert.NoError(t, validator.Struct(cfg))
+
+	cfg = newTestConfig()
+	cfg.Wallets = append(cfg.Wallets, "0x1234567890123456789012345678901234567890")
+	err := validator.Struct(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Wallets[0x1234567890123456789012345678901234567890]")
+
+	cfg = newTestConfig()
+	cfg.Tokens = append(cfg.Tokens,
+		TokenConfig{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+		TokenConfig{Label: "armmUSDC again", Address: "0xed56f76e9cbc6a64b821e9c016eafbd3db5436d1", FallbackDecimals: 6})
+	err = validator.Struct(cfg)
+	require.Error(t, err, "case differences do not hide a duplicate")
+	assert.Contains(t, err.Error(), "Tokens[0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1]", "named checksummed")
+
+	cfg = newTestConfig()
+	cfg.LabeledWallets = []WalletConfig{
+		{Address: "0x0000000000000000000000000000000000000001", Label: "Savings"},
+		{Address: "0x0000000000000000000000000000000000000001", Label: "Hedge"},
+	}
+	assert.ErrorContains(t, validator.Struct(cfg), "LabeledWallets[0x0000000000000000000000000000000000000001]")
+}
+
+func TestConfigRPCSelectionValidation(t *testing.T) {
+	validator := NewValidator()
+
+	for _, selection := range []string{"", "priority", "round_robin"} {
+		cfg := newTestConfig()
+		cfg.RPCSelection = selection
+		assert.NoError(t, validator.Struct(cfg), selection)
+	}
+
+	cfg := newTestConfig()
+	cfg.RPCSelection = "random"
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigRPCCircuitBreakerValidation(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name      string
+		threshold int
+		window    time.Duration
+		wantError bool
+	}{
+		{"unset is valid", 0, 0, false},
+		{"three failures a minute", 3, time.Minute, false},
+		{"negative threshold", -1, 0, true},
+		{"threshold too high", 101, 0, true},
+		{"negative window", 3, -time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.RPCFailureThreshold = tt.threshold
+			cfg.RPCFailureWindow = tt.window
+			err := validator.Struct(cfg)
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigJitterValidation(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Interval = "5m"
+	cfg.Jitter = 30 * time.Second
+	assert.NoError(t, NewValidator().Struct(cfg))
+	assert.NoError(t, cfg.validateJitter())
+
+	cfg.Jitter = 5 * time.Minute
+	assert.ErrorContains(t, cfg.validateJitter(), "jitter 5m0s must be shorter than the interval 5m0s")
+
+	cfg.Interval = "0 9,17 * * *"
+	cfg.Jitter = time.Hour
+	assert.NoError(t, cfg.validateJitter(), "cron schedules use their shortest gap")
+
+	cfg.Jitter = -time.Second
+	assert.Error(t, NewValidator().Struct(cfg))
+}
+
+func TestConfigRunTimeoutValidation(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.RunTimeout = 4 * time.Minute
+	assert.NoError(t, validator.Struct(cfg))
+
+	cfg.RunTimeout = -time.Second
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigTokenDiscoveryPoolValidation(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.TokenDiscoveryPool = "0x5B8D36De471880Ee21936f328AAB2383a280CB2A"
+	assert.NoError(t, validator.Struct(cfg))
+
+	cfg.TokenDiscoveryPool = "not-an-address"
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigTokensOptionalWithDiscoveryPool(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.Tokens = nil
+	assert.Error(t, validator.Struct(cfg), "tokens are required without a discovery pool")
+
+	cfg.TokenDiscoveryPool = "0x5B8D36De471880Ee21936f328AAB2383a280CB2A"
+	assert.NoError(t, validator.Struct(cfg), "discovered tokens replace the configured list")
+}
+
+func TestConfigMigrationRetryValidation(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.MigrationMaxAttempts = 10
+	cfg.MigrationRetryDelay = 5 * time.Second
+	assert.NoError(t, validator.Struct(cfg))
+
+	cfg.MigrationMaxAttempts = 50
+	assert.Error(t, validator.Struct(cfg))
+
+	cfg.MigrationMaxAttempts = 0
+	cfg.MigrationRetryDelay = -time.Second
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigDBBufferSizeValidation(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.DBBufferSize = 10000
+	assert.NoError(t, validator.Struct(cfg))
+
+	cfg.DBBufferSize = -1
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestParseRetention(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"90d":   90 * 24 * time.Hour,
+		"1d":    24 * time.Hour,
+		"2160h": 2160 * time.Hour,
+		"30m":   30 * time.Minute,
+	} {
+		got, err := ParseRetention(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "0d", "-1d", "d", "1.5d", "90 days", "0s"} {
+		_, err := ParseRetention(in)
+		assert.Error(t, err, in)
+	}
+
+	validator := NewValidator()
+	cfg := newTestConfig()
+	assert.NoError(t, validator.Struct(cfg))
+	assert.Zero(t, cfg.RetentionPeriod(), "no retention keeps every row")
+	cfg.Retention = "90d"
+	assert.NoError(t, validator.Struct(cfg))
+	assert.Equal(t, 90*24*time.Hour, cfg.RetentionPeriod())
+	cfg.Retention = "forever"
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigDecimalsPolicyValidation(t *testing.T) {
+	validator := NewValidator()
+
+	for _, policy := range []string{"", "canonical", "per_row"} {
+		cfg := newTestConfig()
+		cfg.DecimalsPolicy = policy
+		assert.NoError(t, validator.Struct(cfg), policy)
+	}
+
+	cfg := newTestConfig()
+	cfg.DecimalsPolicy = "latest"
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigMaxDecimalsValidation(t *testing.T) {
+	validator := NewValidator()
+
+	cfg := newTestConfig()
+	cfg.MaxDecimals = 36
+	cfg.MaxDecimalsPolicy = "reject"
+	assert.NoError(t, validator.Struct(cfg))
+
+	cfg.MaxDecimals = 256
+	assert.Error(t, validator.Struct(cfg))
+
+	cfg = newTestConfig()
+	cfg.MaxDecimalsPolicy = "ignore"
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigSeriesKeyValidation(t *testing.T) {
+	validator := NewValidator()
+
+	for _, key := range []string{"", "symbol", "label"} {
+		cfg := newTestConfig()
+		cfg.SeriesKey = key
+		assert.NoError(t, validator.Struct(cfg), key)
+	}
+
+	cfg := newTestConfig()
+	cfg.SeriesKey = "address"
+	assert.Error(t, validator.Struct(cfg))
+}
+
+func TestConfigLogBalanceSamplingValidation(t *testing.T) {
+	validator := NewValidator()
+
+	for _, sampling := range []string{"", "all", "changed", "none", "1", "10"} {
+		cfg := newTestConfig()
+		cfg.LogBalanceSampling = sampling
+		assert.NoError(t, validator.Struct(cfg), sampling)
+	}
+
+	for _, sampling := range []string{"0", "-5", "some", "ALL"} {
+		cfg := newTestConfig()
+		cfg.LogBalanceSampling = sampling
+		assert.Error(t, validator.Struct(cfg), sampling)
+	}
+}
+
+func TestConfigPriceSourceValidation(t *testing.T) {
+	validator := NewValidator()
+
+	for _, source := range []string{"", "off", "static"} {
+		cfg := newTestConfig()
+		cfg.PriceSource = source
+		assert.NoError(t, validator.Struct(cfg), source)
+	}
+
+	cfg := newTestConfig()
+	cfg.PriceSource = "coingecko"
+	assert.Error(t, validator.Struct(cfg))
+
+	cfg = newTestConfig()
+	cfg.Tokens[0].USDPrice = -1
+	assert.Error(t, validator.Struct(cfg))
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+)
+
+// fingerprintLength is the number of hex digits kept from the SHA-256.
+const fingerprintLength = 12
+
+// Fingerprint returns a short hash of the configuration, to tell instances
+// running with different configs apart. URLs are reduced to their scheme and
+// host first so credentials and API keys never reach the hash: rotating a
+// key leaves the fingerprint unchanged.
+func (cfg *Config) Fingerprint() string {
+	redacted := *cfg
+	redacted.RPCUrl = redactURL(cfg.RPCUrl)
+	redacted.RPCUrls = make([]string, len(cfg.RPCUrls))
+	for i, u := range cfg.RPCUrls {
+		redacted.RPCUrls[i] = redactURL(u)
+	}
+	redacted.RPCEndpoints = make([]RPCEndpointConfig, len(cfg.RPCEndpoints))
+	for i, ep := range cfg.RPCEndpoints {
+		redacted.RPCEndpoints[i] = RPCEndpointConfig{URL: redactURL(ep.URL), Priority: ep.Priority}
+	}
+	redacted.Databases = make([]DatabaseConfig, len(cfg.Databases))
+	for i, db := range cfg.Databases {
+		redacted.Databases[i] = DatabaseConfig{Name: db.Name, URL: redactURL(db.URL)}
+	}
+
+	// Struct fields are encoded in declaration order and map keys sorted,
+	// so equal configs always produce the same bytes. Config only holds
+	// plain values, which cannot fail to encode.
+	data, _ := json.Marshal(redacted)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// redactURL keeps the scheme and host of a URL, dropping the user info,
+// path and query that may carry secrets.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "redacted"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -2,13 +2,94 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/viper"
 )
 
+// EnvVar selects an environment overlay (see OverlayPath).
+const EnvVar = "RMM_TRACKER_ENV"
+
+// ConfigNames are the config files searched for in each of SearchPaths, in
+// order of precedence. The format follows the extension.
+var ConfigNames = []string{"config.toml", "config.yaml", "config.json"}
+
+// SearchPaths returns the directories searched for ConfigNames when no
+// config file is given, in order of precedence: the working directory,
+// $XDG_CONFIG_HOME/rmm-tracker (when set), $HOME/.config/rmm-tracker and
+// /etc/rmm-tracker. The first directory holding the file wins.
+func SearchPaths() []string {
+	paths := []string{"."}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "rmm-tracker"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "rmm-tracker"))
+	}
+	return append(paths, "/etc/rmm-tracker")
+}
+
+// SetConfigFile points v at configPath, or when empty at the first of
+// ConfigNames found in SearchPaths, and returns that file, "" when none was
+// found. The format follows the extension; a file without one is read as TOML.
+func SetConfigFile(v *viper.Viper, configPath string) string {
+	if configPath == "" {
+		configPath = findConfigFile()
+		if configPath == "" {
+			return ""
+		}
+	}
+	v.SetConfigFile(configPath)
+	if filepath.Ext(configPath) == "" {
+		v.SetConfigType("toml")
+	}
+	return configPath
+}
+
+// findConfigFile returns the first of ConfigNames found in SearchPaths, or "".
+func findConfigFile() string {
+	for _, dir := range SearchPaths() {
+		for _, name := range ConfigNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
 // Load reads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
+	return LoadLayered(configPath, "")
+}
+
+// OverlayPath returns the overlay file to merge over the base config: overlay
+// when set, otherwise the file derived from RMM_TRACKER_ENV next to the base
+// config (config.toml + RMM_TRACKER_ENV=prod gives config.prod.toml), or ""
+// for none.
+func OverlayPath(configPath, overlay string) string {
+	if overlay != "" {
+		return overlay
+	}
+	env := os.Getenv(EnvVar)
+	if env == "" {
+		return ""
+	}
+	if configPath == "" {
+		configPath = "config.toml"
+	}
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + "." + env + ext
+}
+
+// LoadLayered reads the base configuration, merges the overlay file (see
+// OverlayPath) over it, then applies environment variables. Keys set in the
+// overlay override the base; arrays such as wallets and [[tokens]] are
+// replaced as a whole, not appended to.
+func LoadLayered(configPath, overlay string) (*Config, error) {
 	v := viper.New()
 
 	// 1. Set defaults
@@ -18,15 +99,10 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("http_port", 8080)
 	v.SetDefault("run_immediately", true)
 	v.SetDefault("timezone", "UTC")
+	v.SetDefault("database_write_mode", "best_effort")
 
 	// 2. Configure config file
-	if configPath != "" {
-		v.SetConfigFile(configPath)
-	} else {
-		v.SetConfigName("config")
-		v.SetConfigType("toml")
-		v.AddConfigPath(".")
-	}
+	basePath := SetConfigFile(v, configPath)
 
 	// 3. Environment variables
 	v.SetEnvPrefix("RMM_TRACKER")
@@ -35,15 +111,61 @@ func Load(configPath string) (*Config, error) {
 	// Map environment variables to config keys (RMM_TRACKER_* prefix is set above).
 	// BindEnv only fails for an empty key, which is a programming error — panic is appropriate.
 	for key, env := range map[string]string{
-		"rpc_url":         "RPC_URL",
-		"rpc_urls":        "RPC_URLS",
-		"wallets":         "WALLETS",
-		"log_level":       "LOG_LEVEL",
-		"log_format":      "LOG_FORMAT",
-		"interval":        "INTERVAL",
-		"http_port":       "HTTP_PORT",
-		"run_immediately": "RUN_IMMEDIATELY",
-		"timezone":        "TIMEZONE",
+		"rpc_url":                  "RPC_URL",
+		"rpc_urls":                 "RPC_URLS",
+		"wallets":                  "WALLETS",
+		"tokens":                   "TOKENS",
+		"token_discovery_pool":     "TOKEN_DISCOVERY_POOL",
+		"log_level":                "LOG_LEVEL",
+		"log_format":               "LOG_FORMAT",
+		"log_file":                 "LOG_FILE",
+		"log_max_size_mb":          "LOG_MAX_SIZE_MB",
+		"log_max_backups":          "LOG_MAX_BACKUPS",
+		"interval":                 "INTERVAL",
+		"http_port":                "HTTP_PORT",
+		"run_immediately":          "RUN_IMMEDIATELY",
+		"fail_on_first_run":        "FAIL_ON_FIRST_RUN",
+		"timezone":                 "TIMEZONE",
+		"jitter":                   "JITTER",
+		"run_timeout":              "RUN_TIMEOUT",
+		"db_connect_timeout":       "DB_CONNECT_TIMEOUT",
+		"db_statement_timeout":     "DB_STATEMENT_TIMEOUT",
+		"migration_max_attempts":   "MIGRATION_MAX_ATTEMPTS",
+		"db_buffer_size":           "DB_BUFFER_SIZE",
+		"migration_retry_delay":    "MIGRATION_RETRY_DELAY",
+		"metrics_enabled":          "METRICS_ENABLED",
+		"metrics_exemplars":        "METRICS_EXEMPLARS",
+		"status_build_info":        "STATUS_BUILD_INFO",
+		"decimals_policy":          "DECIMALS_POLICY",
+		"max_decimals":             "MAX_DECIMALS",
+		"max_decimals_policy":      "MAX_DECIMALS_POLICY",
+		"record_block_timestamp":   "RECORD_BLOCK_TIMESTAMP",
+		"shared_queried_at":        "SHARED_QUERIED_AT",
+		"queried_at_rounding":      "QUERIED_AT_ROUNDING",
+		"record_fetch_latency":     "RECORD_FETCH_LATENCY",
+		"progress_log_every":       "PROGRESS_LOG_EVERY",
+		"rpc_health_ttl":           "RPC_HEALTH_TTL",
+		"rpc_timeout":              "RPC_TIMEOUT",
+		"rpc_max_retries":          "RPC_MAX_RETRIES",
+		"rpc_probe_interval":       "RPC_PROBE_INTERVAL",
+		"rpc_rate_limit":           "RPC_RATE_LIMIT",
+		"rpc_failure_threshold":    "RPC_FAILURE_THRESHOLD",
+		"rpc_failure_window":       "RPC_FAILURE_WINDOW",
+		"rpc_race":                 "RPC_RACE",
+		"rpc_selection":            "RPC_SELECTION",
+		"wallet_concurrency":       "WALLET_CONCURRENCY",
+		"series_key":               "SERIES_KEY",
+		"max_clock_skew":           "MAX_CLOCK_SKEW",
+		"db_insert_batch_size":     "DB_INSERT_BATCH_SIZE",
+		"dedup_unchanged":          "DEDUP_UNCHANGED",
+		"retention":                "RETENTION",
+		"verify_zero":              "VERIFY_ZERO",
+		"log_balance_sampling":     "LOG_BALANCE_SAMPLING",
+		"price_source":             "PRICE_SOURCE",
+		"chain_id":                 "CHAIN_ID",
+		"carry_forward_on_failure": "CARRY_FORWARD_ON_FAILURE",
+		"multicall_address":        "MULTICALL_ADDRESS",
+		"token_chunk_size":         "TOKEN_CHUNK_SIZE",
 	} {
 		if err := v.BindEnv(key, env); err != nil {
 			panic("config: bind env " + key + ": " + err.Error())
@@ -51,17 +173,42 @@ func Load(configPath string) (*Config, error) {
 	}
 
 	// 4. Read config file
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+	if basePath != "" {
+		if err := v.ReadInConfig(); err != nil {
 			return nil, fmt.Errorf("failed to read config: %w", err)
 		}
 	}
+	// An environment overlay sits next to the config file found by the search
+	if overlayPath := OverlayPath(basePath, overlay); overlayPath != "" {
+		v.SetConfigFile(overlayPath)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config overlay %s: %w", overlayPath, err)
+		}
+	}
 
 	// 5. Unmarshal into struct
+	// Tokens from env come as label:address:decimals, like --tokens
+	var envTokens []TokenConfig
+	if tokensEnv, ok := v.Get("tokens").(string); ok && tokensEnv != "" {
+		tokens, err := ParseTokensFlag(tokensEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RMM_TRACKER_TOKENS: %w", err)
+		}
+		envTokens = tokens
+		v.Set("tokens", []any{})
+	}
+	walletTables, err := splitWalletTables(v)
+	if err != nil {
+		return nil, err
+	}
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	cfg.LabeledWallets = append(cfg.LabeledWallets, walletTables...)
+	if envTokens != nil {
+		cfg.Tokens = envTokens
+	}
 
 	// Special handling for comma-separated env vars
 	if walletsEnv := v.GetString("wallets"); walletsEnv != "" {
@@ -96,13 +243,51 @@ func Load(configPath string) (*Config, error) {
 	if err := validate.Struct(&cfg); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
+	if err := cfg.validateJitter(); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	cfg.ChecksumAddresses()
 
 	return &cfg, nil
 }
 
-// LoadWithDefaults loads config with DATABASE_URL from environment
-func LoadWithDefaults(configPath string) (*Config, string, error) {
-	cfg, err := Load(configPath)
+// splitWalletTables accepts wallets given as tables with an address and a
+// label, as [[wallets]] or inline in the wallets array, next to plain
+// addresses. The tables are returned as labeled wallets and wallets is left
+// with the addresses, in their original order.
+func splitWalletTables(v *viper.Viper) ([]WalletConfig, error) {
+	entries, ok := v.Get("wallets").([]any)
+	if !ok {
+		return nil, nil
+	}
+	addresses := make([]string, len(entries))
+	var tables []WalletConfig
+	for i, entry := range entries {
+		table, ok := entry.(map[string]any)
+		if !ok {
+			addresses[i] = fmt.Sprint(entry)
+			continue
+		}
+		sub := viper.New()
+		if err := sub.MergeConfigMap(table); err != nil {
+			return nil, fmt.Errorf("wallets[%d]: %w", i, err)
+		}
+		var w WalletConfig
+		if err := sub.Unmarshal(&w); err != nil {
+			return nil, fmt.Errorf("wallets[%d]: %w", i, err)
+		}
+		addresses[i] = w.Address
+		tables = append(tables, w)
+	}
+	if len(tables) > 0 {
+		v.Set("wallets", addresses)
+	}
+	return tables, nil
+}
+
+// LoadWithDefaults loads the layered config with DATABASE_URL from environment
+func LoadWithDefaults(configPath, overlay string) (*Config, string, error) {
+	cfg, err := LoadLayered(configPath, overlay)
 	if err != nil {
 		return nil, "", err
 	}
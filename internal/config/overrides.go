@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseWalletsFlag splits a comma-separated --wallets value.
+func ParseWalletsFlag(value string) []string {
+	var wallets []string
+	for w := range strings.SplitSeq(value, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets
+}
+
+// ParseTokensFlag parses a comma-separated --tokens value where each entry
+// is label:address:decimals.
+func ParseTokensFlag(value string) ([]TokenConfig, error) {
+	var tokens []TokenConfig
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid token %q: expected label:address:decimals", entry)
+		}
+		decimals, err := strconv.ParseUint(strings.TrimSpace(parts[2]), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token %q: decimals must be 0-255", entry)
+		}
+		tokens = append(tokens, TokenConfig{
+			Label:            strings.TrimSpace(parts[0]),
+			Address:          strings.TrimSpace(parts[1]),
+			FallbackDecimals: uint8(decimals),
+		})
+	}
+	return tokens, nil
+}
+
+// ApplyOverrides replaces the configured wallets and/or tokens with the
+// non-empty lists given, then validates the result like a loaded config.
+// A token override also disables token_discovery_pool, so the given list is
+// exactly what gets polled. Addresses are checksummed, and cfg is left
+// untouched when validation fails.
+func (cfg *Config) ApplyOverrides(wallets []string, tokens []TokenConfig) error {
+	if len(wallets) == 0 && len(tokens) == 0 {
+		return nil
+	}
+
+	updated := *cfg
+	if len(wallets) > 0 {
+		updated.Wallets = wallets
+	}
+	if len(tokens) > 0 {
+		updated.Tokens = tokens
+		updated.TokenDiscoveryPool = ""
+	}
+
+	if err := NewValidator().Struct(&updated); err != nil {
+		return fmt.Errorf("invalid override: %w", err)
+	}
+	updated.ChecksumAddresses()
+
+	*cfg = updated
+	return nil
+}
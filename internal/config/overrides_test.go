@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWalletsFlag(t *testing.T) {
+	assert.Equal(t,
+		[]string{"0x1234567890123456789012345678901234567890", "0x0987654321098765432109876543210987654321"},
+		ParseWalletsFlag(" 0x1234567890123456789012345678901234567890, 0x0987654321098765432109876543210987654321,"))
+	assert.Nil(t, ParseWalletsFlag(""))
+}
+
+func TestParseTokensFlag(t *testing.T) {
+	t.Run("valid entries", func(t *testing.T) {
+		tokens, err := ParseTokensFlag("armmXDAI:0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b:18, armmUSDC:0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1:6")
+		require.NoError(t, err)
+		assert.Equal(t, []TokenConfig{
+			{Label: "armmXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b", FallbackDecimals: 18},
+			{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+		}, tokens)
+	})
+
+	invalid := map[string]string{
+		"missing decimals":    "armmXDAI:0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b",
+		"too many parts":      "armmXDAI:0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b:18:extra",
+		"non-numeric decimal": "armmXDAI:0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b:eighteen",
+		"decimals overflow":   "armmXDAI:0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b:256",
+	}
+	for name, value := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTokensFlag(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	t.Run("replaces wallets and tokens", func(t *testing.T) {
+		cfg := newTestConfig()
+		wallets := []string{"0x0987654321098765432109876543210987654321"}
+		tokens := []TokenConfig{{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6}}
+
+		require.NoError(t, cfg.ApplyOverrides(wallets, tokens))
+		assert.Equal(t, wallets, cfg.Wallets)
+		assert.Equal(t, tokens, cfg.Tokens)
+	})
+
+	t.Run("overrides are checksummed", func(t *testing.T) {
+		cfg := newTestConfig()
+		tokens := []TokenConfig{{Label: "armmUSDC", Address: "0xed56f76e9cbc6a64b821e9c016eafbd3db5436d1", FallbackDecimals: 6}}
+
+		require.NoError(t, cfg.ApplyOverrides([]string{"0x7e5f4552091a69125d5dfcb7b8c2659029395bdf"}, tokens))
+		assert.Equal(t, []string{"0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"}, cfg.Wallets)
+		assert.Equal(t, "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", cfg.Tokens[0].Address)
+		assert.Equal(t, "0xed56f76e9cbc6a64b821e9c016eafbd3db5436d1", tokens[0].Address, "the given list is not modified")
+	})
+
+	t.Run("token override disables discovery", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.TokenDiscoveryPool = "0x5B8D36De471880Ee21936f328AAB2383a280CB2A"
+		tokens := []TokenConfig{{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6}}
+
+		require.NoError(t, cfg.ApplyOverrides(nil, tokens))
+		assert.Empty(t, cfg.TokenDiscoveryPool)
+		assert.Equal(t, tokens, cfg.Tokens)
+	})
+
+	t.Run("wallet override keeps discovery", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.TokenDiscoveryPool = "0x5B8D36De471880Ee21936f328AAB2383a280CB2A"
+
+		require.NoError(t, cfg.ApplyOverrides([]string{"0x0987654321098765432109876543210987654321"}, nil))
+		assert.Equal(t, "0x5B8D36De471880Ee21936f328AAB2383a280CB2A", cfg.TokenDiscoveryPool)
+	})
+
+	t.Run("empty overrides keep config", func(t *testing.T) {
+		cfg := newTestConfig()
+		require.NoError(t, cfg.ApplyOverrides(nil, nil))
+		assert.Equal(t, newTestConfig(), cfg)
+	})
+
+	t.Run("invalid wallet is rejected", func(t *testing.T) {
+		cfg := newTestConfig()
+		err := cfg.ApplyOverrides([]string{"0xnotanaddress"}, nil)
+		require.Error(t, err)
+		assert.Equal(t, newTestConfig(), cfg, "config is unchanged on error")
+	})
+
+	t.Run("invalid token address is rejected", func(t *testing.T) {
+		cfg := newTestConfig()
+		tokens, err := ParseTokensFlag("BAD:0x123:18")
+		require.NoError(t, err)
+		assert.Error(t, cfg.ApplyOverrides(nil, tokens))
+	})
+}
@@ -105,6 +105,11 @@ func TestScheduleValidator(t *testing.T) {
 			interval:  "1h",
 			wantError: false,
 		},
+		{
+			name:      "valid descriptor @daily",
+			interval:  "@daily",
+			wantError: false,
+		},
 		{
 			name:      "valid cron 5 fields",
 			interval:  "*/5 * * * *",
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/metrics"
 	"github.com/matrixise/rmm-tracker/internal/storage"
 )
 
@@ -17,6 +20,7 @@ import (
 type SchedulerInterface interface {
 	NextRun() (time.Time, error)
 	LastRun() (time.Time, error)
+	SkippedRuns() int64
 }
 
 // BuildInfo holds version information set at build time via ldflags
@@ -31,6 +35,7 @@ type BuildInfo struct {
 type storeIface interface {
 	storage.Pinger
 	GetLastRun(ctx context.Context) (time.Time, bool, error)
+	LatestQueriedAt(ctx context.Context) (time.Time, error)
 }
 
 // Checker performs health checks on application dependencies
@@ -42,18 +47,74 @@ type Checker struct {
 	lastRunTime    time.Time
 	lastRunSuccess bool
 	interval       time.Duration // Fallback for grace period calculation
+	daemonGrace    time.Duration // Allowed lateness beyond an expected run time
+	dataCheck      bool          // Whether the daemon check also covers the newest stored balance
+	rpcCache       *rpcHealthCache
 	mu             sync.RWMutex
 }
 
+// DefaultDaemonGrace is the allowed lateness beyond an expected run time
+// when no explicit grace is configured.
+const DefaultDaemonGrace = 2 * time.Minute
+
 // NewChecker creates a new health checker
 func NewChecker(store storeIface, client *blockchain.Client, scheduler SchedulerInterface, interval time.Duration, buildInfo BuildInfo) *Checker {
-	return &Checker{
-		store:     store,
-		client:    client,
-		scheduler: scheduler,
-		buildInfo: buildInfo,
-		interval:  interval,
+	c := &Checker{
+		store:       store,
+		client:      client,
+		scheduler:   scheduler,
+		buildInfo:   buildInfo,
+		interval:    interval,
+		daemonGrace: DefaultDaemonGrace,
+		dataCheck:   true,
 	}
+	c.rpcCache = &rpcHealthCache{probe: c.checkRPC, now: time.Now}
+	return c
+}
+
+// SetRPCHealthTTL caches the RPC check result for ttl, so frequent probes
+// do not each call the endpoint. Past the TTL the cached result is still
+// served, for up to another ttl, while a background check refreshes it.
+// Zero (the default) checks the endpoint on every probe.
+func (c *Checker) SetRPCHealthTTL(ttl time.Duration) {
+	c.rpcCache.mu.Lock()
+	defer c.rpcCache.mu.Unlock()
+	c.rpcCache.ttl = ttl
+}
+
+// SetDaemonGrace sets how late a scheduled run may be before the daemon
+// check reports degraded. Non-positive values keep the default.
+func (c *Checker) SetDaemonGrace(grace time.Duration) {
+	if grace <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.daemonGrace = grace
+}
+
+// SetDataCheck enables or disables the data check, which reports degraded
+// when the newest stored balance is older than twice the interval. It is on
+// by default; disable it when unchanged balances are not inserted.
+func (c *Checker) SetDataCheck(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dataCheck = enabled
+}
+
+// SetClient replaces the blockchain client checked by the RPC check, e.g.
+// after the RPC endpoints were reloaded.
+func (c *Checker) SetClient(client *blockchain.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client = client
+}
+
+// rpcClient returns the blockchain client, nil when none is configured.
+func (c *Checker) rpcClient() *blockchain.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
 }
 
 // UpdateLastRun updates the timestamp and status of the last execution
@@ -86,8 +147,40 @@ type HealthResponse struct {
 
 // CheckDetail contains details about a specific health check
 type CheckDetail struct {
-	Status  CheckStatus `json:"status"`
-	Message string      `json:"message,omitempty"`
+	Status    CheckStatus      `json:"status"`
+	Message   string           `json:"message,omitempty"`
+	Endpoints []EndpointDetail `json:"endpoints,omitempty"`
+}
+
+// EndpointDetail reports an RPC endpoint in the rpc_endpoints check. The
+// endpoint is named by its host, as API keys may sit in its path or query.
+type EndpointDetail struct {
+	Endpoint            string `json:"endpoint"`
+	Healthy             bool   `json:"healthy"`
+	LatencyMs           int64  `json:"latency_ms"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	HalfOpen            bool   `json:"half_open,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+}
+
+// endpointDetails converts stats to their reported form.
+func endpointDetails(stats []blockchain.EndpointStat) []EndpointDetail {
+	details := make([]EndpointDetail, len(stats))
+	for i, s := range stats {
+		host := metrics.EndpointLabel(s.URL)
+		details[i] = EndpointDetail{
+			Endpoint:            host,
+			Healthy:             s.Healthy,
+			LatencyMs:           s.Latency.Milliseconds(),
+			ConsecutiveFailures: s.ConsecutiveFailures,
+			HalfOpen:            s.HalfOpen,
+		}
+		if s.LastError != nil {
+			// Transport errors quote the full URL
+			details[i].LastError = strings.ReplaceAll(s.LastError.Error(), s.URL, host)
+		}
+	}
+	return details
 }
 
 var startTime = time.Now()
@@ -105,8 +198,8 @@ func (c *Checker) Check(ctx context.Context) HealthResponse {
 	}
 
 	// Check 2: RPC endpoint availability (only when blockchain client is configured)
-	if c.client != nil {
-		rpcCheck := c.checkRPC(ctx)
+	if c.rpcClient() != nil {
+		rpcCheck := c.rpcCache.get(ctx)
 		checks["rpc_endpoints"] = rpcCheck
 		if rpcCheck.Status == StatusError {
 			overallStatus = StatusError
@@ -122,6 +215,18 @@ func (c *Checker) Check(ctx context.Context) HealthResponse {
 		if daemonCheck.Status != StatusOK && overallStatus == StatusOK {
 			overallStatus = StatusDegraded
 		}
+
+		// Check 4: Newest stored balance, as a run may succeed without inserting
+		c.mu.RLock()
+		dataCheck := c.dataCheck
+		c.mu.RUnlock()
+		if dataCheck {
+			check := c.checkData(ctx)
+			checks["data"] = check
+			if check.Status != StatusOK && overallStatus == StatusOK {
+				overallStatus = StatusDegraded
+			}
+		}
 	}
 
 	resp := HealthResponse{
@@ -146,7 +251,7 @@ func (c *Checker) checkDatabase(ctx context.Context) CheckDetail {
 	defer cancel()
 
 	if err := c.store.Ping(ctx); err != nil {
-		slog.Error("Health check: database ping failed", "error", err)
+		logger.LogError(ctx, slog.LevelError, "Health check: database ping failed", err)
 		return CheckDetail{
 			Status:  StatusError,
 			Message: "database unreachable: " + err.Error(),
@@ -164,67 +269,132 @@ func (c *Checker) checkRPC(ctx context.Context) CheckDetail {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	client, url, err := c.client.GetHealthyEndpoint()
-	if err != nil {
+	rpc := c.rpcClient()
+
+	// Quick health check: get chain ID, timing the active endpoint
+	url, err := rpc.Ping(ctx)
+	stats := rpc.EndpointStats()
+	if url == "" {
 		slog.Error("Health check: no healthy RPC endpoints", "error", err)
 		return CheckDetail{
-			Status:  StatusError,
-			Message: "no healthy RPC endpoints available",
+			Status:    StatusError,
+			Message:   "no healthy RPC endpoints available",
+			Endpoints: endpointDetails(stats),
 		}
 	}
-
-	// Quick health check: get chain ID
-	if _, err := client.ChainID(ctx); err != nil {
-		slog.Error("Health check: RPC endpoint failed", "url", url, "error", err)
+	if err != nil {
+		logger.LogError(ctx, slog.LevelError, "Health check: RPC endpoint failed", err, "url", url)
 		return CheckDetail{
-			Status:  StatusError,
-			Message: "RPC endpoint not responding: " + err.Error(),
+			Status:    StatusError,
+			Message:   "RPC endpoint not responding: " + err.Error(),
+			Endpoints: endpointDetails(stats),
 		}
 	}
 
-	healthStatus := c.client.GetEndpointsHealth()
 	healthyCount := 0
-	totalCount := len(healthStatus)
+	totalCount := len(stats)
 
-	for _, healthy := range healthStatus {
-		if healthy {
+	for _, s := range stats {
+		if s.Healthy {
 			healthyCount++
 		}
 	}
 
 	if healthyCount == totalCount {
 		return CheckDetail{
-			Status:  StatusOK,
-			Message: "all RPC endpoints healthy",
+			Status:    StatusOK,
+			Message:   "all RPC endpoints healthy",
+			Endpoints: endpointDetails(stats),
 		}
 	}
 
 	return CheckDetail{
-		Status:  StatusDegraded,
-		Message: fmt.Sprintf("%d/%d RPC endpoints healthy", healthyCount, totalCount),
+		Status:    StatusDegraded,
+		Message:   fmt.Sprintf("%d/%d RPC endpoints healthy", healthyCount, totalCount),
+		Endpoints: endpointDetails(stats),
 	}
 }
 
-// checkDaemon verifies the daemon is executing at expected intervals
+// rpcHealthCache caches the RPC check with stale-while-revalidate semantics.
+type rpcHealthCache struct {
+	probe func(ctx context.Context) CheckDetail
+	now   func() time.Time
+
+	mu         sync.Mutex
+	ttl        time.Duration
+	result     CheckDetail
+	checkedAt  time.Time
+	refreshing bool
+}
+
+// get returns a result at most ttl old, or a stale one at most 2*ttl old
+// while a background probe refreshes it; older results are re-checked inline.
+func (r *rpcHealthCache) get(ctx context.Context) CheckDetail {
+	r.mu.Lock()
+	ttl := r.ttl
+	if ttl <= 0 {
+		r.mu.Unlock()
+		return r.probe(ctx)
+	}
+	age := r.now().Sub(r.checkedAt)
+	switch {
+	case !r.checkedAt.IsZero() && age < ttl:
+		defer r.mu.Unlock()
+		return r.result
+	case !r.checkedAt.IsZero() && age < 2*ttl:
+		defer r.mu.Unlock()
+		if !r.refreshing {
+			r.refreshing = true
+			// Detached from the request, which ends before the probe does
+			go r.refresh(context.WithoutCancel(ctx))
+		}
+		return r.result
+	}
+	r.mu.Unlock()
+
+	result := r.probe(ctx)
+	r.store(result)
+	return result
+}
+
+func (r *rpcHealthCache) refresh(ctx context.Context) {
+	result := r.probe(ctx)
+	r.store(result)
+}
+
+func (r *rpcHealthCache) store(result CheckDetail) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result = result
+	r.checkedAt = r.now()
+	r.refreshing = false
+}
+
+// checkDaemon verifies the daemon is executing at expected intervals.
+// When a scheduler is available the check is schedule-aware: a run is late only
+// once its expected fire time plus the configured grace has passed, so sparse
+// cron schedules (e.g. 9am and 5pm) are not flagged between runs.
 func (c *Checker) checkDaemon() CheckDetail {
 	c.mu.RLock()
 	lastRunTime := c.lastRunTime
 	lastRunSuccess := c.lastRunSuccess
+	grace := c.daemonGrace
 	c.mu.RUnlock()
 
+	now := time.Now()
+
 	// Try to get next scheduled run from scheduler for precise monitoring
-	var nextRun time.Time
 	var nextRunMsg string
+	scheduleAware := false
 	if c.scheduler != nil {
-		if nr, err := c.scheduler.NextRun(); err == nil && !nr.IsZero() {
-			nextRun = nr
-			timeUntilNext := time.Until(nextRun)
+		if nextRun, err := c.scheduler.NextRun(); err == nil && !nextRun.IsZero() {
+			scheduleAware = true
+			timeUntilNext := nextRun.Sub(now)
 			if timeUntilNext > 0 {
 				nextRunMsg = fmt.Sprintf(", next run in %s", timeUntilNext.Round(time.Second))
 			} else {
 				// We've passed the next scheduled run time
-				graceThreshold := 2 * time.Minute
-				if time.Now().After(nextRun.Add(graceThreshold)) {
+				if now.After(nextRun.Add(grace)) {
 					return CheckDetail{
 						Status:  StatusDegraded,
 						Message: fmt.Sprintf("missed scheduled run at %s", nextRun.Format(time.RFC3339)),
@@ -233,6 +403,16 @@ func (c *Checker) checkDaemon() CheckDetail {
 				nextRunMsg = " (execution overdue but within grace period)"
 			}
 		}
+
+		// The scheduler fired but the run has not reported completion in time
+		if lastFire, err := c.scheduler.LastRun(); err == nil && !lastFire.IsZero() {
+			if lastRunTime.Before(lastFire) && now.After(lastFire.Add(grace)) {
+				return CheckDetail{
+					Status:  StatusDegraded,
+					Message: fmt.Sprintf("run started at %s has not completed within %s", lastFire.Format(time.RFC3339), grace),
+				}
+			}
+		}
 	}
 
 	// If we've never run, that's OK (might be starting up)
@@ -259,18 +439,13 @@ func (c *Checker) checkDaemon() CheckDetail {
 		}
 	}
 
-	// Check if we're running on schedule (allow 2x interval grace period)
-	timeSinceLastRun := time.Since(lastRunTime)
-	graceThreshold := c.interval * 2
+	timeSinceLastRun := now.Sub(lastRunTime)
 
-	if timeSinceLastRun > graceThreshold {
-		msg := fmt.Sprintf("no execution in %s (expected every %s)", timeSinceLastRun.Round(time.Second), c.interval)
-		if nextRunMsg != "" {
-			msg += nextRunMsg
-		}
+	// Without schedule information fall back to a 2x interval heuristic
+	if !scheduleAware && timeSinceLastRun > c.interval*2 {
 		return CheckDetail{
 			Status:  StatusDegraded,
-			Message: msg,
+			Message: fmt.Sprintf("no execution in %s (expected every %s)", timeSinceLastRun.Round(time.Second), c.interval),
 		}
 	}
 
@@ -278,6 +453,12 @@ func (c *Checker) checkDaemon() CheckDetail {
 	if nextRunMsg != "" {
 		msg += nextRunMsg
 	}
+	// Runs outlasting the interval make the scheduler skip fires
+	if c.scheduler != nil {
+		if skipped := c.scheduler.SkippedRuns(); skipped > 0 {
+			msg += fmt.Sprintf(", %d runs skipped while the previous was in progress", skipped)
+		}
+	}
 	return CheckDetail{
 		Status:  StatusOK,
 		Message: msg,
@@ -291,6 +472,54 @@ type QuickStatusResult struct {
 	LastRunOK *bool
 }
 
+// checkData verifies the newest stored balance is at most twice the interval
+// older than the last scheduled fire, or than now without a scheduler, so
+// sparse cron schedules are not flagged between runs. This catches runs that
+// complete while every token failed and nothing was inserted.
+func (c *Checker) checkData(ctx context.Context) CheckDetail {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	latest, err := c.store.LatestQueriedAt(ctx)
+	if err != nil {
+		logger.LogError(ctx, slog.LevelWarn, "Health check: latest balance read failed", err)
+		return CheckDetail{
+			Status:  StatusDegraded,
+			Message: "latest balance unreadable: " + err.Error(),
+		}
+	}
+
+	if latest.IsZero() {
+		c.mu.RLock()
+		ran := !c.lastRunTime.IsZero()
+		c.mu.RUnlock()
+		// Before the first run an empty table is expected
+		if !ran {
+			return CheckDetail{Status: StatusOK, Message: "no balances stored yet (startup)"}
+		}
+		return CheckDetail{Status: StatusDegraded, Message: "no balances stored"}
+	}
+
+	age := time.Since(latest)
+	reference := time.Now()
+	if c.scheduler != nil {
+		if lastFire, err := c.scheduler.LastRun(); err == nil && !lastFire.IsZero() {
+			reference = lastFire
+		}
+	}
+	if reference.Sub(latest) > c.interval*2 {
+		return CheckDetail{
+			Status:  StatusDegraded,
+			Message: fmt.Sprintf("newest balance is %s old (expected within %s)", age.Round(time.Second), c.interval*2),
+		}
+	}
+
+	return CheckDetail{
+		Status:  StatusOK,
+		Message: fmt.Sprintf("newest balance is %s old", age.Round(time.Second)),
+	}
+}
+
 // QuickStatus returns status and last-run info without any network probing.
 // It reads in-memory state (O(1)) and falls back to a single DB read only on
 // the very first call after startup (when the in-memory timestamp is zero).
@@ -303,11 +532,7 @@ func (c *Checker) QuickStatus(ctx context.Context) QuickStatusResult {
 	res := QuickStatusResult{Status: StatusOK}
 
 	if c.interval > 0 && !lastRunTime.IsZero() {
-		if !lastRunSuccess {
-			res.Status = StatusDegraded
-		} else if time.Since(lastRunTime) > c.interval*2 {
-			res.Status = StatusDegraded
-		}
+		res.Status = c.checkDaemon().Status
 	}
 
 	if !lastRunTime.IsZero() {
@@ -322,8 +547,46 @@ func (c *Checker) QuickStatus(ctx context.Context) QuickStatusResult {
 	return res
 }
 
-// Handler returns an http.HandlerFunc for the health endpoint
+// Handler returns an http.HandlerFunc for the health endpoint, the aggregate
+// of every check. It answers like ReadinessHandler.
 func (c *Checker) Handler() http.HandlerFunc {
+	return c.ReadinessHandler()
+}
+
+// LivenessResponse is the body of the liveness endpoint.
+type LivenessResponse struct {
+	Status    CheckStatus `json:"status"`
+	Timestamp time.Time   `json:"timestamp"`
+	Uptime    string      `json:"uptime"`
+	Build     BuildInfo   `json:"build"`
+}
+
+// LivenessHandler returns an http.HandlerFunc reporting that the process is
+// up. It checks no dependency, so a database or RPC outage does not get the
+// process restarted: readiness takes it out of rotation instead.
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(LivenessResponse{
+			Status:    StatusOK,
+			Timestamp: time.Now(),
+			Uptime:    time.Since(startTime).Round(time.Second).String(),
+			Build:     c.buildInfo,
+		}); err != nil {
+			slog.Error("Failed to encode liveness response", "error", err)
+		}
+	}
+}
+
+// ReadinessHandler returns an http.HandlerFunc running the dependency
+// checks: 503 when the database or every RPC endpoint is down, 200 otherwise,
+// degraded included.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Only support GET
 		if r.Method != http.MethodGet {
@@ -0,0 +1,330 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeScheduler implements SchedulerInterface with fixed times.
+type fakeScheduler struct {
+	nextRun time.Time
+	lastRun time.Time
+	skipped int64
+	err     error
+}
+
+func (f *fakeScheduler) NextRun() (time.Time, error) { return f.nextRun, f.err }
+func (f *fakeScheduler) LastRun() (time.Time, error) { return f.lastRun, f.err }
+func (f *fakeScheduler) SkippedRuns() int64          { return f.skipped }
+
+func TestCheckDaemon_ScheduleAware(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		sched       *fakeScheduler
+		lastRunTime time.Time
+		lastRunOK   bool
+		want        CheckStatus
+		wantMsg     string
+	}{
+		{
+			name:        "on time",
+			sched:       &fakeScheduler{nextRun: now.Add(3 * time.Minute), lastRun: now.Add(-2 * time.Minute)},
+			lastRunTime: now.Add(-110 * time.Second),
+			lastRunOK:   true,
+			want:        StatusOK,
+			wantMsg:     "next run in",
+		},
+		{
+			name:        "slightly late within grace",
+			sched:       &fakeScheduler{nextRun: now.Add(-30 * time.Second), lastRun: now.Add(-5 * time.Minute)},
+			lastRunTime: now.Add(-299 * time.Second),
+			lastRunOK:   true,
+			want:        StatusOK,
+			wantMsg:     "within grace period",
+		},
+		{
+			name:        "very late beyond grace",
+			sched:       &fakeScheduler{nextRun: now.Add(-10 * time.Minute), lastRun: now.Add(-15 * time.Minute)},
+			lastRunTime: now.Add(-15 * time.Minute),
+			lastRunOK:   true,
+			want:        StatusDegraded,
+			wantMsg:     "missed scheduled run",
+		},
+		{
+			name: "sparse cron between runs is not degraded",
+			// 9am/5pm schedule checked at noon: last run 3h ago, next in 5h
+			sched:       &fakeScheduler{nextRun: now.Add(5 * time.Hour), lastRun: now.Add(-3 * time.Hour)},
+			lastRunTime: now.Add(-3*time.Hour + 20*time.Second),
+			lastRunOK:   true,
+			want:        StatusOK,
+		},
+		{
+			name:        "fired but not completed within grace",
+			sched:       &fakeScheduler{nextRun: now.Add(time.Minute), lastRun: now.Add(-4 * time.Minute)},
+			lastRunTime: now.Add(-9 * time.Minute),
+			lastRunOK:   true,
+			want:        StatusDegraded,
+			wantMsg:     "has not completed",
+		},
+		{
+			name:        "skipped runs are reported",
+			sched:       &fakeScheduler{nextRun: now.Add(3 * time.Minute), lastRun: now.Add(-2 * time.Minute), skipped: 2},
+			lastRunTime: now.Add(-110 * time.Second),
+			lastRunOK:   true,
+			want:        StatusOK,
+			wantMsg:     "2 runs skipped while the previous was in progress",
+		},
+		{
+			name:        "last execution failed",
+			sched:       &fakeScheduler{nextRun: now.Add(time.Minute), lastRun: now.Add(-4 * time.Minute)},
+			lastRunTime: now.Add(-3 * time.Minute),
+			lastRunOK:   false,
+			want:        StatusDegraded,
+			wantMsg:     "last execution failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(nil, nil, tt.sched, 5*time.Minute, BuildInfo{})
+			c.mu.Lock()
+			c.lastRunTime = tt.lastRunTime
+			c.lastRunSuccess = tt.lastRunOK
+			c.mu.Unlock()
+
+			got := c.checkDaemon()
+			assert.Equal(t, tt.want, got.Status, got.Message)
+			if tt.wantMsg != "" {
+				assert.Contains(t, got.Message, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckDaemon_ConfigurableGrace(t *testing.T) {
+	now := time.Now()
+	sched := &fakeScheduler{nextRun: now.Add(-5 * time.Minute), lastRun: now.Add(-10 * time.Minute)}
+
+	c := NewChecker(nil, nil, sched, 5*time.Minute, BuildInfo{})
+	c.UpdateLastRun(true)
+	assert.Equal(t, StatusDegraded, c.checkDaemon().Status, "default 2m grace is exceeded")
+
+	c.SetDaemonGrace(10 * time.Minute)
+	assert.Equal(t, StatusOK, c.checkDaemon().Status, "10m grace tolerates a 5m delay")
+
+	c.SetDaemonGrace(0)
+	assert.Equal(t, StatusOK, c.checkDaemon().Status, "non-positive grace keeps the previous value")
+}
+
+func TestCheckDaemon_FallbackWithoutScheduler(t *testing.T) {
+	c := NewChecker(nil, nil, &fakeScheduler{err: errors.New("no job")}, time.Minute, BuildInfo{})
+	c.mu.Lock()
+	c.lastRunTime = time.Now().Add(-5 * time.Minute)
+	c.lastRunSuccess = true
+	c.mu.Unlock()
+
+	got := c.checkDaemon()
+	assert.Equal(t, StatusDegraded, got.Status)
+	assert.Contains(t, got.Message, "expected every 1m0s")
+}
+
+// countingProbe stands in for the ChainID-based RPC check, counting calls
+// and returning a result numbered after the call.
+type countingProbe struct {
+	calls atomic.Int32
+}
+
+func (p *countingProbe) probe(context.Context) CheckDetail {
+	n := p.calls.Add(1)
+	return CheckDetail{Status: StatusOK, Message: fmt.Sprintf("check %d", n)}
+}
+
+// fakeClock is a manually advanced clock safe for concurrent reads.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRPCHealthCache(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	probe := &countingProbe{}
+	c := NewChecker(nil, nil, nil, 0, BuildInfo{})
+	c.rpcCache.probe = probe.probe
+	c.rpcCache.now = clock.Now
+	c.SetRPCHealthTTL(5 * time.Second)
+
+	for range 10 {
+		assert.Equal(t, "check 1", c.rpcCache.get(ctx).Message)
+		clock.Advance(400 * time.Millisecond)
+	}
+	assert.Equal(t, int32(1), probe.calls.Load(), "probes within the TTL reuse one ChainID call")
+
+	// Past the TTL: the stale result is served while a background check runs
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, "check 1", c.rpcCache.get(ctx).Message)
+	assert.Eventually(t, func() bool { return c.rpcCache.get(ctx).Message == "check 2" },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(2), probe.calls.Load())
+
+	// Past twice the TTL: too stale to serve, checked inline
+	clock.Advance(11 * time.Second)
+	assert.Equal(t, "check 3", c.rpcCache.get(ctx).Message)
+	assert.Equal(t, int32(3), probe.calls.Load())
+}
+
+func TestRPCHealthCache_DisabledByDefault(t *testing.T) {
+	probe := &countingProbe{}
+	c := NewChecker(nil, nil, nil, 0, BuildInfo{})
+	c.rpcCache.probe = probe.probe
+
+	for range 3 {
+		c.rpcCache.get(context.Background())
+	}
+	assert.Equal(t, int32(3), probe.calls.Load())
+}
+
+func TestEndpointDetails(t *testing.T) {
+	const keyed = "https://gnosis.example.com/v2/secret-key"
+	details := endpointDetails([]blockchain.EndpointStat{
+		{URL: "https://rpc.gnosischain.com", Healthy: true, Latency: 42 * time.Millisecond},
+		{URL: keyed, ConsecutiveFailures: 3, LastError: fmt.Errorf(`Post "%s": dial tcp: connection refused`, keyed)},
+	})
+
+	assert.Equal(t, []EndpointDetail{
+		{Endpoint: "rpc.gnosischain.com", Healthy: true, LatencyMs: 42},
+		{
+			Endpoint:            "gnosis.example.com",
+			ConsecutiveFailures: 3,
+			LastError:           `Post "gnosis.example.com": dial tcp: connection refused`,
+		},
+	}, details, "API keys in URLs are not reported")
+}
+
+// fakeStore is a storeIface whose Ping returns err and whose newest stored
+// balance is latest.
+type fakeStore struct {
+	err    error
+	latest time.Time
+}
+
+func (f fakeStore) Ping(context.Context) error { return f.err }
+func (f fakeStore) GetLastRun(context.Context) (time.Time, bool, error) {
+	return time.Time{}, false, nil
+}
+func (f fakeStore) LatestQueriedAt(context.Context) (time.Time, error) {
+	return f.latest, f.err
+}
+
+func TestCheckData(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   fakeStore
+		ran     bool
+		want    CheckStatus
+		message string
+	}{
+		{"fresh", fakeStore{latest: time.Now().Add(-3 * time.Minute)}, true, StatusOK, "newest balance is 3m0s old"},
+		{"stale", fakeStore{latest: time.Now().Add(-11 * time.Minute)}, true, StatusDegraded, "newest balance is 11m0s old (expected within 10m0s)"},
+		{"empty at startup", fakeStore{}, false, StatusOK, "no balances stored yet (startup)"},
+		{"empty after a run", fakeStore{}, true, StatusDegraded, "no balances stored"},
+		{"read error", fakeStore{err: errors.New("timeout")}, true, StatusDegraded, "latest balance unreadable: timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(tt.store, nil, nil, 5*time.Minute, BuildInfo{})
+			if tt.ran {
+				c.UpdateLastRun(true)
+			}
+			got := c.checkData(context.Background())
+			assert.Equal(t, tt.want, got.Status)
+			assert.Equal(t, tt.message, got.Message)
+		})
+	}
+
+	t.Run("sparse cron between runs", func(t *testing.T) {
+		now := time.Now()
+		sched := &fakeScheduler{nextRun: now.Add(5 * time.Hour), lastRun: now.Add(-3 * time.Hour)}
+		c := NewChecker(fakeStore{latest: now.Add(-3*time.Hour + 20*time.Second)}, nil, sched, 5*time.Minute, BuildInfo{})
+		c.UpdateLastRun(true)
+		assert.Equal(t, StatusOK, c.checkData(context.Background()).Status, "measured from the last fire")
+
+		sched.lastRun = now.Add(-time.Minute)
+		assert.Equal(t, StatusDegraded, c.checkData(context.Background()).Status, "the last fire inserted nothing")
+	})
+}
+
+func TestCheck_DataCheck(t *testing.T) {
+	c := NewChecker(fakeStore{latest: time.Now().Add(-time.Hour)}, nil, nil, 5*time.Minute, BuildInfo{})
+	c.UpdateLastRun(true)
+
+	resp := c.Check(context.Background())
+	assert.Equal(t, StatusOK, resp.Checks["daemon"].Status, "the job ran")
+	assert.Equal(t, StatusDegraded, resp.Checks["data"].Status, "but inserted nothing")
+	assert.Equal(t, StatusDegraded, resp.Status)
+
+	c.SetDataCheck(false)
+	resp = c.Check(context.Background())
+	assert.NotContains(t, resp.Checks, "data")
+	assert.Equal(t, StatusOK, resp.Status)
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	serve := func(h http.HandlerFunc) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec
+	}
+
+	tests := []struct {
+		name          string
+		pingErr       error
+		wantReadiness int
+	}{
+		{"database up", nil, http.StatusOK},
+		{"database down", errors.New("connection refused"), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(fakeStore{err: tt.pingErr}, nil, nil, 0, BuildInfo{Version: "1.2.3"})
+
+			live := serve(c.LivenessHandler())
+			assert.Equal(t, http.StatusOK, live.Code, "liveness ignores dependencies")
+			assert.Contains(t, live.Body.String(), `"status":"ok"`)
+			assert.Contains(t, live.Body.String(), `"version":"1.2.3"`)
+
+			ready := serve(c.ReadinessHandler())
+			assert.Equal(t, tt.wantReadiness, ready.Code)
+			assert.Contains(t, ready.Body.String(), `"build":{"version":"1.2.3"`, "the running build is reported")
+			assert.Equal(t, tt.wantReadiness, serve(c.Handler()).Code, "/health answers like readiness")
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	NewChecker(fakeStore{}, nil, nil, 0, BuildInfo{}).LivenessHandler()(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
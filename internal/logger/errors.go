@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Causes attached to logged context errors.
+const (
+	CauseCanceled = "canceled"
+	CauseTimeout  = "timeout"
+)
+
+// ErrorCause classifies err: CauseCanceled for a cancelled context (normally
+// a shutdown or a client going away), CauseTimeout for an exceeded deadline,
+// "" for anything else.
+func ErrorCause(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return CauseCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return CauseTimeout
+	}
+	return ""
+}
+
+// ErrorLevel returns the level to log err at: Debug for cancellations, which
+// are expected on shutdown, and level for anything else, timeouts included.
+func ErrorLevel(err error, level slog.Level) slog.Level {
+	if ErrorCause(err) == CauseCanceled {
+		return slog.LevelDebug
+	}
+	return level
+}
+
+// LogError logs msg and err at ErrorLevel(err, level), tagging context errors
+// with their cause so "we shut down" is not mistaken for "the RPC timed out".
+func LogError(ctx context.Context, level slog.Level, msg string, err error, args ...any) {
+	LogErrorTo(ctx, slog.Default(), level, msg, err, args...)
+}
+
+// LogErrorTo is LogError on a specific logger.
+func LogErrorTo(ctx context.Context, l *slog.Logger, level slog.Level, msg string, err error, args ...any) {
+	args = append(args, "error", err)
+	if cause := ErrorCause(err); cause != "" {
+		args = append(args, "cause", cause)
+	}
+	l.Log(ctx, ErrorLevel(err, level), msg, args...)
+}
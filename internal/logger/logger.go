@@ -1,13 +1,76 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-// Setup configures the structured logger
+// Output is where SetupOutput writes the logs.
+type Output struct {
+	// Path is "stdout" (the default), "stderr" or a file the logs are
+	// appended to
+	Path string
+	// MaxSizeMB rotates the file once it reaches this many megabytes, 0 to
+	// never rotate it
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files kept, 0 to keep them all
+	MaxBackups int
+}
+
+// Setup configures the structured logger, writing to stdout
 func Setup(levelStr, format string) {
+	setup(levelStr, format, os.Stdout)
+}
+
+// SetupOutput configures the structured logger like Setup, writing to out.
+// The returned Closer closes the log file, if any, on shutdown.
+func SetupOutput(levelStr, format string, out Output) (io.Closer, error) {
+	w, err := open(out)
+	if err != nil {
+		return nil, err
+	}
+	setup(levelStr, format, w)
+	return w, nil
+}
+
+// open returns the writer of out. Closing stdout or stderr is a no-op.
+func open(out Output) (io.WriteCloser, error) {
+	switch out.Path {
+	case "", "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	}
+
+	// Open the file now so a bad path fails at startup, not at the first line
+	f, err := os.OpenFile(out.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	if out.MaxSizeMB <= 0 {
+		return f, nil
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	return &lumberjack.Logger{
+		Filename:   out.Path,
+		MaxSize:    out.MaxSizeMB,
+		MaxBackups: out.MaxBackups,
+	}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// setup installs the default logger, writing to w.
+func setup(levelStr, format string, w io.Writer) {
 	var level slog.Level
 
 	switch strings.ToLower(levelStr) {
@@ -26,9 +89,9 @@ func Setup(levelStr, format string) {
 	opts := &slog.HandlerOptions{Level: level}
 	var handler slog.Handler
 	if format == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(w, opts)
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(w, opts)
 	}
 	slog.SetDefault(slog.New(handler))
 }
@@ -1,10 +1,20 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 func TestSetup(t *testing.T) {
@@ -69,3 +79,113 @@ func TestSetupNoErrors(t *testing.T) {
 
 	assert.NotNil(t, slog.Default())
 }
+
+func TestSetupFormat(t *testing.T) {
+	t.Cleanup(func() { Setup("info", "text") })
+
+	Setup("info", "json")
+	assert.IsType(t, &slog.JSONHandler{}, slog.Default().Handler())
+
+	for _, format := range []string{"text", "", "unknown"} {
+		Setup("info", format)
+		assert.IsType(t, &slog.TextHandler{}, slog.Default().Handler(), "format %q", format)
+	}
+}
+
+func TestSetupOutput(t *testing.T) {
+	t.Cleanup(func() { Setup("info", "text") })
+
+	t.Run("file is appended to", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tracker.log")
+		require.NoError(t, os.WriteFile(path, []byte("previous line\n"), 0o600))
+
+		closer, err := SetupOutput("info", "json", Output{Path: path})
+		require.NoError(t, err)
+		slog.Info("Tracker started")
+		require.NoError(t, closer.Close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, "previous line", lines[0])
+		assert.Contains(t, lines[1], `"msg":"Tracker started"`)
+	})
+
+	t.Run("rotated file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tracker.log")
+		closer, err := SetupOutput("info", "text", Output{Path: path, MaxSizeMB: 10, MaxBackups: 3})
+		require.NoError(t, err)
+		assert.IsType(t, &lumberjack.Logger{}, closer)
+		slog.Info("Tracker started")
+		require.NoError(t, closer.Close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "Tracker started")
+	})
+
+	t.Run("standard streams", func(t *testing.T) {
+		for _, path := range []string{"", "stdout", "stderr"} {
+			closer, err := SetupOutput("info", "text", Output{Path: path})
+			require.NoError(t, err, path)
+			assert.NoError(t, closer.Close(), "closing %q is a no-op", path)
+		}
+	})
+
+	t.Run("unwritable path", func(t *testing.T) {
+		_, err := SetupOutput("info", "text", Output{Path: filepath.Join(t.TempDir(), "missing", "tracker.log")})
+		assert.ErrorContains(t, err, "open log file")
+	})
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCause string
+		wantLevel slog.Level
+	}{
+		{"cancellation", context.Canceled, CauseCanceled, slog.LevelDebug},
+		{"wrapped cancellation", fmt.Errorf("balanceOf: %w", context.Canceled), CauseCanceled, slog.LevelDebug},
+		{"deadline", context.DeadlineExceeded, CauseTimeout, slog.LevelError},
+		{"wrapped deadline", fmt.Errorf("failed after 3 retries: %w", context.DeadlineExceeded), CauseTimeout, slog.LevelError},
+		{"other error", errors.New("execution reverted"), "", slog.LevelError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCause, ErrorCause(tt.err))
+			assert.Equal(t, tt.wantLevel, ErrorLevel(tt.err, slog.LevelError))
+		})
+	}
+}
+
+func TestLogError(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	logged := func(err error) map[string]any {
+		buf.Reset()
+		LogError(context.Background(), slog.LevelError, "Token query error", err, "token_address", "0x01")
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry
+	}
+
+	entry := logged(fmt.Errorf("balanceOf: %w", context.Canceled))
+	assert.Equal(t, "DEBUG", entry["level"])
+	assert.Equal(t, CauseCanceled, entry["cause"])
+	assert.Equal(t, "0x01", entry["token_address"])
+
+	entry = logged(fmt.Errorf("balanceOf: %w", context.DeadlineExceeded))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, CauseTimeout, entry["cause"])
+
+	entry = logged(errors.New("execution reverted"))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.NotContains(t, entry, "cause")
+	assert.Equal(t, "execution reverted", entry["error"])
+}
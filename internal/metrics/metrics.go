@@ -0,0 +1,204 @@
+// Package metrics exposes tracker metrics in the Prometheus format.
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ExemplarBlockLabel is the exemplar label carrying the chain block number.
+const ExemplarBlockLabel = "block_number"
+
+// Options controls optional metric features.
+type Options struct {
+	// Exemplars attaches the block number of each balance observation as an
+	// OpenMetrics exemplar. Exemplars are only served to scrapers that
+	// negotiate the OpenMetrics format.
+	Exemplars bool
+}
+
+// Metrics holds the tracker collectors.
+type Metrics struct {
+	opts Options
+
+	balance      *prometheus.GaugeVec
+	observations *prometheus.CounterVec
+
+	rpcAttempts         *prometheus.CounterVec
+	rpcRetrySucceeded   *prometheus.CounterVec
+	rpcRetriesExhausted *prometheus.CounterVec
+	rpcFailovers        *prometheus.CounterVec
+
+	rowsInserted prometheus.Counter
+	rowsSkipped  *prometheus.CounterVec
+
+	pollFailures     prometheus.Counter
+	lastSuccess      prometheus.Gauge
+	healthyEndpoints atomic.Pointer[func() int]
+}
+
+// New creates the tracker collectors and registers them on reg.
+func New(reg prometheus.Registerer, opts Options) *Metrics {
+	m := &Metrics{
+		opts: opts,
+		balance: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "rmm_tracker_token_balance",
+			Help: "Last observed token balance per wallet.",
+		}, []string{"wallet", "symbol"}),
+		// Gauges cannot carry exemplars, so the block number is attached to
+		// this companion counter, which moves in lockstep with the gauge.
+		observations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rmm_tracker_balance_observations_total",
+			Help: "Number of balance observations per wallet and token.",
+		}, []string{"wallet", "symbol"}),
+		rpcAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rmm_tracker_rpc_attempts_total",
+			Help: "Number of RPC call attempts per endpoint, retries included.",
+		}, []string{"endpoint"}),
+		rpcRetrySucceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rmm_tracker_rpc_retry_succeeded_total",
+			Help: "Number of RPC calls that succeeded after a failed attempt, per endpoint.",
+		}, []string{"endpoint"}),
+		rpcRetriesExhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rmm_tracker_rpc_retries_exhausted_total",
+			Help: "Number of RPC calls that failed on every attempt, per last endpoint tried.",
+		}, []string{"endpoint"}),
+		rpcFailovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rmm_tracker_failover_total",
+			Help: "Number of switches of the active RPC endpoint.",
+		}, []string{"from", "to"}),
+		rowsInserted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rmm_tracker_rows_inserted_total",
+			Help: "Number of balance rows written to the database.",
+		}),
+		rowsSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rmm_tracker_rows_skipped_total",
+			Help: "Number of balance rows not written, per reason.",
+		}, []string{"reason"}),
+		pollFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rmm_tracker_poll_failures_total",
+			Help: "Number of polling cycles that failed.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "rmm_tracker_last_success_timestamp_seconds",
+			Help: "Unix time of the end of the last successful polling cycle.",
+		}),
+	}
+	healthy := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rmm_tracker_rpc_endpoints_healthy",
+		Help: "Number of RPC endpoints currently healthy.",
+	}, m.countHealthyEndpoints)
+	reg.MustRegister(m.balance, m.observations, m.rpcAttempts, m.rpcRetrySucceeded, m.rpcRetriesExhausted, m.rpcFailovers,
+		m.rowsInserted, m.rowsSkipped, m.pollFailures, m.lastSuccess, healthy)
+	return m
+}
+
+// ObserveBalance records a balance. When exemplars are enabled and
+// blockNumber is known (non-zero), it is attached as an exemplar.
+func (m *Metrics) ObserveBalance(b storage.TokenBalance, blockNumber uint64) {
+	value, _ := b.Balance.Float64()
+	m.balance.WithLabelValues(b.Wallet, b.Symbol).Set(value)
+
+	counter := m.observations.WithLabelValues(b.Wallet, b.Symbol)
+	if m.opts.Exemplars && blockNumber > 0 {
+		if adder, ok := counter.(prometheus.ExemplarAdder); ok {
+			adder.AddWithExemplar(1, prometheus.Labels{
+				ExemplarBlockLabel: strconv.FormatUint(blockNumber, 10),
+			})
+			return
+		}
+	}
+	counter.Inc()
+}
+
+// ObserveBalances records a batch of persisted balances, with their block
+// number as exemplar when known. It matches tracker.PersistHook.
+func (m *Metrics) ObserveBalances(balances []storage.TokenBalance) {
+	for _, b := range balances {
+		m.ObserveBalance(b, b.BlockNumber)
+	}
+}
+
+// RPCAttempt counts an RPC call attempt. It implements
+// blockchain.RetryRecorder, like RPCRetrySucceeded and RPCRetriesExhausted.
+func (m *Metrics) RPCAttempt(endpoint string) {
+	m.rpcAttempts.WithLabelValues(EndpointLabel(endpoint)).Inc()
+}
+
+// RPCRetrySucceeded counts an RPC call that succeeded after a failed attempt.
+func (m *Metrics) RPCRetrySucceeded(endpoint string) {
+	m.rpcRetrySucceeded.WithLabelValues(EndpointLabel(endpoint)).Inc()
+}
+
+// RPCRetriesExhausted counts an RPC call that failed on every attempt.
+func (m *Metrics) RPCRetriesExhausted(endpoint string) {
+	m.rpcRetriesExhausted.WithLabelValues(EndpointLabel(endpoint)).Inc()
+}
+
+// RPCFailover counts a switch of the active RPC endpoint. It implements
+// blockchain.FailoverRecorder.
+func (m *Metrics) RPCFailover(from, to string) {
+	m.rpcFailovers.WithLabelValues(EndpointLabel(from), EndpointLabel(to)).Inc()
+}
+
+// RowsInserted counts rows written to the database. It implements
+// tracker.RowRecorder, like RowsSkipped.
+func (m *Metrics) RowsInserted(n int) {
+	m.rowsInserted.Add(float64(n))
+}
+
+// RowsSkipped counts rows not written for reason.
+func (m *Metrics) RowsSkipped(reason string, n int) {
+	m.rowsSkipped.WithLabelValues(reason).Add(float64(n))
+}
+
+// PollCompleted records the outcome of a polling cycle ending at end.
+func (m *Metrics) PollCompleted(succeeded bool, end time.Time) {
+	if !succeeded {
+		m.pollFailures.Inc()
+		return
+	}
+	m.lastSuccess.Set(float64(end.Unix()))
+}
+
+// SetHealthyEndpoints sets the function counting the healthy RPC endpoints,
+// called on every scrape. The gauge reads 0 until it is set.
+func (m *Metrics) SetHealthyEndpoints(count func() int) {
+	m.healthyEndpoints.Store(&count)
+}
+
+func (m *Metrics) countHealthyEndpoints() float64 {
+	count := m.healthyEndpoints.Load()
+	if count == nil {
+		return 0
+	}
+	return float64((*count)())
+}
+
+// EndpointLabel reduces an RPC URL to its host, so API keys carried in the
+// path or query string do not end up in metric labels. An empty URL (no
+// endpoint was available) is reported as "none".
+func EndpointLabel(endpoint string) string {
+	if endpoint == "" {
+		return "none"
+	}
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return endpoint
+}
+
+// Handler serves the metrics gathered by g. OpenMetrics negotiation is
+// enabled when exemplars are on, since the classic text format drops them.
+func (m *Metrics) Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{
+		EnableOpenMetrics: m.opts.Exemplars,
+	})
+}
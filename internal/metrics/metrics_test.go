@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleBalance() storage.TokenBalance {
+	return storage.TokenBalance{
+		Wallet:  "0x1234567890123456789012345678901234567890",
+		Symbol:  "armmXDAI",
+		Balance: decimal.RequireFromString("1234.5"),
+	}
+}
+
+func observationsMetric(t *testing.T, reg *prometheus.Registry) *dto.Metric {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() == "rmm_tracker_balance_observations_total" {
+			require.Len(t, mf.GetMetric(), 1)
+			return mf.GetMetric()[0]
+		}
+	}
+	t.Fatal("observations metric not gathered")
+	return nil
+}
+
+func TestObserveBalance_AttachesBlockExemplar(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{Exemplars: true})
+
+	m.ObserveBalance(sampleBalance(), 41234567)
+
+	metric := observationsMetric(t, reg)
+	assert.Equal(t, 1.0, metric.GetCounter().GetValue())
+	exemplar := metric.GetCounter().GetExemplar()
+	require.NotNil(t, exemplar)
+	require.Len(t, exemplar.GetLabel(), 1)
+	assert.Equal(t, ExemplarBlockLabel, exemplar.GetLabel()[0].GetName())
+	assert.Equal(t, "41234567", exemplar.GetLabel()[0].GetValue())
+}
+
+func TestObserveBalance_NoExemplarWhenDisabledOrUnknownBlock(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		New(reg, Options{}).ObserveBalance(sampleBalance(), 41234567)
+		assert.Nil(t, observationsMetric(t, reg).GetCounter().GetExemplar())
+	})
+
+	t.Run("unknown block", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		New(reg, Options{Exemplars: true}).ObserveBalance(sampleBalance(), 0)
+		metric := observationsMetric(t, reg)
+		assert.Equal(t, 1.0, metric.GetCounter().GetValue())
+		assert.Nil(t, metric.GetCounter().GetExemplar())
+	})
+}
+
+func TestObserveBalance_SetsGauge(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	New(reg, Options{}).ObserveBalance(sampleBalance(), 0)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, mf := range families {
+		if mf.GetName() == "rmm_tracker_token_balance" {
+			found = true
+			assert.Equal(t, 1234.5, mf.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestObserveBalances_RecordsBatch(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{Exemplars: true})
+
+	other := sampleBalance()
+	other.Symbol = "armmUSDC"
+	m.ObserveBalances([]storage.TokenBalance{sampleBalance(), other, sampleBalance()})
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() == "rmm_tracker_balance_observations_total" {
+			require.Len(t, mf.GetMetric(), 2)
+			var total float64
+			for _, metric := range mf.GetMetric() {
+				total += metric.GetCounter().GetValue()
+			}
+			assert.Equal(t, 3.0, total)
+			return
+		}
+	}
+	t.Fatal("observations metric not gathered")
+}
+
+func TestObserveBalances_AttachesBlockNumber(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{Exemplars: true})
+
+	b := sampleBalance()
+	b.BlockNumber = 41234567
+	m.ObserveBalances([]storage.TokenBalance{b})
+
+	exemplar := observationsMetric(t, reg).GetCounter().GetExemplar()
+	require.NotNil(t, exemplar)
+	assert.Equal(t, "41234567", exemplar.GetLabel()[0].GetValue())
+}
+
+func TestHandler_ServesExemplarsOverOpenMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{Exemplars: true})
+	m.ObserveBalance(sampleBalance(), 41234567)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	m.Handler(reg).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+	assert.Contains(t, rec.Body.String(), `# {block_number="41234567"} 1`)
+}
+
+// counterValue returns the value of the counter name whose single label has
+// the given value, or of the unlabelled counter name when label is empty,
+// failing the test when it was not gathered.
+func counterValue(t *testing.T, reg *prometheus.Registry, name, label string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := metric.GetLabel()
+			if (len(labels) == 0 && label == "") || (len(labels) > 0 && labels[0].GetValue() == label) {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	t.Fatalf("%s{%q} not gathered", name, label)
+	return 0
+}
+
+func TestRetryCounters(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{})
+
+	m.RPCAttempt("https://rpc.example.com/v3/secret-key")
+	m.RPCAttempt("https://rpc.example.com/v3/secret-key")
+	m.RPCRetrySucceeded("https://rpc.example.com/v3/secret-key")
+	m.RPCRetriesExhausted("")
+
+	assert.Equal(t, 2.0, counterValue(t, reg, "rmm_tracker_rpc_attempts_total", "rpc.example.com"))
+	assert.Equal(t, 1.0, counterValue(t, reg, "rmm_tracker_rpc_retry_succeeded_total", "rpc.example.com"))
+	assert.Equal(t, 1.0, counterValue(t, reg, "rmm_tracker_rpc_retries_exhausted_total", "none"))
+}
+
+func TestEndpointLabel(t *testing.T) {
+	assert.Equal(t, "rpc.gnosischain.com", EndpointLabel("https://rpc.gnosischain.com"))
+	assert.Equal(t, "gnosis-mainnet.example.io", EndpointLabel("https://gnosis-mainnet.example.io/v3/abcdef?key=1"))
+	assert.Equal(t, "none", EndpointLabel(""))
+	assert.Equal(t, "not a url", EndpointLabel("not a url"))
+}
+
+func TestRPCFailover(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{})
+
+	m.RPCFailover("https://primary.example.com/key", "https://backup.example.com")
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() == "rmm_tracker_failover_total" {
+			require.Len(t, mf.GetMetric(), 1)
+			metric := mf.GetMetric()[0]
+			labels := map[string]string{}
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			assert.Equal(t, map[string]string{"from": "primary.example.com", "to": "backup.example.com"}, labels)
+			assert.Equal(t, 1.0, metric.GetCounter().GetValue())
+			return
+		}
+	}
+	t.Fatal("failover metric not gathered")
+}
+
+func TestRowCounters(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{})
+
+	m.RowsInserted(3)
+	m.RowsInserted(2)
+	m.RowsSkipped("query_failed", 1)
+	m.RowsSkipped("no_raw_balance", 2)
+
+	assert.Equal(t, 5.0, counterValue(t, reg, "rmm_tracker_rows_inserted_total", ""))
+	assert.Equal(t, 1.0, counterValue(t, reg, "rmm_tracker_rows_skipped_total", "query_failed"))
+	assert.Equal(t, 2.0, counterValue(t, reg, "rmm_tracker_rows_skipped_total", "no_raw_balance"))
+}
+
+// gaugeValue returns the value of the unlabelled gauge name.
+func gaugeValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() == name {
+			require.Len(t, mf.GetMetric(), 1)
+			return mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("%s not gathered", name)
+	return 0
+}
+
+func TestPollCompleted(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{})
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	m.PollCompleted(true, end)
+	m.PollCompleted(false, end.Add(5*time.Minute))
+	m.PollCompleted(false, end.Add(10*time.Minute))
+
+	assert.Equal(t, 2.0, counterValue(t, reg, "rmm_tracker_poll_failures_total", ""))
+	assert.Equal(t, float64(end.Unix()), gaugeValue(t, reg, "rmm_tracker_last_success_timestamp_seconds"),
+		"failed cycles leave the last success alone")
+}
+
+func TestHealthyEndpoints(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg, Options{})
+	assert.Zero(t, gaugeValue(t, reg, "rmm_tracker_rpc_endpoints_healthy"))
+
+	healthy := 2
+	m.SetHealthyEndpoints(func() int { return healthy })
+	assert.Equal(t, 2.0, gaugeValue(t, reg, "rmm_tracker_rpc_endpoints_healthy"))
+	healthy = 1
+	assert.Equal(t, 1.0, gaugeValue(t, reg, "rmm_tracker_rpc_endpoints_healthy"), "read on every scrape")
+}
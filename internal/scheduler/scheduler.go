@@ -5,11 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/robfig/cron/v3"
 )
 
 // JobFunc is the function signature for scheduled jobs
@@ -18,11 +23,19 @@ type JobFunc func(ctx context.Context) error
 // Scheduler wraps gocron v2 and provides clock-aligned scheduling
 type Scheduler struct {
 	gocronScheduler gocron.Scheduler
+	mu              sync.Mutex // guards job and interval against Reschedule
 	job             gocron.Job
 	interval        string
 	timezone        *time.Location
 	runImmediately  bool
+	failOnFirstRun  bool
+	jitter          time.Duration
+	runTimeout      time.Duration
 	logger          *slog.Logger
+	ctx             context.Context
+	jobFunc         JobFunc
+	running         atomic.Bool  // a run is in progress
+	skipped         atomic.Int64 // fires skipped while a run was in progress
 }
 
 // Config holds scheduler configuration
@@ -30,13 +43,36 @@ type Config struct {
 	Interval       string         // Duration (e.g., "5m") or cron expression (e.g., "*/5 * * * *")
 	Timezone       *time.Location // Timezone for cron expressions (default: UTC)
 	RunImmediately bool           // Execute immediately on start (default: true)
+	FailOnFirstRun bool           // Return the immediate run's error from Start instead of logging it
+	Jitter         time.Duration  // Delay each run by a random offset up to this bound (default: none)
+	RunTimeout     time.Duration  // Cancel a run still going after this long (default: none)
 	Logger         *slog.Logger   // Logger for scheduler events
 }
 
+// FastIntervalThreshold is the poll interval below which a startup warning is
+// logged: every run costs one eth_call per wallet/token pair plus a DB write,
+// so sub-30s schedules quickly hit public RPC rate limits.
+const FastIntervalThreshold = 30 * time.Second
+
 var (
+	// cronParser mirrors the parser gocron uses for CronJob(expr, true)
+	cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 	// cronPattern matches cron expressions (5 or 6 fields)
 	cronPattern = regexp.MustCompile(`^(\S+\s+){4,5}\S+$`)
 
+	// cronDescriptors maps the crontab shortcuts to the cron expression they
+	// stand for. "@every" is left out as it is not clock aligned.
+	cronDescriptors = map[string]cronDescriptor{
+		"@yearly":   {cron: "0 0 1 1 *", description: "yearly on January 1 at midnight"},
+		"@annually": {cron: "0 0 1 1 *", description: "yearly on January 1 at midnight"},
+		"@monthly":  {cron: "0 0 1 * *", description: "monthly on the 1st at midnight"},
+		"@weekly":   {cron: "0 0 * * 0", description: "weekly on Sunday at midnight"},
+		"@daily":    {cron: "0 0 * * *", description: "daily at midnight"},
+		"@midnight": {cron: "0 0 * * *", description: "daily at midnight"},
+		"@hourly":   {cron: "0 * * * *", description: "hourly at minute 0"},
+	}
+
 	// validMinuteIntervals are minute intervals that divide evenly into 60
 	validMinuteIntervals = map[int]bool{
 		1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 10: true, 12: true,
@@ -55,6 +91,12 @@ var (
 	}
 )
 
+// cronDescriptor is a crontab shortcut such as "@daily".
+type cronDescriptor struct {
+	cron        string // equivalent cron expression
+	description string
+}
+
 // NewScheduler creates a new scheduler instance
 func NewScheduler(ctx context.Context, cfg Config, jobFunc JobFunc) (*Scheduler, error) {
 	if cfg.Timezone == nil {
@@ -64,11 +106,32 @@ func NewScheduler(ctx context.Context, cfg Config, jobFunc JobFunc) (*Scheduler,
 		cfg.Logger = slog.Default()
 	}
 
+	if cfg.RunTimeout < 0 {
+		return nil, fmt.Errorf("run timeout must not be negative (got %s)", cfg.RunTimeout)
+	}
+	if cfg.Jitter < 0 {
+		return nil, fmt.Errorf("jitter must not be negative (got %s)", cfg.Jitter)
+	}
+	if cfg.Jitter > 0 {
+		interval, err := EffectiveInterval(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+		if cfg.Jitter >= interval {
+			return nil, fmt.Errorf("jitter %s must be shorter than the interval %s", cfg.Jitter, interval)
+		}
+	}
+
 	s := &Scheduler{
 		interval:       cfg.Interval,
 		timezone:       cfg.Timezone,
 		runImmediately: cfg.RunImmediately,
+		failOnFirstRun: cfg.FailOnFirstRun,
+		jitter:         cfg.Jitter,
+		runTimeout:     cfg.RunTimeout,
 		logger:         cfg.Logger,
+		ctx:            ctx,
+		jobFunc:        jobFunc,
 	}
 
 	// Create gocron scheduler
@@ -81,71 +144,172 @@ func NewScheduler(ctx context.Context, cfg Config, jobFunc JobFunc) (*Scheduler,
 	}
 	s.gocronScheduler = gocronScheduler
 
-	// Determine if interval is duration or cron expression
-	isCron := isCronExpression(cfg.Interval)
-
-	var job gocron.Job
-	if isCron {
-		// Use cron expression directly
-		s.logger.Info("Using cron expression", "cron", cfg.Interval, "timezone", cfg.Timezone.String())
-		job, err = gocronScheduler.NewJob(
-			gocron.CronJob(cfg.Interval, true), // withSeconds = true for 6-field cron
-			gocron.NewTask(func() {
-				if err := jobFunc(ctx); err != nil {
-					s.logger.Error("Job execution failed", "error", err)
-				}
-			}),
-		)
-	} else {
-		// Convert duration to clock-aligned cron expression
-		cronExpr, cronErr := durationToCron(cfg.Interval)
-		if cronErr != nil {
-			return nil, fmt.Errorf("invalid interval: %w", cronErr)
-		}
+	definition, err := s.jobDefinition(cfg.Interval)
+	if err != nil {
+		return nil, err
+	}
+	job, err := gocronScheduler.NewJob(definition, s.task())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduled job: %w", err)
+	}
+
+	s.job = job
 
-		s.logger.Info("Converting duration to cron", "duration", cfg.Interval, "cron", cronExpr, "timezone", cfg.Timezone.String())
+	return s, nil
+}
 
-		job, err = gocronScheduler.NewJob(
-			gocron.CronJob(cronExpr, strings.Count(cronExpr, " ") == 5), // withSeconds if 6 fields
-			gocron.NewTask(func() {
-				if err := jobFunc(ctx); err != nil {
-					s.logger.Error("Job execution failed", "error", err)
-				}
-			}),
-		)
+// jobDefinition returns the clock-aligned schedule of interval, a duration
+// or a cron expression.
+func (s *Scheduler) jobDefinition(interval string) (gocron.JobDefinition, error) {
+	if isCronExpression(interval) {
+		// Use cron expression directly, descriptors expanded
+		expr := expandDescriptor(interval)
+		s.logger.Info("Using cron expression", "schedule", interval, "cron", expr, "timezone", s.timezone.String())
+		return gocron.CronJob(expr, true), nil // withSeconds = true for 6-field cron
 	}
 
+	// Convert duration to clock-aligned cron expression
+	cronExpr, err := durationToCron(interval)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create scheduled job: %w", err)
+		return nil, fmt.Errorf("invalid interval: %w", err)
 	}
+	s.logger.Info("Converting duration to cron", "duration", interval, "cron", cronExpr, "timezone", s.timezone.String())
+	return gocron.CronJob(cronExpr, strings.Count(cronExpr, " ") == 5), nil // withSeconds if 6 fields
+}
 
+// task runs the job through runExclusive.
+func (s *Scheduler) task() gocron.Task {
+	return gocron.NewTask(s.runExclusive)
+}
+
+// runExclusive runs the job function, logging its error. A fire arriving
+// while the previous run is still in progress is skipped and counted. With a
+// jitter the run first waits a random delay: the cron still fires on the
+// clock boundary, but trackers sharing a schedule do not all call the RPC in
+// the same second.
+func (s *Scheduler) runExclusive() {
+	if !s.running.CompareAndSwap(false, true) {
+		skipped := s.skipped.Add(1)
+		s.logger.Warn("Skipping run, previous still in progress", "skipped_runs", skipped)
+		return
+	}
+	defer s.running.Store(false)
+
+	if !s.waitJitter() {
+		return
+	}
+	if err := s.run(); err != nil {
+		logger.LogErrorTo(s.ctx, s.logger, slog.LevelError, "Job execution failed", err)
+	}
+}
+
+// SkippedRuns returns how many fires were skipped because the previous run
+// was still in progress, a sign that runs take longer than the interval.
+func (s *Scheduler) SkippedRuns() int64 {
+	return s.skipped.Load()
+}
+
+// run executes the job function. With a run timeout the job context is
+// cancelled once it has elapsed, so a hung RPC call cannot make the run
+// overlap the next one; the timed out run returns an error.
+func (s *Scheduler) run() error {
+	if s.runTimeout <= 0 {
+		return s.jobFunc(s.ctx)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.runTimeout)
+	defer cancel()
+	err := s.jobFunc(ctx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && s.ctx.Err() == nil {
+		if err == nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("run timed out after %s: %w", s.runTimeout, err)
+	}
+	return err
+}
+
+// waitJitter sleeps a random delay below the jitter. It returns false when
+// the scheduler context is done first.
+func (s *Scheduler) waitJitter() bool {
+	if s.jitter <= 0 {
+		return true
+	}
+	delay := rand.N(s.jitter)
+	s.logger.Debug("Delaying run by jitter", "delay", delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Reschedule moves the job to interval, a duration or a cron expression,
+// without stopping the scheduler. A run in progress is not interrupted.
+func (s *Scheduler) Reschedule(interval string) error {
+	definition, err := s.jobDefinition(interval)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, err := s.gocronScheduler.Update(s.job.ID(), definition, s.task())
+	if err != nil {
+		return fmt.Errorf("failed to reschedule job: %w", err)
+	}
 	s.job = job
+	s.interval = interval
+	return nil
+}
 
-	return s, nil
+// currentJob returns the scheduled job, replaced by Reschedule.
+func (s *Scheduler) currentJob() gocron.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
 }
 
-// Start begins the scheduler
+// Start begins the scheduler. With FailOnFirstRun, the immediate run is
+// awaited before scheduling and its error is returned, leaving the scheduler
+// stopped.
 func (s *Scheduler) Start() error {
+	if s.runImmediately && s.failOnFirstRun {
+		s.logger.Info("Executing job immediately")
+		if err := s.run(); err != nil {
+			return fmt.Errorf("first run failed: %w", err)
+		}
+		s.gocronScheduler.Start()
+		s.logStarted()
+		return nil
+	}
+
 	// Start the scheduler first (required before RunNow)
 	s.gocronScheduler.Start()
 
 	// Run immediately if configured
 	if s.runImmediately {
 		s.logger.Info("Executing job immediately")
-		if err := s.job.RunNow(); err != nil {
+		if err := s.currentJob().RunNow(); err != nil {
 			s.logger.Error("Immediate execution failed", "error", err)
 			// Don't return error, continue with scheduled execution
 		}
 	}
 
+	s.logStarted()
+	return nil
+}
+
+// logStarted logs the next scheduled run.
+func (s *Scheduler) logStarted() {
 	nextRun, err := s.NextRun()
 	if err == nil {
 		s.logger.Info("Scheduler started", "next_run", nextRun.Format(time.RFC3339), "timezone", s.timezone.String())
 	} else {
 		s.logger.Info("Scheduler started")
 	}
-
-	return nil
 }
 
 // Stop stops the scheduler gracefully
@@ -156,16 +320,25 @@ func (s *Scheduler) Stop() error {
 
 // NextRun returns the next scheduled run time
 func (s *Scheduler) NextRun() (time.Time, error) {
-	nextRun, err := s.job.NextRun()
+	nextRun, err := s.currentJob().NextRun()
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed to get next run: %w", err)
 	}
 	return nextRun, nil
 }
 
+// NextRuns returns the next n scheduled run times
+func (s *Scheduler) NextRuns(n int) ([]time.Time, error) {
+	runs, err := s.currentJob().NextRuns(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next runs: %w", err)
+	}
+	return runs, nil
+}
+
 // LastRun returns the last run time
 func (s *Scheduler) LastRun() (time.Time, error) {
-	lastRun, err := s.job.LastRun()
+	lastRun, err := s.currentJob().LastRun()
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed to get last run: %w", err)
 	}
@@ -175,8 +348,12 @@ func (s *Scheduler) LastRun() (time.Time, error) {
 // GetExpectedInterval calculates the expected interval between executions
 // This is used by the health checker to determine if executions are on schedule
 func (s *Scheduler) GetExpectedInterval() (time.Duration, error) {
+	s.mu.Lock()
+	interval := s.interval
+	s.mu.Unlock()
+
 	// Try to parse as duration first
-	if duration, err := time.ParseDuration(s.interval); err == nil {
+	if duration, err := time.ParseDuration(interval); err == nil {
 		return duration, nil
 	}
 
@@ -190,10 +367,22 @@ func (s *Scheduler) GetExpectedInterval() (time.Duration, error) {
 
 // isCronExpression checks if a string is a cron expression (vs duration)
 func isCronExpression(s string) bool {
+	if _, ok := cronDescriptors[s]; ok {
+		return true
+	}
 	// Cron expressions have 5 or 6 space-separated fields
 	return cronPattern.MatchString(s)
 }
 
+// expandDescriptor returns the cron expression a descriptor such as "@daily"
+// stands for, and any other schedule unchanged.
+func expandDescriptor(s string) string {
+	if d, ok := cronDescriptors[s]; ok {
+		return d.cron
+	}
+	return s
+}
+
 // durationToCron converts a duration string to a clock-aligned cron expression
 // Examples:
 //
@@ -254,6 +443,7 @@ func ValidateScheduleInterval(interval string) error {
 
 	// Check if it's a cron expression
 	if isCronExpression(interval) {
+		interval = expandDescriptor(interval)
 		// Basic validation - gocron will do deeper validation
 		fields := strings.Fields(interval)
 		if len(fields) != 5 && len(fields) != 6 {
@@ -267,6 +457,106 @@ func ValidateScheduleInterval(interval string) error {
 	return err
 }
 
+// NextCronTime returns the first time after after at which the cron
+// expression fires, in after's location.
+func NextCronTime(expr string, after time.Time) (time.Time, error) {
+	sched, err := cronParser.Parse(expr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	next := sched.Next(after)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never fires", expr)
+	}
+	return next, nil
+}
+
+// NextRunTimes returns the first n times after after at which interval, a
+// duration or a cron expression, fires in timezone. It computes the schedule a
+// Scheduler would follow without creating one.
+func NextRunTimes(interval string, timezone *time.Location, after time.Time, n int) ([]time.Time, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("run count must be positive (got %d)", n)
+	}
+	if timezone == nil {
+		timezone = time.UTC
+	}
+	expr := expandDescriptor(interval)
+	if !isCronExpression(interval) {
+		var err error
+		if expr, err = durationToCron(interval); err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+	}
+
+	runs := make([]time.Time, 0, n)
+	next := after.In(timezone)
+	for range n {
+		var err error
+		if next, err = NextCronTime(expr, next); err != nil {
+			return nil, err
+		}
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
+
+// EffectiveInterval returns the shortest gap between two consecutive runs.
+// For durations this is the duration itself; for cron expressions it is the
+// smallest gap among the upcoming fire times.
+func EffectiveInterval(interval string) (time.Duration, error) {
+	if !isCronExpression(interval) {
+		return time.ParseDuration(interval)
+	}
+
+	sched, err := cronParser.Parse(expandDescriptor(interval))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	const samples = 64
+	prev := sched.Next(time.Now().UTC())
+	shortest := time.Duration(0)
+	for range samples {
+		next := sched.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		prev = next
+	}
+	if shortest == 0 {
+		return 0, fmt.Errorf("cron expression %q never fires twice", interval)
+	}
+	return shortest, nil
+}
+
+// WarnIfTooFast logs a warning when the interval fires more often than
+// FastIntervalThreshold, unless the operator acknowledged it. It returns
+// whether the warning was emitted. This is a guardrail, not a hard block.
+func WarnIfTooFast(logger *slog.Logger, interval string, acknowledged bool) bool {
+	if interval == "" || acknowledged {
+		return false
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	effective, err := EffectiveInterval(interval)
+	if err != nil || effective >= FastIntervalThreshold {
+		return false
+	}
+
+	logger.Warn("Poll interval is very short: expect RPC rate limiting and fast table growth",
+		"interval", interval,
+		"effective", effective,
+		"threshold", FastIntervalThreshold,
+		"hint", "set i_know_this_is_fast = true to silence this warning")
+	return true
+}
+
 // gocronLoggerAdapter adapts slog.Logger to gocron.Logger interface
 type gocronLoggerAdapter struct {
 	logger *slog.Logger
@@ -323,6 +613,9 @@ func DescribeSchedule(interval string, timezone *time.Location) string {
 		timezone = time.UTC
 	}
 
+	if d, ok := cronDescriptors[interval]; ok {
+		return fmt.Sprintf("%s (%s)", d.description, timezone.String())
+	}
 	if isCronExpression(interval) {
 		return fmt.Sprintf("cron: %s (%s)", interval, timezone.String())
 	}
@@ -1,7 +1,11 @@
 package scheduler
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"log/slog"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -67,6 +71,13 @@ func TestValidateScheduleInterval(t *testing.T) {
 		{"cron 6 fields", "*/30 * * * * *", false},
 		{"cron with seconds at hour start", "0 0 * * * *", false},
 
+		// Descriptors
+		{"descriptor daily", "@daily", false},
+		{"descriptor hourly", "@hourly", false},
+		{"descriptor weekly", "@weekly", false},
+		{"descriptor every", "@every 5m", true},
+		{"unknown descriptor", "@fortnightly", true},
+
 		// Invalid cron expressions
 		{"cron too few fields", "*/5 * * *", true},
 		{"cron too many fields", "*/5 * * * * * *", true},
@@ -162,6 +173,7 @@ func TestIsCronExpression(t *testing.T) {
 	}{
 		{"5-field cron", "*/5 * * * *", true},
 		{"6-field cron", "*/30 * * * * *", true},
+		{"descriptor", "@daily", true},
 		{"duration 5m", "5m", false},
 		{"duration 1h", "1h", false},
 		{"invalid", "not a cron", false},
@@ -206,6 +218,10 @@ func TestDescribeSchedule(t *testing.T) {
 		// Cron with 6 fields (seconds)
 		{"cron 6 fields UTC", "*/30 * * * * *", utc, "cron: */30 * * * * * (UTC)"},
 
+		// Descriptors
+		{"daily UTC", "@daily", utc, "daily at midnight (UTC)"},
+		{"hourly NYC", "@hourly", ny, "hourly at minute 0 (America/New_York)"},
+
 		// Invalid durations (non-aligned)
 		{"invalid 7m", "7m", utc, "duration: 7m (non-aligned)"},
 		{"invalid 13m", "13m", utc, "duration: 13m (non-aligned)"},
@@ -288,3 +304,284 @@ func TestGocronLoggerAdapter(t *testing.T) {
 		// If we got here without panic, test passes
 	})
 }
+
+func TestEffectiveInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"duration", "5m", 5 * time.Minute, false},
+		{"seconds duration", "10s", 10 * time.Second, false},
+		{"cron every 5 minutes", "*/5 * * * *", 5 * time.Minute, false},
+		{"cron with seconds", "*/10 * * * * *", 10 * time.Second, false},
+		{"irregular cron uses shortest gap", "0 9,17 * * *", 8 * time.Hour, false},
+		{"invalid cron", "a b c d e", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EffectiveInterval(tt.interval)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWarnIfTooFast(t *testing.T) {
+	tests := []struct {
+		name         string
+		interval     string
+		acknowledged bool
+		wantWarn     bool
+	}{
+		{"1s is too fast", "1s", false, true},
+		{"cron every 10s is too fast", "*/10 * * * * *", false, true},
+		{"30s is at the threshold", "30s", false, false},
+		{"5m is fine", "5m", false, false},
+		{"override suppresses warning", "1s", true, false},
+		{"one-shot mode never warns", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+			warned := WarnIfTooFast(logger, tt.interval, tt.acknowledged)
+
+			assert.Equal(t, tt.wantWarn, warned)
+			if tt.wantWarn {
+				assert.Contains(t, buf.String(), "level=WARN")
+				assert.Contains(t, buf.String(), "i_know_this_is_fast")
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+func TestStart_FirstRunFailure(t *testing.T) {
+	errBroken := errors.New("rpc unreachable")
+	newFailing := func(t *testing.T, failOnFirstRun bool) (*Scheduler, chan struct{}) {
+		t.Helper()
+		ran := make(chan struct{}, 1)
+		s, err := NewScheduler(context.Background(), Config{
+			Interval:       "1h",
+			RunImmediately: true,
+			FailOnFirstRun: failOnFirstRun,
+			Logger:         slog.New(slog.DiscardHandler),
+		}, func(context.Context) error {
+			ran <- struct{}{}
+			return errBroken
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Stop() })
+		return s, ran
+	}
+
+	t.Run("returned with fail_on_first_run", func(t *testing.T) {
+		s, ran := newFailing(t, true)
+		err := s.Start()
+		require.ErrorIs(t, err, errBroken)
+		assert.ErrorContains(t, err, "first run failed")
+		assert.Len(t, ran, 1)
+	})
+
+	t.Run("swallowed by default", func(t *testing.T) {
+		s, ran := newFailing(t, false)
+		require.NoError(t, s.Start())
+		select {
+		case <-ran:
+		case <-time.After(5 * time.Second):
+			t.Fatal("immediate run did not happen")
+		}
+	})
+}
+
+func TestReschedule(t *testing.T) {
+	s, err := NewScheduler(context.Background(), Config{
+		Interval: "1h",
+		Logger:   slog.New(slog.DiscardHandler),
+	}, func(context.Context) error { return nil })
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+	require.NoError(t, s.Start())
+
+	next, err := s.NextRun()
+	require.NoError(t, err)
+	assert.Zero(t, next.Minute(), "hourly runs are on the hour")
+
+	require.NoError(t, s.Reschedule("0 30 12 1 1 *"))
+	next, err = s.NextRun()
+	require.NoError(t, err)
+	assert.Equal(t, time.January, next.Month())
+	assert.Equal(t, 30, next.Minute())
+	interval, err := s.GetExpectedInterval()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, interval, "cron schedules use the conservative estimate")
+
+	require.NoError(t, s.Reschedule("10m"))
+	interval, err = s.GetExpectedInterval()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, interval)
+
+	assert.Error(t, s.Reschedule("7m"), "invalid intervals keep the current schedule")
+	interval, err = s.GetExpectedInterval()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, interval)
+}
+
+func TestJitter(t *testing.T) {
+	newJittered := func(ctx context.Context, jitter time.Duration) (*Scheduler, error) {
+		return NewScheduler(ctx, Config{
+			Interval: "1m",
+			Jitter:   jitter,
+			Logger:   slog.New(slog.DiscardHandler),
+		}, func(context.Context) error { return nil })
+	}
+
+	_, err := newJittered(context.Background(), time.Minute)
+	assert.ErrorContains(t, err, "jitter 1m0s must be shorter than the interval 1m0s")
+	_, err = newJittered(context.Background(), -time.Second)
+	assert.Error(t, err)
+
+	s, err := newJittered(context.Background(), 20*time.Millisecond)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+	start := time.Now()
+	assert.True(t, s.waitJitter())
+	assert.Less(t, time.Since(start), time.Second, "the delay stays below the jitter")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, err = newJittered(ctx, 50*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+	assert.False(t, s.waitJitter(), "a cancelled run is not started")
+}
+
+func TestRunTimeout(t *testing.T) {
+	newTimed := func(t *testing.T, timeout time.Duration, job JobFunc) *Scheduler {
+		t.Helper()
+		s, err := NewScheduler(context.Background(), Config{
+			Interval:   "1h",
+			RunTimeout: timeout,
+			Logger:     slog.New(slog.DiscardHandler),
+		}, job)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Stop() })
+		return s
+	}
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil // the tracker returns nil once its last wallet gives up
+	}
+
+	err := newTimed(t, 20*time.Millisecond, hung).run()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "run timed out after 20ms")
+
+	assert.NoError(t, newTimed(t, time.Minute, func(context.Context) error { return nil }).run())
+
+	_, err = NewScheduler(context.Background(), Config{Interval: "1h", RunTimeout: -time.Second}, hung)
+	assert.Error(t, err)
+}
+
+func TestRunExclusive(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var runs atomic.Int32
+	s, err := NewScheduler(context.Background(), Config{
+		Interval: "1h",
+		Logger:   slog.New(slog.DiscardHandler),
+	}, func(context.Context) error {
+		runs.Add(1)
+		close(started)
+		<-release
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+
+	done := make(chan struct{})
+	go func() {
+		s.runExclusive()
+		close(done)
+	}()
+	<-started
+
+	s.runExclusive() // the second tick arrives while the first run is still going
+	assert.Equal(t, int64(1), s.SkippedRuns())
+	assert.Equal(t, int32(1), runs.Load(), "the second tick is skipped")
+
+	close(release)
+	<-done
+	assert.False(t, s.running.Load(), "the next tick can run again")
+}
+
+func TestNextRunTimes(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	// Friday 2026-03-06 12:02 in Paris
+	after := time.Date(2026, 3, 6, 12, 2, 0, 0, paris)
+
+	runs, err := NextRunTimes("0 9,17 * * 1-5", paris, after, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 3, 6, 17, 0, 0, 0, paris),
+		time.Date(2026, 3, 9, 9, 0, 0, 0, paris),
+		time.Date(2026, 3, 9, 17, 0, 0, 0, paris),
+	}, runs, "weekends are skipped")
+
+	runs, err = NextRunTimes("5m", nil, after.UTC(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 3, 6, 11, 5, 0, 0, time.UTC),
+		time.Date(2026, 3, 6, 11, 10, 0, 0, time.UTC),
+	}, runs, "durations are clock aligned")
+
+	runs, err = NextRunTimes("@daily", paris, after, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2026, 3, 7, 0, 0, 0, 0, paris)}, runs)
+
+	_, err = NextRunTimes("7m", nil, after, 2)
+	assert.Error(t, err)
+	_, err = NextRunTimes("5m", nil, after, 0)
+	assert.Error(t, err)
+}
+
+func TestScheduler_NextRuns(t *testing.T) {
+	s, err := NewScheduler(context.Background(), Config{
+		Interval: "1h",
+		Logger:   slog.New(slog.DiscardHandler),
+	}, func(context.Context) error { return nil })
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+	require.NoError(t, s.Start())
+
+	runs, err := s.NextRuns(3)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, time.Hour, runs[1].Sub(runs[0]))
+	assert.Equal(t, 0, runs[0].Minute(), "aligned to the hour")
+}
+
+func TestNewScheduler_Descriptor(t *testing.T) {
+	s, err := NewScheduler(context.Background(), Config{
+		Interval: "@hourly",
+		Logger:   slog.New(slog.DiscardHandler),
+	}, func(context.Context) error { return nil })
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+	require.NoError(t, s.Start())
+
+	next, err := s.NextRun()
+	require.NoError(t, err)
+	assert.Zero(t, next.Minute())
+	assert.Zero(t, next.Second())
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Balance archives are the newline-delimited JSON files read by import. The
+// first line is a header naming the format and its version, so files stay
+// importable as columns are added:
+//
+//	{"format":"rmm-tracker-balances","version":10}
+//
+// Each following line is one TokenBalance in its JSON shape. Versions:
+//
+//  1. queried_at, wallet, token_address, symbol, decimals, raw_balance, balance
+//  2. adds source and block_timestamp
+//  3. adds label
+//  4. adds tags
+//  5. adds usd_value
+//  6. adds carried_forward
+//  7. adds block_number
+//  8. adds fetch_latency_ms
+//  9. adds wallet_label
+//  10. adds token_name
+const (
+	ArchiveFormat  = "rmm-tracker-balances"
+	ArchiveVersion = 10
+)
+
+// ErrArchiveVersion is returned when an archive's version is not supported.
+var ErrArchiveVersion = errors.New("unsupported archive version")
+
+// ArchiveHeader is the first line of a balance archive.
+type ArchiveHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// ArchiveWriter writes balances as an archive of the current version.
+type ArchiveWriter struct {
+	enc *json.Encoder
+}
+
+// NewArchiveWriter writes the archive header to w.
+func NewArchiveWriter(w io.Writer) (*ArchiveWriter, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(ArchiveHeader{Format: ArchiveFormat, Version: ArchiveVersion}); err != nil {
+		return nil, fmt.Errorf("write archive header: %w", err)
+	}
+	return &ArchiveWriter{enc: enc}, nil
+}
+
+// Write appends one balance to the archive.
+func (a *ArchiveWriter) Write(b TokenBalance) error {
+	return a.enc.Encode(b)
+}
+
+// ArchiveReader reads the balances of an archive, upgrading rows of older
+// versions to the current TokenBalance.
+type ArchiveReader struct {
+	Header ArchiveHeader
+
+	dec  *json.Decoder
+	rows int
+}
+
+// NewArchiveReader reads and checks the archive header from r. Archives of a
+// version newer than ArchiveVersion are rejected with ErrArchiveVersion.
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	dec := json.NewDecoder(r)
+	var h ArchiveHeader
+	if err := dec.Decode(&h); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("empty archive")
+		}
+		return nil, fmt.Errorf("read archive header: %w", err)
+	}
+	if h.Format != ArchiveFormat {
+		return nil, fmt.Errorf("not a balance archive: missing %q header", ArchiveFormat)
+	}
+	if h.Version < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrArchiveVersion, h.Version)
+	}
+	if h.Version > ArchiveVersion {
+		return nil, fmt.Errorf("%w: archive version %d is newer than supported version %d, upgrade rmm-tracker",
+			ErrArchiveVersion, h.Version, ArchiveVersion)
+	}
+	return &ArchiveReader{Header: h, dec: dec}, nil
+}
+
+// Read returns the next balance, or io.EOF after the last one.
+func (a *ArchiveReader) Read() (TokenBalance, error) {
+	var b TokenBalance
+	if err := a.dec.Decode(&b); err != nil {
+		if errors.Is(err, io.EOF) {
+			return TokenBalance{}, io.EOF
+		}
+		return TokenBalance{}, fmt.Errorf("archive row %d: %w", a.rows+1, err)
+	}
+	a.rows++
+
+	// Ignore anything found in columns predating the archive's version
+	if a.Header.Version < 2 {
+		b.Source = ""
+		b.BlockTimestamp = nil
+	}
+	if a.Header.Version < 3 {
+		b.Label = ""
+	}
+	if a.Header.Version < 4 {
+		b.Tags = nil
+	}
+	if a.Header.Version < 5 {
+		b.USDValue = nil
+	}
+	if a.Header.Version < 6 {
+		b.CarriedForward = false
+	}
+	if a.Header.Version < 7 {
+		b.BlockNumber = 0
+	}
+	if a.Header.Version < 8 {
+		b.FetchLatencyMS = nil
+	}
+	if a.Header.Version < 9 {
+		b.WalletLabel = ""
+	}
+	if a.Header.Version < 10 {
+		b.TokenName = ""
+	}
+	return b, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readArchive(t *testing.T, r io.Reader) (ArchiveHeader, []TokenBalance) {
+	t.Helper()
+	ar, err := NewArchiveReader(r)
+	require.NoError(t, err)
+	var balances []TokenBalance
+	for {
+		b, err := ar.Read()
+		if errors.Is(err, io.EOF) {
+			return ar.Header, balances
+		}
+		require.NoError(t, err)
+		balances = append(balances, b)
+	}
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	raw, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+	queried := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	block := queried.Add(-5 * time.Second)
+	usd := decimal.RequireFromString("123456789012.35")
+	latency := int64(42)
+	in := []TokenBalance{
+		{
+			QueriedAt:      queried,
+			Wallet:         "0xabcdef0123456789abcdef0123456789abcdef01",
+			TokenAddress:   "0x0000000000000000000000000000000000000001",
+			Symbol:         "armmXDAI",
+			Decimals:       18,
+			RawBalance:     raw,
+			Balance:        decimal.RequireFromString("123456789012.34567890123456789"),
+			Source:         SourceBackfill,
+			BlockTimestamp: &block,
+			Label:          "armmXDAI",
+			Tags:           map[string]string{"owner": "alice"},
+			USDValue:       &usd,
+			CarriedForward: true,
+			BlockNumber:    41234567,
+			FetchLatencyMS: &latency,
+			WalletLabel:    "Savings",
+			TokenName:      "RealT RMM V3 USDC",
+		},
+		{
+			QueriedAt:    queried,
+			Wallet:       "0xabcdef0123456789abcdef0123456789abcdef01",
+			TokenAddress: "0x0000000000000000000000000000000000000002",
+			Symbol:       "armmUSDC",
+			Decimals:     6,
+			RawBalance:   big.NewInt(2_000_000),
+			Balance:      decimal.NewFromInt(2),
+		},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewArchiveWriter(&buf)
+	require.NoError(t, err)
+	for _, b := range in {
+		require.NoError(t, w.Write(b))
+	}
+
+	firstLine, _, _ := strings.Cut(buf.String(), "\n")
+	assert.JSONEq(t, `{"format":"rmm-tracker-balances","version":10}`, firstLine)
+
+	header, out := readArchive(t, &buf)
+	assert.Equal(t, ArchiveVersion, header.Version)
+	require.Len(t, out, len(in))
+	for i := range in {
+		assert.Equal(t, in[i].RawBalance.String(), out[i].RawBalance.String())
+		assert.True(t, in[i].Balance.Equal(out[i].Balance))
+		assert.True(t, in[i].QueriedAt.Equal(out[i].QueriedAt))
+		assert.Equal(t, in[i].Source, out[i].Source)
+		assert.Equal(t, in[i].Label, out[i].Label)
+		assert.Equal(t, in[i].Tags, out[i].Tags)
+		assert.Equal(t, in[i].CarriedForward, out[i].CarriedForward)
+		assert.Equal(t, in[i].BlockNumber, out[i].BlockNumber)
+		assert.Equal(t, in[i].FetchLatencyMS, out[i].FetchLatencyMS)
+		assert.Equal(t, in[i].WalletLabel, out[i].WalletLabel)
+		assert.Equal(t, in[i].TokenName, out[i].TokenName)
+	}
+	require.NotNil(t, out[0].USDValue)
+	assert.True(t, usd.Equal(*out[0].USDValue))
+	assert.Nil(t, out[1].USDValue)
+	require.NotNil(t, out[0].BlockTimestamp)
+	assert.True(t, block.Equal(*out[0].BlockTimestamp))
+	assert.Nil(t, out[1].BlockTimestamp)
+}
+
+func TestArchive_ReadsVersion1(t *testing.T) {
+	// Version 1 had no source nor block_timestamp; stray values are ignored
+	file := `{"format":"rmm-tracker-balances","version":1}
+{"queried_at":"2025-01-01T00:00:00Z","wallet":"0xabc","token_address":"0x01","symbol":"armmXDAI","decimals":18,"raw_balance":"1500000000000000000","balance":"1.5"}
+{"queried_at":"2025-01-01T00:00:00Z","wallet":"0xabc","token_address":"0x02","symbol":"armmUSDC","decimals":6,"raw_balance":"2000000","balance":"2","source":"bogus"}
+`
+	header, balances := readArchive(t, strings.NewReader(file))
+	assert.Equal(t, 1, header.Version)
+	require.Len(t, balances, 2)
+	assert.Equal(t, "1500000000000000000", balances[0].RawBalance.String())
+	assert.Equal(t, "1.5", balances[0].Balance.String())
+	assert.Empty(t, balances[1].Source)
+	assert.Nil(t, balances[1].BlockTimestamp)
+}
+
+func TestArchive_ReadsVersion2(t *testing.T) {
+	file := `{"format":"rmm-tracker-balances","version":2}
+{"queried_at":"2025-01-01T00:00:00Z","wallet":"0xabc","token_address":"0x01","symbol":"armmXDAI","decimals":18,"raw_balance":"1","balance":"1","source":"backfill","label":"stray","tags":{"owner":"stray"},"usd_value":"1","carried_forward":true,"block_number":1,"fetch_latency_ms":1,"wallet_label":"stray","token_name":"stray"}
+`
+	_, balances := readArchive(t, strings.NewReader(file))
+	require.Len(t, balances, 1)
+	assert.Equal(t, SourceBackfill, balances[0].Source)
+	assert.Empty(t, balances[0].Label)
+	assert.Nil(t, balances[0].Tags)
+	assert.Nil(t, balances[0].USDValue)
+	assert.False(t, balances[0].CarriedForward)
+	assert.Zero(t, balances[0].BlockNumber)
+	assert.Nil(t, balances[0].FetchLatencyMS)
+	assert.Empty(t, balances[0].WalletLabel)
+	assert.Empty(t, balances[0].TokenName)
+}
+
+func TestArchive_RejectsUnknownFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"empty", "", "empty archive"},
+		{"future version", `{"format":"rmm-tracker-balances","version":11}`, "newer than supported version 10"},
+		{"zero version", `{"format":"rmm-tracker-balances","version":0}`, "unsupported archive version"},
+		{"headerless", `{"queried_at":"2025-01-01T00:00:00Z","wallet":"0xabc"}`, "not a balance archive"},
+		{"not json", "queried_at,wallet\n", "read archive header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewArchiveReader(strings.NewReader(tt.file))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := NewArchiveReader(strings.NewReader(`{"format":"rmm-tracker-balances","version":11}`))
+	assert.ErrorIs(t, err, ErrArchiveVersion)
+}
+
+func TestArchive_ReportsBadRow(t *testing.T) {
+	file := `{"format":"rmm-tracker-balances","version":10}
+{"queried_at":"2025-01-01T00:00:00Z","raw_balance":"1","balance":"1"}
+{"queried_at":"2025-01-01T00:00:00Z","raw_balance":"nope","balance":"1"}
+`
+	ar, err := NewArchiveReader(strings.NewReader(file))
+	require.NoError(t, err)
+	_, err = ar.Read()
+	require.NoError(t, err)
+	_, err = ar.Read()
+	assert.ErrorContains(t, err, "archive row 2")
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// BufferedStore keeps balances that could not be persisted because the
+// database was unreachable in a bounded in-memory buffer and retries them
+// with the next write, so a brief outage does not lose the cycles polled in
+// the meantime.
+//
+// When the buffer exceeds its capacity the oldest balances are dropped. The
+// buffer does not survive a restart.
+type BufferedStore struct {
+	Commander
+
+	mu       sync.Mutex
+	pending  []TokenBalance
+	capacity int
+}
+
+// NewBufferedStore wraps next with a buffer holding at most capacity balances.
+func NewBufferedStore(next Commander, capacity int) *BufferedStore {
+	return &BufferedStore{Commander: next, capacity: capacity}
+}
+
+// BatchInsertBalances writes any buffered balances, then balances. Balances
+// failing on a connection error are buffered; other failures (e.g. a rejected
+// row) would fail again on retry, so those balances are dropped and logged.
+// Either way the error is returned so the cycle is still reported as failed.
+// The balances written are those of balances, not the flushed ones.
+func (b *BufferedStore) BatchInsertBalances(ctx context.Context, balances []TokenBalance) ([]TokenBalance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if unreachable, err := b.flush(ctx); unreachable {
+		b.buffer(balances)
+		return nil, fmt.Errorf("%w (%d balances buffered)", err, len(b.pending))
+	}
+
+	if len(balances) == 0 {
+		return nil, nil
+	}
+	written, err := b.Commander.BatchInsertBalances(ctx, balances)
+	if err != nil {
+		if b.isUnreachable(ctx, err) {
+			b.buffer(balances)
+			return nil, fmt.Errorf("%w (%d balances buffered)", err, len(b.pending))
+		}
+		slog.Error("Balances dropped, write failed", "count", len(balances), "error", err)
+		return nil, err
+	}
+	return written, nil
+}
+
+// Flush writes the buffered balances, if any. It is called at the start of
+// each daemon cycle so a recovered database catches up before new balances
+// are polled.
+func (b *BufferedStore) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err := b.flush(ctx)
+	return err
+}
+
+// flush writes the buffered balances. They are kept when the database is
+// unreachable and discarded on any other error. Callers must hold b.mu.
+func (b *BufferedStore) flush(ctx context.Context) (unreachable bool, err error) {
+	if len(b.pending) == 0 {
+		return false, nil
+	}
+	if _, err := b.Commander.BatchInsertBalances(ctx, b.pending); err != nil {
+		if b.isUnreachable(ctx, err) {
+			return true, err
+		}
+		slog.Error("Buffered balances dropped, write failed", "count", len(b.pending), "error", err)
+		b.pending = nil
+		return false, err
+	}
+
+	slog.Info("Buffered balances flushed", "count", len(b.pending))
+	b.pending = nil
+	return false, nil
+}
+
+// isUnreachable reports whether err means the database could not be reached,
+// as opposed to rejecting the write. Errors that are not recognisably
+// connection errors count as such when the wrapped store also fails a ping.
+func (b *BufferedStore) isUnreachable(ctx context.Context, err error) bool {
+	if isConnectionError(err) {
+		return true
+	}
+	if p, ok := b.Commander.(Pinger); ok {
+		return p.Ping(ctx) != nil
+	}
+	return false
+}
+
+// isConnectionError reports whether err comes from a failed or lost
+// database connection rather than from the statement itself.
+func isConnectionError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || errors.As(err, &connectErr) || errors.As(err, &netErr)
+}
+
+// Pending returns the number of buffered balances.
+func (b *BufferedStore) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// buffer appends balances, dropping the oldest beyond capacity.
+// Callers must hold b.mu.
+func (b *BufferedStore) buffer(balances []TokenBalance) {
+	b.pending = append(b.pending, balances...)
+	if excess := len(b.pending) - b.capacity; excess > 0 {
+		slog.Warn("Balance buffer full, dropping oldest balances",
+			"dropped", excess,
+			"capacity", b.capacity)
+		b.pending = append([]TokenBalance(nil), b.pending[excess:]...)
+	}
+}
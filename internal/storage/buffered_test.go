@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errConnRefused is what a write returns while the database is down.
+var errConnRefused = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+// pingStore is a memStore whose Ping fails with pingErr.
+type pingStore struct {
+	memStore
+	pingErr error
+}
+
+func (p *pingStore) Ping(context.Context) error { return p.pingErr }
+
+func balanceAt(symbol string, at time.Time) TokenBalance {
+	b := sampleBatch()[0]
+	b.Symbol = symbol
+	b.QueriedAt = at
+	return b
+}
+
+func symbols(balances []TokenBalance) []string {
+	out := make([]string, len(balances))
+	for i, b := range balances {
+		out[i] = b.Symbol
+	}
+	return out
+}
+
+func TestBufferedStore_PassesThroughWhenHealthy(t *testing.T) {
+	db := &memStore{}
+	bs := NewBufferedStore(db, 10)
+
+	_, err := bs.BatchInsertBalances(context.Background(), sampleBatch())
+	require.NoError(t, err)
+
+	assert.Len(t, db.balances, 1)
+	assert.Equal(t, 0, bs.Pending())
+}
+
+func TestBufferedStore_PersistsAfterOutage(t *testing.T) {
+	ctx := context.Background()
+	db := &memStore{}
+	bs := NewBufferedStore(db, 10)
+	now := time.Now().UTC()
+
+	// Database down for two cycles
+	db.err = errConnRefused
+	_, err := bs.BatchInsertBalances(ctx, []TokenBalance{balanceAt("C1", now)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 balances buffered")
+	_, err = bs.BatchInsertBalances(ctx, []TokenBalance{balanceAt("C2", now.Add(time.Minute))})
+	require.Error(t, err)
+	assert.Equal(t, 2, bs.Pending())
+	assert.Empty(t, db.balances)
+
+	// Database back: buffered balances are written first, in order
+	db.err = nil
+	_, err = bs.BatchInsertBalances(ctx, []TokenBalance{balanceAt("C3", now.Add(2*time.Minute))})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"C1", "C2", "C3"}, symbols(db.balances))
+	assert.Equal(t, 0, bs.Pending())
+}
+
+func TestBufferedStore_FlushWithoutNewBalances(t *testing.T) {
+	ctx := context.Background()
+	db := &memStore{err: errConnRefused}
+	bs := NewBufferedStore(db, 10)
+
+	_, err := bs.BatchInsertBalances(ctx, sampleBatch())
+	require.Error(t, err)
+	db.err = nil
+	require.NoError(t, bs.Flush(ctx))
+
+	assert.Len(t, db.balances, 1)
+	assert.Equal(t, 0, bs.Pending())
+}
+
+func TestBufferedStore_DropsOldestBeyondCapacity(t *testing.T) {
+	ctx := context.Background()
+	db := &memStore{err: errConnRefused}
+	bs := NewBufferedStore(db, 2)
+	now := time.Now().UTC()
+
+	for i, s := range []string{"C1", "C2", "C3"} {
+		_, err := bs.BatchInsertBalances(ctx, []TokenBalance{balanceAt(s, now.Add(time.Duration(i)*time.Minute))})
+		require.Error(t, err)
+	}
+	assert.Equal(t, 2, bs.Pending())
+
+	db.err = nil
+	require.NoError(t, bs.Flush(ctx))
+	assert.Equal(t, []string{"C2", "C3"}, symbols(db.balances))
+}
+
+func TestBufferedStore_DropsOnRejectedWrite(t *testing.T) {
+	ctx := context.Background()
+	db := &memStore{err: errors.New(`invalid input syntax for type numeric`)}
+	bs := NewBufferedStore(db, 10)
+
+	_, err := bs.BatchInsertBalances(ctx, sampleBatch())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "buffered")
+	assert.Equal(t, 0, bs.Pending(), "a rejected write is not retried")
+}
+
+func TestBufferedStore_BuffersWhenPingFails(t *testing.T) {
+	ctx := context.Background()
+	db := &pingStore{memStore: memStore{err: errors.New("unexpected EOF")}, pingErr: errConnRefused}
+	bs := NewBufferedStore(db, 10)
+
+	_, err := bs.BatchInsertBalances(ctx, sampleBatch())
+	require.Error(t, err)
+	assert.Equal(t, 1, bs.Pending())
+
+	db.pingErr = nil
+	_, err = bs.BatchInsertBalances(ctx, sampleBatch())
+	require.Error(t, err)
+	assert.Equal(t, 0, bs.Pending(), "write rejected by a reachable database is dropped")
+}
+
+func TestBufferedStore_RejectedBufferDoesNotBlockNewBalances(t *testing.T) {
+	ctx := context.Background()
+	db := &pingStore{memStore: memStore{err: errConnRefused}, pingErr: errConnRefused}
+	bs := NewBufferedStore(db, 10)
+	now := time.Now().UTC()
+
+	_, err := bs.BatchInsertBalances(ctx, []TokenBalance{balanceAt("C1", now)})
+	require.Error(t, err)
+	require.Equal(t, 1, bs.Pending())
+
+	// The buffered batch is rejected once the database is back, the new one is not
+	db.err, db.pingErr = errors.New("rejected"), nil
+	require.Error(t, bs.Flush(ctx))
+	assert.Equal(t, 0, bs.Pending())
+
+	db.err = nil
+	_, err = bs.BatchInsertBalances(ctx, []TokenBalance{balanceAt("C2", now)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C2"}, symbols(db.balances))
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, isConnectionError(errConnRefused))
+	assert.True(t, isConnectionError(fmt.Errorf("batch insert: %w", errConnRefused)))
+	assert.True(t, isConnectionError(&pgconn.ConnectError{}))
+	assert.False(t, isConnectionError(&pgconn.PgError{Code: "22P02"}))
+	assert.False(t, isConnectionError(errors.New("rejected")))
+}
+
+func TestBufferedStore_SetLastRunStatusPassesThrough(t *testing.T) {
+	db := &memStore{}
+	bs := NewBufferedStore(db, 10)
+
+	require.NoError(t, bs.SetLastRunStatus(context.Background(), true))
+	require.NotNil(t, db.lastRunOK)
+	assert.True(t, *db.lastRunOK)
+}
@@ -4,8 +4,10 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,15 +25,15 @@ func newTestStore(t *testing.T) (context.Context, *Store) {
 
 	ctx := context.Background()
 
-	err := RunMigrations(ctx, dsn)
+	err := RunMigrations(ctx, dsn, MigrationOptions{})
 	require.NoError(t, err, "migrations should run without error")
 
-	store, err := NewStore(ctx, dsn)
+	store, err := NewStore(ctx, dsn, Options{})
 	require.NoError(t, err, "store should be created successfully")
 	t.Cleanup(func() { store.Close() })
 
 	t.Cleanup(func() {
-		_, err := store.pool.Exec(ctx, "TRUNCATE TABLE token_balances RESTART IDENTITY CASCADE")
+		_, err := store.pool.Exec(ctx, "TRUNCATE TABLE token_balances, wallet_snapshot_summary RESTART IDENTITY CASCADE")
 		if err != nil {
 			t.Logf("cleanup truncate failed: %v", err)
 		}
@@ -73,7 +75,7 @@ func TestIntegration_InsertAndGetBalances(t *testing.T) {
 		},
 	}
 
-	err := store.BatchInsertBalances(ctx, balances)
+	_, err := store.BatchInsertBalances(ctx, balances)
 	require.NoError(t, err, "BatchInsertBalances should succeed")
 
 	// No filter — ordered by queried_at DESC: armmXDAI first
@@ -96,6 +98,7 @@ func TestIntegration_InsertAndGetBalances(t *testing.T) {
 	require.Equal(t, wallet, got[0].Wallet)
 	require.Equal(t, tokenAddress1, got[0].TokenAddress)
 	require.Equal(t, uint8(18), got[0].Decimals)
+	require.Equal(t, "1500000000000000000", got[0].RawBalance.String())
 	require.True(t, got[0].Balance.Equal(decimal.NewFromFloat(1.5)))
 	require.True(t, t1.Equal(got[0].QueriedAt), "QueriedAt should match: expected %v, got %v", t1, got[0].QueriedAt)
 
@@ -106,6 +109,7 @@ func TestIntegration_InsertAndGetBalances(t *testing.T) {
 	require.Equal(t, "armmUSDC", got[0].Symbol)
 	require.Equal(t, tokenAddress2, got[0].TokenAddress)
 	require.Equal(t, uint8(6), got[0].Decimals)
+	require.Equal(t, "2000000", got[0].RawBalance.String())
 	require.True(t, got[0].Balance.Equal(decimal.NewFromFloat(2.0)))
 
 	// Unknown wallet — empty result
@@ -114,9 +118,1189 @@ func TestIntegration_InsertAndGetBalances(t *testing.T) {
 	require.Empty(t, got)
 }
 
+func TestIntegration_BalanceSource(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	base := TokenBalance{
+		Wallet:       "0x1234567890123456789012345678901234567890",
+		TokenAddress: "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.RequireFromString("0.000000000000000001"),
+	}
+	want := map[string]string{
+		"DEFAULT":  SourcePoll,
+		"POLL":     SourcePoll,
+		"BACKFILL": SourceBackfill,
+		"IMPORT":   SourceImport,
+		"MANUAL":   SourceManual,
+	}
+	var balances []TokenBalance
+	i := 0
+	for symbol, source := range want {
+		b := base
+		b.Symbol = symbol
+		b.QueriedAt = now.Add(time.Duration(i) * time.Second)
+		if symbol != "DEFAULT" {
+			b.Source = source
+		}
+		balances = append(balances, b)
+		i++
+	}
+	_, err := store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, "", "", 100)
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for _, b := range got {
+		require.Equal(t, want[b.Symbol], b.Source, "symbol %s", b.Symbol)
+	}
+
+	bad := base
+	bad.Symbol = "BAD"
+	bad.QueriedAt = now
+	bad.Source = "scraper"
+	_, err = store.BatchInsertBalances(ctx, []TokenBalance{bad})
+	require.Error(t, err)
+}
+
+func TestIntegration_BatchInsertBeyondParamLimit(t *testing.T) {
+	ctx, store := newTestStore(t)
+	oversized := &Store{pool: store.pool, insertBatchSize: effectiveInsertBatchSize(1_000_000)}
+
+	// More rows than one statement can bind parameters for
+	now := time.Now().UTC().Truncate(time.Second)
+	balances := make([]TokenBalance, 7000)
+	for i := range balances {
+		balances[i] = TokenBalance{
+			QueriedAt:    now.Add(time.Duration(i) * time.Millisecond),
+			Wallet:       "0x1234567890123456789012345678901234567890",
+			TokenAddress: "0x0000000000000000000000000000000000000001",
+			Symbol:       "BULK",
+			Decimals:     18,
+			RawBalance:   big.NewInt(int64(i)),
+			Balance:      decimal.NewFromInt(int64(i)),
+		}
+	}
+	_, err := oversized.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, store.pool.QueryRow(ctx, "SELECT count(*) FROM token_balances").Scan(&count))
+	require.Equal(t, len(balances), count)
+}
+
+func TestIntegration_BlockTimestamp(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	blockTime := now.Add(-7 * time.Second)
+	stamped := TokenBalance{
+		QueriedAt:      now,
+		Wallet:         "0x1234567890123456789012345678901234567890",
+		TokenAddress:   "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+		Symbol:         "STAMPED",
+		Decimals:       18,
+		RawBalance:     big.NewInt(1),
+		Balance:        decimal.RequireFromString("0.000000000000000001"),
+		BlockTimestamp: &blockTime,
+	}
+	unstamped := stamped
+	unstamped.Symbol = "UNSTAMPED"
+	unstamped.QueriedAt = now.Add(-time.Minute)
+	unstamped.BlockTimestamp = nil
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{stamped, unstamped})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, "", "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "STAMPED", got[0].Symbol)
+	require.NotNil(t, got[0].BlockTimestamp)
+	require.True(t, blockTime.Equal(*got[0].BlockTimestamp))
+	require.Equal(t, 7*time.Second, got[0].QueriedAt.Sub(*got[0].BlockTimestamp))
+	require.Nil(t, got[1].BlockTimestamp)
+}
+
+func TestIntegration_SnapshotSummary(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	snapshot := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	balance := func(symbol, amount string, at time.Time) TokenBalance {
+		return TokenBalance{
+			QueriedAt:    at,
+			Wallet:       wallet,
+			TokenAddress: "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+			Symbol:       symbol,
+			Decimals:     18,
+			RawBalance:   big.NewInt(1),
+			Balance:      decimal.RequireFromString(amount),
+		}
+	}
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{
+		balance("armmXDAI", "100.5", snapshot.Add(2*time.Second)),
+		balance("armmUSDC", "50.25", snapshot.Add(3*time.Second)),
+		balance("debtrmmWXDAI", "30", snapshot.Add(4*time.Second)),
+		balance("armmXDAI", "101", snapshot.Add(5*time.Minute)),
+	})
+	require.NoError(t, err)
+
+	n, err := store.RefreshSnapshotSummary(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	summaries, err := store.GetSnapshotSummaries(ctx, "0x"+strings.ToUpper(wallet[2:]), snapshot, snapshot.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	require.True(t, snapshot.Equal(summaries[0].SnapshotAt))
+	require.Equal(t, "150.75", summaries[0].TotalSupply.String())
+	require.Equal(t, "30", summaries[0].TotalDebt.String())
+	require.Equal(t, 3, summaries[0].TokenCount)
+	require.Equal(t, "101", summaries[1].TotalSupply.String())
+	require.True(t, summaries[1].TotalDebt.IsZero())
+
+	// A late row for an already summarized snapshot is folded into it;
+	// untouched snapshots are not rewritten.
+	_, err = store.BatchInsertBalances(ctx, []TokenBalance{
+		balance("armmXDAIDEBT", "10", snapshot.Add(30*time.Second)),
+	})
+	require.NoError(t, err)
+	n, err = store.RefreshSnapshotSummary(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	summaries, err = store.GetSnapshotSummaries(ctx, wallet, snapshot, snapshot.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Equal(t, "40", summaries[0].TotalDebt.String())
+	require.Equal(t, 4, summaries[0].TokenCount)
+
+	// The summary matches the per-token rows it aggregates
+	var supply, debt decimal.Decimal
+	err = store.pool.QueryRow(ctx, `
+		SELECT
+			SUM(balance) FILTER (WHERE symbol NOT ILIKE '%debt%'),
+			SUM(balance) FILTER (WHERE symbol ILIKE '%debt%')
+		FROM token_balances
+		WHERE wallet = $1 AND queried_at >= $2 AND queried_at < $3`,
+		wallet, snapshot, snapshot.Add(time.Minute)).Scan(&supply, &debt)
+	require.NoError(t, err)
+	require.True(t, supply.Equal(summaries[0].TotalSupply))
+	require.True(t, debt.Equal(summaries[0].TotalDebt))
+
+	n, err = store.RefreshSnapshotSummary(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n, "nothing new to summarize")
+}
+
 func TestIntegration_BatchInsertEmpty(t *testing.T) {
 	ctx, store := newTestStore(t)
 
-	err := store.BatchInsertBalances(ctx, []TokenBalance{})
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{})
 	require.NoError(t, err, "BatchInsertBalances with empty slice should be a no-op")
 }
+
+func TestIntegration_BatchInsertSkipsNilRawBalance(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	balance := func(token, symbol string, raw *big.Int) TokenBalance {
+		return TokenBalance{
+			QueriedAt:    now,
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       symbol,
+			Decimals:     18,
+			RawBalance:   raw,
+			Balance:      decimal.NewFromInt(1),
+		}
+	}
+
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{
+		balance("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1", "armmXDAI", big.NewInt(1)),
+		balance("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa2", "broken", nil),
+		balance("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa3", "armmUSDC", big.NewInt(2)),
+	})
+	require.NoError(t, err, "a row without raw balance must not fail the batch")
+
+	got, err := store.GetBalances(ctx, wallet, "", 100)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for _, b := range got {
+		require.NotEqual(t, "broken", b.Symbol)
+	}
+}
+
+func TestIntegration_CopyInsertBalances(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	raw, ok := new(big.Int).SetString("1234567890123456789012", 10)
+	require.True(t, ok)
+	balances := []TokenBalance{
+		{
+			QueriedAt:    time.Now().UTC().Truncate(time.Microsecond),
+			Wallet:       "0xABCDEF0123456789ABCDEF0123456789ABCDEF01",
+			TokenAddress: "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   raw,
+			Balance:      decimal.RequireFromString("1234.567890123456789012"),
+			Source:       SourceImport,
+		},
+		{
+			QueriedAt:    time.Now().UTC().Truncate(time.Microsecond).Add(-time.Minute),
+			Wallet:       "0xABCDEF0123456789ABCDEF0123456789ABCDEF01",
+			TokenAddress: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			Symbol:       "armmUSDC",
+			Decimals:     6,
+			RawBalance:   big.NewInt(2_500_000),
+			Balance:      decimal.RequireFromString("2.5"),
+		},
+	}
+
+	n, err := store.CopyInsertBalances(ctx, balances)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	got, err := store.GetBalances(ctx, "0xabcdef0123456789abcdef0123456789abcdef01", "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "armmXDAI", got[0].Symbol)
+	require.Equal(t, uint8(18), got[0].Decimals)
+	require.Equal(t, raw.String(), got[0].RawBalance.String())
+	// NUMERIC(78, 18) keeps 18 fractional digits
+	require.True(t, got[0].Balance.Equal(decimal.RequireFromString("1234.567890123456789012").Truncate(18)), "balance %s", got[0].Balance)
+	require.Equal(t, SourceImport, got[0].Source)
+	require.Equal(t, SourcePoll, got[1].Source)
+	require.True(t, got[1].Balance.Equal(decimal.RequireFromString("2.5")))
+}
+
+// benchmarkBalances returns n distinct balances for insert benchmarks.
+func benchmarkBalances(n int) []TokenBalance {
+	now := time.Now().UTC()
+	balances := make([]TokenBalance, n)
+	for i := range balances {
+		balances[i] = TokenBalance{
+			QueriedAt:    now.Add(-time.Duration(i) * time.Minute),
+			Wallet:       "0x1234567890123456789012345678901234567890",
+			TokenAddress: "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(int64(i) * 1_000_000_000),
+			Balance:      decimal.New(int64(i), -9),
+			Source:       SourceImport,
+		}
+	}
+	return balances
+}
+
+// BenchmarkIntegration_InsertBalances compares BatchInsertBalances with
+// CopyInsertBalances for a bulk load.
+func BenchmarkIntegration_InsertBalances(b *testing.B) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		b.Skip("DATABASE_URL not set, skipping integration benchmark")
+	}
+	ctx := context.Background()
+	require.NoError(b, RunMigrations(ctx, dsn, MigrationOptions{}))
+	store, err := NewStore(ctx, dsn, Options{})
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		_, _ = store.pool.Exec(ctx, "TRUNCATE TABLE token_balances, wallet_snapshot_summary RESTART IDENTITY CASCADE")
+		store.Close()
+	})
+
+	balances := benchmarkBalances(5000)
+
+	b.Run("batch", func(b *testing.B) {
+		for b.Loop() {
+			_, err := store.BatchInsertBalances(ctx, balances)
+			require.NoError(b, err)
+		}
+		b.ReportMetric(float64(len(balances)*b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+
+	b.Run("copy", func(b *testing.B) {
+		for b.Loop() {
+			_, err := store.CopyInsertBalances(ctx, balances)
+			require.NoError(b, err)
+		}
+		b.ReportMetric(float64(len(balances)*b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+}
+
+func TestIntegration_SchemaMatchesMigrations(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	actual, err := store.IntrospectTable(ctx, "token_balances")
+	require.NoError(t, err)
+	expected, err := ExpectedTokenBalancesSchema()
+	require.NoError(t, err)
+	require.Empty(t, DiffSchema(expected, actual))
+}
+
+func TestIntegration_SchemaDiffReportsMissingColumn(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	_, err := store.pool.Exec(ctx, `CREATE TABLE schema_drift_test (LIKE token_balances INCLUDING ALL)`)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = store.pool.Exec(ctx, `DROP TABLE IF EXISTS schema_drift_test`) })
+	_, err = store.pool.Exec(ctx, `ALTER TABLE schema_drift_test DROP COLUMN raw_balance`)
+	require.NoError(t, err)
+
+	actual, err := store.IntrospectTable(ctx, "schema_drift_test")
+	require.NoError(t, err)
+
+	expected, err := ExpectedTokenBalancesSchema()
+	require.NoError(t, err)
+	expected.Indexes = nil // copied indexes get generated names
+	actual.Indexes = nil
+
+	diffs := DiffSchema(expected, actual)
+	require.Len(t, diffs, 1)
+	require.Equal(t, "missing_column", diffs[0].Kind)
+	require.Equal(t, "raw_balance", diffs[0].Name)
+}
+
+func TestIntegration_ConcurrentMigrations(t *testing.T) {
+	ctx, _ := newTestStore(t)
+	dsn := os.Getenv("DATABASE_URL")
+
+	// Several instances starting at once must all come up cleanly
+	const instances = 4
+	errs := make(chan error, instances)
+	for range instances {
+		go func() { errs <- RunMigrations(ctx, dsn, MigrationOptions{}) }()
+	}
+	for range instances {
+		require.NoError(t, <-errs)
+	}
+}
+
+func TestIntegration_MigrateDownAndStatus(t *testing.T) {
+	ctx, _ := newTestStore(t)
+	dsn := os.Getenv("DATABASE_URL")
+	t.Cleanup(func() { _ = RunMigrations(ctx, dsn, MigrationOptions{}) })
+
+	require.NoError(t, MigrateDown(ctx, dsn))
+
+	var out strings.Builder
+	require.NoError(t, MigrateStatus(ctx, dsn, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Contains(t, lines[len(lines)-1], "Pending")
+	require.NotContains(t, lines[2], "Pending")
+}
+
+func TestIntegration_PlanMigrateUp(t *testing.T) {
+	ctx, _ := newTestStore(t)
+	dsn := os.Getenv("DATABASE_URL")
+	t.Cleanup(func() { _ = RunMigrations(ctx, dsn, MigrationOptions{}) })
+
+	plan, err := PlanMigrateUp(ctx, dsn)
+	require.NoError(t, err)
+	latest := plan.CurrentVersion
+	require.Empty(t, plan.Pending, "nothing pending after RunMigrations")
+
+	require.NoError(t, MigrateDown(ctx, dsn))
+	require.NoError(t, MigrateDown(ctx, dsn))
+
+	plan, err = PlanMigrateUp(ctx, dsn)
+	require.NoError(t, err)
+	require.Len(t, plan.Pending, 2)
+	require.Equal(t, plan.CurrentVersion+1, plan.Pending[0].Version)
+	require.Equal(t, latest, plan.Pending[1].Version)
+
+	after, err := PlanMigrateUp(ctx, dsn)
+	require.NoError(t, err)
+	require.Equal(t, plan, after, "planning applies nothing")
+}
+
+func TestIntegration_GetYieldRate(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	perDay := decimal.RequireFromString("0.10").Div(decimal.NewFromInt(365))
+
+	var balances []TokenBalance
+	for i := range 10 {
+		day := decimal.NewFromInt(int64(i))
+		balances = append(balances, TokenBalance{
+			QueriedAt:    now.Add(time.Duration(i-9) * 24 * time.Hour),
+			Wallet:       wallet,
+			TokenAddress: "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(1),
+			Balance:      decimal.NewFromInt(1000).Mul(decimal.NewFromInt(1).Add(perDay.Mul(day))),
+		})
+	}
+	_, err := store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	got, err := store.GetYieldRate(ctx, wallet, "armmXDAI", 30*24*time.Hour)
+	require.NoError(t, err)
+	require.False(t, got.TransferSuspected)
+	require.True(t, got.Rate.Sub(decimal.RequireFromString("0.10")).Abs().LessThan(decimal.RequireFromString("0.0001")), "rate %s", got.Rate)
+
+	_, err = store.GetYieldRate(ctx, wallet, "armmUSDC", 30*24*time.Hour)
+	require.ErrorIs(t, err, ErrInsufficientData)
+}
+
+func TestIntegration_SeriesByLabel(t *testing.T) {
+	ctx, store := newTestStore(t)
+	byLabel := &Store{pool: store.pool, seriesKey: SeriesByLabel}
+
+	// The token moved to a new address (with a new symbol) under one label
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	balance := func(at time.Time, address, symbol string, amount int64) TokenBalance {
+		return TokenBalance{
+			QueriedAt:    at,
+			Wallet:       wallet,
+			TokenAddress: address,
+			Symbol:       symbol,
+			Decimals:     18,
+			RawBalance:   big.NewInt(amount),
+			Balance:      decimal.NewFromInt(amount),
+			Label:        "xdai-deposit",
+		}
+	}
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{
+		balance(now.Add(-48*time.Hour), "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1", "armmXDAI", 100),
+		balance(now.Add(-24*time.Hour), "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1", "armmXDAI", 101),
+		balance(now, "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb2", "armmXDAIv2", 102),
+	})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 100)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, "xdai-deposit", got[0].Label)
+
+	latest, err := store.GetLatestBalances(ctx, wallet)
+	require.NoError(t, err)
+	require.Len(t, latest, 2, "by symbol, each address is its own series")
+
+	latest, err = byLabel.GetLatestBalances(ctx, wallet)
+	require.NoError(t, err)
+	require.Len(t, latest, 1, "by label, the series continues across the address change")
+	require.Equal(t, "xdai-deposit", latest[0].Symbol)
+	require.Equal(t, "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb2", latest[0].TokenAddress)
+	require.True(t, latest[0].Balance.Equal(decimal.NewFromInt(102)))
+
+	report, err := byLabel.GetDailyReport(ctx, wallet, 3)
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+
+	daily, err := byLabel.GetDailyBalances(ctx, wallet)
+	require.NoError(t, err)
+	for _, d := range daily {
+		require.Equal(t, "xdai-deposit", d.Symbol)
+	}
+
+	_, err = byLabel.GetYieldRate(ctx, wallet, "xdai-deposit", 7*24*time.Hour)
+	require.NoError(t, err)
+}
+
+func TestIntegration_GetBalancesByTag(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	balance := func(wallet string, tags map[string]string) TokenBalance {
+		return TokenBalance{
+			QueriedAt:    now,
+			Wallet:       wallet,
+			TokenAddress: "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(1),
+			Balance:      decimal.NewFromInt(1),
+			Tags:         tags,
+		}
+	}
+	alice := "0x1111111111111111111111111111111111111111"
+	bob := "0x2222222222222222222222222222222222222222"
+	plain := "0x3333333333333333333333333333333333333333"
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{
+		balance(alice, map[string]string{"owner": "alice", "strategy": "hedge"}),
+		balance(bob, map[string]string{"owner": "bob", "strategy": "hedge"}),
+		balance(plain, nil),
+	})
+	require.NoError(t, err)
+
+	got, err := store.GetBalancesByTag(ctx, "owner", "alice")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, alice, got[0].Wallet)
+	require.Equal(t, map[string]string{"owner": "alice", "strategy": "hedge"}, got[0].Tags)
+
+	got, err = store.GetBalancesByTag(ctx, "strategy", "hedge")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+
+	got, err = store.GetBalancesByTag(ctx, "owner", "carol")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	all, err := store.GetBalances(ctx, plain, "", 10)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	require.Nil(t, all[0].Tags, "untagged wallets store NULL")
+}
+
+func TestIntegration_GetTokens(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	balance := func(at time.Time, address, symbol string) TokenBalance {
+		decimals := uint8(18)
+		if symbol == "ONE" {
+			decimals = 6
+		}
+		return TokenBalance{
+			QueriedAt:    at,
+			Wallet:       "0x1234567890123456789012345678901234567890",
+			TokenAddress: address,
+			Symbol:       symbol,
+			Decimals:     decimals,
+			RawBalance:   big.NewInt(1),
+			Balance:      decimal.NewFromInt(1),
+		}
+	}
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{
+		balance(now.Add(-time.Hour), "0x0000000000000000000000000000000000000002", "OLDNAME"),
+		balance(now, "0x0000000000000000000000000000000000000002", "NEWNAME"),
+		balance(now, "0x0000000000000000000000000000000000000001", "ONE"),
+	})
+	require.NoError(t, err)
+
+	tokens, err := store.GetTokens(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []StoredToken{
+		{Address: "0x0000000000000000000000000000000000000001", Symbol: "ONE", Decimals: 6},
+		{Address: "0x0000000000000000000000000000000000000002", Symbol: "NEWNAME", Decimals: 18},
+	}, tokens)
+}
+
+func TestIntegration_USDValue(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	usd := decimal.RequireFromString("2.25")
+	priced := TokenBalance{
+		QueriedAt:    now,
+		Wallet:       wallet,
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "PRICED",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+		USDValue:     &usd,
+	}
+	unpriced := priced
+	unpriced.TokenAddress = "0x0000000000000000000000000000000000000002"
+	unpriced.Symbol = "UNPRICED"
+	unpriced.USDValue = nil
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{priced, unpriced})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for _, b := range got {
+		if b.Symbol == "PRICED" {
+			require.NotNil(t, b.USDValue)
+			require.True(t, usd.Equal(*b.USDValue))
+		} else {
+			require.Nil(t, b.USDValue, "usd_value stays NULL without a price")
+		}
+	}
+}
+
+func TestIntegration_GetLatestBalance(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	token := "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b"
+	now := time.Now().UTC().Truncate(time.Second)
+	raw, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+	balance := func(at time.Time, address string, raw *big.Int) TokenBalance {
+		return TokenBalance{
+			QueriedAt:    at,
+			Wallet:       wallet,
+			TokenAddress: address,
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   raw,
+			Balance:      decimal.NewFromBigInt(raw, -18),
+		}
+	}
+
+	_, err := store.GetLatestBalance(ctx, wallet, token)
+	require.ErrorIs(t, err, ErrNoBalance)
+
+	_, err = store.BatchInsertBalances(ctx, []TokenBalance{
+		balance(now.Add(-time.Hour), token, big.NewInt(1)),
+		balance(now, token, raw),
+		balance(now.Add(time.Minute), "0x0000000000000000000000000000000000000002", big.NewInt(2)),
+	})
+	require.NoError(t, err)
+
+	got, err := store.GetLatestBalance(ctx, "0x"+strings.ToUpper(wallet[2:]), token)
+	require.NoError(t, err)
+	require.True(t, now.Equal(got.QueriedAt))
+	require.Equal(t, raw.String(), got.RawBalance.String())
+	require.True(t, decimal.RequireFromString("123456789012.34567890123456789").Equal(got.Balance))
+
+	_, err = store.GetLatestBalance(ctx, "0x9999999999999999999999999999999999999999", token)
+	require.ErrorIs(t, err, ErrNoBalance)
+}
+
+func TestIntegration_GetBalanceHistory(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	token := "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"
+	now := time.Now().UTC().Truncate(time.Second)
+	var balances []TokenBalance
+	for day := range 40 {
+		balances = append(balances, TokenBalance{
+			QueriedAt:    now.AddDate(0, 0, -day),
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       "armmUSDC",
+			Decimals:     6,
+			RawBalance:   big.NewInt(int64(1_000_000 + day)),
+			Balance:      decimal.New(int64(1_000_000+day), -6),
+		})
+	}
+	other := balances[0]
+	other.TokenAddress = "0x0000000000000000000000000000000000000002"
+	_, err := store.BatchInsertBalances(ctx, append(balances, other))
+	require.NoError(t, err)
+
+	// Last 30 days up to now, oldest first
+	got, err := store.GetBalanceHistory(ctx, wallet, token, now.AddDate(0, 0, -30), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, got, 31)
+	require.True(t, now.AddDate(0, 0, -30).Equal(got[0].QueriedAt))
+	require.True(t, now.Equal(got[30].QueriedAt))
+	require.Equal(t, "1000030", got[0].RawBalance.String())
+	require.True(t, decimal.RequireFromString("1.00003").Equal(got[0].Balance))
+
+	got, err = store.GetBalanceHistory(ctx, wallet, token, now.AddDate(0, 0, -3), now.AddDate(0, 0, -2))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+
+	_, err = store.GetBalanceHistory(ctx, wallet, token, now, now.AddDate(0, 0, -1))
+	require.ErrorContains(t, err, "invalid range")
+}
+
+func TestIntegration_CarriedForward(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	observed := TokenBalance{
+		QueriedAt:    now.Add(-5 * time.Minute),
+		Wallet:       wallet,
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "armmXDAI",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+	}
+	carried := observed
+	carried.QueriedAt = now
+	carried.CarriedForward = true
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{observed, carried})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.True(t, got[0].CarriedForward, "newest row is the carried one")
+	require.False(t, got[1].CarriedForward)
+}
+
+func TestIntegration_BlockNumber(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	known := TokenBalance{
+		QueriedAt:    now,
+		Wallet:       wallet,
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "armmXDAI",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+		BlockNumber:  41234567,
+	}
+	unknown := known
+	unknown.QueriedAt = now.Add(-5 * time.Minute)
+	unknown.BlockNumber = 0
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{known})
+	require.NoError(t, err)
+	_, err = store.CopyInsertBalances(ctx, []TokenBalance{unknown})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, uint64(41234567), got[0].BlockNumber)
+	require.Zero(t, got[1].BlockNumber, "unknown block number is stored as NULL")
+
+	var nulls int
+	require.NoError(t, store.pool.QueryRow(ctx, `SELECT count(*) FROM token_balances WHERE block_number IS NULL`).Scan(&nulls))
+	require.Equal(t, 1, nulls)
+}
+
+func TestIntegration_GetStoredBlocks(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	var balances []TokenBalance
+	for i, block := range []uint64{100, 200, 200, 300, 0} {
+		balances = append(balances, TokenBalance{
+			QueriedAt:    now.Add(time.Duration(i) * time.Minute),
+			Wallet:       "0x1234567890123456789012345678901234567890",
+			TokenAddress: "0x0000000000000000000000000000000000000001",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(1),
+			Balance:      decimal.NewFromInt(1),
+			Source:       SourceBackfill,
+			BlockNumber:  block,
+		})
+	}
+	_, err := store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	blocks, err := store.GetStoredBlocks(ctx, 150, 300)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]bool{200: true, 300: true}, blocks)
+}
+
+func TestIntegration_FetchLatency(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	latency := int64(250)
+	timed := TokenBalance{
+		QueriedAt:      now,
+		Wallet:         wallet,
+		TokenAddress:   "0x0000000000000000000000000000000000000001",
+		Symbol:         "armmXDAI",
+		Decimals:       18,
+		RawBalance:     big.NewInt(1),
+		Balance:        decimal.NewFromInt(1),
+		FetchLatencyMS: &latency,
+	}
+	untimed := timed
+	untimed.QueriedAt = now.Add(-5 * time.Minute)
+	untimed.FetchLatencyMS = nil
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{timed, untimed})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.NotNil(t, got[0].FetchLatencyMS)
+	require.Equal(t, latency, *got[0].FetchLatencyMS)
+	require.Nil(t, got[1].FetchLatencyMS)
+}
+
+func TestIntegration_PruneOlderThan(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	var balances []TokenBalance
+	for day := range 10 {
+		balances = append(balances, TokenBalance{
+			QueriedAt:    now.AddDate(0, 0, -day),
+			Wallet:       wallet,
+			TokenAddress: "0x0000000000000000000000000000000000000001",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(int64(day)),
+			Balance:      decimal.NewFromInt(int64(day)),
+		})
+	}
+	_, err := store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	// Rows 4 to 9 days old go, three per statement
+	deleted, err := store.pruneOlderThan(ctx, now.AddDate(0, 0, -3).Add(-time.Minute), 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), deleted)
+
+	got, err := store.GetBalances(ctx, wallet, "", 100)
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	require.True(t, got[3].QueriedAt.Equal(now.AddDate(0, 0, -3)), "the oldest kept row is 3 days old")
+
+	deleted, err = store.PruneOlderThan(ctx, now.AddDate(0, 0, -3).Add(-time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+}
+
+func TestIntegration_GetAllTimeHigh(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	token := "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"
+	start := time.Now().UTC().Truncate(time.Second).Add(-24 * time.Hour)
+
+	_, err := store.GetAllTimeHigh(ctx, wallet, token)
+	require.ErrorIs(t, err, ErrNoBalance)
+
+	// 10.5 is the peak, held twice; as text 9 would sort above it
+	var balances []TokenBalance
+	for i, amount := range []string{"2", "9", "10.5", "3.25", "10.5", "0"} {
+		b := decimal.RequireFromString(amount)
+		balances = append(balances, TokenBalance{
+			QueriedAt:    start.Add(time.Duration(i) * time.Hour),
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       "armmUSDC",
+			Decimals:     6,
+			RawBalance:   b.Shift(6).BigInt(),
+			Balance:      b,
+		})
+	}
+	_, err = store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	got, err := store.GetAllTimeHigh(ctx, "0x"+strings.ToUpper(wallet[2:]), token)
+	require.NoError(t, err)
+	require.True(t, decimal.RequireFromString("10.5").Equal(got.Balance))
+	require.True(t, start.Add(2*time.Hour).Equal(got.QueriedAt), "the earliest time the peak was reached")
+	require.Equal(t, "10500000", got.RawBalance.String())
+}
+
+func TestIntegration_DedupUnchanged(t *testing.T) {
+	ctx, store := newTestStore(t)
+	store.dedupUnchanged = true
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	start := time.Now().UTC().Truncate(time.Second)
+	balance := func(minute int, token string, raw int64) TokenBalance {
+		return TokenBalance{
+			QueriedAt:    start.Add(time.Duration(minute) * time.Minute),
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(raw),
+			Balance:      decimal.NewFromInt(raw),
+		}
+	}
+	tokenA := "0x0000000000000000000000000000000000000001"
+	tokenB := "0x0000000000000000000000000000000000000002"
+
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{balance(0, tokenA, 5), balance(0, tokenB, 5)})
+	require.NoError(t, err)
+	// Unchanged, changed, then unchanged again within the same batch
+	inserted, err := store.InsertBalancesDedup(ctx, []TokenBalance{
+		balance(1, tokenA, 5),
+		balance(1, tokenB, 6),
+		balance(2, tokenB, 6),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), inserted)
+	written, err := store.BatchInsertBalances(ctx, []TokenBalance{balance(3, tokenA, 5), balance(3, tokenB, 5)})
+	require.NoError(t, err)
+	require.Len(t, written, 1, "only the changed balance is reported written")
+	require.Equal(t, tokenB, written[0].TokenAddress)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	latest, err := store.GetLatestBalance(ctx, wallet, tokenB)
+	require.NoError(t, err)
+	require.Equal(t, "5", latest.RawBalance.String(), "a balance returning to an older value is kept")
+	latest, err = store.GetLatestBalance(ctx, wallet, tokenA)
+	require.NoError(t, err)
+	require.True(t, start.Equal(latest.QueriedAt), "the first row stands for the unchanged ones")
+}
+
+func TestIntegration_WalletLabel(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	labeled := TokenBalance{
+		QueriedAt:    now,
+		Wallet:       wallet,
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "armmXDAI",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+		WalletLabel:  "Savings",
+	}
+	unlabeled := labeled
+	unlabeled.QueriedAt = now.Add(-5 * time.Minute)
+	unlabeled.WalletLabel = ""
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{labeled, unlabeled})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "Savings", got[0].WalletLabel)
+	require.Empty(t, got[1].WalletLabel)
+}
+
+func TestIntegration_TokenName(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	named := TokenBalance{
+		QueriedAt:    now,
+		Wallet:       wallet,
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "armmXDAI",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+		TokenName:    "RealT RMM V3 WXDAI",
+	}
+	unnamed := named
+	unnamed.QueriedAt = now.Add(-5 * time.Minute)
+	unnamed.TokenName = ""
+	_, err := store.BatchInsertBalances(ctx, []TokenBalance{named, unnamed})
+	require.NoError(t, err)
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "RealT RMM V3 WXDAI", got[0].TokenName)
+	require.Empty(t, got[1].TokenName)
+}
+
+func TestIntegration_LatestQueriedAt(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	at, err := store.LatestQueriedAt(ctx)
+	require.NoError(t, err)
+	require.True(t, at.IsZero(), "empty table")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	balance := TokenBalance{
+		QueriedAt:    now.Add(-time.Hour),
+		Wallet:       "0x1234567890123456789012345678901234567890",
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "armmXDAI",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+	}
+	newest := balance
+	newest.QueriedAt = now
+	_, err = store.BatchInsertBalances(ctx, []TokenBalance{balance, newest})
+	require.NoError(t, err)
+
+	at, err = store.LatestQueriedAt(ctx)
+	require.NoError(t, err)
+	require.True(t, now.Equal(at))
+}
+
+func TestIntegration_GetDailyCloseBalances(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	token := "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var balances []TokenBalance
+	// Three days of 6-hourly readings
+	for i := range 12 {
+		balances = append(balances, TokenBalance{
+			QueriedAt:    start.Add(time.Duration(i) * 6 * time.Hour),
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       "armmUSDC",
+			Decimals:     6,
+			RawBalance:   big.NewInt(int64(1_000_000 + i)),
+			Balance:      decimal.New(int64(1_000_000+i), -6),
+		})
+	}
+	_, err := store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	got, err := store.GetDailyCloseBalances(ctx, wallet, token, start, start.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.Len(t, got, 3, "one row per day")
+	for day, b := range got {
+		require.True(t, start.AddDate(0, 0, day).Add(18*time.Hour).Equal(b.QueriedAt), "the last reading of the day")
+	}
+	require.Equal(t, "1000003", got[0].RawBalance.String())
+	require.True(t, decimal.RequireFromString("1.000011").Equal(got[2].Balance))
+
+	got, err = store.GetDailyCloseBalances(ctx, wallet, token, start, start.Add(7*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.True(t, start.Add(6*time.Hour).Equal(got[0].QueriedAt), "the range bounds the readings")
+
+	_, err = store.GetDailyCloseBalances(ctx, wallet, token, start, start)
+	require.ErrorContains(t, err, "invalid range")
+}
+
+func TestIntegration_GetWeeklyDeltas(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	token := "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1"
+	// Monday 2026-03-02, then daily readings over three weeks
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	var balances []TokenBalance
+	for day := range 21 {
+		raw := int64(1_000_000_000_000_000_000) + int64(day)
+		balances = append(balances, TokenBalance{
+			QueriedAt:    start.AddDate(0, 0, day),
+			Wallet:       wallet,
+			TokenAddress: token,
+			Symbol:       "armmWXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(raw),
+			Balance:      decimal.New(raw, -18),
+		})
+	}
+	_, err := store.BatchInsertBalances(ctx, balances)
+	require.NoError(t, err)
+
+	got, err := store.GetWeeklyDeltas(ctx, wallet, token)
+	require.NoError(t, err)
+	require.Len(t, got, 2, "the first week has no opening balance")
+	require.True(t, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC).Equal(got[0].WeekStart))
+	require.Equal(t, "1.000000000000000006", got[0].OpeningBalance.String(), "close of the first week, on Sunday")
+	require.Equal(t, "1.000000000000000013", got[0].ClosingBalance.String())
+	require.Equal(t, "0.000000000000000007", got[0].Change.String())
+	require.True(t, got[1].OpeningBalance.Equal(got[0].ClosingBalance))
+}
+
+func TestIntegration_ExportBalances(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var balances []TokenBalance
+	for day := range 40 {
+		balances = append(balances, TokenBalance{
+			QueriedAt:    start.AddDate(0, 0, day),
+			Wallet:       wallet,
+			TokenAddress: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1",
+			Symbol:       "armmUSDC",
+			Decimals:     6,
+			RawBalance:   big.NewInt(int64(day)),
+			Balance:      decimal.New(int64(day), -6),
+		})
+	}
+	other := balances[0]
+	other.Wallet = "0x0000000000000000000000000000000000000001"
+	_, err := store.BatchInsertBalances(ctx, append(balances, other))
+	require.NoError(t, err)
+
+	var got []TokenBalance
+	collect := func(b TokenBalance) error {
+		got = append(got, b)
+		return nil
+	}
+	require.NoError(t, store.ExportBalances(ctx, ExportFilter{
+		Wallet: "0x1234567890123456789012345678901234567890",
+		From:   start,
+		To:     start.AddDate(0, 1, 0),
+	}, collect))
+	require.Len(t, got, 31, "January only, to is exclusive")
+	require.True(t, start.Equal(got[0].QueriedAt), "oldest first")
+	require.Equal(t, "30", got[30].RawBalance.String())
+
+	got = nil
+	require.NoError(t, store.ExportBalances(ctx, ExportFilter{}, collect))
+	require.Len(t, got, 41)
+
+	errStop := errors.New("stop")
+	require.ErrorIs(t, store.ExportBalances(ctx, ExportFilter{}, func(TokenBalance) error { return errStop }), errStop)
+}
+
+func TestIntegration_InsertBalance(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	require.NoError(t, store.InsertBalance(ctx, TokenBalance{
+		QueriedAt:    time.Now().UTC().Truncate(time.Second),
+		Wallet:       wallet,
+		TokenAddress: "0x0000000000000000000000000000000000000001",
+		Symbol:       "armmXDAI",
+		Decimals:     18,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+	}))
+
+	got, err := store.GetBalances(ctx, wallet, "", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "1", got[0].RawBalance.String())
+}
+
+func TestIntegration_InsertBalancesIdempotent(t *testing.T) {
+	ctx, store := newTestStore(t)
+
+	wallet := "0x1234567890123456789012345678901234567890"
+	now := time.Now().UTC().Truncate(time.Second)
+	var balances []TokenBalance
+	for i := range 3 {
+		balances = append(balances, TokenBalance{
+			QueriedAt:    now.Add(time.Duration(i) * time.Minute),
+			Wallet:       wallet,
+			TokenAddress: "0x0000000000000000000000000000000000000001",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(int64(i)),
+			Balance:      decimal.NewFromInt(int64(i)),
+			Source:       SourceBackfill,
+		})
+	}
+	count := func() int {
+		var n int
+		require.NoError(t, store.pool.QueryRow(ctx, "SELECT count(*) FROM token_balances").Scan(&n))
+		return n
+	}
+
+	inserted, err := store.InsertBalancesIdempotent(ctx, balances)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), inserted)
+
+	inserted, err = store.InsertBalancesIdempotent(ctx, balances)
+	require.NoError(t, err)
+	require.Zero(t, inserted, "the same batch again adds nothing")
+	require.Equal(t, 3, count())
+
+	// The default and dedup paths skip stored rows too instead of failing,
+	// and report only the new ones as written
+	next := balances[2]
+	next.QueriedAt = now.Add(time.Hour)
+	written, err := store.BatchInsertBalances(ctx, append(balances, next))
+	require.NoError(t, err)
+	require.Equal(t, []TokenBalance{next}, written)
+	require.Equal(t, 4, count())
+	balances = append(balances, next)
+	_, err = store.InsertBalancesDedup(ctx, balances)
+	require.NoError(t, err)
+	require.Equal(t, 4, count())
+
+	_, err = store.CopyInsertBalances(ctx, balances[:1])
+	require.Error(t, err, "COPY cannot skip a stored row")
+}
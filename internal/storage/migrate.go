@@ -4,76 +4,290 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
 )
 
 //go:embed migrations/*.sql
 var migrations embed.FS
 
+// Defaults for MigrationOptions.
+const (
+	DefaultMigrationMaxAttempts = 5
+	DefaultMigrationRetryDelay  = 2 * time.Second
+)
+
+// SQLSTATE codes reported when concurrent DDL collides with another session.
+var lockConflictCodes = map[string]bool{
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"40001": true, // serialization_failure
+}
+
+// MigrationOptions controls how RunMigrations copes with concurrent startups.
+type MigrationOptions struct {
+	// MaxAttempts is how many times the migration run is tried when it fails
+	// on a lock conflict. Zero means DefaultMigrationMaxAttempts.
+	MaxAttempts int
+	// RetryDelay is the delay before the second attempt, doubled after each
+	// failure. Zero means DefaultMigrationRetryDelay.
+	RetryDelay time.Duration
+}
+
 // RunMigrations applies all pending database migrations.
 // It opens a temporary database/sql connection (required by goose),
 // applies migrations, then closes the connection.
-func RunMigrations(ctx context.Context, dsn string) error {
-	db, err := sql.Open("pgx", dsn)
+//
+// A PostgreSQL advisory lock serialises schema setup across instances
+// starting at the same time, and runs that still fail on a lock conflict
+// (e.g. index DDL deadlocking with inserts) are retried.
+func RunMigrations(ctx context.Context, dsn string, opts MigrationOptions) error {
+	provider, db, err := newMigrationProvider(dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open database for migrations: %w", err)
+		return err
 	}
 	defer func() { _ = db.Close() }()
 
-	goose.SetBaseFS(migrations)
+	err = retryOnLockConflict(ctx, opts, func() error {
+		results, err := provider.Up(ctx)
+		for _, r := range results {
+			slog.Info("Migration applied", "migration", r.Source.Path, "duration", r.Duration)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return nil
+}
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %w", err)
+// newMigrationProvider opens a temporary database/sql connection (required
+// by goose) and returns a provider over the embedded migrations, holding the
+// PostgreSQL advisory lock while it runs. The caller closes the connection.
+func newMigrationProvider(dsn string) (*goose.Provider, *sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database for migrations: %w", err)
 	}
 
-	if err := goose.UpContext(ctx, db, "migrations"); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	return nil
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("failed to create migration provider: %w", err)
+	}
+
+	return provider, db, nil
+}
+
+// retryOnLockConflict runs fn until it succeeds, fails with an error other
+// than a lock conflict, or runs out of attempts.
+func retryOnLockConflict(ctx context.Context, opts MigrationOptions, fn func() error) error {
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMigrationMaxAttempts
+	}
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = DefaultMigrationRetryDelay
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !isLockConflict(err) || attempt >= attempts {
+			return err
+		}
+
+		slog.Warn("Migration hit a lock conflict, retrying",
+			"attempt", attempt,
+			"max_attempts", attempts,
+			"retry_in", delay,
+			"error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// isLockConflict reports whether err is a PostgreSQL lock conflict that is
+// expected to clear once the competing session finishes.
+func isLockConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && lockConflictCodes[pgErr.Code]
 }
 
 // MigrateDown rolls back the last applied migration.
 func MigrateDown(ctx context.Context, dsn string) error {
-	db, err := sql.Open("pgx", dsn)
+	provider, db, err := newMigrationProvider(dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open database for migrations: %w", err)
+		return err
 	}
 	defer func() { _ = db.Close() }()
 
-	goose.SetBaseFS(migrations)
+	result, err := provider.Down(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to rollback migration: %w", err)
+	}
+	slog.Info("Migration rolled back", "migration", result.Source.Path, "duration", result.Duration)
+
+	return nil
+}
+
+// RollbackPlan describes the migration MigrateDown would roll back.
+type RollbackPlan struct {
+	Version   int64
+	Migration string // file name, e.g. 008_add_source.sql
+	// Destructive is set when the Down section drops or deletes data
+	Destructive bool
+}
+
+// destructiveDown matches Down statements that lose data.
+var destructiveDown = regexp.MustCompile(`(?i)\b(DROP\s+(TABLE|COLUMN)|TRUNCATE|DELETE\s+FROM)\b`)
+
+// PlanMigrateDown returns the migration MigrateDown would roll back next.
+func PlanMigrateDown(ctx context.Context, dsn string) (RollbackPlan, error) {
+	provider, db, err := newMigrationProvider(dsn)
+	if err != nil {
+		return RollbackPlan{}, err
+	}
+	defer func() { _ = db.Close() }()
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %w", err)
+	version, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return RollbackPlan{}, fmt.Errorf("failed to get database version: %w", err)
+	}
+	if version == 0 {
+		return RollbackPlan{}, fmt.Errorf("no migration to roll back")
 	}
 
-	if err := goose.DownContext(ctx, db, "migrations"); err != nil {
-		return fmt.Errorf("failed to rollback migration: %w", err)
+	for _, src := range provider.ListSources() {
+		if src.Version != version {
+			continue
+		}
+		name := filepath.Base(src.Path)
+		data, err := fs.ReadFile(migrations, "migrations/"+name)
+		if err != nil {
+			return RollbackPlan{}, fmt.Errorf("failed to read migration: %w", err)
+		}
+		return RollbackPlan{
+			Version:     version,
+			Migration:   name,
+			Destructive: isDestructiveDown(string(data)),
+		}, nil
 	}
+	return RollbackPlan{}, fmt.Errorf("applied migration %d is not embedded in this binary", version)
+}
 
-	return nil
+// isDestructiveDown reports whether the Down section of a migration drops
+// or deletes data.
+func isDestructiveDown(migration string) bool {
+	_, down, _ := strings.Cut(migration, "-- +goose Down")
+	return destructiveDown.MatchString(lineComment.ReplaceAllString(down, ""))
 }
 
-// MigrateStatus prints the status of all migrations.
-func MigrateStatus(ctx context.Context, dsn string) error {
-	db, err := sql.Open("pgx", dsn)
+// MigrationPlan describes what RunMigrations would apply.
+type MigrationPlan struct {
+	CurrentVersion int64              `json:"current_version"`
+	Pending        []PendingMigration `json:"pending"`
+}
+
+// PendingMigration is an embedded migration not applied to the database.
+type PendingMigration struct {
+	Version   int64  `json:"version"`
+	Migration string `json:"migration"` // file name, e.g. 008_add_source.sql
+}
+
+// PlanMigrateUp returns the database version and the embedded migrations
+// not applied yet, in the order RunMigrations would apply them. Nothing is
+// applied.
+func PlanMigrateUp(ctx context.Context, dsn string) (MigrationPlan, error) {
+	provider, db, err := newMigrationProvider(dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open database for migrations: %w", err)
+		return MigrationPlan{}, err
 	}
 	defer func() { _ = db.Close() }()
 
-	goose.SetBaseFS(migrations)
+	version, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return MigrationPlan{}, fmt.Errorf("failed to get database version: %w", err)
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return MigrationPlan{}, fmt.Errorf("failed to get migration status: %w", err)
+	}
+	return MigrationPlan{CurrentVersion: version, Pending: pendingMigrations(statuses)}, nil
+}
+
+// pendingMigrations returns the pending entries of statuses, keeping their
+// order. The list is never nil, so it encodes as an empty JSON array.
+func pendingMigrations(statuses []*goose.MigrationStatus) []PendingMigration {
+	pending := []PendingMigration{}
+	for _, st := range statuses {
+		if st.State == goose.StatePending {
+			pending = append(pending, PendingMigration{
+				Version:   st.Source.Version,
+				Migration: filepath.Base(st.Source.Path),
+			})
+		}
+	}
+	return pending
+}
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %w", err)
+// MigrateStatus writes the status of all migrations to w.
+func MigrateStatus(ctx context.Context, dsn string, w io.Writer) error {
+	provider, db, err := newMigrationProvider(dsn)
+	if err != nil {
+		return err
 	}
+	defer func() { _ = db.Close() }()
 
-	if err := goose.StatusContext(ctx, db, "migrations"); err != nil {
+	statuses, err := provider.Status(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to get migration status: %w", err)
 	}
 
+	writeMigrationStatus(w, statuses)
 	return nil
 }
+
+// writeMigrationStatus renders statuses as goose's status table.
+func writeMigrationStatus(w io.Writer, statuses []*goose.MigrationStatus) {
+	_, _ = fmt.Fprintln(w, "    Applied At                  Migration")
+	_, _ = fmt.Fprintln(w, "    =======================================")
+	for _, st := range statuses {
+		appliedAt := "Pending"
+		if st.State == goose.StateApplied {
+			appliedAt = st.AppliedAt.UTC().Format(time.ANSIC)
+		}
+		_, _ = fmt.Fprintf(w, "    %-24s -- %s\n", appliedAt, filepath.Base(st.Source.Path))
+	}
+}
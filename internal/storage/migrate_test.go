@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingMigration fails with err for the first n calls, then succeeds.
+func failingMigration(n int, err error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetryOnLockConflict_RecoversFromConcurrentSchemaCreation(t *testing.T) {
+	// A second instance creating the same index deadlocks with ours
+	deadlock := fmt.Errorf("failed to run SQL migration: %w",
+		&pgconn.PgError{Code: "40P01", Message: "deadlock detected"})
+	fn, calls := failingMigration(2, deadlock)
+
+	err := retryOnLockConflict(context.Background(), MigrationOptions{MaxAttempts: 5, RetryDelay: time.Millisecond}, fn)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestRetryOnLockConflict_GivesUpAfterMaxAttempts(t *testing.T) {
+	lockTimeout := &pgconn.PgError{Code: "55P03", Message: "could not obtain lock"}
+	fn, calls := failingMigration(10, lockTimeout)
+
+	err := retryOnLockConflict(context.Background(), MigrationOptions{MaxAttempts: 3, RetryDelay: time.Millisecond}, fn)
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, lockTimeout)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestRetryOnLockConflict_DoesNotRetryOtherErrors(t *testing.T) {
+	syntaxErr := &pgconn.PgError{Code: "42601", Message: "syntax error"}
+	fn, calls := failingMigration(10, syntaxErr)
+
+	err := retryOnLockConflict(context.Background(), MigrationOptions{MaxAttempts: 5, RetryDelay: time.Millisecond}, fn)
+
+	require.Error(t, err)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestRetryOnLockConflict_StopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fn, calls := failingMigration(10, &pgconn.PgError{Code: "40P01"})
+
+	err := retryOnLockConflict(ctx, MigrationOptions{MaxAttempts: 5, RetryDelay: time.Hour}, fn)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestIsLockConflict(t *testing.T) {
+	assert.True(t, isLockConflict(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, isLockConflict(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40001"})))
+	assert.False(t, isLockConflict(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, isLockConflict(errors.New("connection refused")))
+}
+
+func TestWriteMigrationStatus(t *testing.T) {
+	appliedAt := time.Date(2026, 3, 2, 10, 4, 5, 0, time.UTC)
+	statuses := []*goose.MigrationStatus{
+		{Source: &goose.Source{Path: "001_create_token_balances.sql"}, State: goose.StateApplied, AppliedAt: appliedAt},
+		{Source: &goose.Source{Path: "002_migrate_balance_to_numeric.sql"}, State: goose.StatePending},
+	}
+
+	var out strings.Builder
+	writeMigrationStatus(&out, statuses)
+
+	assert.Equal(t, `    Applied At                  Migration
+    =======================================
+    Mon Mar  2 10:04:05 2026 -- 001_create_token_balances.sql
+    Pending                  -- 002_migrate_balance_to_numeric.sql
+`, out.String())
+}
+
+func TestPendingMigrations(t *testing.T) {
+	statuses := []*goose.MigrationStatus{
+		{Source: &goose.Source{Version: 1, Path: "migrations/001_create_token_balances.sql"}, State: goose.StateApplied},
+		{Source: &goose.Source{Version: 2, Path: "migrations/002_migrate_balance_to_numeric.sql"}, State: goose.StatePending},
+		{Source: &goose.Source{Version: 3, Path: "migrations/003_add_week_bucket.sql"}, State: goose.StatePending},
+	}
+
+	assert.Equal(t, []PendingMigration{
+		{Version: 2, Migration: "002_migrate_balance_to_numeric.sql"},
+		{Version: 3, Migration: "003_add_week_bucket.sql"},
+	}, pendingMigrations(statuses))
+	assert.Equal(t, []PendingMigration{}, pendingMigrations(statuses[:1]))
+}
+
+func TestIsDestructiveDown(t *testing.T) {
+	tests := map[string]bool{
+		"001_create_token_balances.sql":      true,  // DROP TABLE
+		"002_migrate_balance_to_numeric.sql": false, // type change only
+		"004_add_day_bucket_and_indexes.sql": true,  // DROP COLUMN
+		"006_backfill_tracker_metadata.sql":  false, // UPDATE
+		"007_lowercase_wallet_addresses.sql": false, // no-op
+		"008_add_source.sql":                 true,  // DROP COLUMN
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := migrations.ReadFile("migrations/" + name)
+			require.NoError(t, err)
+			assert.Equal(t, want, isDestructiveDown(string(data)))
+		})
+	}
+
+	assert.False(t, isDestructiveDown("-- +goose Up\nDROP TABLE x;\n-- +goose Down\n-- DROP TABLE in a comment\n"))
+}
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- How a row got into the table: poll (daemon), backfill, import or manual.
+-- Existing rows were all written by the poller.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'poll';
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS source;
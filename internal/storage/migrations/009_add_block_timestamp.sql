@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- Timestamp of the latest block when the row was queried, recorded when
+-- record_block_timestamp is enabled. queried_at - block_timestamp is the RPC
+-- lag; NULL for rows written without it.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS block_timestamp TIMESTAMPTZ;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS block_timestamp;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- Per-wallet totals of each polling snapshot, so dashboards read one row per
+-- snapshot instead of every token balance. A snapshot is the minute a cycle's
+-- rows were queried in; debt tokens are those whose symbol contains "debt".
+-- Maintained by RefreshSnapshotSummary after each cycle.
+CREATE TABLE IF NOT EXISTS wallet_snapshot_summary (
+    wallet          TEXT        NOT NULL,
+    snapshot_at     TIMESTAMPTZ NOT NULL,
+    total_supply    NUMERIC     NOT NULL,
+    total_debt      NUMERIC     NOT NULL,
+    token_count     INTEGER     NOT NULL,
+    last_balance_id BIGINT      NOT NULL,
+    PRIMARY KEY (wallet, snapshot_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_snapshot_summary_last_balance_id
+    ON wallet_snapshot_summary (last_balance_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS wallet_snapshot_summary;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- Configured [[tokens]] label of the row. With series_key = "label" the
+-- aggregate reads group by it (falling back to the symbol), so a series
+-- survives a token address change. NULL for rows written without a label.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS label TEXT;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS label;
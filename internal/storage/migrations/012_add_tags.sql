@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- Tags of the wallet from [[labeled_wallets]] (e.g. {"owner": "alice"}),
+-- selected with containment queries (tags @> '{"owner": "alice"}') served by
+-- the GIN index of migration 020. NULL for wallets without tags.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS tags JSONB;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS tags;
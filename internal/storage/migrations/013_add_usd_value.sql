@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- USD value of the balance (balance × price) when price_source is set, NULL
+-- otherwise. Reserved now so a price oracle can be added without a schema
+-- change.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS usd_value NUMERIC;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS usd_value;
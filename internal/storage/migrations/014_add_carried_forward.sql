@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Rows re-inserting the last known balance after a failed poll
+-- (carry_forward_on_failure) rather than an observed balance.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS carried_forward BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS carried_forward;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Latest block number when the row's wallet was queried, NULL when unknown.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS block_number BIGINT;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS block_number;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Duration of the row's balanceOf read in milliseconds, retries included,
+-- NULL unless record_fetch_latency is enabled.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS fetch_latency_ms INTEGER;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS fetch_latency_ms;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Configured label of the row's wallet (e.g. "Savings"), so reports can name
+-- wallets instead of printing raw addresses. NULL for unlabeled wallets.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS wallet_label TEXT;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS wallet_label;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- On-chain name() of the row's token (e.g. "RMM v3 armmUSDC Variable Debt"),
+-- clearer in reports than terse symbols. NULL when it could not be read.
+ALTER TABLE token_balances
+    ADD COLUMN IF NOT EXISTS token_name TEXT;
+
+-- +goose Down
+
+ALTER TABLE token_balances DROP COLUMN IF EXISTS token_name;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- A wallet holds one balance of a token at a given time: re-running a
+-- backfill or an import must not store it twice. Existing duplicates keep
+-- their first inserted row, so that migration 021 can build the unique index
+-- on (wallet, token_address, queried_at).
+DELETE FROM token_balances dup
+    USING token_balances kept
+    WHERE dup.wallet = kept.wallet
+      AND dup.token_address = kept.token_address
+      AND dup.queried_at = kept.queried_at
+      AND dup.id > kept.id;
+
+-- +goose Down
+
+-- Removed duplicates are not recoverable.
+-- This down migration is intentionally a no-op.
@@ -0,0 +1,12 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+
+-- GIN index serving the tags containment queries, built without blocking
+-- inserts into token_balances. Databases that created it with migration 012
+-- keep theirs.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_token_balances_tags
+    ON token_balances USING GIN (tags);
+
+-- +goose Down
+
+DROP INDEX CONCURRENTLY IF EXISTS idx_token_balances_tags;
@@ -0,0 +1,12 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+
+-- Conflict target of the ON CONFLICT DO NOTHING inserts, built without
+-- blocking inserts into token_balances. Databases that created it with an
+-- earlier version of migration 019 keep theirs.
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_token_balances_wallet_token_queried_at
+    ON token_balances(wallet, token_address, queried_at);
+
+-- +goose Down
+
+DROP INDEX CONCURRENTLY IF EXISTS idx_token_balances_wallet_token_queried_at;
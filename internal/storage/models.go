@@ -1,12 +1,31 @@
 package storage
 
 import (
+	"encoding/json"
+	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+// Sources recorded with each balance row, describing the write path.
+const (
+	SourcePoll     = "poll"
+	SourceBackfill = "backfill"
+	SourceImport   = "import"
+	SourceManual   = "manual"
+)
+
+// ValidSource reports whether s is a known balance source.
+func ValidSource(s string) bool {
+	switch s {
+	case SourcePoll, SourceBackfill, SourceImport, SourceManual:
+		return true
+	}
+	return false
+}
+
 // TokenBalance represents a token balance record
 type TokenBalance struct {
 	ID           int64           `json:"id"`
@@ -17,6 +36,108 @@ type TokenBalance struct {
 	Decimals     uint8           `json:"decimals"`
 	RawBalance   *big.Int        `json:"-"`
 	Balance      decimal.Decimal `json:"balance"`
+	// Source is the write path that produced the row; empty means SourcePoll
+	Source string `json:"source,omitempty"`
+	// BlockTimestamp is the time of the latest block when the row was
+	// queried, nil unless record_block_timestamp is enabled. QueriedAt minus
+	// BlockTimestamp is the RPC lag.
+	BlockTimestamp *time.Time `json:"block_timestamp,omitempty"`
+	// Label is the configured [[tokens]] label, empty when unknown
+	Label string `json:"label,omitempty"`
+	// Tags are the tags of the wallet in [[labeled_wallets]], nil when it has none
+	Tags map[string]string `json:"tags,omitempty"`
+	// USDValue is Balance times the token's USD price, nil unless a
+	// price_source is configured and prices the token
+	USDValue *decimal.Decimal `json:"usd_value,omitempty"`
+	// CarriedForward marks a row repeating the last known balance after a
+	// failed poll (carry_forward_on_failure), not an observed balance
+	CarriedForward bool `json:"carried_forward,omitempty"`
+	// BlockNumber is the latest block when the wallet's tokens were queried,
+	// 0 when unknown
+	BlockNumber uint64 `json:"block_number,omitempty"`
+	// FetchLatencyMS is the duration of the balanceOf read in milliseconds,
+	// retries included, nil unless record_fetch_latency is enabled
+	FetchLatencyMS *int64 `json:"fetch_latency_ms,omitempty"`
+	// WalletLabel is the configured label of the wallet, empty when it has none
+	WalletLabel string `json:"wallet_label,omitempty"`
+	// TokenName is the on-chain name() of the token, its configured label
+	// when name() failed, or empty when unknown
+	TokenName string `json:"token_name,omitempty"`
+}
+
+// WalletDisplayName names a wallet for humans: "Savings (0x1234…)" when it has
+// a label, its full address otherwise.
+func WalletDisplayName(label, wallet string) string {
+	if label == "" {
+		return wallet
+	}
+	short := wallet
+	if len(short) > 6 {
+		short = short[:6] + "…"
+	}
+	return label + " (" + short + ")"
+}
+
+// MarshalJSON renders a TokenBalance in the stable shape shared by the API,
+// exports and stdout emission: raw_balance and balance as decimal strings,
+// queried_at and block_timestamp as RFC 3339 in UTC. raw_balance and
+// block_timestamp are omitted when unknown.
+func (b TokenBalance) MarshalJSON() ([]byte, error) {
+	type alias TokenBalance
+	var raw *string
+	if b.RawBalance != nil {
+		s := b.RawBalance.String()
+		raw = &s
+	}
+	var blockTime *string
+	if b.BlockTimestamp != nil {
+		s := b.BlockTimestamp.UTC().Format(time.RFC3339Nano)
+		blockTime = &s
+	}
+	return json.Marshal(struct {
+		alias
+		QueriedAt      string  `json:"queried_at"`
+		RawBalance     *string `json:"raw_balance,omitempty"`
+		BlockTimestamp *string `json:"block_timestamp,omitempty"`
+	}{
+		alias:          alias(b),
+		QueriedAt:      b.QueriedAt.UTC().Format(time.RFC3339Nano),
+		RawBalance:     raw,
+		BlockTimestamp: blockTime,
+	})
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON.
+func (b *TokenBalance) UnmarshalJSON(data []byte) error {
+	type alias TokenBalance
+	aux := struct {
+		*alias
+		QueriedAt  string  `json:"queried_at"`
+		RawBalance *string `json:"raw_balance"`
+	}{alias: (*alias)(b)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	b.QueriedAt = time.Time{}
+	if aux.QueriedAt != "" {
+		t, err := time.Parse(time.RFC3339Nano, aux.QueriedAt)
+		if err != nil {
+			return fmt.Errorf("invalid queried_at: %w", err)
+		}
+		b.QueriedAt = t
+	}
+
+	b.RawBalance = nil
+	if aux.RawBalance != nil {
+		raw, ok := new(big.Int).SetString(*aux.RawBalance, 10)
+		if !ok {
+			return fmt.Errorf("invalid raw_balance %q", *aux.RawBalance)
+		}
+		b.RawBalance = raw
+	}
+
+	return nil
 }
 
 // WeeklyBalance represents the last recorded balance for a (week, symbol) pair.
@@ -65,6 +186,19 @@ type PeriodYield struct {
 	ChangePercent decimal.Decimal `json:"change_percent"`
 }
 
+// YieldRate represents the annualized growth of one token balance over a window.
+type YieldRate struct {
+	Symbol string          `json:"symbol"`
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Rate   decimal.Decimal `json:"rate"` // fraction per year (0.05 = 5%)
+	// TransferSuspected is set when a step between two snapshots looks like a
+	// deposit, withdrawal, borrow or repayment rather than interest, so Rate
+	// is likely distorted. Transfer describes the first such step.
+	TransferSuspected bool   `json:"transfer_suspected"`
+	Transfer          string `json:"transfer,omitempty"`
+}
+
 // LatestBalance represents the most recent recorded balance for a token in a wallet.
 type LatestBalance struct {
 	Symbol       string          `json:"symbol"`
@@ -73,12 +207,39 @@ type LatestBalance struct {
 	QueriedAt    time.Time       `json:"queried_at"`
 }
 
+// StoredToken is a token address found in token_balances with the symbol
+// and decimals of its most recent row.
+type StoredToken struct {
+	Address  string `json:"token_address"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// SnapshotSummary holds the supply and debt totals of one wallet snapshot.
+type SnapshotSummary struct {
+	Wallet      string          `json:"wallet"`
+	SnapshotAt  time.Time       `json:"snapshot_at"`
+	TotalSupply decimal.Decimal `json:"total_supply"`
+	TotalDebt   decimal.Decimal `json:"total_debt"`
+	TokenCount  int             `json:"token_count"`
+}
+
 // DashboardSummary holds aggregated counts for the dashboard endpoint.
 type DashboardSummary struct {
 	WalletCount int
 	TokenCount  int
 }
 
+// BalanceDelta represents the change of a token balance over an ISO week,
+// from the closing balance of the previous stored week to this week's.
+type BalanceDelta struct {
+	WeekStart      time.Time       `json:"week_start"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	Change         decimal.Decimal `json:"change"`
+	ChangePercent  decimal.Decimal `json:"change_percent"`
+}
+
 // WeeklyReport represents the balance comparison between current and previous week for a token.
 type WeeklyReport struct {
 	Symbol          string          `json:"symbol"`
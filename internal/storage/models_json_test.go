@@ -38,8 +38,8 @@ func TestTokenBalance_JSONSnakeCase(t *testing.T) {
 	assert.Contains(t, m, "decimals")
 	assert.Contains(t, m, "balance")
 
-	// RawBalance must be excluded (json:"-")
-	assert.NotContains(t, m, "raw_balance")
+	// RawBalance is rendered as a decimal string under raw_balance
+	assert.Contains(t, m, "raw_balance")
 	assert.NotContains(t, m, "RawBalance")
 
 	// No PascalCase keys
@@ -57,10 +57,13 @@ func TestTokenBalance_JSONSnakeCase(t *testing.T) {
 	assert.Equal(t, "0xDEF", m["token_address"])
 	assert.Equal(t, "armmUSDC", m["symbol"])
 	assert.EqualValues(t, 6, m["decimals"])
+	assert.Equal(t, "1000000", m["raw_balance"])
+	assert.Equal(t, "1", m["balance"])
+	assert.Equal(t, "2026-01-15T10:00:00Z", m["queried_at"])
 }
 
 func TestTokenBalance_JSONRoundTrip(t *testing.T) {
-	// RawBalance is excluded, so we test the fields that survive marshaling.
+	// Without RawBalance the field is omitted and decodes back to nil.
 	original := TokenBalance{
 		ID:           7,
 		QueriedAt:    time.Date(2026, 2, 10, 8, 30, 0, 0, time.UTC),
@@ -84,10 +87,60 @@ func TestTokenBalance_JSONRoundTrip(t *testing.T) {
 	assert.Equal(t, original.Symbol, decoded.Symbol)
 	assert.Equal(t, original.Decimals, decoded.Decimals)
 	assert.Equal(t, original.Balance.String(), decoded.Balance.String())
-	// RawBalance not preserved (json:"-")
 	assert.Nil(t, decoded.RawBalance)
 }
 
+func TestTokenBalance_JSONRoundTripRawBalance(t *testing.T) {
+	maxUint256, ok := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+	require.True(t, ok)
+
+	tests := []struct {
+		name     string
+		raw      *big.Int
+		decimals uint8
+		balance  string
+	}{
+		{"zero", big.NewInt(0), 18, "0"},
+		{"six decimals", big.NewInt(1_500_000), 6, "1.5"},
+		{"max uint256", maxUint256, 18, "115792089237316195423570985008687907853269984665640564039457.584007913129639935"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := TokenBalance{
+				ID:           1,
+				QueriedAt:    time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.FixedZone("CET", 3600)),
+				Wallet:       "0xwallet",
+				TokenAddress: "0xToken",
+				Symbol:       "armmXDAI",
+				Decimals:     tt.decimals,
+				RawBalance:   tt.raw,
+				Balance:      decimal.RequireFromString(tt.balance),
+			}
+
+			data, err := json.Marshal(original)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), `"raw_balance":"`+tt.raw.String()+`"`)
+
+			var decoded TokenBalance
+			require.NoError(t, json.Unmarshal(data, &decoded))
+
+			require.NotNil(t, decoded.RawBalance)
+			assert.Equal(t, 0, original.RawBalance.Cmp(decoded.RawBalance))
+			assert.True(t, original.Balance.Equal(decoded.Balance))
+			assert.True(t, original.QueriedAt.Equal(decoded.QueriedAt))
+			assert.Equal(t, time.UTC, decoded.QueriedAt.Location())
+			assert.Equal(t, original.Decimals, decoded.Decimals)
+		})
+	}
+}
+
+func TestTokenBalance_UnmarshalJSONInvalid(t *testing.T) {
+	var b TokenBalance
+	assert.Error(t, json.Unmarshal([]byte(`{"raw_balance":"12abc"}`), &b))
+	assert.Error(t, json.Unmarshal([]byte(`{"queried_at":"yesterday"}`), &b))
+}
+
 func TestWeeklyBalance_JSONSnakeCase(t *testing.T) {
 	wb := WeeklyBalance{
 		Week:         time.Date(2026, 2, 16, 0, 0, 0, 0, time.UTC),
@@ -170,3 +223,8 @@ func TestWeeklyReport_NilSlice_MarshaledAsEmptyArray(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "[]", string(data))
 }
+
+func TestWalletDisplayName(t *testing.T) {
+	assert.Equal(t, "Savings (0x1234…)", WalletDisplayName("Savings", "0x1234567890123456789012345678901234567890"))
+	assert.Equal(t, "0x1234567890123456789012345678901234567890", WalletDisplayName("", "0x1234567890123456789012345678901234567890"))
+}
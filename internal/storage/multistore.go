@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// WriteTarget is a named Commander participating in a MultiStore fan-out.
+type WriteTarget struct {
+	Name string
+	Commander
+}
+
+// MultiStore fans out writes to several Commander targets concurrently.
+//
+// The primary target is the store used for reads and health, so a write
+// always fails when the primary fails. In best-effort mode (the default)
+// failures on secondary targets are only logged; in all-or-nothing mode any
+// target failure fails the whole write. There is no distributed transaction:
+// targets that succeeded keep their rows either way.
+type MultiStore struct {
+	targets      []WriteTarget // targets[0] is the primary
+	allOrNothing bool
+}
+
+// NewMultiStore creates a MultiStore writing to primary and every secondary.
+func NewMultiStore(allOrNothing bool, primary WriteTarget, secondaries ...WriteTarget) *MultiStore {
+	targets := append([]WriteTarget{primary}, secondaries...)
+	return &MultiStore{targets: targets, allOrNothing: allOrNothing}
+}
+
+// BatchInsertBalances writes the batch to every target and returns the
+// balances written by the primary.
+func (m *MultiStore) BatchInsertBalances(ctx context.Context, balances []TokenBalance) ([]TokenBalance, error) {
+	var written []TokenBalance
+	err := m.fanOut(ctx, "batch insert", func(ctx context.Context, i int, c Commander) error {
+		w, err := c.BatchInsertBalances(ctx, balances)
+		if i == 0 {
+			written = w
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
+// SetLastRunStatus records the run status on every target.
+func (m *MultiStore) SetLastRunStatus(ctx context.Context, succeeded bool) error {
+	return m.fanOut(ctx, "set last run status", func(ctx context.Context, _ int, c Commander) error {
+		return c.SetLastRunStatus(ctx, succeeded)
+	})
+}
+
+// fanOut runs op against all targets in parallel, with their index in
+// m.targets, and aggregates the errors according to the configured mode.
+func (m *MultiStore) fanOut(ctx context.Context, opName string, op func(ctx context.Context, i int, c Commander) error) error {
+	errs := make([]error, len(m.targets))
+	var wg sync.WaitGroup
+	for i, t := range m.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := op(ctx, i, t.Commander); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", t.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+
+	joined := errors.Join(errs...)
+	if m.allOrNothing || errs[0] != nil {
+		return fmt.Errorf("%s failed on %d/%d targets: %w", opName, failed, len(m.targets), joined)
+	}
+
+	slog.Warn("Write failed on secondary database targets",
+		"operation", opName,
+		"failed", failed,
+		"targets", len(m.targets),
+		"error", joined)
+	return nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory Commander used to test write fan-out.
+type memStore struct {
+	mu        sync.Mutex
+	balances  []TokenBalance
+	lastRunOK *bool
+	err       error
+}
+
+func (m *memStore) BatchInsertBalances(_ context.Context, balances []TokenBalance) ([]TokenBalance, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.balances = append(m.balances, balances...)
+	return balances, nil
+}
+
+func (m *memStore) SetLastRunStatus(_ context.Context, succeeded bool) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastRunOK = &succeeded
+	return nil
+}
+
+func sampleBatch() []TokenBalance {
+	return []TokenBalance{
+		{
+			QueriedAt:    time.Now().UTC(),
+			Wallet:       "0x1234567890123456789012345678901234567890",
+			TokenAddress: "0x0000000000000000000000000000000000000001",
+			Symbol:       "armmXDAI",
+			Decimals:     18,
+			RawBalance:   big.NewInt(1_000_000_000_000_000_000),
+			Balance:      decimal.NewFromInt(1),
+		},
+	}
+}
+
+func TestMultiStore_WritesToAllTargets(t *testing.T) {
+	primary, secondary := &memStore{}, &memStore{}
+	ms := NewMultiStore(false,
+		WriteTarget{Name: "primary", Commander: primary},
+		WriteTarget{Name: "analytics", Commander: secondary},
+	)
+
+	_, err := ms.BatchInsertBalances(context.Background(), sampleBatch())
+	require.NoError(t, err)
+	require.NoError(t, ms.SetLastRunStatus(context.Background(), true))
+
+	for _, s := range []*memStore{primary, secondary} {
+		assert.Len(t, s.balances, 1)
+		require.NotNil(t, s.lastRunOK)
+		assert.True(t, *s.lastRunOK)
+	}
+}
+
+func TestMultiStore_BestEffort(t *testing.T) {
+	t.Run("partial failure is tolerated", func(t *testing.T) {
+		ok := &memStore{}
+		ms := NewMultiStore(false,
+			WriteTarget{Name: "primary", Commander: ok},
+			WriteTarget{Name: "analytics", Commander: &memStore{err: errors.New("connection refused")}},
+		)
+
+		_, err := ms.BatchInsertBalances(context.Background(), sampleBatch())
+		require.NoError(t, err)
+		assert.Len(t, ok.balances, 1)
+	})
+
+	t.Run("primary failure fails the write", func(t *testing.T) {
+		secondary := &memStore{}
+		ms := NewMultiStore(false,
+			WriteTarget{Name: "primary", Commander: &memStore{err: errors.New("disk full")}},
+			WriteTarget{Name: "analytics", Commander: secondary},
+		)
+
+		_, err := ms.BatchInsertBalances(context.Background(), sampleBatch())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1/2 targets")
+		assert.Contains(t, err.Error(), "primary: disk full")
+		assert.Len(t, secondary.balances, 1, "secondary still receives the write")
+
+		require.Error(t, ms.SetLastRunStatus(context.Background(), true))
+	})
+
+	t.Run("all targets failing returns aggregated error", func(t *testing.T) {
+		ms := NewMultiStore(false,
+			WriteTarget{Name: "primary", Commander: &memStore{err: errors.New("disk full")}},
+			WriteTarget{Name: "analytics", Commander: &memStore{err: errors.New("connection refused")}},
+		)
+
+		_, err := ms.BatchInsertBalances(context.Background(), sampleBatch())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "2/2 targets")
+		assert.Contains(t, err.Error(), "primary: disk full")
+		assert.Contains(t, err.Error(), "analytics: connection refused")
+	})
+}
+
+func TestMultiStore_AllOrNothing(t *testing.T) {
+	ok := &memStore{}
+	cause := errors.New("connection refused")
+	ms := NewMultiStore(true,
+		WriteTarget{Name: "primary", Commander: ok},
+		WriteTarget{Name: "analytics", Commander: &memStore{err: cause}},
+	)
+
+	_, err := ms.BatchInsertBalances(context.Background(), sampleBatch())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, cause)
+	assert.Contains(t, err.Error(), "1/2 targets")
+	assert.Contains(t, err.Error(), "analytics")
+}
@@ -2,7 +2,12 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -10,20 +15,77 @@ import (
 	shop "github.com/jackc/pgx-shopspring-decimal"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 )
 
 const dashboardCacheTTL = time.Minute
 
+// Series keys select how aggregate reads group rows into one series per token.
+const (
+	SeriesBySymbol = "symbol" // on-chain symbol (default)
+	SeriesByLabel  = "label"  // configured label, falling back to the symbol
+)
+
 // Store manages PostgreSQL operations
 type Store struct {
-	pool         *pgxpool.Pool
-	dashCache    DashboardSummary
-	dashCachedAt time.Time
-	dashCacheMu  sync.RWMutex
+	pool            *pgxpool.Pool
+	seriesKey       string
+	insertBatchSize int
+	dedupUnchanged  bool
+	dashCache       DashboardSummary
+	dashCachedAt    time.Time
+	dashCacheMu     sync.RWMutex
+}
+
+// Options holds optional connection settings applied on top of the DSN.
+// Zero values leave the DSN (or pgx defaults) untouched.
+type Options struct {
+	ConnectTimeout   time.Duration // Overrides connect_timeout from the DSN
+	StatementTimeout time.Duration // Applied with SET statement_timeout on every new connection
+	SeriesKey        string        // Groups aggregate reads by SeriesBySymbol (default) or SeriesByLabel
+	InsertBatchSize  int           // Rows per INSERT statement; 0 means DefaultInsertBatchSize
+	DedupUnchanged   bool          // BatchInsertBalances leaves out balances equal to the newest stored one
 }
 
 // NewStore creates a new PostgreSQL store with connection pooling
-func NewStore(ctx context.Context, dsn string) (*Store, error) {
+func NewStore(ctx context.Context, dsn string, opts Options) (*Store, error) {
+	config, err := poolConfig(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create pool
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pool: %w", err)
+	}
+
+	// Verify connection
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
+	}
+
+	return &Store{
+		pool:            pool,
+		seriesKey:       opts.SeriesKey,
+		insertBatchSize: effectiveInsertBatchSize(opts.InsertBatchSize),
+		dedupUnchanged:  opts.DedupUnchanged,
+	}, nil
+}
+
+// series returns the SQL expression keying a token series in aggregate reads.
+func (s *Store) series() string {
+	if s.seriesKey == SeriesByLabel {
+		return "COALESCE(label, symbol)"
+	}
+	return "symbol"
+}
+
+// poolConfig parses the DSN and applies pool tuning and timeout overrides.
+// Overrides win over whatever the DSN specifies, so operators can enforce
+// limits without editing a shared connection string.
+func poolConfig(dsn string, opts Options) (*pgxpool.Config, error) {
 	// Parse and configure connection pool
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
@@ -36,25 +98,27 @@ func NewStore(ctx context.Context, dsn string) (*Store, error) {
 	config.MaxConnLifetime = 1 * time.Hour
 	config.MaxConnIdleTime = 30 * time.Minute
 
-	// Register decimal.Decimal type mapping
+	if opts.ConnectTimeout > 0 {
+		config.ConnConfig.ConnectTimeout = opts.ConnectTimeout
+	}
+
+	// Register decimal.Decimal type mapping and session settings
 	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
 		shop.Register(conn.TypeMap())
+		if opts.StatementTimeout > 0 {
+			if _, err := conn.Exec(ctx, statementTimeoutSQL(opts.StatementTimeout)); err != nil {
+				return fmt.Errorf("failed to set statement_timeout: %w", err)
+			}
+		}
 		return nil
 	}
 
-	// Create pool
-	pool, err := pgxpool.NewWithConfig(ctx, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create pool: %w", err)
-	}
-
-	// Verify connection
-	if err := pool.Ping(ctx); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
-	}
+	return config, nil
+}
 
-	return &Store{pool: pool}, nil
+// statementTimeoutSQL renders the SET statement for a timeout, in milliseconds.
+func statementTimeoutSQL(d time.Duration) string {
+	return fmt.Sprintf("SET statement_timeout = %d", d.Milliseconds())
 }
 
 // Close closes the connection pool
@@ -62,42 +126,410 @@ func (s *Store) Close() {
 	s.pool.Close()
 }
 
-// BatchInsertBalances inserts multiple token balances using pgx.Batch
-func (s *Store) BatchInsertBalances(ctx context.Context, balances []TokenBalance) error {
+// BatchInsertBalances inserts multiple token balances with one multi-row
+// INSERT per chunk of the insert batch size, sent together in a pgx.Batch.
+// Balances without a raw balance are skipped with a warning, as are those
+// already stored for their wallet, token and queried_at, so a batch written
+// twice is stored once. With Options.DedupUnchanged it inserts through
+// InsertBalancesDedup instead. It returns the balances written, leaving out
+// those already stored.
+func (s *Store) BatchInsertBalances(ctx context.Context, balances []TokenBalance) ([]TokenBalance, error) {
 	if len(balances) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	// A row without raw balance is an upstream bug; skip it rather than
+	// losing the whole batch
+	balances = withRawBalance(balances)
+	if len(balances) == 0 {
+		return nil, nil
+	}
+
+	if s.dedupUnchanged {
+		written, err := s.insertDedup(ctx, balances)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("Unchanged balances not inserted",
+			"inserted", len(written),
+			"unchanged", len(balances)-len(written))
+		return written, nil
+	}
+
+	return s.insertBatch(ctx, balances)
+}
+
+// InsertBalancesIdempotent inserts balances like BatchInsertBalances, never
+// leaving out unchanged ones, and returns the number of rows inserted: the
+// balances already stored for their wallet, token and queried_at are not
+// counted, so re-running a backfill or an import reports what it added.
+func (s *Store) InsertBalancesIdempotent(ctx context.Context, balances []TokenBalance) (int64, error) {
+	balances = withRawBalance(balances)
+	if len(balances) == 0 {
+		return 0, nil
+	}
+	written, err := s.insertBatch(ctx, balances)
+	return int64(len(written)), err
+}
+
+// insertBatch runs the insertStatements of balances, which all have a raw
+// balance, in a single pgx.Batch and returns those inserted, matched by the
+// key of the rows the statements return.
+func (s *Store) insertBatch(ctx context.Context, balances []TokenBalance) ([]TokenBalance, error) {
+	statements, err := insertStatements(balances, s.insertBatchSize)
+	if err != nil {
+		return nil, err
 	}
 
 	// Use pgx.Batch for optimal performance
 	batch := &pgx.Batch{}
+	for _, st := range statements {
+		batch.Queue(st.sql, st.args...)
+	}
 
+	// Execute batch
+	br := s.pool.SendBatch(ctx, batch)
+	defer func() { _ = br.Close() }()
+
+	// Collect the keys of the inserted rows
+	inserted := make(map[string]int, len(balances))
+	for range statements {
+		rows, err := br.Query()
+		if err != nil {
+			return nil, fmt.Errorf("batch insert failed: %w", err)
+		}
+		for rows.Next() {
+			var wallet, token string
+			var queriedAt time.Time
+			if err := rows.Scan(&wallet, &token, &queriedAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("batch insert failed: %w", err)
+			}
+			inserted[balanceKey(wallet, token, queriedAt)]++
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("batch insert failed: %w", err)
+		}
+	}
+
+	written := make([]TokenBalance, 0, len(balances))
 	for _, bal := range balances {
-		batch.Queue(`
-			INSERT INTO token_balances
-			(queried_at, wallet, token_address, symbol, decimals, raw_balance, balance)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-			bal.QueriedAt,
-			strings.ToLower(bal.Wallet),
-			bal.TokenAddress,
-			bal.Symbol,
-			bal.Decimals,
-			bal.RawBalance.String(),
-			bal.Balance,
-		)
+		key := balanceKey(bal.Wallet, bal.TokenAddress, bal.QueriedAt)
+		if inserted[key] > 0 {
+			inserted[key]--
+			written = append(written, bal)
+		}
+	}
+	return written, nil
+}
+
+// balanceKey identifies a row by its unique wallet, token and queried_at, as
+// copyRow writes it: wallet lowercased and queried_at to the microsecond.
+func balanceKey(wallet, token string, queriedAt time.Time) string {
+	return strings.ToLower(wallet) + "|" + token + "|" + strconv.FormatInt(queriedAt.UnixMicro(), 10)
+}
+
+// InsertBalance inserts one balance like BatchInsertBalances. A balance
+// without raw balance is rejected rather than skipped.
+func (s *Store) InsertBalance(ctx context.Context, balance TokenBalance) error {
+	if balance.RawBalance == nil {
+		return fmt.Errorf("balance without raw balance (wallet %s, token %s)", balance.Wallet, balance.TokenAddress)
+	}
+	_, err := s.BatchInsertBalances(ctx, []TokenBalance{balance})
+	return err
+}
+
+// maxQueryParams is the PostgreSQL extended-protocol limit on the bind
+// parameters of one statement.
+const maxQueryParams = 65535
+
+// DefaultInsertBatchSize is the number of rows per INSERT statement written
+// by BatchInsertBalances unless configured otherwise.
+const DefaultInsertBatchSize = 1000
+
+// effectiveInsertBatchSize returns the rows per INSERT statement for a
+// configured size (0 means DefaultInsertBatchSize), capped so one statement
+// never exceeds maxQueryParams.
+func effectiveInsertBatchSize(configured int) int {
+	size := configured
+	if size <= 0 {
+		size = DefaultInsertBatchSize
+	}
+	if limit := maxQueryParams / len(balanceColumns); size > limit {
+		slog.Warn("db_insert_batch_size exceeds the PostgreSQL parameter limit, capped",
+			"configured", configured,
+			"effective", limit,
+			"params_per_row", len(balanceColumns),
+			"max_params", maxQueryParams)
+		return limit
+	}
+	return size
+}
+
+// insertStatement is one multi-row INSERT and its arguments.
+type insertStatement struct {
+	sql  string
+	args []any
+}
+
+// onConflictSkip leaves out the rows already stored for their wallet, token
+// and queried_at, the key of idx_token_balances_wallet_token_queried_at.
+const onConflictSkip = "ON CONFLICT (wallet, token_address, queried_at) DO NOTHING"
+
+// returningKey returns the key of the rows inserted, those left out by
+// onConflictSkip returning nothing.
+const returningKey = "RETURNING wallet, token_address, queried_at"
+
+// insertStatements splits balances into INSERT statements of at most
+// batchSize rows each (DefaultInsertBatchSize when unset), with values in
+// balanceColumns order, skipping the rows already stored and returning the key
+// of those inserted.
+func insertStatements(balances []TokenBalance, batchSize int) ([]insertStatement, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultInsertBatchSize
+	}
+	header := "INSERT INTO token_balances (" + strings.Join(balanceColumns, ", ") + ") VALUES "
+	var statements []insertStatement
+	for chunk := range slices.Chunk(balances, batchSize) {
+		var sql strings.Builder
+		sql.WriteString(header)
+		args := make([]any, 0, len(chunk)*len(balanceColumns))
+		for i, bal := range chunk {
+			row, err := copyRow(bal)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				sql.WriteString(", ")
+			}
+			sql.WriteByte('(')
+			for j := range row {
+				if j > 0 {
+					sql.WriteString(", ")
+				}
+				fmt.Fprintf(&sql, "$%d", len(args)+j+1)
+			}
+			sql.WriteByte(')')
+			args = append(args, row...)
+		}
+		sql.WriteString(" " + onConflictSkip + " " + returningKey)
+		statements = append(statements, insertStatement{sql: sql.String(), args: args})
+	}
+	return statements, nil
+}
+
+// balanceColumnTypes are the SQL types of balanceColumns, spelled out in
+// dedupInsertSQL where INSERT ... SELECT cannot infer them.
+var balanceColumnTypes = map[string]string{
+	"queried_at":       "timestamptz",
+	"wallet":           "text",
+	"token_address":    "text",
+	"symbol":           "text",
+	"decimals":         "smallint",
+	"raw_balance":      "text",
+	"balance":          "numeric",
+	"source":           "text",
+	"block_timestamp":  "timestamptz",
+	"label":            "text",
+	"tags":             "jsonb",
+	"usd_value":        "numeric",
+	"carried_forward":  "boolean",
+	"block_number":     "bigint",
+	"fetch_latency_ms": "integer",
+	"wallet_label":     "text",
+	"token_name":       "text",
+}
+
+// dedupInsertSQL inserts one balance, with values in balanceColumns order,
+// unless the newest stored row of its wallet and token has the same raw
+// balance or it is already stored.
+var dedupInsertSQL = func() string {
+	values := make([]string, len(balanceColumns))
+	param := make(map[string]string, len(balanceColumns))
+	for i, col := range balanceColumns {
+		param[col] = fmt.Sprintf("$%d::%s", i+1, balanceColumnTypes[col])
+		values[i] = param[col]
+	}
+	// Served by idx_token_balances_wallet_token_time
+	return "INSERT INTO token_balances (" + strings.Join(balanceColumns, ", ") + ")\n" +
+		"SELECT " + strings.Join(values, ", ") + "\n" +
+		"WHERE NOT EXISTS (\n" +
+		"\tSELECT 1 FROM (\n" +
+		"\t\tSELECT raw_balance FROM token_balances\n" +
+		"\t\tWHERE wallet = " + param["wallet"] + " AND token_address = " + param["token_address"] + "\n" +
+		"\t\tORDER BY queried_at DESC LIMIT 1\n" +
+		"\t) latest\n" +
+		"\tWHERE latest.raw_balance = " + param["raw_balance"] + "\n" +
+		")\n" +
+		onConflictSkip
+}()
+
+// InsertBalancesDedup inserts balances like BatchInsertBalances but leaves
+// out those whose raw balance equals the newest stored one of their wallet
+// and token, and returns the number of rows inserted. Each balance is one
+// INSERT ... WHERE NOT EXISTS, all sent in a single pgx.Batch, so a balance
+// repeated within balances is compared with the one queued before it.
+func (s *Store) InsertBalancesDedup(ctx context.Context, balances []TokenBalance) (int64, error) {
+	balances = withRawBalance(balances)
+	if len(balances) == 0 {
+		return 0, nil
+	}
+	written, err := s.insertDedup(ctx, balances)
+	return int64(len(written)), err
+}
+
+// insertDedup runs InsertBalancesDedup on balances that all have a raw
+// balance and returns those inserted.
+func (s *Store) insertDedup(ctx context.Context, balances []TokenBalance) ([]TokenBalance, error) {
+	batch := &pgx.Batch{}
+	for _, bal := range balances {
+		row, err := copyRow(bal)
+		if err != nil {
+			return nil, err
+		}
+		batch.Queue(dedupInsertSQL, row...)
 	}
 
-	// Execute batch
 	br := s.pool.SendBatch(ctx, batch)
 	defer func() { _ = br.Close() }()
 
-	// Check for errors
-	for range balances {
-		if _, err := br.Exec(); err != nil {
-			return fmt.Errorf("batch insert failed: %w", err)
+	var written []TokenBalance
+	for _, bal := range balances {
+		tag, err := br.Exec()
+		if err != nil {
+			return nil, fmt.Errorf("batch insert failed: %w", err)
 		}
+		if tag.RowsAffected() > 0 {
+			written = append(written, bal)
+		}
+	}
+	return written, nil
+}
+
+// withRawBalance returns balances without the rows missing a raw balance,
+// logging a warning for each one skipped.
+func withRawBalance(balances []TokenBalance) []TokenBalance {
+	kept := make([]TokenBalance, 0, len(balances))
+	for _, bal := range balances {
+		if bal.RawBalance == nil {
+			slog.Warn("Balance without raw balance skipped",
+				"wallet", bal.Wallet,
+				"token_address", bal.TokenAddress,
+				"symbol", bal.Symbol)
+			continue
+		}
+		kept = append(kept, bal)
+	}
+	return kept
+}
+
+// balanceColumns are the token_balances columns written on insert.
+var balanceColumns = []string{"queried_at", "wallet", "token_address", "symbol", "decimals", "raw_balance", "balance", "source", "block_timestamp", "label", "tags", "usd_value", "carried_forward", "block_number", "fetch_latency_ms", "wallet_label", "token_name"}
+
+// CopyInsertBalances bulk-loads balances with COPY and returns the number of
+// rows copied. It is much faster than BatchInsertBalances for large imports,
+// but is all-or-nothing and not suited to the live polling path: a row
+// without raw balance, or already stored for its wallet, token and
+// queried_at, fails the whole copy instead of being skipped.
+func (s *Store) CopyInsertBalances(ctx context.Context, balances []TokenBalance) (int64, error) {
+	if len(balances) == 0 {
+		return 0, nil
 	}
 
-	return nil
+	src := pgx.CopyFromSlice(len(balances), func(i int) ([]any, error) {
+		return copyRow(balances[i])
+	})
+	n, err := s.pool.CopyFrom(ctx, pgx.Identifier{"token_balances"}, balanceColumns, src)
+	if err != nil {
+		return n, fmt.Errorf("copy insert failed: %w", err)
+	}
+	return n, nil
+}
+
+// copyRow converts a balance to a row in balanceColumns order, normalising
+// it for both COPY and BatchInsertBalances.
+func copyRow(bal TokenBalance) ([]any, error) {
+	source, err := balanceSource(bal)
+	if err != nil {
+		return nil, err
+	}
+	if bal.RawBalance == nil {
+		return nil, fmt.Errorf("balance without raw balance (wallet %s, token %s)", bal.Wallet, bal.TokenAddress)
+	}
+	return []any{
+		bal.QueriedAt,
+		strings.ToLower(bal.Wallet),
+		bal.TokenAddress,
+		bal.Symbol,
+		int16(bal.Decimals),
+		bal.RawBalance.String(),
+		bal.Balance,
+		source,
+		bal.BlockTimestamp,
+		nullableLabel(bal),
+		nullableTags(bal),
+		bal.USDValue,
+		bal.CarriedForward,
+		nullableBlockNumber(bal),
+		bal.FetchLatencyMS,
+		nullableWalletLabel(bal),
+		nullableTokenName(bal),
+	}, nil
+}
+
+// nullableBlockNumber returns the block number to store for b, NULL when it
+// is unknown.
+func nullableBlockNumber(b TokenBalance) *int64 {
+	if b.BlockNumber == 0 {
+		return nil
+	}
+	n := int64(b.BlockNumber)
+	return &n
+}
+
+// nullableLabel returns the label to store for b, NULL when it has none.
+func nullableLabel(b TokenBalance) *string {
+	if b.Label == "" {
+		return nil
+	}
+	return &b.Label
+}
+
+// nullableWalletLabel returns the wallet label to store for b, NULL when it
+// has none.
+func nullableWalletLabel(b TokenBalance) *string {
+	if b.WalletLabel == "" {
+		return nil
+	}
+	return &b.WalletLabel
+}
+
+// nullableTokenName returns the token name to store for b, NULL when it is
+// unknown.
+func nullableTokenName(b TokenBalance) *string {
+	if b.TokenName == "" {
+		return nil
+	}
+	return &b.TokenName
+}
+
+// nullableTags returns the tags to store for b, NULL when it has none.
+func nullableTags(b TokenBalance) any {
+	if len(b.Tags) == 0 {
+		return nil
+	}
+	return b.Tags
+}
+
+// balanceSource returns the source to record for b, defaulting to SourcePoll.
+func balanceSource(b TokenBalance) (string, error) {
+	if b.Source == "" {
+		return SourcePoll, nil
+	}
+	if !ValidSource(b.Source) {
+		return "", fmt.Errorf("invalid balance source %q", b.Source)
+	}
+	return b.Source, nil
 }
 
 // Ping verifies the connection is alive
@@ -112,7 +544,7 @@ func (s *Store) GetBalances(ctx context.Context, wallet, symbol string, limit in
 	}
 
 	rows, err := s.pool.Query(ctx, `
-		SELECT id, queried_at, wallet, token_address, symbol, decimals, balance
+		SELECT `+balanceSelect+`
 		FROM token_balances
 		WHERE ($1 = '' OR wallet = $1)
 		  AND ($2 = '' OR symbol = $2)
@@ -123,13 +555,147 @@ func (s *Store) GetBalances(ctx context.Context, wallet, symbol string, limit in
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
+	return scanBalances(rows)
+}
+
+// GetBalancesByTag returns the balances of wallets tagged key = value in
+// [[labeled_wallets]], newest first.
+func (s *Store) GetBalancesByTag(ctx context.Context, key, value string) ([]TokenBalance, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+balanceSelect+`
+		FROM token_balances
+		WHERE tags @> jsonb_build_object($1::text, $2::text)
+		ORDER BY queried_at DESC`,
+		key, value,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	return scanBalances(rows)
+}
+
+// ErrNoBalance is returned by GetLatestBalance when no balance is stored
+// for the wallet/token pair.
+var ErrNoBalance = errors.New("no balance stored")
+
+// GetLatestBalance returns the most recent balance of a token for a wallet,
+// or ErrNoBalance when the pair has no row. The wallet is matched
+// case-insensitively; tokenAddress must match the stored address
+// (checksummed for polled rows).
+func (s *Store) GetLatestBalance(ctx context.Context, wallet, tokenAddress string) (TokenBalance, error) {
+	// Served by idx_token_balances_wallet_token_time
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+balanceSelect+`
+		FROM token_balances
+		WHERE wallet = $1 AND token_address = $2
+		ORDER BY queried_at DESC
+		LIMIT 1`,
+		strings.ToLower(wallet), tokenAddress,
+	)
+	if err != nil {
+		return TokenBalance{}, fmt.Errorf("query failed: %w", err)
+	}
+	balances, err := scanBalances(rows)
+	if err != nil {
+		return TokenBalance{}, err
+	}
+	if len(balances) == 0 {
+		return TokenBalance{}, fmt.Errorf("%w for wallet %s and token %s", ErrNoBalance, wallet, tokenAddress)
+	}
+	return balances[0], nil
+}
+
+// GetAllTimeHigh returns the row holding the highest balance of a token for
+// a wallet, the earliest one when the peak was held several times, or
+// ErrNoBalance when the pair has no row. Addresses are matched like in
+// GetLatestBalance.
+func (s *Store) GetAllTimeHigh(ctx context.Context, wallet, tokenAddress string) (TokenBalance, error) {
+	// balance is NUMERIC, so the ordering is exact
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+balanceSelect+`
+		FROM token_balances
+		WHERE wallet = $1 AND token_address = $2
+		ORDER BY balance DESC, queried_at ASC
+		LIMIT 1`,
+		strings.ToLower(wallet), tokenAddress,
+	)
+	if err != nil {
+		return TokenBalance{}, fmt.Errorf("query failed: %w", err)
+	}
+	balances, err := scanBalances(rows)
+	if err != nil {
+		return TokenBalance{}, err
+	}
+	if len(balances) == 0 {
+		return TokenBalance{}, fmt.Errorf("%w for wallet %s and token %s", ErrNoBalance, wallet, tokenAddress)
+	}
+	return balances[0], nil
+}
+
+// GetBalanceHistory returns the balances of a token for a wallet queried in
+// [from, to], oldest first. A zero to means now; from must be before to.
+// Addresses are matched like in GetLatestBalance.
+func (s *Store) GetBalanceHistory(ctx context.Context, wallet, tokenAddress string, from, to time.Time) ([]TokenBalance, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid range: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	// Served by idx_token_balances_wallet_token_time
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+balanceSelect+`
+		FROM token_balances
+		WHERE wallet = $1 AND token_address = $2
+		  AND queried_at BETWEEN $3 AND $4
+		ORDER BY queried_at ASC`,
+		strings.ToLower(wallet), tokenAddress, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	return scanBalances(rows)
+}
+
+// GetDailyCloseBalances returns the last balance of each UTC day of a token
+// for a wallet queried in [from, to], oldest day first. The range and
+// addresses are handled like in GetBalanceHistory.
+func (s *Store) GetDailyCloseBalances(ctx context.Context, wallet, tokenAddress string, from, to time.Time) ([]TokenBalance, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid range: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	// day_bucket is the generated UTC day of queried_at
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (day_bucket) `+balanceSelect+`
+		FROM token_balances
+		WHERE wallet = $1 AND token_address = $2
+		  AND queried_at BETWEEN $3 AND $4
+		ORDER BY day_bucket ASC, queried_at DESC`,
+		strings.ToLower(wallet), tokenAddress, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	return scanBalances(rows)
+}
+
+// balanceSelect lists the token_balances columns read by scanBalances.
+const balanceSelect = `id, queried_at, wallet, token_address, symbol, decimals, raw_balance, balance, source, block_timestamp, COALESCE(label, ''), tags, usd_value, carried_forward, block_number, fetch_latency_ms, COALESCE(wallet_label, ''), COALESCE(token_name, '')`
+
+// scanBalances reads rows selected with balanceSelect and closes them.
+func scanBalances(rows pgx.Rows) ([]TokenBalance, error) {
 	defer rows.Close()
 
 	var balances []TokenBalance
 	for rows.Next() {
-		var b TokenBalance
-		if err := rows.Scan(&b.ID, &b.QueriedAt, &b.Wallet, &b.TokenAddress, &b.Symbol, &b.Decimals, &b.Balance); err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+		b, err := scanBalance(rows)
+		if err != nil {
+			return nil, err
 		}
 		balances = append(balances, b)
 	}
@@ -137,21 +703,89 @@ func (s *Store) GetBalances(ctx context.Context, wallet, symbol string, limit in
 	return balances, rows.Err()
 }
 
+// scanBalance reads the current row selected with balanceSelect.
+func scanBalance(rows pgx.Rows) (TokenBalance, error) {
+	var b TokenBalance
+	var raw string
+	var blockNumber *int64
+	if err := rows.Scan(&b.ID, &b.QueriedAt, &b.Wallet, &b.TokenAddress, &b.Symbol, &b.Decimals, &raw, &b.Balance, &b.Source, &b.BlockTimestamp, &b.Label, &b.Tags, &b.USDValue, &b.CarriedForward, &blockNumber, &b.FetchLatencyMS, &b.WalletLabel, &b.TokenName); err != nil {
+		return TokenBalance{}, fmt.Errorf("scan failed: %w", err)
+	}
+	if blockNumber != nil {
+		b.BlockNumber = uint64(*blockNumber)
+	}
+	rawBalance, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return TokenBalance{}, fmt.Errorf("invalid raw_balance %q for row %d", raw, b.ID)
+	}
+	b.RawBalance = rawBalance
+	return b, nil
+}
+
+// ExportFilter selects the balances streamed by ExportBalances. Zero fields
+// do not filter.
+type ExportFilter struct {
+	Wallet string    // matched case-insensitively
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+}
+
+// ExportBalances calls fn with every balance matching filter, oldest first.
+// Rows are read one at a time, so the result set is never held in memory.
+// It stops at the first error returned by fn.
+func (s *Store) ExportBalances(ctx context.Context, filter ExportFilter, fn func(TokenBalance) error) error {
+	var conditions []string
+	var args []any
+	if filter.Wallet != "" {
+		args = append(args, strings.ToLower(filter.Wallet))
+		conditions = append(conditions, fmt.Sprintf("wallet = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("queried_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("queried_at < $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT `+balanceSelect+` FROM token_balances`+where+` ORDER BY queried_at, id`, args...)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b, err := scanBalance(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // GetDailyBalances returns the last recorded balance per (day, symbol) for a wallet,
 // ordered by day descending.
 func (s *Store) GetDailyBalances(ctx context.Context, wallet string) ([]DailyBalance, error) {
-	rows, err := s.pool.Query(ctx, `
-		SELECT DISTINCT ON (day_bucket, symbol)
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (day_bucket, %[1]s)
 			day_bucket AS day,
 			wallet,
 			token_address,
-			symbol,
+			%[1]s AS symbol,
 			decimals,
 			balance,
 			queried_at
 		FROM token_balances
 		WHERE wallet = $1
-		ORDER BY day_bucket DESC, symbol, queried_at DESC`,
+		ORDER BY day_bucket DESC, %[1]s, queried_at DESC`, s.series()),
 		wallet,
 	)
 	if err != nil {
@@ -176,14 +810,14 @@ func (s *Store) GetDailyReport(ctx context.Context, wallet string, days int) ([]
 	if days < 2 {
 		return nil, fmt.Errorf("days must be >= 2")
 	}
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
 		WITH ranked AS (
-			SELECT DISTINCT ON (day_bucket, symbol)
+			SELECT DISTINCT ON (day_bucket, %[1]s)
 				day_bucket,
-				symbol, token_address, balance
+				%[1]s AS symbol, token_address, balance
 			FROM token_balances
 			WHERE wallet = $1
-			ORDER BY day_bucket DESC, symbol, queried_at DESC
+			ORDER BY day_bucket DESC, %[1]s, queried_at DESC
 		),
 		recent_days AS (
 			SELECT day_bucket FROM ranked
@@ -194,7 +828,7 @@ func (s *Store) GetDailyReport(ctx context.Context, wallet string, days int) ([]
 		SELECT r.symbol, r.token_address, r.day_bucket, r.balance
 		FROM ranked r
 		WHERE r.day_bucket IN (SELECT day_bucket FROM recent_days)
-		ORDER BY r.symbol, r.day_bucket DESC`,
+		ORDER BY r.symbol, r.day_bucket DESC`, s.series()),
 		wallet, days,
 	)
 	if err != nil {
@@ -221,20 +855,62 @@ func (s *Store) GetDailyReport(ctx context.Context, wallet string, days int) ([]
 	return computeDailyReport(symbolOrder, bySymbol), nil
 }
 
+// GetYieldRate estimates the annualized growth rate of one token balance over
+// the trailing window, from the earliest and latest snapshots in it. token is
+// a symbol, a token address or, with SeriesByLabel, a label. The result is a
+// fraction (0.05 = 5% per year); for debt tokens it is the borrowing cost.
+// ErrInsufficientData is returned when fewer than two snapshots exist;
+// TransferSuspected is set on the result when a deposit, withdrawal, borrow or
+// repayment distorts the figure.
+func (s *Store) GetYieldRate(ctx context.Context, wallet, token string, window time.Duration) (YieldRate, error) {
+	if window <= 0 {
+		return YieldRate{}, fmt.Errorf("window must be positive")
+	}
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT %[1]s, queried_at, balance
+		FROM token_balances
+		WHERE wallet = $1
+		  AND (%[1]s = $2 OR symbol = $2 OR LOWER(token_address) = LOWER($2))
+		  AND queried_at >= $3
+		ORDER BY queried_at`, s.series()),
+		strings.ToLower(wallet), token, time.Now().Add(-window),
+	)
+	if err != nil {
+		return YieldRate{}, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var symbol string
+	var points []yieldPoint
+	for rows.Next() {
+		var p yieldPoint
+		if err := rows.Scan(&symbol, &p.queriedAt, &p.balance); err != nil {
+			return YieldRate{}, fmt.Errorf("scan failed: %w", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return YieldRate{}, err
+	}
+
+	return computeYieldRate(symbol, points)
+}
+
 // GetDailyPeriodYield returns the total yield per token over the last N day buckets for a wallet.
 // days must be >= 2 and <= 365.
 func (s *Store) GetDailyPeriodYield(ctx context.Context, wallet string, days int) ([]PeriodYield, error) {
 	if days < 2 {
 		return nil, fmt.Errorf("days must be >= 2")
 	}
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
 		WITH ranked AS (
-			SELECT DISTINCT ON (day_bucket, symbol)
+			SELECT DISTINCT ON (day_bucket, %[1]s)
 				day_bucket,
-				symbol, token_address, balance
+				%[1]s AS symbol, token_address, balance
 			FROM token_balances
 			WHERE wallet = $1
-			ORDER BY day_bucket DESC, symbol, queried_at DESC
+			ORDER BY day_bucket DESC, %[1]s, queried_at DESC
 		),
 		recent_days AS (
 			SELECT day_bucket FROM ranked
@@ -245,7 +921,7 @@ func (s *Store) GetDailyPeriodYield(ctx context.Context, wallet string, days int
 		SELECT r.symbol, r.token_address, r.day_bucket, r.balance
 		FROM ranked r
 		WHERE r.day_bucket IN (SELECT day_bucket FROM recent_days)
-		ORDER BY r.symbol, r.day_bucket DESC`,
+		ORDER BY r.symbol, r.day_bucket DESC`, s.series()),
 		wallet, days,
 	)
 	if err != nil {
@@ -277,14 +953,14 @@ func (s *Store) GetWeeklyPeriodYield(ctx context.Context, wallet string, weeks i
 	if weeks < 2 {
 		return nil, fmt.Errorf("weeks must be >= 2")
 	}
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
 		WITH ranked AS (
-			SELECT DISTINCT ON (week_bucket, symbol)
+			SELECT DISTINCT ON (week_bucket, %[1]s)
 				week_bucket,
-				symbol, token_address, balance
+				%[1]s AS symbol, token_address, balance
 			FROM token_balances
 			WHERE wallet = $1
-			ORDER BY week_bucket DESC, symbol, queried_at DESC
+			ORDER BY week_bucket DESC, %[1]s, queried_at DESC
 		),
 		recent_weeks AS (
 			SELECT week_bucket FROM ranked
@@ -295,7 +971,7 @@ func (s *Store) GetWeeklyPeriodYield(ctx context.Context, wallet string, weeks i
 		SELECT r.symbol, r.token_address, r.week_bucket, r.balance
 		FROM ranked r
 		WHERE r.week_bucket IN (SELECT week_bucket FROM recent_weeks)
-		ORDER BY r.symbol, r.week_bucket DESC`,
+		ORDER BY r.symbol, r.week_bucket DESC`, s.series()),
 		wallet, weeks,
 	)
 	if err != nil {
@@ -321,23 +997,62 @@ func (s *Store) GetWeeklyPeriodYield(ctx context.Context, wallet string, weeks i
 	return computeWeeklyPeriodYield(symbolOrder, bySymbol), nil
 }
 
+// GetWeeklyDeltas returns the week-over-week change of a token balance for a
+// wallet, oldest week first. Each week closes on its last stored balance and
+// opens on the close of the previous stored week, so the first stored week
+// is left out. Addresses are matched like in GetLatestBalance.
+func (s *Store) GetWeeklyDeltas(ctx context.Context, wallet, tokenAddress string) ([]BalanceDelta, error) {
+	rows, err := s.pool.Query(ctx, `
+		WITH closes AS (
+			SELECT DISTINCT ON (week_bucket) week_bucket, balance
+			FROM token_balances
+			WHERE wallet = $1 AND token_address = $2
+			ORDER BY week_bucket, queried_at DESC
+		),
+		deltas AS (
+			SELECT week_bucket, lag(balance) OVER (ORDER BY week_bucket) AS opening, balance AS closing
+			FROM closes
+		)
+		SELECT week_bucket, opening, closing
+		FROM deltas
+		WHERE opening IS NOT NULL
+		ORDER BY week_bucket`,
+		strings.ToLower(wallet), tokenAddress,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []BalanceDelta
+	for rows.Next() {
+		var week time.Time
+		var opening, closing decimal.Decimal
+		if err := rows.Scan(&week, &opening, &closing); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		results = append(results, newBalanceDelta(week, opening, closing))
+	}
+	return results, rows.Err()
+}
+
 // GetWeeklyBalances returns the last recorded balance per (week, symbol) for a wallet,
 // ordered by week descending.
 // Uses the stored week_bucket column + idx_token_balances_wallet_wbucket_symbol to avoid
 // a full sort on DATE_TRUNC.
 func (s *Store) GetWeeklyBalances(ctx context.Context, wallet string) ([]WeeklyBalance, error) {
-	rows, err := s.pool.Query(ctx, `
-		SELECT DISTINCT ON (week_bucket, symbol)
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (week_bucket, %[1]s)
 			week_bucket AS week,
 			wallet,
 			token_address,
-			symbol,
+			%[1]s AS symbol,
 			decimals,
 			balance,
 			queried_at
 		FROM token_balances
 		WHERE wallet = $1
-		ORDER BY week_bucket DESC, symbol, queried_at DESC`,
+		ORDER BY week_bucket DESC, %[1]s, queried_at DESC`, s.series()),
 		wallet,
 	)
 	if err != nil {
@@ -363,14 +1078,14 @@ func (s *Store) GetWeeklyReport(ctx context.Context, wallet string, weeks int) (
 	if weeks < 2 {
 		return nil, fmt.Errorf("weeks must be >= 2")
 	}
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
 		WITH ranked AS (
-			SELECT DISTINCT ON (week_bucket, symbol)
+			SELECT DISTINCT ON (week_bucket, %[1]s)
 				week_bucket,
-				symbol, token_address, balance
+				%[1]s AS symbol, token_address, balance
 			FROM token_balances
 			WHERE wallet = $1
-			ORDER BY week_bucket DESC, symbol, queried_at DESC
+			ORDER BY week_bucket DESC, %[1]s, queried_at DESC
 		),
 		recent_weeks AS (
 			SELECT week_bucket FROM ranked
@@ -381,7 +1096,7 @@ func (s *Store) GetWeeklyReport(ctx context.Context, wallet string, weeks int) (
 		SELECT r.symbol, r.token_address, r.week_bucket, r.balance
 		FROM ranked r
 		WHERE r.week_bucket IN (SELECT week_bucket FROM recent_weeks)
-		ORDER BY r.symbol, r.week_bucket DESC`,
+		ORDER BY r.symbol, r.week_bucket DESC`, s.series()),
 		wallet, weeks,
 	)
 	if err != nil {
@@ -437,6 +1152,19 @@ func (s *Store) GetLastRun(ctx context.Context) (time.Time, bool, error) {
 	return at, ok, err
 }
 
+// LatestQueriedAt returns the newest queried_at in token_balances, the zero
+// time when no balance is stored.
+func (s *Store) LatestQueriedAt(ctx context.Context) (time.Time, error) {
+	var at *time.Time
+	if err := s.pool.QueryRow(ctx, `SELECT max(queried_at) FROM token_balances`).Scan(&at); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read latest queried_at: %w", err)
+	}
+	if at == nil {
+		return time.Time{}, nil
+	}
+	return *at, nil
+}
+
 // GetDashboardSummary returns the count of distinct wallets and token symbols.
 // Results are cached for dashboardCacheTTL and invalidated by SetLastRun.
 func (s *Store) GetDashboardSummary(ctx context.Context) (DashboardSummary, error) {
@@ -449,9 +1177,9 @@ func (s *Store) GetDashboardSummary(ctx context.Context) (DashboardSummary, erro
 	s.dashCacheMu.RUnlock()
 
 	var d DashboardSummary
-	err := s.pool.QueryRow(ctx, `
-		SELECT COUNT(DISTINCT wallet), COUNT(DISTINCT symbol)
-		FROM token_balances`).
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT COUNT(DISTINCT wallet), COUNT(DISTINCT %s)
+		FROM token_balances`, s.series())).
 		Scan(&d.WalletCount, &d.TokenCount)
 	if err != nil {
 		return d, err
@@ -467,15 +1195,15 @@ func (s *Store) GetDashboardSummary(ctx context.Context) (DashboardSummary, erro
 
 // GetLatestBalances returns the most recent balance for each token symbol for a wallet.
 func (s *Store) GetLatestBalances(ctx context.Context, wallet string) ([]LatestBalance, error) {
-	rows, err := s.pool.Query(ctx, `
-		SELECT DISTINCT ON (symbol)
-			symbol,
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (%[1]s)
+			%[1]s AS symbol,
 			token_address,
 			balance,
 			queried_at
 		FROM token_balances
 		WHERE wallet = $1
-		ORDER BY symbol, queried_at DESC`,
+		ORDER BY %[1]s, queried_at DESC`, s.series()),
 		wallet,
 	)
 	if err != nil {
@@ -495,7 +1223,8 @@ func (s *Store) GetLatestBalances(ctx context.Context, wallet string) ([]LatestB
 	return results, rows.Err()
 }
 
-// GetWallets returns distinct wallet addresses stored in the database.
+// GetWallets returns the distinct wallet addresses stored in the database,
+// in alphabetical order.
 func (s *Store) GetWallets(ctx context.Context) ([]string, error) {
 	rows, err := s.pool.Query(ctx, `SELECT DISTINCT wallet FROM token_balances ORDER BY wallet`)
 	if err != nil {
@@ -514,3 +1243,84 @@ func (s *Store) GetWallets(ctx context.Context) ([]string, error) {
 
 	return wallets, rows.Err()
 }
+
+// GetTokens returns every token address found in token_balances, with the
+// symbol and decimals of its most recent row, ordered by address. Unlike the
+// configuration it covers every token ever stored.
+func (s *Store) GetTokens(ctx context.Context) ([]StoredToken, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (token_address) token_address, symbol, decimals
+		FROM token_balances
+		ORDER BY token_address, queried_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []StoredToken
+	for rows.Next() {
+		var t StoredToken
+		if err := rows.Scan(&t.Address, &t.Symbol, &t.Decimals); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetStoredBlocks returns the block numbers between from and to, inclusive,
+// that already have balance rows.
+func (s *Store) GetStoredBlocks(ctx context.Context, from, to uint64) (map[uint64]bool, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT block_number
+		FROM token_balances
+		WHERE block_number BETWEEN $1 AND $2`, int64(from), int64(to))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	blocks := make(map[uint64]bool)
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		blocks[uint64(n)] = true
+	}
+
+	return blocks, rows.Err()
+}
+
+// pruneBatchSize is the number of rows deleted per statement by
+// PruneOlderThan.
+const pruneBatchSize = 10000
+
+// PruneOlderThan deletes the balances queried before cutoff and returns the
+// number of rows deleted. Rows go in batches, each its own statement, so a
+// large first prune never holds its locks for long.
+func (s *Store) PruneOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.pruneOlderThan(ctx, cutoff, pruneBatchSize)
+}
+
+func (s *Store) pruneOlderThan(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	var deleted int64
+	for {
+		tag, err := s.pool.Exec(ctx, `
+			DELETE FROM token_balances
+			WHERE id IN (
+				SELECT id FROM token_balances
+				WHERE queried_at < $1
+				ORDER BY id
+				LIMIT $2
+			)`, cutoff, batchSize)
+		if err != nil {
+			return deleted, fmt.Errorf("delete failed: %w", err)
+		}
+		deleted += tag.RowsAffected()
+		if tag.RowsAffected() < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
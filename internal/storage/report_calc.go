@@ -1,12 +1,91 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+// ErrInsufficientData is returned when a window holds too few usable balances
+// to estimate a rate.
+var ErrInsufficientData = errors.New("insufficient data")
+
+// transferJumpThreshold is the largest relative increase per day between two
+// consecutive snapshots still attributed to interest; snapshots less than a
+// day apart get the full daily allowance. Any decrease is treated as a
+// transfer: supply and debt balances only grow through interest.
+var transferJumpThreshold = decimal.RequireFromString("0.01")
+
+// yieldPoint is one balance snapshot used by computeYieldRate.
+type yieldPoint struct {
+	queriedAt time.Time
+	balance   decimal.Decimal
+}
+
+// isDebtToken reports whether symbol names a debt token (e.g. armmXDAIDEBT,
+// debtrmmWXDAI), whose growth is borrowing cost rather than yield.
+func isDebtToken(symbol string) bool {
+	return strings.Contains(strings.ToLower(symbol), "debt")
+}
+
+// computeYieldRate returns the simple annualized growth rate between the
+// first and last points (ordered oldest first), as a fraction (0.05 = 5%/yr).
+// For debt tokens the rate is the annualized borrowing cost. A step that looks
+// like a transfer sets TransferSuspected on the result.
+func computeYieldRate(symbol string, points []yieldPoint) (YieldRate, error) {
+	if len(points) < 2 {
+		return YieldRate{}, ErrInsufficientData
+	}
+	first, last := points[0], points[len(points)-1]
+	elapsed := last.queriedAt.Sub(first.queriedAt)
+	if elapsed <= 0 || !first.balance.IsPositive() {
+		return YieldRate{}, ErrInsufficientData
+	}
+
+	growth := last.balance.Sub(first.balance).Div(first.balance)
+	year := decimal.NewFromInt(int64(365 * 24 * time.Hour))
+	result := YieldRate{
+		Symbol: symbol,
+		From:   first.queriedAt,
+		To:     last.queriedAt,
+		Rate:   growth.Mul(year).Div(decimal.NewFromInt(int64(elapsed))),
+	}
+
+	day := decimal.NewFromInt(int64(24 * time.Hour))
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		step := cur.balance.Sub(prev.balance)
+
+		var kind string
+		switch {
+		case step.IsNegative():
+			kind = "withdrawal"
+			if isDebtToken(symbol) {
+				kind = "repayment"
+			}
+			step = step.Neg()
+		case prev.balance.IsPositive():
+			days := decimal.Max(decimal.NewFromInt(int64(cur.queriedAt.Sub(prev.queriedAt))).Div(day), decimal.NewFromInt(1))
+			if step.Div(prev.balance).GreaterThan(transferJumpThreshold.Mul(days)) {
+				kind = "deposit"
+				if isDebtToken(symbol) {
+					kind = "borrow"
+				}
+			}
+		}
+		if kind != "" {
+			result.TransferSuspected = true
+			result.Transfer = fmt.Sprintf("%s of %s at %s", kind, step, cur.queriedAt.Format(time.RFC3339))
+			break
+		}
+	}
+	return result, nil
+}
+
 // dayEntry holds one data point for a token returned by the daily CTE query.
 // Rows are ordered day_bucket DESC (newest first) within each symbol group.
 type dayEntry struct {
@@ -229,3 +308,20 @@ func computeWeeklyReport(symbolOrder []string, bySymbol map[string][]weekEntry)
 
 	return results
 }
+
+// newBalanceDelta computes the change from opening to closing over the week
+// starting at weekStart. ChangePercent is zero when the week opens at zero.
+func newBalanceDelta(weekStart time.Time, opening, closing decimal.Decimal) BalanceDelta {
+	change := closing.Sub(opening)
+	var changePercent decimal.Decimal
+	if !opening.IsZero() {
+		changePercent = change.Div(opening).Mul(decimal.NewFromInt(100))
+	}
+	return BalanceDelta{
+		WeekStart:      weekStart,
+		OpeningBalance: opening,
+		ClosingBalance: closing,
+		Change:         change,
+		ChangePercent:  changePercent,
+	}
+}
@@ -317,3 +317,113 @@ func TestComputeWeeklyReport_SkipsEmptyEntries(t *testing.T) {
 	require.Len(t, results, 1)
 	assert.Equal(t, "REAL", results[0].Symbol)
 }
+
+// --- computeYieldRate ---
+
+// growingSeries returns n daily snapshots growing at a constant simple rate
+// of annualRate per year from start.
+func growingSeries(start decimal.Decimal, annualRate string, n int) []yieldPoint {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	perDay := dec(annualRate).Div(decimal.NewFromInt(365))
+	points := make([]yieldPoint, n)
+	for i := range n {
+		growth := decimal.NewFromInt(1).Add(perDay.Mul(decimal.NewFromInt(int64(i))))
+		points[i] = yieldPoint{
+			queriedAt: t0.Add(time.Duration(i) * 24 * time.Hour),
+			balance:   start.Mul(growth),
+		}
+	}
+	return points
+}
+
+func TestComputeYieldRate_SteadyGrowth(t *testing.T) {
+	points := growingSeries(dec("1000"), "0.05", 31)
+	got, err := computeYieldRate("armmXDAI", points)
+	require.NoError(t, err)
+	assertDecimalApprox(t, dec("0.05"), got.Rate, "0.000001")
+	assert.False(t, got.TransferSuspected)
+	assert.Equal(t, points[0].queriedAt, got.From)
+	assert.Equal(t, points[30].queriedAt, got.To)
+}
+
+func TestComputeYieldRate_DebtTokenGrowth(t *testing.T) {
+	got, err := computeYieldRate("armmXDAIDEBT", growingSeries(dec("500"), "0.12", 8))
+	require.NoError(t, err)
+	assertDecimalApprox(t, dec("0.12"), got.Rate, "0.000001")
+	assert.False(t, got.TransferSuspected)
+}
+
+func TestComputeYieldRate_InsufficientData(t *testing.T) {
+	_, err := computeYieldRate("armmXDAI", nil)
+	assert.ErrorIs(t, err, ErrInsufficientData)
+
+	_, err = computeYieldRate("armmXDAI", growingSeries(dec("1000"), "0.05", 1))
+	assert.ErrorIs(t, err, ErrInsufficientData)
+
+	_, err = computeYieldRate("armmXDAI", growingSeries(decimal.Zero, "0.05", 5))
+	assert.ErrorIs(t, err, ErrInsufficientData)
+}
+
+func TestComputeYieldRate_FlagsDeposit(t *testing.T) {
+	points := growingSeries(dec("1000"), "0.05", 10)
+	for i := 5; i < len(points); i++ {
+		points[i].balance = points[i].balance.Add(dec("500"))
+	}
+
+	got, err := computeYieldRate("armmXDAI", points)
+	require.NoError(t, err)
+	assert.True(t, got.TransferSuspected)
+	assert.Contains(t, got.Transfer, "deposit")
+	assert.True(t, got.Rate.GreaterThan(dec("1")), "distorted rate is still returned")
+}
+
+func TestComputeYieldRate_FlagsWithdrawal(t *testing.T) {
+	points := growingSeries(dec("1000"), "0.05", 10)
+	points[9].balance = dec("100")
+
+	got, err := computeYieldRate("armmUSDC", points)
+	require.NoError(t, err)
+	assert.True(t, got.TransferSuspected)
+	assert.Contains(t, got.Transfer, "withdrawal")
+}
+
+func TestComputeYieldRate_DebtRepaymentIsNotYield(t *testing.T) {
+	points := growingSeries(dec("500"), "0.12", 10)
+	points[9].balance = dec("250")
+
+	got, err := computeYieldRate("debtrmmWXDAI", points)
+	require.NoError(t, err)
+	assert.True(t, got.TransferSuspected)
+	assert.Contains(t, got.Transfer, "repayment")
+}
+
+func TestComputeYieldRate_ScalesJumpThresholdWithGap(t *testing.T) {
+	// Tracker down for 11 days at 50%/yr: a ~1.5% step in one gap is interest
+	points := growingSeries(dec("1000"), "0.50", 12)
+	points = append(points[:1], points[11:]...)
+
+	got, err := computeYieldRate("armmXDAI", points)
+	require.NoError(t, err)
+	assert.False(t, got.TransferSuspected)
+	assertDecimalApprox(t, dec("0.50"), got.Rate, "0.000001")
+
+	// The same step within a single day is not
+	points[1].queriedAt = points[0].queriedAt.Add(12 * time.Hour)
+	got, err = computeYieldRate("armmXDAI", points)
+	require.NoError(t, err)
+	assert.True(t, got.TransferSuspected)
+}
+
+// --- newBalanceDelta ---
+
+func TestNewBalanceDelta(t *testing.T) {
+	week := monday(2026, time.February, 23)
+	d := newBalanceDelta(week, dec("1000.000000000000000001"), dec("1001.500000000000000002"))
+	assert.Equal(t, week, d.WeekStart)
+	assertDecEqual(t, "1.500000000000000001", d.Change, "18 decimals are kept exactly")
+	assertDecEqual(t, "0.15", d.ChangePercent.Round(2))
+
+	d = newBalanceDelta(week, dec("0"), dec("5"))
+	assertDecEqual(t, "5", d.Change)
+	assert.True(t, d.ChangePercent.IsZero(), "no percentage from a zero opening")
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ColumnSchema describes a table column as reported by information_schema.
+type ColumnSchema struct {
+	Name     string
+	DataType string
+	Nullable bool
+}
+
+// TableSchema is the introspected (or expected) shape of a table.
+type TableSchema struct {
+	Table   string
+	Columns []ColumnSchema
+	Indexes []string
+}
+
+// SchemaDifference is a single mismatch between expected and live schema.
+type SchemaDifference struct {
+	Kind     string // missing_column, unexpected_column, type_mismatch, nullability_mismatch, missing_index, unexpected_index
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (d SchemaDifference) String() string {
+	switch d.Kind {
+	case "type_mismatch", "nullability_mismatch":
+		return fmt.Sprintf("%s %s: expected %s, got %s", d.Kind, d.Name, d.Expected, d.Actual)
+	default:
+		return fmt.Sprintf("%s %s", d.Kind, d.Name)
+	}
+}
+
+// ExpectedTokenBalancesSchema returns the token_balances shape produced by
+// the embedded migrations, derived by replaying their Up sections.
+func ExpectedTokenBalancesSchema() (TableSchema, error) {
+	return deriveTableSchema(migrations, "token_balances")
+}
+
+// IntrospectTable reads the live columns and indexes of a table in the
+// current schema.
+func (s *Store) IntrospectTable(ctx context.Context, table string) (TableSchema, error) {
+	ts := TableSchema{Table: table}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT column_name, data_type, is_nullable = 'YES'
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`,
+		table,
+	)
+	if err != nil {
+		return ts, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c ColumnSchema
+		if err := rows.Scan(&c.Name, &c.DataType, &c.Nullable); err != nil {
+			return ts, fmt.Errorf("scan failed: %w", err)
+		}
+		ts.Columns = append(ts.Columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return ts, err
+	}
+	if len(ts.Columns) == 0 {
+		return ts, fmt.Errorf("table %q not found", table)
+	}
+
+	idxRows, err := s.pool.Query(ctx, `
+		SELECT indexname FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = $1
+		ORDER BY indexname`,
+		table,
+	)
+	if err != nil {
+		return ts, fmt.Errorf("query failed: %w", err)
+	}
+	defer idxRows.Close()
+
+	for idxRows.Next() {
+		var name string
+		if err := idxRows.Scan(&name); err != nil {
+			return ts, fmt.Errorf("scan failed: %w", err)
+		}
+		ts.Indexes = append(ts.Indexes, name)
+	}
+
+	return ts, idxRows.Err()
+}
+
+// DiffSchema compares a live schema against the expected one. Differences are
+// returned sorted by kind then name for stable output.
+func DiffSchema(expected, actual TableSchema) []SchemaDifference {
+	var diffs []SchemaDifference
+
+	actualCols := make(map[string]ColumnSchema, len(actual.Columns))
+	for _, c := range actual.Columns {
+		actualCols[c.Name] = c
+	}
+	expectedCols := make(map[string]bool, len(expected.Columns))
+	for _, want := range expected.Columns {
+		expectedCols[want.Name] = true
+		got, ok := actualCols[want.Name]
+		if !ok {
+			diffs = append(diffs, SchemaDifference{Kind: "missing_column", Name: want.Name, Expected: want.DataType})
+			continue
+		}
+		if got.DataType != want.DataType {
+			diffs = append(diffs, SchemaDifference{Kind: "type_mismatch", Name: want.Name, Expected: want.DataType, Actual: got.DataType})
+		}
+		if got.Nullable != want.Nullable {
+			diffs = append(diffs, SchemaDifference{
+				Kind:     "nullability_mismatch",
+				Name:     want.Name,
+				Expected: nullability(want.Nullable),
+				Actual:   nullability(got.Nullable),
+			})
+		}
+	}
+	for _, c := range actual.Columns {
+		if !expectedCols[c.Name] {
+			diffs = append(diffs, SchemaDifference{Kind: "unexpected_column", Name: c.Name, Actual: c.DataType})
+		}
+	}
+
+	actualIdx := make(map[string]bool, len(actual.Indexes))
+	for _, name := range actual.Indexes {
+		actualIdx[name] = true
+	}
+	expectedIdx := make(map[string]bool, len(expected.Indexes))
+	for _, name := range expected.Indexes {
+		expectedIdx[name] = true
+		if !actualIdx[name] {
+			diffs = append(diffs, SchemaDifference{Kind: "missing_index", Name: name})
+		}
+	}
+	for _, name := range actual.Indexes {
+		if !expectedIdx[name] {
+			diffs = append(diffs, SchemaDifference{Kind: "unexpected_index", Name: name})
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	lineComment = regexp.MustCompile(`--[^\n]*`)
+	createTable = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+)\s*\((.*)\)$`)
+	alterTable  = regexp.MustCompile(`(?is)^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?(\w+)\s+(.*)$`)
+	createIndex = regexp.MustCompile(`(?is)^CREATE (?:UNIQUE )?INDEX (?:CONCURRENTLY )?(?:IF NOT EXISTS )?(\w+)\s+ON (\w+)`)
+	dropIndex   = regexp.MustCompile(`(?is)^DROP INDEX (?:CONCURRENTLY )?(?:IF EXISTS )?(\w+)`)
+
+	addColumn   = regexp.MustCompile(`(?is)^ADD COLUMN (?:IF NOT EXISTS )?(\w+)\s+(.*)$`)
+	dropColumn  = regexp.MustCompile(`(?is)^DROP COLUMN (?:IF EXISTS )?(\w+)`)
+	columnType  = regexp.MustCompile(`(?is)^ALTER COLUMN (\w+) (?:SET DATA )?TYPE (.*)$`)
+	setNotNull  = regexp.MustCompile(`(?is)^ALTER COLUMN (\w+) SET NOT NULL$`)
+	dropNotNull = regexp.MustCompile(`(?is)^ALTER COLUMN (\w+) DROP NOT NULL$`)
+
+	// Keywords ending the type part of a column definition
+	typeEnd = regexp.MustCompile(`(?i)\s(?:NOT NULL|NULL|PRIMARY KEY|DEFAULT|GENERATED|REFERENCES|UNIQUE|CHECK|CONSTRAINT|COLLATE|USING)\b`)
+)
+
+// sqlDataTypes maps SQL type names to information_schema data_type values.
+var sqlDataTypes = map[string]string{
+	"bigserial":                   "bigint",
+	"bigint":                      "bigint",
+	"int8":                        "bigint",
+	"serial":                      "integer",
+	"integer":                     "integer",
+	"int":                         "integer",
+	"int4":                        "integer",
+	"smallint":                    "smallint",
+	"int2":                        "smallint",
+	"text":                        "text",
+	"varchar":                     "character varying",
+	"character varying":           "character varying",
+	"boolean":                     "boolean",
+	"bool":                        "boolean",
+	"numeric":                     "numeric",
+	"decimal":                     "numeric",
+	"timestamptz":                 "timestamp with time zone",
+	"timestamp with time zone":    "timestamp with time zone",
+	"timestamp":                   "timestamp without time zone",
+	"timestamp without time zone": "timestamp without time zone",
+	"jsonb":                       "jsonb",
+}
+
+// deriveTableSchema replays the Up sections of the migrations in fsys
+// (migrations/*.sql, applied in name order) and returns the resulting shape
+// of table. Statements touching the table in a form it does not understand
+// are reported as errors rather than skipped.
+func deriveTableSchema(fsys fs.FS, table string) (TableSchema, error) {
+	ts := TableSchema{Table: table}
+
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return ts, err
+	}
+	slices.Sort(files)
+
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return ts, err
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		up = lineComment.ReplaceAllString(up, "")
+
+		for stmt := range strings.SplitSeq(up, ";") {
+			stmt = strings.Join(strings.Fields(stmt), " ")
+			if stmt == "" {
+				continue
+			}
+			if err := applyStatement(&ts, stmt); err != nil {
+				return ts, fmt.Errorf("%s: %w", f, err)
+			}
+		}
+	}
+
+	if len(ts.Columns) == 0 {
+		return ts, fmt.Errorf("no migration creates table %q", table)
+	}
+	return ts, nil
+}
+
+// applyStatement updates ts with the effect of one DDL statement.
+func applyStatement(ts *TableSchema, stmt string) error {
+	if m := createTable.FindStringSubmatch(stmt); m != nil {
+		if m[1] != ts.Table {
+			return nil
+		}
+		for _, def := range splitTopLevel(m[2]) {
+			name, rest, _ := strings.Cut(def, " ")
+			if strings.EqualFold(name, "CONSTRAINT") || strings.EqualFold(name, "PRIMARY") {
+				return fmt.Errorf("unsupported table constraint %q", def)
+			}
+			if err := addColumnDef(ts, name, rest); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if m := alterTable.FindStringSubmatch(stmt); m != nil {
+		if m[1] != ts.Table {
+			return nil
+		}
+		for _, action := range splitTopLevel(m[2]) {
+			if err := applyAlterAction(ts, action); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if m := createIndex.FindStringSubmatch(stmt); m != nil {
+		if m[2] == ts.Table && !slices.Contains(ts.Indexes, m[1]) {
+			ts.Indexes = append(ts.Indexes, m[1])
+		}
+		return nil
+	}
+
+	if m := dropIndex.FindStringSubmatch(stmt); m != nil {
+		ts.Indexes = slices.DeleteFunc(ts.Indexes, func(name string) bool { return name == m[1] })
+	}
+	return nil
+}
+
+func applyAlterAction(ts *TableSchema, action string) error {
+	if m := addColumn.FindStringSubmatch(action); m != nil {
+		if ts.column(m[1]) != nil {
+			return nil // ADD COLUMN IF NOT EXISTS on an existing column
+		}
+		return addColumnDef(ts, m[1], m[2])
+	}
+	if m := dropColumn.FindStringSubmatch(action); m != nil {
+		ts.Columns = slices.DeleteFunc(ts.Columns, func(c ColumnSchema) bool { return c.Name == m[1] })
+		return nil
+	}
+	if m := columnType.FindStringSubmatch(action); m != nil {
+		col := ts.column(m[1])
+		if col == nil {
+			return fmt.Errorf("ALTER COLUMN on unknown column %q", m[1])
+		}
+		dataType, err := parseDataType(m[2])
+		if err != nil {
+			return err
+		}
+		col.DataType = dataType
+		return nil
+	}
+	for _, rule := range []struct {
+		re       *regexp.Regexp
+		nullable bool
+	}{{setNotNull, false}, {dropNotNull, true}} {
+		if m := rule.re.FindStringSubmatch(action); m != nil {
+			col := ts.column(m[1])
+			if col == nil {
+				return fmt.Errorf("ALTER COLUMN on unknown column %q", m[1])
+			}
+			col.Nullable = rule.nullable
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported ALTER TABLE %s action %q", ts.Table, action)
+}
+
+// addColumnDef appends a column from its definition (type and constraints).
+func addColumnDef(ts *TableSchema, name, def string) error {
+	dataType, err := parseDataType(def)
+	if err != nil {
+		return fmt.Errorf("column %s: %w", name, err)
+	}
+	upper := strings.ToUpper(def)
+	primaryKey := strings.Contains(upper, "PRIMARY KEY")
+	ts.Columns = append(ts.Columns, ColumnSchema{
+		Name:     name,
+		DataType: dataType,
+		Nullable: !primaryKey && !strings.Contains(upper, "NOT NULL"),
+	})
+	if primaryKey {
+		ts.Indexes = append(ts.Indexes, ts.Table+"_pkey")
+	}
+	return nil
+}
+
+// parseDataType extracts the type from a column definition or a TYPE clause
+// and returns its information_schema name.
+func parseDataType(def string) (string, error) {
+	typ := " " + def
+	if loc := typeEnd.FindStringIndex(typ); loc != nil {
+		typ = typ[:loc[0]]
+	}
+	if i := strings.Index(typ, "("); i >= 0 {
+		typ = typ[:i] // drop precision/length
+	}
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	dataType, ok := sqlDataTypes[typ]
+	if !ok {
+		return "", fmt.Errorf("unsupported column type %q", typ)
+	}
+	return dataType, nil
+}
+
+// splitTopLevel splits s on commas that are not inside parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+func (ts *TableSchema) column(name string) *ColumnSchema {
+	for i := range ts.Columns {
+		if ts.Columns[i].Name == name {
+			return &ts.Columns[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSchema_Identical(t *testing.T) {
+	expected := tokenBalancesSchema
+	assert.Empty(t, DiffSchema(expected, expected))
+}
+
+func TestDiffSchema_ReportsDrift(t *testing.T) {
+	expected := tokenBalancesSchema
+
+	actual := TableSchema{Table: expected.Table}
+	for _, c := range expected.Columns {
+		switch c.Name {
+		case "day_bucket":
+			continue // dropped manually
+		case "balance":
+			c.DataType = "text" // migration 002 never applied
+		case "symbol":
+			c.Nullable = true
+		}
+		actual.Columns = append(actual.Columns, c)
+	}
+	actual.Columns = append(actual.Columns, ColumnSchema{Name: "note", DataType: "text", Nullable: true})
+	for _, idx := range expected.Indexes {
+		if idx != "idx_token_balances_wallet_dbucket_symbol" {
+			actual.Indexes = append(actual.Indexes, idx)
+		}
+	}
+	actual.Indexes = append(actual.Indexes, "idx_manual_hotfix")
+
+	diffs := DiffSchema(expected, actual)
+
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.String())
+	}
+	assert.Equal(t, []string{
+		"missing_column day_bucket",
+		"missing_index idx_token_balances_wallet_dbucket_symbol",
+		"nullability_mismatch symbol: expected NOT NULL, got NULL",
+		"type_mismatch balance: expected numeric, got text",
+		"unexpected_column note",
+		"unexpected_index idx_manual_hotfix",
+	}, got)
+}
+
+// tokenBalancesSchema is the reviewed shape of token_balances after all
+// migrations. Update it together with any migration touching the table.
+var tokenBalancesSchema = TableSchema{
+	Table: "token_balances",
+	Columns: []ColumnSchema{
+		{Name: "id", DataType: "bigint"},
+		{Name: "queried_at", DataType: "timestamp with time zone"},
+		{Name: "wallet", DataType: "text"},
+		{Name: "token_address", DataType: "text"},
+		{Name: "symbol", DataType: "text"},
+		{Name: "decimals", DataType: "smallint"},
+		{Name: "raw_balance", DataType: "text"},
+		{Name: "balance", DataType: "numeric"},
+		{Name: "week_bucket", DataType: "timestamp with time zone", Nullable: true},
+		{Name: "day_bucket", DataType: "timestamp with time zone", Nullable: true},
+		{Name: "source", DataType: "text"},
+		{Name: "block_timestamp", DataType: "timestamp with time zone", Nullable: true},
+		{Name: "label", DataType: "text", Nullable: true},
+		{Name: "tags", DataType: "jsonb", Nullable: true},
+		{Name: "usd_value", DataType: "numeric", Nullable: true},
+		{Name: "carried_forward", DataType: "boolean"},
+		{Name: "block_number", DataType: "bigint", Nullable: true},
+		{Name: "fetch_latency_ms", DataType: "integer", Nullable: true},
+		{Name: "wallet_label", DataType: "text", Nullable: true},
+		{Name: "token_name", DataType: "text", Nullable: true},
+	},
+	Indexes: []string{
+		"token_balances_pkey",
+		"idx_token_balances_wallet_token_time",
+		"idx_token_balances_queried_at",
+		"idx_token_balances_wallet",
+		"idx_token_balances_wallet_wbucket_symbol",
+		"idx_token_balances_wallet_symbol_time",
+		"idx_token_balances_wallet_dbucket_symbol",
+		"idx_token_balances_tags",
+		"idx_token_balances_wallet_token_queried_at",
+	},
+}
+
+func TestExpectedTokenBalancesSchema_DerivedFromMigrations(t *testing.T) {
+	expected, err := ExpectedTokenBalancesSchema()
+	require.NoError(t, err)
+	assert.Empty(t, DiffSchema(tokenBalancesSchema, expected))
+}
+
+func TestDeriveTableSchema(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_create.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE IF NOT EXISTS items (
+    id BIGSERIAL PRIMARY KEY,
+    price NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    label TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_items_label ON items(label);
+CREATE TABLE other (id INT NOT NULL);
+CREATE INDEX idx_other_id ON other(id);
+
+-- +goose Down
+DROP TABLE items;
+`)},
+		"migrations/002_alter.sql": {Data: []byte(`-- +goose Up
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ALTER COLUMN label SET NOT NULL;
+ALTER TABLE items ALTER COLUMN price TYPE TEXT USING price::TEXT;
+ALTER TABLE items DROP COLUMN IF EXISTS created_at;
+DROP INDEX IF EXISTS idx_items_label;
+
+-- +goose Down
+ALTER TABLE items ADD COLUMN ignored TEXT;
+`)},
+	}
+
+	got, err := deriveTableSchema(fsys, "items")
+	require.NoError(t, err)
+	assert.Equal(t, TableSchema{
+		Table: "items",
+		Columns: []ColumnSchema{
+			{Name: "id", DataType: "bigint"},
+			{Name: "price", DataType: "text"},
+			{Name: "label", DataType: "text"},
+		},
+		Indexes: []string{"items_pkey"},
+	}, got)
+}
+
+func TestDeriveTableSchema_RejectsUnsupportedStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+	}{
+		{"unknown type", "CREATE TABLE items (id UUID NOT NULL);"},
+		{"rename column", "CREATE TABLE items (id INT); ALTER TABLE items RENAME COLUMN id TO item_id;"},
+		{"table constraint", "CREATE TABLE items (id INT, PRIMARY KEY (id));"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"migrations/001.sql": {Data: []byte("-- +goose Up\n" + tt.sql)},
+			}
+			_, err := deriveTableSchema(fsys, "items")
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RefreshSnapshotSummary brings wallet_snapshot_summary up to date with the
+// balances inserted since the last refresh. Every (wallet, minute) snapshot
+// touched by a new row is recomputed from token_balances, so late or
+// backfilled rows are folded into the right snapshot. It returns the number
+// of snapshots written.
+func (s *Store) RefreshSnapshotSummary(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		WITH touched AS (
+			SELECT DISTINCT wallet, date_trunc('minute', queried_at) AS snapshot_at
+			FROM token_balances
+			WHERE id > (SELECT COALESCE(MAX(last_balance_id), 0) FROM wallet_snapshot_summary)
+		)
+		INSERT INTO wallet_snapshot_summary
+			(wallet, snapshot_at, total_supply, total_debt, token_count, last_balance_id)
+		SELECT
+			t.wallet,
+			t.snapshot_at,
+			COALESCE(SUM(tb.balance) FILTER (WHERE tb.symbol NOT ILIKE '%debt%'), 0),
+			COALESCE(SUM(tb.balance) FILTER (WHERE tb.symbol ILIKE '%debt%'), 0),
+			COUNT(*),
+			MAX(tb.id)
+		FROM touched t
+		JOIN token_balances tb
+		  ON tb.wallet = t.wallet
+		 AND tb.queried_at >= t.snapshot_at
+		 AND tb.queried_at < t.snapshot_at + INTERVAL '1 minute'
+		GROUP BY t.wallet, t.snapshot_at
+		ON CONFLICT (wallet, snapshot_at) DO UPDATE SET
+			total_supply    = EXCLUDED.total_supply,
+			total_debt      = EXCLUDED.total_debt,
+			token_count     = EXCLUDED.token_count,
+			last_balance_id = EXCLUDED.last_balance_id`)
+	if err != nil {
+		return 0, fmt.Errorf("refresh snapshot summary failed: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetSnapshotSummaries returns the per-snapshot supply and debt totals of a
+// wallet with snapshot_at in [from, to), oldest first.
+func (s *Store) GetSnapshotSummaries(ctx context.Context, wallet string, from, to time.Time) ([]SnapshotSummary, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT wallet, snapshot_at, total_supply, total_debt, token_count
+		FROM wallet_snapshot_summary
+		WHERE wallet = $1
+		  AND snapshot_at >= $2
+		  AND snapshot_at < $3
+		ORDER BY snapshot_at`,
+		strings.ToLower(wallet), from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []SnapshotSummary
+	for rows.Next() {
+		var sum SnapshotSummary
+		if err := rows.Scan(&sum.Wallet, &sum.SnapshotAt, &sum.TotalSupply, &sum.TotalDebt, &sum.TokenCount); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		summaries = append(summaries, sum)
+	}
+
+	return summaries, rows.Err()
+}
@@ -9,7 +9,9 @@ import (
 type Commander interface {
 	// BatchInsertBalances persists a batch of token balances and updates
 	// tracker_metadata.last_run_at with the MAX queried_at from the batch.
-	BatchInsertBalances(ctx context.Context, balances []TokenBalance) error
+	// It returns the balances actually written, in batch order: those left
+	// out (no raw balance, unchanged with dedup_unchanged) are not.
+	BatchInsertBalances(ctx context.Context, balances []TokenBalance) ([]TokenBalance, error)
 	// SetLastRunStatus records whether the last tracker run succeeded or failed.
 	// last_run_at is managed by BatchInsertBalances; this only updates succeeded.
 	SetLastRunStatus(ctx context.Context, succeeded bool) error
@@ -0,0 +1,137 @@
+// Package stream pushes newly persisted balances to HTTP clients as
+// Server-Sent Events.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+)
+
+// DefaultBufferSize is the number of events queued per client before new
+// events are dropped for that client.
+const DefaultBufferSize = 256
+
+// keepAliveInterval keeps idle connections open through proxies.
+const keepAliveInterval = 30 * time.Second
+
+type subscriber struct {
+	wallet string // lowercase; empty matches every wallet
+	events chan storage.TokenBalance
+}
+
+// Broker fans balances out to subscribed SSE clients.
+type Broker struct {
+	bufferSize int
+
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+// NewBroker creates a Broker with a per-client buffer of bufferSize events.
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Broker{bufferSize: bufferSize, subs: make(map[*subscriber]struct{})}
+}
+
+// Publish sends balances to every matching subscriber without blocking.
+// A client whose buffer is full misses the events that do not fit.
+func (b *Broker) Publish(balances []storage.TokenBalance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		dropped := 0
+		for _, bal := range balances {
+			if sub.wallet != "" && sub.wallet != strings.ToLower(bal.Wallet) {
+				continue
+			}
+			select {
+			case sub.events <- bal:
+			default:
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			slog.Warn("Stream client too slow, events dropped",
+				"wallet_filter", sub.wallet, "dropped", dropped)
+		}
+	}
+}
+
+func (b *Broker) subscribe(wallet string) *subscriber {
+	sub := &subscriber{
+		wallet: strings.ToLower(wallet),
+		events: make(chan storage.TokenBalance, b.bufferSize),
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *Broker) unsubscribe(sub *subscriber) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// subscribers returns the number of connected clients.
+func (b *Broker) subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// ServeHTTP streams balance events to the client until it disconnects.
+// An optional ?wallet= query parameter restricts events to one wallet.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := b.subscribe(r.URL.Query().Get("wallet"))
+	defer b.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case bal := <-sub.events:
+			data, err := json.Marshal(bal)
+			if err != nil {
+				slog.Error("Failed to encode stream event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: balance\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
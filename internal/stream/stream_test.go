@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	walletA = "0x1234567890123456789012345678901234567890"
+	walletB = "0x0987654321098765432109876543210987654321"
+)
+
+func balance(wallet, symbol string) storage.TokenBalance {
+	return storage.TokenBalance{
+		QueriedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Wallet:    wallet,
+		Symbol:    symbol,
+		Balance:   decimal.NewFromInt(1),
+	}
+}
+
+// connect opens an SSE connection and waits until the broker registered it.
+func connect(t *testing.T, ctx context.Context, srv *httptest.Server, b *Broker, query string) *bufio.Scanner {
+	t.Helper()
+	want := b.subscribers() + 1
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream"+query, nil)
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return b.subscribers() == want }, time.Second, 5*time.Millisecond)
+	return bufio.NewScanner(resp.Body)
+}
+
+// nextEvent returns the decoded data of the next balance event.
+func nextEvent(t *testing.T, sc *bufio.Scanner) storage.TokenBalance {
+	t.Helper()
+	for sc.Scan() {
+		line := sc.Text()
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var bal storage.TokenBalance
+			require.NoError(t, json.Unmarshal([]byte(data), &bal))
+			return bal
+		}
+	}
+	t.Fatalf("stream ended: %v", sc.Err())
+	return storage.TokenBalance{}
+}
+
+func TestBroker_StreamsMatchingWallet(t *testing.T) {
+	b := NewBroker(8)
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filtered := connect(t, ctx, srv, b, "?wallet="+strings.ToUpper(walletA))
+	all := connect(t, ctx, srv, b, "")
+
+	b.Publish([]storage.TokenBalance{balance(walletB, "OTHER"), balance(walletA, "armmXDAI")})
+
+	got := nextEvent(t, filtered)
+	assert.Equal(t, walletA, got.Wallet)
+	assert.Equal(t, "armmXDAI", got.Symbol)
+
+	assert.Equal(t, "OTHER", nextEvent(t, all).Symbol)
+	assert.Equal(t, "armmXDAI", nextEvent(t, all).Symbol)
+}
+
+func TestBroker_UnsubscribesOnDisconnect(t *testing.T) {
+	b := NewBroker(8)
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	connect(t, ctx, srv, b, "")
+	cancel()
+
+	assert.Eventually(t, func() bool { return b.subscribers() == 0 }, time.Second, 5*time.Millisecond)
+	b.Publish([]storage.TokenBalance{balance(walletA, "armmXDAI")}) // must not block
+}
+
+func TestBroker_DropsWhenClientBufferFull(t *testing.T) {
+	b := NewBroker(2)
+	sub := b.subscribe("")
+	defer b.unsubscribe(sub)
+
+	done := make(chan struct{})
+	go func() {
+		b.Publish([]storage.TokenBalance{
+			balance(walletA, "T1"), balance(walletA, "T2"), balance(walletA, "T3"),
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full client buffer")
+	}
+	assert.Len(t, sub.events, 2)
+	assert.Equal(t, "T1", (<-sub.events).Symbol)
+}
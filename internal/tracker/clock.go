@@ -0,0 +1,31 @@
+package tracker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// CheckClockSkew compares the local clock with the timestamp of the latest
+// block, which validators keep roughly in sync with real time, and warns when
+// they differ by more than tolerance: a badly set host clock makes the
+// scheduler's clock-aligned snapshots meaningless. It returns the skew, local
+// time minus block time. Blocks trail real time by up to a block interval plus
+// the RPC lag, so tolerance must allow for both.
+func CheckClockSkew(ctx context.Context, reader BlockTimeReader, now time.Time, tolerance time.Duration) (time.Duration, error) {
+	blockTime, err := reader.LatestBlockTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	skew := now.Sub(blockTime)
+	if skew.Abs() > tolerance {
+		slog.Warn("Local clock differs from the chain, check the host's time synchronisation",
+			"skew", skew.Round(time.Second),
+			"tolerance", tolerance,
+			"local_time", now.UTC(),
+			"block_timestamp", blockTime)
+		return skew, nil
+	}
+	slog.Debug("Local clock matches the chain", "skew", skew.Round(time.Second), "tolerance", tolerance)
+	return skew, nil
+}
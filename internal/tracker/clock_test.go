@@ -0,0 +1,47 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedBlockTime is a BlockTimeReader returning a fixed block time.
+type fixedBlockTime struct {
+	at  time.Time
+	err error
+}
+
+func (f fixedBlockTime) LatestBlockTime(context.Context) (time.Time, error) {
+	return f.at, f.err
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		blockTime time.Time
+		want      time.Duration
+	}{
+		{"block a few seconds behind", now.Add(-5 * time.Second), 5 * time.Second},
+		{"local clock ahead", now.Add(-10 * time.Minute), 10 * time.Minute},
+		{"local clock behind", now.Add(3 * time.Minute), -3 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skew, err := CheckClockSkew(context.Background(), fixedBlockTime{at: tt.blockTime}, now, time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, skew)
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		_, err := CheckClockSkew(context.Background(), fixedBlockTime{err: errors.New("rpc down")}, now, time.Minute)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,71 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+)
+
+// TokenDiscoverer enumerates the tokens of a lending pool.
+// It is implemented by *blockchain.Client.
+type TokenDiscoverer interface {
+	DiscoverTokens(ctx context.Context, poolAddr common.Address) ([]blockchain.TokenInfo, error)
+}
+
+// MergeDiscovered appends discovered tokens to the configured ones. Tokens
+// already configured (matched by address, case-insensitively) keep their
+// configured settings; duplicates in discovered are ignored.
+func MergeDiscovered(configured []config.TokenConfig, discovered []blockchain.TokenInfo) []config.TokenConfig {
+	seen := make(map[string]bool, len(configured)+len(discovered))
+	merged := make([]config.TokenConfig, 0, len(configured)+len(discovered))
+	for _, tok := range configured {
+		seen[strings.ToLower(tok.Address)] = true
+		merged = append(merged, tok)
+	}
+	for _, tok := range discovered {
+		key := strings.ToLower(tok.Address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, config.TokenConfig{
+			Label:            tok.Label,
+			Address:          tok.Address,
+			FallbackDecimals: tok.FallbackDecimals,
+		})
+	}
+	return merged
+}
+
+// FindUntracked returns the balances of wallet in the pool's tokens that are
+// not configured, keeping only the non-zero ones: positions the wallet holds
+// but the tracker does not record.
+func FindUntracked(ctx context.Context, discoverer TokenDiscoverer, fetcher BalanceFetcher, wallet, pool common.Address, configured []config.TokenConfig) ([]storage.TokenBalance, error) {
+	discovered, err := discoverer.DiscoverTokens(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := MergeDiscovered(configured, discovered)[len(configured):]
+	var untracked []storage.TokenBalance
+	for _, tok := range candidates {
+		balance, err := fetcher.GetTokenBalance(ctx, wallet, blockchain.TokenInfo{
+			Label:            tok.Label,
+			Address:          tok.Address,
+			FallbackDecimals: tok.FallbackDecimals,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("balance of %s: %w", tok.Label, err)
+		}
+		if balance.RawBalance == nil || balance.RawBalance.Sign() == 0 {
+			continue
+		}
+		untracked = append(untracked, balance)
+	}
+	return untracked, nil
+}
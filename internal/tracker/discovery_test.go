@@ -0,0 +1,174 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDiscovered(t *testing.T) {
+	configured := []config.TokenConfig{
+		{Label: "armmXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b", FallbackDecimals: 18, Interval: "1h"},
+	}
+	discovered := []blockchain.TokenInfo{
+		{Label: "armmWXDAI", Address: "0x0ca4f5554dd9da6217d62d8df2816c82bba4157b", FallbackDecimals: 18},
+		{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+		{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+	}
+
+	merged := MergeDiscovered(configured, discovered)
+
+	assert.Equal(t, []config.TokenConfig{
+		configured[0],
+		{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+	}, merged)
+}
+
+// fakePool returns a fixed reserve token list, or err when set.
+type fakePool struct {
+	tokens []blockchain.TokenInfo
+	err    error
+}
+
+func (p fakePool) DiscoverTokens(_ context.Context, _ common.Address) ([]blockchain.TokenInfo, error) {
+	return p.tokens, p.err
+}
+
+// holdingsFetcher returns the raw balance held in each token, keyed by
+// lowercase address; absent tokens have a zero balance.
+type holdingsFetcher map[string]int64
+
+func (h holdingsFetcher) GetTokenBalance(_ context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	return storage.TokenBalance{
+		Wallet:       wallet.Hex(),
+		TokenAddress: token.Address,
+		Symbol:       token.Label,
+		Decimals:     token.FallbackDecimals,
+		RawBalance:   big.NewInt(h[strings.ToLower(token.Address)]),
+	}, nil
+}
+
+func TestFindUntracked(t *testing.T) {
+	wallet := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	pool := common.HexToAddress("0x5B8D36De471880Ee21936f328AAB2383a280CB2A")
+	configured := []config.TokenConfig{
+		{Label: "armmXDAI", Address: "0x0cA4f5554Dd9Da6217d62D8df2816c82bba4157b", FallbackDecimals: 18},
+	}
+	discoverer := fakePool{tokens: []blockchain.TokenInfo{
+		{Label: "armmWXDAI", Address: "0x0ca4f5554dd9da6217d62d8df2816c82bba4157b", FallbackDecimals: 18},
+		{Label: "armmUSDC", Address: "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", FallbackDecimals: 6},
+		{Label: "debtrmmUSDC", Address: "0x69c731aE5f5356a779f44C355aBB685d84e5E9e6", FallbackDecimals: 6},
+	}}
+	fetcher := holdingsFetcher{
+		"0x0ca4f5554dd9da6217d62d8df2816c82bba4157b": 5, // configured: not reported
+		"0xed56f76e9cbc6a64b821e9c016eafbd3db5436d1": 1_500_000,
+		// debtrmmUSDC: zero balance, not reported
+	}
+
+	untracked, err := FindUntracked(context.Background(), discoverer, fetcher, wallet, pool, configured)
+	require.NoError(t, err)
+	require.Len(t, untracked, 1)
+	assert.Equal(t, "armmUSDC", untracked[0].Symbol)
+	assert.Equal(t, "0xeD56F76E9cBC6A64b821e9c016eAFbd3db5436D1", untracked[0].TokenAddress)
+	assert.Equal(t, uint8(6), untracked[0].Decimals)
+
+	t.Run("nothing untracked", func(t *testing.T) {
+		untracked, err := FindUntracked(context.Background(), discoverer, holdingsFetcher{}, wallet, pool, configured)
+		require.NoError(t, err)
+		assert.Empty(t, untracked)
+	})
+
+	t.Run("discovery failure", func(t *testing.T) {
+		_, err := FindUntracked(context.Background(), fakePool{err: errors.New("execution reverted")}, fetcher, wallet, pool, configured)
+		assert.Error(t, err)
+	})
+}
+
+// inflightFetcher records the peak number of concurrent GetTokenBalance calls.
+type inflightFetcher struct {
+	mu       sync.Mutex
+	inflight int
+	peak     int
+	calls    int
+}
+
+func (f *inflightFetcher) GetTokenBalance(_ context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	f.mu.Lock()
+	f.inflight++
+	f.calls++
+	f.peak = max(f.peak, f.inflight)
+	f.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	f.mu.Lock()
+	f.inflight--
+	f.mu.Unlock()
+	return storage.TokenBalance{
+		Wallet:       wallet.Hex(),
+		TokenAddress: token.Address,
+		Symbol:       token.Label,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+	}, nil
+}
+
+// chunkStore records the size of each batch and the fetches done before it.
+type chunkStore struct {
+	fetcher        *inflightFetcher
+	batches        []int
+	fetchedAtBatch []int
+}
+
+func (s *chunkStore) BatchInsertBalances(_ context.Context, balances []storage.TokenBalance) ([]storage.TokenBalance, error) {
+	s.fetcher.mu.Lock()
+	defer s.fetcher.mu.Unlock()
+	s.batches = append(s.batches, len(balances))
+	s.fetchedAtBatch = append(s.fetchedAtBatch, s.fetcher.calls)
+	return balances, nil
+}
+
+func (s *chunkStore) SetLastRunStatus(context.Context, bool) error { return nil }
+
+func TestProcessAllWallets_ChunksDiscoveredTokens(t *testing.T) {
+	var reserves []blockchain.TokenInfo
+	for i := range 1000 {
+		reserves = append(reserves, blockchain.TokenInfo{
+			Label:            fmt.Sprintf("T%d", i),
+			Address:          common.BigToAddress(big.NewInt(int64(i + 1))).Hex(),
+			FallbackDecimals: 18,
+		})
+	}
+	discovered, err := fakePool{tokens: reserves}.DiscoverTokens(context.Background(), common.Address{})
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Wallets:        []string{"0x1234567890123456789012345678901234567890"},
+		Tokens:         MergeDiscovered(nil, discovered),
+		TokenChunkSize: 64,
+	}
+	fetcher := &inflightFetcher{}
+	store := &chunkStore{fetcher: fetcher}
+	require.NoError(t, New(cfg, fetcher, store).ProcessAllWallets(context.Background()))
+
+	assert.Equal(t, 1000, fetcher.calls)
+	assert.LessOrEqual(t, fetcher.peak, 64, "concurrency is bounded by the chunk size")
+	require.Len(t, store.batches, 16)
+	for i, n := range store.batches {
+		assert.LessOrEqual(t, n, 64)
+		assert.Equal(t, min((i+1)*64, 1000), store.fetchedAtBatch[i], "each chunk is stored before the next is fetched")
+	}
+}
@@ -0,0 +1,55 @@
+package tracker
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/shopspring/decimal"
+)
+
+// PriceSource returns the USD price of a token, with ok false when it has
+// none. Balances of priced tokens are stored with their USD value.
+type PriceSource interface {
+	USDPrice(ctx context.Context, token config.TokenConfig) (price decimal.Decimal, ok bool, err error)
+}
+
+// StaticPrices prices tokens with the usd_price of their [[tokens]] entry
+// (price_source = "static").
+type StaticPrices struct{}
+
+// USDPrice returns the configured usd_price of token, if any.
+func (StaticPrices) USDPrice(_ context.Context, token config.TokenConfig) (decimal.Decimal, bool, error) {
+	if token.USDPrice <= 0 {
+		return decimal.Decimal{}, false, nil
+	}
+	return decimal.NewFromFloat(token.USDPrice), true, nil
+}
+
+// newPriceSource returns the price source selected by price_source, or nil
+// when pricing is off.
+func newPriceSource(cfg *config.Config) PriceSource {
+	if cfg.PriceSource == config.PriceSourceStatic {
+		return StaticPrices{}
+	}
+	return nil
+}
+
+// usdValue returns balance times the USD price of token, or nil when there
+// is no price source, the token has no price or the price is unavailable.
+func (t *Tracker) usdValue(ctx context.Context, token config.TokenConfig, balance decimal.Decimal) *decimal.Decimal {
+	if t.prices == nil {
+		return nil
+	}
+	price, ok, err := t.prices.USDPrice(ctx, token)
+	if err != nil {
+		logger.LogError(ctx, slog.LevelWarn, "Token price unavailable, balance stored without USD value", err, "token_address", token.Address)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	value := balance.Mul(price)
+	return &value
+}
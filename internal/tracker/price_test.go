@@ -0,0 +1,61 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingPrices is a PriceSource whose lookups fail.
+type failingPrices struct{}
+
+func (failingPrices) USDPrice(context.Context, config.TokenConfig) (decimal.Decimal, bool, error) {
+	return decimal.Decimal{}, false, errors.New("oracle down")
+}
+
+func TestProcessAllWallets_USDValue(t *testing.T) {
+	run := func(t *testing.T, cfg *config.Config, prices PriceSource) *fakeStore {
+		t.Helper()
+		store := &fakeStore{}
+		tr := New(cfg, newFakeFetcher(), store)
+		if prices != nil {
+			tr.prices = prices
+		}
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+		require.Len(t, store.balances, 2)
+		return store
+	}
+
+	t.Run("no price source", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.Tokens[0].USDPrice = 1.5
+		for _, b := range run(t, cfg, nil).balances {
+			assert.Nil(t, b.USDValue)
+		}
+	})
+
+	t.Run("static prices", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.PriceSource = config.PriceSourceStatic
+		cfg.Tokens[0].USDPrice = 1.5
+		for _, b := range run(t, cfg, nil).balances {
+			if b.Symbol == "FAST" {
+				require.NotNil(t, b.USDValue)
+				assert.Equal(t, "1.5", b.USDValue.String(), "balance 1 × price 1.5")
+			} else {
+				assert.Nil(t, b.USDValue, "token without usd_price")
+			}
+		}
+	})
+
+	t.Run("price lookup fails", func(t *testing.T) {
+		for _, b := range run(t, testConfig(), failingPrices{}).balances {
+			assert.Nil(t, b.USDValue)
+		}
+	})
+}
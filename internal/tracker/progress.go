@@ -0,0 +1,130 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Progress is a snapshot of the current (or last) polling cycle.
+// TokensDone counts every token query that finished, failures included;
+// InsertsFailed counts the batch inserts that failed.
+type Progress struct {
+	Running       bool       `json:"running"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	WalletsDone   int64      `json:"wallets_done"`
+	WalletsTotal  int64      `json:"wallets_total"`
+	TokensDone    int64      `json:"tokens_done"`
+	TokensFailed  int64      `json:"tokens_failed"`
+	TokensTotal   int64      `json:"tokens_total"`
+	InsertsFailed int64      `json:"inserts_failed"`
+}
+
+// cycleProgress holds the counters behind Progress. They are updated by the
+// polling goroutines and read concurrently by the status handler.
+type cycleProgress struct {
+	running       atomic.Bool
+	startedAt     atomic.Int64 // unix nanoseconds, 0 before the first cycle
+	walletsDone   atomic.Int64
+	walletsTotal  atomic.Int64
+	tokensDone    atomic.Int64
+	tokensFailed  atomic.Int64
+	tokensTotal   atomic.Int64
+	inserts       atomic.Int64 // batch inserts attempted
+	insertsFailed atomic.Int64
+}
+
+// start resets the counters for a cycle over wallets wallets and tokens tokens.
+func (p *cycleProgress) start(at time.Time, wallets, tokens int) {
+	p.walletsDone.Store(0)
+	p.tokensDone.Store(0)
+	p.tokensFailed.Store(0)
+	p.inserts.Store(0)
+	p.insertsFailed.Store(0)
+	p.walletsTotal.Store(int64(wallets))
+	p.tokensTotal.Store(int64(tokens))
+	p.startedAt.Store(at.UnixNano())
+	p.running.Store(true)
+}
+
+func (p *cycleProgress) snapshot() Progress {
+	progress := Progress{
+		Running:       p.running.Load(),
+		WalletsDone:   p.walletsDone.Load(),
+		WalletsTotal:  p.walletsTotal.Load(),
+		TokensDone:    p.tokensDone.Load(),
+		TokensFailed:  p.tokensFailed.Load(),
+		TokensTotal:   p.tokensTotal.Load(),
+		InsertsFailed: p.insertsFailed.Load(),
+	}
+	if ns := p.startedAt.Load(); ns != 0 {
+		at := time.Unix(0, ns).UTC()
+		progress.StartedAt = &at
+	}
+	return progress
+}
+
+// failure reports a finished cycle that could neither fetch nor store
+// anything: every token query failed, or every batch insert did. A cycle with
+// nothing due did not fail.
+func (p *cycleProgress) failure() error {
+	if failed := p.tokensFailed.Load(); failed > 0 && failed == p.tokensDone.Load() {
+		return fmt.Errorf("all %d balance queries failed", failed)
+	}
+	if failed := p.insertsFailed.Load(); failed > 0 && failed == p.inserts.Load() {
+		return fmt.Errorf("all %d balance inserts failed", failed)
+	}
+	return nil
+}
+
+// Progress returns the progress of the running cycle, or of the last one
+// once it has finished.
+func (t *Tracker) Progress() Progress {
+	return t.progress.snapshot()
+}
+
+// tokenDone counts a finished token query and logs the cycle progress every
+// progress_log_every tokens.
+func (t *Tracker) tokenDone(failed bool) {
+	if failed {
+		t.progress.tokensFailed.Add(1)
+	}
+	done := t.progress.tokensDone.Add(1)
+	if every := int64(t.cfg.ProgressLogEvery); every > 0 && done%every == 0 {
+		p := t.progress.snapshot()
+		slog.Info("Cycle progress",
+			"wallets_done", p.WalletsDone,
+			"wallets_total", p.WalletsTotal,
+			"tokens_done", p.TokensDone,
+			"tokens_total", p.TokensTotal,
+			"tokens_failed", p.TokensFailed)
+	}
+}
+
+// StatusInfo identifies the running instance on /status.
+type StatusInfo struct {
+	Version           string `json:"version"`
+	Commit            string `json:"commit"`
+	ConfigFingerprint string `json:"config_fingerprint"`
+}
+
+// SetStatusInfo adds info to the /status response; nil (the default) leaves
+// it out.
+func (t *Tracker) SetStatusInfo(info *StatusInfo) {
+	t.statusInfo.Store(info)
+}
+
+// StatusHandler serves the cycle progress as JSON, with the build and config
+// fingerprint when set.
+func (t *Tracker) StatusHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Cycle Progress    `json:"cycle"`
+		Build *StatusInfo `json:"build,omitempty"`
+	}{t.Progress(), t.statusInfo.Load()}); err != nil {
+		slog.Error("Failed to encode status response", "error", err)
+	}
+}
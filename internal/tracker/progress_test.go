@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress_AdvancesAndResetsPerCycle(t *testing.T) {
+	cfg := testConfig()
+	cfg.Wallets = append(cfg.Wallets, "0x2345678901234567890123456789012345678901")
+	fetcher := newFakeFetcher()
+	tr := New(cfg, fetcher, &fakeStore{})
+
+	var seen []Progress
+	tr.OnPersist(func([]storage.TokenBalance) {
+		seen = append(seen, tr.Progress())
+	})
+
+	assert.Equal(t, Progress{}, tr.Progress(), "no cycle yet")
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return start }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	require.Len(t, seen, 2)
+	for i, p := range seen {
+		assert.True(t, p.Running)
+		assert.Equal(t, int64(i), p.WalletsDone, "wallet %d", i)
+		assert.Equal(t, int64(2), p.WalletsTotal)
+		assert.Equal(t, int64(2*(i+1)), p.TokensDone)
+		assert.Equal(t, int64(4), p.TokensTotal)
+	}
+	done := tr.Progress()
+	assert.False(t, done.Running)
+	assert.Equal(t, int64(2), done.WalletsDone)
+	assert.Equal(t, int64(4), done.TokensDone)
+	require.NotNil(t, done.StartedAt)
+	assert.Equal(t, start, *done.StartedAt)
+
+	// Next cycle: SLOW (15m interval) is not due and a FAST query fails
+	seen = nil
+	fetcher.fail = map[string]bool{"FAST": true}
+	next := start.Add(5 * time.Minute)
+	tr.now = func() time.Time { return next }
+	require.EqualError(t, tr.ProcessAllWallets(context.Background()), "all 2 balance queries failed")
+
+	assert.Empty(t, seen, "nothing persisted")
+	p := tr.Progress()
+	assert.Equal(t, int64(2), p.WalletsDone)
+	assert.Equal(t, int64(2), p.TokensTotal, "totals reset to the due tokens")
+	assert.Equal(t, int64(2), p.TokensDone)
+	assert.Equal(t, int64(2), p.TokensFailed)
+	assert.Equal(t, next, *p.StartedAt)
+}
+
+func TestStatusHandler(t *testing.T) {
+	tr := New(testConfig(), newFakeFetcher(), &fakeStore{})
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	rec := httptest.NewRecorder()
+	tr.StatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body struct {
+		Cycle Progress `json:"cycle"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.False(t, body.Cycle.Running)
+	assert.Equal(t, int64(1), body.Cycle.WalletsDone)
+	assert.Equal(t, int64(2), body.Cycle.TokensDone)
+	assert.NotContains(t, rec.Body.String(), "build", "left out by default")
+
+	tr.SetStatusInfo(&StatusInfo{Version: "v1.4.0", Commit: "a1b2c3d", ConfigFingerprint: "3f9a0c12be47"})
+	rec = httptest.NewRecorder()
+	tr.StatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var withBuild struct {
+		Build StatusInfo `json:"build"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &withBuild))
+	assert.Equal(t, StatusInfo{Version: "v1.4.0", Commit: "a1b2c3d", ConfigFingerprint: "3f9a0c12be47"}, withBuild.Build)
+}
@@ -0,0 +1,631 @@
+// Package tracker runs polling cycles: it fetches every configured token
+// balance for every wallet and persists the results.
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/logger"
+	"github.com/matrixise/rmm-tracker/internal/scheduler"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+)
+
+// pollSlack absorbs scheduler timing noise so a token whose interval is a
+// multiple of the global cadence is not skipped by a few milliseconds.
+const pollSlack = time.Second
+
+// BalanceFetcher retrieves the balance of one token for one wallet.
+// It is implemented by *blockchain.Client.
+type BalanceFetcher interface {
+	GetTokenBalance(ctx context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error)
+}
+
+// BlockTimeReader reports the timestamp of the latest block. When the fetcher
+// implements it and record_block_timestamp is enabled, every balance of a
+// cycle is stamped with the block time read once at its start.
+type BlockTimeReader interface {
+	LatestBlockTime(ctx context.Context) (time.Time, error)
+}
+
+// BlockNumberReader reports the number of the latest block. When the fetcher
+// implements it, the balances of each wallet are stamped with the block
+// number read once before its tokens are queried.
+type BlockNumberReader interface {
+	LatestBlockNumber(ctx context.Context) (uint64, error)
+}
+
+// BalanceVerifier re-reads a token balance, preferably on another RPC
+// endpoint. When the fetcher implements it and verify_zero is enabled,
+// suspicious zero balances are re-read through it rather than through
+// GetTokenBalance. It is implemented by *blockchain.Client.
+type BalanceVerifier interface {
+	VerifyTokenBalance(ctx context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error)
+}
+
+// MulticallFetcher reads all the token balances of a wallet at once. When the
+// fetcher implements it and multicall_address is set, each wallet's tokens
+// are prefetched through it; tokens missing from its result are queried with
+// GetTokenBalance. It is implemented by *blockchain.Client.
+type MulticallFetcher interface {
+	GetTokenBalancesMulticall(ctx context.Context, wallet common.Address, tokens []blockchain.TokenInfo) ([]storage.TokenBalance, error)
+}
+
+// Reasons a balance row is not written, reported to the RowRecorder.
+const (
+	SkipQueryFailed    = "query_failed"    // the token query failed
+	SkipZeroUnverified = "zero_unverified" // verify_zero could not re-read a zero balance
+	SkipNoRawBalance   = "no_raw_balance"  // the balance has no raw value to store
+	SkipInsertFailed   = "insert_failed"   // the batch insert failed
+	SkipUnchanged      = "unchanged"       // dedup_unchanged left out a balance equal to the stored one
+	SkipDuplicate      = "duplicate"       // a balance was already stored for its wallet, token and queried_at
+)
+
+// RowRecorder receives the number of balance rows written or skipped, by
+// skip reason. It is implemented by *metrics.Metrics.
+type RowRecorder interface {
+	RowsInserted(n int)
+	RowsSkipped(reason string, n int)
+}
+
+type nopRowRecorder struct{}
+
+func (nopRowRecorder) RowsInserted(int)        {}
+func (nopRowRecorder) RowsSkipped(string, int) {}
+
+// PersistHook is called with each batch of balances once it is persisted.
+type PersistHook func(balances []storage.TokenBalance)
+
+// Tracker polls balances for the configured wallets and tokens.
+type Tracker struct {
+	cfg     *config.Config
+	fetcher BalanceFetcher
+	store   storage.Commander
+	now     func() time.Time
+	hooks   []PersistHook
+	rows    RowRecorder
+	prices  PriceSource // nil when price_source is off
+
+	mu          sync.Mutex
+	lastPolled  map[string]time.Time            // last persisted poll, keyed by pollKey
+	lastBalance map[string]storage.TokenBalance // last persisted balance, keyed by pollKey
+	retrievals  map[string]int                  // balances retrieved, keyed by pollKey
+	reload      *pendingReload                  // applied at the start of the next cycle
+
+	progress   cycleProgress
+	statusInfo atomic.Pointer[StatusInfo] // nil unless status_build_info is set
+}
+
+// New creates a Tracker.
+func New(cfg *config.Config, fetcher BalanceFetcher, store storage.Commander) *Tracker {
+	return &Tracker{
+		cfg:         cfg,
+		fetcher:     fetcher,
+		store:       store,
+		now:         time.Now,
+		rows:        nopRowRecorder{},
+		prices:      newPriceSource(cfg),
+		lastPolled:  make(map[string]time.Time),
+		lastBalance: make(map[string]storage.TokenBalance),
+		retrievals:  make(map[string]int),
+	}
+}
+
+// OnPersist registers a hook run after every successful batch insert.
+// Hooks must not block: they run on the polling goroutine, and may run
+// concurrently for different wallets when wallet_concurrency is above 1.
+func (t *Tracker) OnPersist(hook PersistHook) {
+	t.hooks = append(t.hooks, hook)
+}
+
+// SetRowRecorder sets where written and skipped rows are counted. By
+// default they are not reported.
+func (t *Tracker) SetRowRecorder(r RowRecorder) {
+	t.rows = r
+}
+
+// pollKey identifies a wallet/token pair in lastPolled.
+func pollKey(wallet, token string) string {
+	return strings.ToLower(wallet) + "/" + strings.ToLower(token)
+}
+
+// dueTokens returns the tokens to poll for wallet in a cycle starting at now.
+// Tokens with their own interval are skipped until it has elapsed, or their
+// cron expression has fired, since their last persisted poll; the others are
+// polled every cycle.
+func (t *Tracker) dueTokens(wallet string, now time.Time) []config.TokenConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	due := make([]config.TokenConfig, 0, len(t.cfg.Tokens))
+	for _, tok := range t.cfg.Tokens {
+		if last, ok := t.lastPolled[pollKey(wallet, tok.Address)]; ok && tok.Interval != "" {
+			if next := t.nextPoll(tok, last); now.Add(pollSlack).Before(next) {
+				slog.Debug("Token skipped, interval not elapsed",
+					"wallet", wallet,
+					"label", tok.Label,
+					"interval", tok.Interval,
+					"next_poll_in", next.Sub(now))
+				continue
+			}
+		}
+		due = append(due, tok)
+	}
+	return due
+}
+
+// nextPoll returns when a token with its own interval, last polled at last,
+// is due again. A cron expression is read in the configured timezone.
+func (t *Tracker) nextPoll(tok config.TokenConfig, last time.Time) time.Time {
+	if every := tok.PollInterval(); every > 0 {
+		return last.Add(every)
+	}
+	next, err := scheduler.NextCronTime(tok.PollCron(), last.In(t.cfg.GetTimezone()))
+	if err != nil {
+		return time.Time{} // rejected by validation; poll every cycle
+	}
+	return next
+}
+
+// markPolled records a cycle start as the last poll of the persisted
+// balances, so failed fetches or inserts are retried on the next cycle, and
+// keeps them for the next cycles' comparisons. Carried-forward rows are not
+// polls and are skipped.
+func (t *Tracker) markPolled(balances []storage.TokenBalance, cycleStart time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range balances {
+		if b.CarriedForward {
+			continue
+		}
+		key := pollKey(b.Wallet, b.TokenAddress)
+		t.lastPolled[key] = cycleStart
+		if b.RawBalance != nil {
+			t.lastBalance[key] = b
+		}
+	}
+}
+
+// carryForward returns the last persisted balance of a wallet/token pair
+// queried again at at and flagged as carried forward, or false when none is
+// known (balances persisted before a restart are not).
+func (t *Tracker) carryForward(wallet, token string, at time.Time) (storage.TokenBalance, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.lastBalance[pollKey(wallet, token)]
+	if !ok {
+		return storage.TokenBalance{}, false
+	}
+	last.ID = 0
+	last.QueriedAt = at
+	last.BlockTimestamp = nil
+	last.BlockNumber = 0
+	last.CarriedForward = true
+	return last, true
+}
+
+// wasNonZero reports whether the last persisted balance of a wallet/token
+// pair is known and not zero. Balances persisted before a restart are not
+// known.
+func (t *Tracker) wasNonZero(wallet, token string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.lastBalance[pollKey(wallet, token)]
+	return ok && last.RawBalance.Sign() != 0
+}
+
+// balanceLogged reports whether the retrieval of a balance is logged under
+// log_balance_sampling: always (the default), never, only when it differs
+// from the last persisted balance, or on every Nth retrieval of the token.
+func (t *Tracker) balanceLogged(b storage.TokenBalance) bool {
+	key := pollKey(b.Wallet, b.TokenAddress)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch sampling := t.cfg.LogBalanceSampling; sampling {
+	case "", config.BalanceSamplingAll:
+		return true
+	case config.BalanceSamplingNone:
+		return false
+	case config.BalanceSamplingChanged:
+		last, ok := t.lastBalance[key]
+		return !ok || b.RawBalance == nil || last.RawBalance.Cmp(b.RawBalance) != 0
+	default:
+		every, err := strconv.Atoi(sampling)
+		if err != nil || every <= 0 {
+			return true
+		}
+		n := t.retrievals[key]
+		t.retrievals[key] = n + 1
+		return n%every == 0
+	}
+}
+
+// prefetch reads the balances of tokens for wallet in one multicall when it
+// is enabled, keyed by lowercased token address. Tokens that failed are left
+// out and queried one by one by the caller.
+func (t *Tracker) prefetch(ctx context.Context, wallet common.Address, tokens []config.TokenConfig) map[string]storage.TokenBalance {
+	multicall, ok := t.fetcher.(MulticallFetcher)
+	if !ok || t.cfg.MulticallAddress == "" {
+		return nil
+	}
+
+	infos := make([]blockchain.TokenInfo, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Address == "" {
+			continue
+		}
+		infos = append(infos, blockchain.TokenInfo{
+			Label:                token.Label,
+			Address:              token.Address,
+			FallbackDecimals:     token.FallbackDecimals,
+			ForceRefreshMetadata: token.RefreshMetadata,
+		})
+	}
+	if len(infos) == 0 {
+		return nil
+	}
+
+	balances, err := multicall.GetTokenBalancesMulticall(ctx, wallet, infos)
+	if err != nil {
+		slog.Debug("Multicall left tokens out, querying them one by one",
+			"wallet", wallet.Hex(), "error", err)
+	}
+	prefetched := make(map[string]storage.TokenBalance, len(balances))
+	for _, b := range balances {
+		prefetched[strings.ToLower(b.TokenAddress)] = b
+	}
+	return prefetched
+}
+
+// verifyZero re-reads a zero balance of a token whose last persisted balance
+// was not zero, so a false zero returned during an RPC hiccup is not stored.
+// The re-read result replaces the zero; a failed re-read fails the query.
+func (t *Tracker) verifyZero(ctx context.Context, wallet common.Address, token blockchain.TokenInfo, result storage.TokenBalance) (storage.TokenBalance, error) {
+	if result.RawBalance == nil || result.RawBalance.Sign() != 0 || !t.wasNonZero(wallet.Hex(), token.Address) {
+		return result, nil
+	}
+
+	fetch := t.fetcher.GetTokenBalance
+	if verifier, ok := t.fetcher.(BalanceVerifier); ok {
+		fetch = verifier.VerifyTokenBalance
+	}
+	again, err := fetch(ctx, wallet, token)
+	if err != nil {
+		return result, fmt.Errorf("verify zero balance: %w", err)
+	}
+	if again.RawBalance != nil && again.RawBalance.Sign() != 0 {
+		slog.Warn("Spurious zero balance discarded",
+			"wallet", wallet.Hex(),
+			"token_address", token.Address,
+			"balance", again.Balance.String())
+	} else {
+		slog.Info("Zero balance confirmed", "wallet", wallet.Hex(), "token_address", token.Address)
+	}
+	return again, nil
+}
+
+// walletBlockNumber reads the latest block number before the tokens of
+// wallet are queried, or returns 0 (unknown) when the fetcher cannot or the
+// read fails.
+func (t *Tracker) walletBlockNumber(ctx context.Context, wallet common.Address) uint64 {
+	reader, ok := t.fetcher.(BlockNumberReader)
+	if !ok {
+		return 0
+	}
+	number, err := reader.LatestBlockNumber(ctx)
+	if err != nil {
+		slog.Warn("Latest block number not read, balances stored without it", "wallet", wallet.Hex(), "error", err)
+		return 0
+	}
+	return number
+}
+
+// cycleBlockTime reads the latest block time for a cycle, or returns nil when
+// recording is disabled or the read fails (rows are then stored without it).
+func (t *Tracker) cycleBlockTime(ctx context.Context, cycleStart time.Time) *time.Time {
+	if !t.cfg.RecordBlockTimestamp {
+		return nil
+	}
+	reader, ok := t.fetcher.(BlockTimeReader)
+	if !ok {
+		return nil
+	}
+	blockTime, err := reader.LatestBlockTime(ctx)
+	if err != nil {
+		logger.LogError(ctx, slog.LevelWarn, "Latest block time unavailable, balances stored without it", err)
+		return nil
+	}
+	slog.Debug("Latest block time", "block_timestamp", blockTime, "skew", cycleStart.Sub(blockTime))
+	return &blockTime
+}
+
+// pendingReload is a configuration waiting for the next cycle.
+type pendingReload struct {
+	cfg     *config.Config
+	fetcher BalanceFetcher
+}
+
+// Reload replaces the configuration, and the fetcher unless nil, from the
+// next cycle on; a cycle in progress finishes with the current ones. The
+// poll history (intervals, last balances) is kept.
+func (t *Tracker) Reload(cfg *config.Config, fetcher BalanceFetcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reload = &pendingReload{cfg: cfg, fetcher: fetcher}
+}
+
+// applyReload switches to the configuration given to Reload, if any.
+func (t *Tracker) applyReload() {
+	t.mu.Lock()
+	r := t.reload
+	t.reload = nil
+	t.mu.Unlock()
+	if r == nil {
+		return
+	}
+	t.cfg = r.cfg
+	t.prices = newPriceSource(r.cfg)
+	if r.fetcher != nil {
+		t.fetcher = r.fetcher
+	}
+}
+
+// ProcessAllWallets runs one polling cycle over every wallet. Up to
+// wallet_concurrency wallets are processed at once; by default they are
+// processed one after the other. It fails when the cycle fetched or stored
+// nothing because every balance query, or every batch insert, failed.
+func (t *Tracker) ProcessAllWallets(ctx context.Context) error {
+	t.applyReload()
+	cycleStart := t.now()
+	blockTime := t.cycleBlockTime(ctx, cycleStart)
+
+	// Select the due tokens upfront so the cycle size is known for progress
+	due := make([][]config.TokenConfig, len(t.cfg.Wallets))
+	total := 0
+	for i, walletAddr := range t.cfg.Wallets {
+		due[i] = t.dueTokens(common.HexToAddress(walletAddr).Hex(), cycleStart)
+		total += len(due[i])
+	}
+	t.progress.start(cycleStart, len(t.cfg.Wallets), total)
+	defer t.progress.running.Store(false)
+
+	sem := make(chan struct{}, max(t.cfg.WalletConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, walletAddr := range t.cfg.Wallets {
+		// Check for cancellation, waiting for a free slot if needed
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			slog.Info("Shutdown requested, stopping processing")
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(wallet common.Address, tokens []config.TokenConfig) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t.processWallet(ctx, wallet, tokens, cycleStart, blockTime)
+		}(common.HexToAddress(walletAddr), due[i])
+	}
+	wg.Wait()
+
+	if err := t.progress.failure(); err != nil {
+		return err
+	}
+	slog.Info("Processing completed successfully")
+	return nil
+}
+
+// cycleQueriedAt returns the queried_at shared by the rows of a cycle started
+// at cycleStart, rounded down to queried_at_rounding when set, or the zero
+// time unless shared_queried_at is enabled.
+func (t *Tracker) cycleQueriedAt(cycleStart time.Time) time.Time {
+	if !t.cfg.SharedQueriedAt {
+		return time.Time{}
+	}
+	at := cycleStart.UTC()
+	if d, err := time.ParseDuration(t.cfg.QueriedAtRounding); err == nil && d > 0 {
+		at = at.Truncate(d)
+	}
+	return at
+}
+
+// walletPoll holds what the balances of one wallet share within a cycle.
+type walletPoll struct {
+	wallet      common.Address
+	label       string
+	tags        map[string]string
+	cycleStart  time.Time
+	queriedAt   time.Time // shared by every row, zero to keep each fetch time
+	blockTime   *time.Time
+	blockNumber uint64
+}
+
+// processWallet fetches the due tokens of one wallet and persists the
+// successful balances. Tokens are fetched in parallel and persisted in a
+// single batch, or chunk by chunk when token_chunk_size is set, so a wallet
+// with thousands of tokens keeps memory and RPC concurrency bounded.
+func (t *Tracker) processWallet(ctx context.Context, wallet common.Address, tokens []config.TokenConfig, cycleStart time.Time, blockTime *time.Time) {
+	defer t.progress.walletsDone.Add(1)
+
+	if len(tokens) == 0 {
+		slog.Info("No token due this cycle", "wallet", wallet.Hex())
+		return
+	}
+	label := t.cfg.WalletLabel(wallet.Hex())
+	slog.Info("Processing wallet", "wallet", storage.WalletDisplayName(label, wallet.Hex()))
+	poll := walletPoll{
+		wallet:      wallet,
+		label:       label,
+		tags:        t.cfg.WalletTags(wallet.Hex()),
+		cycleStart:  cycleStart,
+		queriedAt:   t.cycleQueriedAt(cycleStart),
+		blockTime:   blockTime,
+		blockNumber: t.walletBlockNumber(ctx, wallet),
+	}
+
+	chunkSize := t.cfg.TokenChunkSize
+	if chunkSize <= 0 {
+		chunkSize = len(tokens)
+	}
+	for chunk := range slices.Chunk(tokens, chunkSize) {
+		if ctx.Err() != nil {
+			slog.Info("Shutdown requested, remaining tokens not processed", "wallet", wallet.Hex())
+			return
+		}
+		t.processChunk(ctx, poll, chunk)
+	}
+}
+
+// processChunk fetches tokens of a wallet in parallel and persists the
+// successful balances in a single batch.
+func (t *Tracker) processChunk(ctx context.Context, poll walletPoll, tokens []config.TokenConfig) {
+	wallet := poll.wallet
+	prefetched := t.prefetch(ctx, wallet, tokens)
+
+	// Process tokens in parallel
+	results := make(chan storage.TokenBalance, len(tokens))
+	var wg sync.WaitGroup
+
+	for _, tok := range tokens {
+		if tok.Address == "" {
+			slog.Warn("Token without address ignored", "label", tok.Label)
+			t.tokenDone(true)
+			continue
+		}
+
+		wg.Add(1)
+		go func(token config.TokenConfig) {
+			defer wg.Done()
+
+			tokenInfo := blockchain.TokenInfo{
+				Label:                token.Label,
+				Address:              token.Address,
+				FallbackDecimals:     token.FallbackDecimals,
+				ForceRefreshMetadata: token.RefreshMetadata,
+			}
+
+			result, ok := prefetched[strings.ToLower(token.Address)]
+			var err error
+			if !ok {
+				result, err = t.fetcher.GetTokenBalance(ctx, wallet, tokenInfo)
+			}
+			reason := SkipQueryFailed
+			if err == nil && t.cfg.VerifyZero {
+				result, err = t.verifyZero(ctx, wallet, tokenInfo, result)
+				reason = SkipZeroUnverified
+			}
+			t.tokenDone(err != nil)
+			if err != nil {
+				logger.LogError(ctx, slog.LevelError, "Token query error", err, "token_address", token.Address)
+				if t.cfg.CarryForwardOnFailure {
+					at := poll.queriedAt
+					if at.IsZero() {
+						at = t.now().UTC()
+					}
+					if carried, ok := t.carryForward(wallet.Hex(), token.Address, at); ok {
+						slog.Warn("Last known balance carried forward",
+							"wallet", carried.Wallet,
+							"symbol", carried.Symbol,
+							"balance", carried.Balance.String())
+						results <- carried
+						return
+					}
+				}
+				t.rows.RowsSkipped(reason, 1)
+				return
+			}
+			if !poll.queriedAt.IsZero() {
+				result.QueriedAt = poll.queriedAt
+			}
+			result.Source = storage.SourcePoll
+			result.Label = token.Label
+			result.Tags = poll.tags
+			result.WalletLabel = poll.label
+			result.BlockTimestamp = poll.blockTime
+			result.BlockNumber = poll.blockNumber
+			result.USDValue = t.usdValue(ctx, token, result.Balance)
+
+			if t.balanceLogged(result) {
+				slog.Info("Balance retrieved",
+					"wallet", storage.WalletDisplayName(result.WalletLabel, result.Wallet),
+					"symbol", result.Symbol,
+					"balance", result.Balance.String(),
+					"decimals", result.Decimals,
+				)
+			}
+
+			results <- result
+		}(tok)
+	}
+
+	// Wait and collect results
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var successResults []storage.TokenBalance
+	for result := range results {
+		successResults = append(successResults, result)
+	}
+
+	// Batch insert
+	if len(successResults) == 0 {
+		return
+	}
+	// The store drops rows without a raw balance
+	stored := len(successResults)
+	for _, b := range successResults {
+		if b.RawBalance == nil {
+			stored--
+		}
+	}
+	if skipped := len(successResults) - stored; skipped > 0 {
+		t.rows.RowsSkipped(SkipNoRawBalance, skipped)
+	}
+	t.progress.inserts.Add(1)
+	written, err := t.store.BatchInsertBalances(ctx, successResults)
+	if err != nil {
+		t.progress.insertsFailed.Add(1)
+		logger.LogError(ctx, slog.LevelError, "Batch insert error", err, "wallet", wallet.Hex())
+		t.rows.RowsSkipped(SkipInsertFailed, stored)
+		return
+	}
+	t.rows.RowsInserted(len(written))
+	if left := stored - len(written); left > 0 {
+		// With dedup_unchanged, an already stored balance is also unchanged
+		reason := SkipDuplicate
+		if t.cfg.DedupUnchanged {
+			reason = SkipUnchanged
+		}
+		t.rows.RowsSkipped(reason, left)
+	}
+
+	slog.Info("Records inserted successfully",
+		"wallet", wallet.Hex(),
+		"count", len(written),
+	)
+	t.markPolled(successResults, poll.cycleStart)
+	if len(written) == 0 {
+		return
+	}
+	for _, hook := range t.hooks {
+		hook(written)
+	}
+}
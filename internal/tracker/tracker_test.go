@@ -0,0 +1,937 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/matrixise/rmm-tracker/internal/blockchain"
+	"github.com/matrixise/rmm-tracker/internal/config"
+	"github.com/matrixise/rmm-tracker/internal/scheduler"
+	"github.com/matrixise/rmm-tracker/internal/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFetcher records the tokens it was asked for and returns a fixed balance,
+// or an error for the labels in fail.
+type fakeFetcher struct {
+	mu    sync.Mutex
+	calls map[string]int
+	fail  map[string]bool
+}
+
+func newFakeFetcher() *fakeFetcher {
+	return &fakeFetcher{calls: make(map[string]int)}
+}
+
+func (f *fakeFetcher) GetTokenBalance(_ context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	f.mu.Lock()
+	f.calls[token.Label]++
+	fail := f.fail[token.Label]
+	f.mu.Unlock()
+	if fail {
+		return storage.TokenBalance{}, errors.New("rpc unavailable")
+	}
+	return storage.TokenBalance{
+		QueriedAt:    time.Now().UTC(),
+		Wallet:       wallet.Hex(),
+		TokenAddress: token.Address,
+		Symbol:       token.Label,
+		Decimals:     token.FallbackDecimals,
+		RawBalance:   big.NewInt(1),
+		Balance:      decimal.NewFromInt(1),
+	}, nil
+}
+
+func (f *fakeFetcher) count(label string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[label]
+}
+
+// fakeStore collects the balances it is asked to insert, or returns err when
+// set. Like the store, it reports balances without raw balance as not
+// written, and those for which skip returns true, standing for unchanged or
+// already stored rows, are left out.
+type fakeStore struct {
+	mu       sync.Mutex
+	balances []storage.TokenBalance
+	err      error
+	skip     func(storage.TokenBalance) bool
+}
+
+func (s *fakeStore) BatchInsertBalances(_ context.Context, balances []storage.TokenBalance) ([]storage.TokenBalance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var written []storage.TokenBalance
+	for _, b := range balances {
+		if s.skip != nil && s.skip(b) {
+			continue
+		}
+		s.balances = append(s.balances, b)
+		if b.RawBalance != nil {
+			written = append(written, b)
+		}
+	}
+	return written, nil
+}
+
+func (s *fakeStore) SetLastRunStatus(_ context.Context, _ bool) error {
+	return nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Wallets: []string{"0x1234567890123456789012345678901234567890"},
+		Tokens: []config.TokenConfig{
+			{Label: "FAST", Address: "0x0000000000000000000000000000000000000001", FallbackDecimals: 18},
+			{Label: "SLOW", Address: "0x0000000000000000000000000000000000000002", FallbackDecimals: 18, Interval: "15m"},
+		},
+	}
+}
+
+func TestProcessAllWallets_PerTokenInterval(t *testing.T) {
+	fetcher := newFakeFetcher()
+	store := &fakeStore{}
+	tr := New(testConfig(), fetcher, store)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Global cadence of 5 minutes: cycles at 0, 5, 10, 15 and 20 minutes.
+	for i := range 5 {
+		now := start.Add(time.Duration(i) * 5 * time.Minute)
+		tr.now = func() time.Time { return now }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	}
+
+	assert.Equal(t, 5, fetcher.count("FAST"), "token without interval is polled every cycle")
+	assert.Equal(t, 2, fetcher.count("SLOW"), "token with 15m interval is polled at 0 and 15 only")
+	assert.Len(t, store.balances, 7)
+}
+
+func TestProcessAllWallets_PerTokenCron(t *testing.T) {
+	cfg := testConfig()
+	cfg.Tokens[1].Interval = "0 * * * *" // on the hour
+	cfg.Timezone = "Europe/Brussels"     // whole hours apart from UTC
+	fetcher := newFakeFetcher()
+	tr := New(cfg, fetcher, &fakeStore{})
+
+	start := time.Date(2026, 1, 1, 0, 10, 0, 0, time.UTC)
+	// Global cadence of 5 minutes from 00:10 to 02:05
+	for i := range 24 {
+		now := start.Add(time.Duration(i) * 5 * time.Minute)
+		tr.now = func() time.Time { return now }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	}
+
+	assert.Equal(t, 24, fetcher.count("FAST"))
+	assert.Equal(t, 3, fetcher.count("SLOW"), "polled first at 00:10, then at 01:00 and 02:00")
+}
+
+func TestProcessAllWallets_TagsRowsAsPoll(t *testing.T) {
+	store := &fakeStore{}
+	tr := New(testConfig(), newFakeFetcher(), store)
+
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	require.NotEmpty(t, store.balances)
+	for _, b := range store.balances {
+		assert.Equal(t, storage.SourcePoll, b.Source)
+	}
+}
+
+func TestProcessAllWallets_SharedQueriedAt(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 5, 0, 800_000_000, time.UTC)
+	cycle := func(t *testing.T, cfg *config.Config) []storage.TokenBalance {
+		t.Helper()
+		cfg.Wallets = append(cfg.Wallets, "0x0000000000000000000000000000000000000abc")
+		store := &fakeStore{}
+		tr := New(cfg, newFakeFetcher(), store)
+		tr.now = func() time.Time { return start }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+		require.Len(t, store.balances, 4)
+		return store.balances
+	}
+
+	t.Run("fetch time by default", func(t *testing.T) {
+		for _, b := range cycle(t, testConfig()) {
+			assert.False(t, start.Equal(b.QueriedAt))
+		}
+	})
+
+	t.Run("cycle start", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.SharedQueriedAt = true
+		for _, b := range cycle(t, cfg) {
+			assert.Equal(t, start, b.QueriedAt)
+		}
+	})
+
+	t.Run("rounded to the interval", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.SharedQueriedAt = true
+		cfg.QueriedAtRounding = "1m"
+		for _, b := range cycle(t, cfg) {
+			assert.Equal(t, time.Date(2026, 1, 1, 12, 5, 0, 0, time.UTC), b.QueriedAt)
+		}
+	})
+}
+
+func TestProcessAllWallets_IntervalToleratesSchedulerJitter(t *testing.T) {
+	fetcher := newFakeFetcher()
+	tr := New(testConfig(), fetcher, &fakeStore{})
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return start }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	// The next due cycle fires a few milliseconds early
+	tr.now = func() time.Time { return start.Add(15*time.Minute - 20*time.Millisecond) }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	assert.Equal(t, 2, fetcher.count("SLOW"))
+}
+
+func TestProcessAllWallets_RetriesTokenAfterFailure(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("insert failure", func(t *testing.T) {
+		fetcher := newFakeFetcher()
+		store := &fakeStore{err: errors.New("database unavailable")}
+		tr := New(testConfig(), fetcher, store)
+
+		tr.now = func() time.Time { return start }
+		require.EqualError(t, tr.ProcessAllWallets(context.Background()), "all 1 balance inserts failed")
+
+		store.err = nil
+		tr.now = func() time.Time { return start.Add(5 * time.Minute) }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+		assert.Equal(t, 2, fetcher.count("SLOW"), "slow token is retried after a failed insert")
+	})
+
+	t.Run("fetch failure", func(t *testing.T) {
+		fetcher := newFakeFetcher()
+		fetcher.fail = map[string]bool{"SLOW": true}
+		tr := New(testConfig(), fetcher, &fakeStore{})
+
+		tr.now = func() time.Time { return start }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+		fetcher.fail = nil
+		tr.now = func() time.Time { return start.Add(5 * time.Minute) }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+		tr.now = func() time.Time { return start.Add(10 * time.Minute) }
+		require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+		assert.Equal(t, 2, fetcher.count("SLOW"), "slow token is retried after a failed fetch, then waits its interval")
+	})
+}
+
+func TestProcessAllWallets_FailsWhenNothingFetchedOrStored(t *testing.T) {
+	tests := []struct {
+		name     string
+		fail     map[string]bool
+		storeErr error
+		wantErr  string
+	}{
+		{name: "all queries fail", fail: map[string]bool{"FAST": true, "SLOW": true}, wantErr: "all 2 balance queries failed"},
+		{name: "some queries fail", fail: map[string]bool{"SLOW": true}},
+		{name: "all inserts fail", storeErr: errors.New("database unavailable"), wantErr: "all 1 balance inserts failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := newFakeFetcher()
+			fetcher.fail = tt.fail
+			tr := New(testConfig(), fetcher, &fakeStore{err: tt.storeErr})
+
+			err := tr.ProcessAllWallets(context.Background())
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessAllWallets_FailOnFirstRun(t *testing.T) {
+	fetcher := newFakeFetcher()
+	fetcher.fail = map[string]bool{"FAST": true, "SLOW": true}
+	tr := New(testConfig(), fetcher, &fakeStore{})
+
+	s, err := scheduler.NewScheduler(context.Background(), scheduler.Config{
+		Interval:       "1h",
+		RunImmediately: true,
+		FailOnFirstRun: true,
+		Logger:         slog.New(slog.DiscardHandler),
+	}, tr.ProcessAllWallets)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+
+	err = s.Start()
+	require.ErrorContains(t, err, "first run failed")
+	assert.ErrorContains(t, err, "all 2 balance queries failed")
+}
+
+func TestProcessAllWallets_NoTokenDue(t *testing.T) {
+	cfg := testConfig()
+	cfg.Tokens = cfg.Tokens[1:] // only the slow token
+	fetcher := newFakeFetcher()
+	store := &fakeStore{}
+	tr := New(cfg, fetcher, store)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return start }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	tr.now = func() time.Time { return start.Add(5 * time.Minute) }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	assert.Equal(t, 1, fetcher.count("SLOW"))
+	assert.Len(t, store.balances, 1)
+}
+
+func TestProcessAllWallets_RunsPersistHooks(t *testing.T) {
+	store := &fakeStore{}
+	tr := New(testConfig(), newFakeFetcher(), store)
+
+	var published []storage.TokenBalance
+	tr.OnPersist(func(balances []storage.TokenBalance) {
+		published = append(published, balances...)
+	})
+
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	assert.ElementsMatch(t, store.balances, published)
+}
+
+func TestProcessAllWallets_PersistHooksSkipRowsNotWritten(t *testing.T) {
+	cfg := testConfig()
+	store := &fakeStore{skip: func(b storage.TokenBalance) bool { return b.Symbol == "SLOW" }}
+	tr := New(cfg, newFakeFetcher(), store)
+
+	var published []storage.TokenBalance
+	tr.OnPersist(func(balances []storage.TokenBalance) {
+		published = append(published, balances...)
+	})
+
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	require.Len(t, published, 1, "the unchanged SLOW balance is not published")
+	assert.Equal(t, "FAST", published[0].Symbol)
+}
+
+// blockTimeFetcher is a fakeFetcher that also reports a latest block time.
+type blockTimeFetcher struct {
+	*fakeFetcher
+	blockTime time.Time
+	err       error
+	reads     int
+}
+
+func (f *blockTimeFetcher) LatestBlockTime(_ context.Context) (time.Time, error) {
+	f.reads++
+	return f.blockTime, f.err
+}
+
+// blockNumberFetcher is a fakeFetcher that also reports a latest block
+// number, one higher on each read.
+type blockNumberFetcher struct {
+	*fakeFetcher
+	number uint64
+	err    error
+}
+
+func (f *blockNumberFetcher) LatestBlockNumber(_ context.Context) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.number++
+	return f.number, f.err
+}
+
+func TestProcessAllWallets_RecordsBlockNumber(t *testing.T) {
+	cfg := testConfig()
+	cfg.Wallets = append(cfg.Wallets, "0x2345678901234567890123456789012345678901")
+
+	t.Run("one read per wallet", func(t *testing.T) {
+		fetcher := &blockNumberFetcher{fakeFetcher: newFakeFetcher(), number: 41234566}
+		store := &fakeStore{}
+		require.NoError(t, New(cfg, fetcher, store).ProcessAllWallets(context.Background()))
+
+		assert.Equal(t, uint64(41234568), fetcher.number, "block number read once per wallet")
+		require.Len(t, store.balances, 4)
+		byWallet := map[string]uint64{}
+		for _, b := range store.balances {
+			if n, ok := byWallet[b.Wallet]; ok {
+				assert.Equal(t, n, b.BlockNumber, "all tokens of a wallet share its block")
+			}
+			byWallet[b.Wallet] = b.BlockNumber
+		}
+		assert.ElementsMatch(t, []uint64{41234567, 41234568}, []uint64{byWallet[common.HexToAddress(cfg.Wallets[0]).Hex()], byWallet[common.HexToAddress(cfg.Wallets[1]).Hex()]})
+	})
+
+	t.Run("read failure still stores balances", func(t *testing.T) {
+		fetcher := &blockNumberFetcher{fakeFetcher: newFakeFetcher(), err: errors.New("rpc unavailable")}
+		store := &fakeStore{}
+		require.NoError(t, New(cfg, fetcher, store).ProcessAllWallets(context.Background()))
+
+		require.Len(t, store.balances, 4)
+		for _, b := range store.balances {
+			assert.Zero(t, b.BlockNumber)
+		}
+	})
+}
+
+func TestProcessAllWallets_RecordsBlockTimestamp(t *testing.T) {
+	newConfig := func(enabled bool) *config.Config {
+		cfg := testConfig()
+		cfg.Wallets = append(cfg.Wallets, "0x2345678901234567890123456789012345678901")
+		cfg.RecordBlockTimestamp = enabled
+		return cfg
+	}
+
+	t.Run("stamps every row with one read per cycle", func(t *testing.T) {
+		fetcher := &blockTimeFetcher{fakeFetcher: newFakeFetcher(), blockTime: time.Now().UTC().Add(-5 * time.Second)}
+		store := &fakeStore{}
+		require.NoError(t, New(newConfig(true), fetcher, store).ProcessAllWallets(context.Background()))
+
+		assert.Equal(t, 1, fetcher.reads)
+		require.Len(t, store.balances, 4)
+		for _, b := range store.balances {
+			require.NotNil(t, b.BlockTimestamp)
+			assert.Equal(t, fetcher.blockTime, *b.BlockTimestamp)
+			skew := b.QueriedAt.Sub(*b.BlockTimestamp)
+			assert.GreaterOrEqual(t, skew, time.Duration(0), "block time is not after queried_at")
+			assert.Less(t, skew, time.Minute)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		fetcher := &blockTimeFetcher{fakeFetcher: newFakeFetcher(), blockTime: time.Now().UTC()}
+		store := &fakeStore{}
+		require.NoError(t, New(newConfig(false), fetcher, store).ProcessAllWallets(context.Background()))
+
+		assert.Zero(t, fetcher.reads)
+		require.NotEmpty(t, store.balances)
+		for _, b := range store.balances {
+			assert.Nil(t, b.BlockTimestamp)
+		}
+	})
+
+	t.Run("read failure still stores balances", func(t *testing.T) {
+		fetcher := &blockTimeFetcher{fakeFetcher: newFakeFetcher(), err: errors.New("header unavailable")}
+		store := &fakeStore{}
+		require.NoError(t, New(newConfig(true), fetcher, store).ProcessAllWallets(context.Background()))
+
+		require.Len(t, store.balances, 4)
+		for _, b := range store.balances {
+			assert.Nil(t, b.BlockTimestamp)
+		}
+	})
+}
+
+// concurrencyFetcher is a fakeFetcher that holds every call for a while and
+// records the highest number of calls in flight at once.
+type concurrencyFetcher struct {
+	*fakeFetcher
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (f *concurrencyFetcher) GetTokenBalance(ctx context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
+	return f.fakeFetcher.GetTokenBalance(ctx, wallet, token)
+}
+
+func TestProcessAllWallets_WalletConcurrency(t *testing.T) {
+	// One token per wallet, so calls in flight equal wallets in flight
+	newConfig := func(concurrency int) *config.Config {
+		cfg := testConfig()
+		cfg.Tokens = cfg.Tokens[:1]
+		cfg.Wallets = []string{
+			"0x0000000000000000000000000000000000000a01",
+			"0x0000000000000000000000000000000000000a02",
+			"0x0000000000000000000000000000000000000a03",
+			"0x0000000000000000000000000000000000000a04",
+			"0x0000000000000000000000000000000000000a05",
+			"0x0000000000000000000000000000000000000a06",
+		}
+		cfg.WalletConcurrency = concurrency
+		return cfg
+	}
+
+	for _, tc := range []struct {
+		name        string
+		concurrency int
+		wantPeak    int
+	}{
+		{"sequential by default", 0, 1},
+		{"bounded by wallet_concurrency", 2, 2},
+		{"bound above wallet count", 10, 6},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fetcher := &concurrencyFetcher{fakeFetcher: newFakeFetcher()}
+			store := &fakeStore{}
+			tr := New(newConfig(tc.concurrency), fetcher, store)
+
+			require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+			assert.LessOrEqual(t, fetcher.peak, tc.wantPeak, "wallet bound exceeded")
+			assert.Equal(t, tc.wantPeak, fetcher.peak)
+			assert.Len(t, store.balances, 6, "every wallet's batch is inserted")
+			p := tr.Progress()
+			assert.EqualValues(t, 6, p.WalletsDone)
+			assert.False(t, p.Running)
+		})
+	}
+}
+
+func TestProcessAllWallets_StoresWalletTags(t *testing.T) {
+	cfg := testConfig()
+	cfg.RPCUrl = "https://rpc.gnosischain.com"
+	cfg.LabeledWallets = []config.WalletConfig{{
+		Address: "0x2345678901234567890123456789012345678901",
+		Label:   "Savings",
+		Tags:    map[string]string{"owner": "alice"},
+	}}
+	require.NoError(t, cfg.Normalize())
+	store := &fakeStore{}
+	require.NoError(t, New(cfg, newFakeFetcher(), store).ProcessAllWallets(context.Background()))
+
+	require.Len(t, store.balances, 4)
+	for _, b := range store.balances {
+		if b.Wallet == "0x2345678901234567890123456789012345678901" {
+			assert.Equal(t, map[string]string{"owner": "alice"}, b.Tags)
+			assert.Equal(t, "Savings", b.WalletLabel)
+		} else {
+			assert.Nil(t, b.Tags)
+			assert.Empty(t, b.WalletLabel)
+		}
+	}
+}
+
+// scriptedFetcher returns the raw balances of polls, then of verifies, in
+// order. A negative poll balance fails the query.
+type scriptedFetcher struct {
+	mu       sync.Mutex
+	polls    []int64
+	verifies []int64
+	verified int
+}
+
+func (f *scriptedFetcher) next(queue *[]int64, wallet common.Address, token blockchain.TokenInfo) storage.TokenBalance {
+	raw := (*queue)[0]
+	*queue = (*queue)[1:]
+	return storage.TokenBalance{
+		Wallet:       wallet.Hex(),
+		TokenAddress: token.Address,
+		Symbol:       token.Label,
+		RawBalance:   big.NewInt(raw),
+		Balance:      decimal.NewFromInt(raw),
+	}
+}
+
+func (f *scriptedFetcher) GetTokenBalance(_ context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.polls[0] < 0 {
+		f.polls = f.polls[1:]
+		return storage.TokenBalance{}, errors.New("rpc unavailable")
+	}
+	return f.next(&f.polls, wallet, token), nil
+}
+
+func (f *scriptedFetcher) VerifyTokenBalance(_ context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.verified++
+	return f.next(&f.verifies, wallet, token), nil
+}
+
+func TestProcessAllWallets_VerifyZero(t *testing.T) {
+	tests := []struct {
+		name         string
+		verifyZero   bool
+		polls        []int64
+		verifies     []int64
+		want         []int64
+		wantVerified int
+	}{
+		{"false zero corrected", true, []int64{5, 0, 0}, []int64{5, 7}, []int64{5, 5, 7}, 2},
+		{"genuine zero accepted", true, []int64{5, 0, 0}, []int64{0}, []int64{5, 0, 0}, 1},
+		{"zero without prior balance", true, []int64{0, 0}, nil, []int64{0, 0}, 0},
+		{"disabled", false, []int64{5, 0}, nil, []int64{5, 0}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Tokens = cfg.Tokens[:1]
+			cfg.VerifyZero = tt.verifyZero
+			fetcher := &scriptedFetcher{polls: tt.polls, verifies: tt.verifies}
+			store := &fakeStore{}
+			tr := New(cfg, fetcher, store)
+
+			for range tt.polls {
+				require.NoError(t, tr.ProcessAllWallets(context.Background()))
+			}
+
+			var got []int64
+			for _, b := range store.balances {
+				got = append(got, b.RawBalance.Int64())
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantVerified, fetcher.verified)
+		})
+	}
+}
+
+func TestProcessAllWallets_VerifyZeroWithoutVerifier(t *testing.T) {
+	// A fetcher without VerifyTokenBalance is simply queried again
+	cfg := testConfig()
+	cfg.Tokens = cfg.Tokens[:1]
+	cfg.VerifyZero = true
+	fetcher := &struct{ BalanceFetcher }{&scriptedFetcher{polls: []int64{5, 0, 5}}}
+	store := &fakeStore{}
+	tr := New(cfg, fetcher, store)
+
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+	require.Len(t, store.balances, 2)
+	assert.Equal(t, int64(5), store.balances[1].RawBalance.Int64())
+}
+
+// multicallFetcher reads every token at once through fakeFetcher, leaving
+// out the tokens in skip.
+type multicallFetcher struct {
+	*fakeFetcher
+	skip       map[string]bool
+	multicalls int
+}
+
+func (f *multicallFetcher) GetTokenBalancesMulticall(ctx context.Context, wallet common.Address, tokens []blockchain.TokenInfo) ([]storage.TokenBalance, error) {
+	f.mu.Lock()
+	f.multicalls++
+	f.mu.Unlock()
+	var balances []storage.TokenBalance
+	for _, token := range tokens {
+		if f.skip[token.Label] {
+			continue
+		}
+		balances = append(balances, storage.TokenBalance{
+			Wallet:       wallet.Hex(),
+			TokenAddress: token.Address,
+			Symbol:       token.Label,
+			Decimals:     token.FallbackDecimals,
+			Balance:      decimal.NewFromInt(2),
+		})
+	}
+	return balances, errors.New("some tokens failed")
+}
+
+func TestProcessAllWallets_Multicall(t *testing.T) {
+	tests := []struct {
+		name           string
+		address        string
+		wantMulticalls int
+		wantSingle     int
+	}{
+		{"enabled", blockchain.DefaultMulticallAddress, 1, 1},
+		{"disabled", "", 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.MulticallAddress = tt.address
+			fetcher := &multicallFetcher{fakeFetcher: newFakeFetcher(), skip: map[string]bool{"SLOW": true}}
+			store := &fakeStore{}
+			tr := New(cfg, fetcher, store)
+
+			require.NoError(t, tr.ProcessAllWallets(context.Background()))
+
+			assert.Equal(t, tt.wantMulticalls, fetcher.multicalls)
+			assert.Equal(t, tt.wantSingle, fetcher.count("FAST")+fetcher.count("SLOW"))
+			assert.Equal(t, 1, fetcher.count("SLOW"), "a token left out of the multicall is queried alone")
+			require.Len(t, store.balances, 2)
+			for _, b := range store.balances {
+				assert.Equal(t, storage.SourcePoll, b.Source)
+			}
+		})
+	}
+}
+
+// fakeRowRecorder counts the rows reported by the tracker.
+type fakeRowRecorder struct {
+	mu       sync.Mutex
+	inserted int
+	skipped  map[string]int
+}
+
+func (r *fakeRowRecorder) RowsInserted(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inserted += n
+}
+
+func (r *fakeRowRecorder) RowsSkipped(reason string, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipped[reason] += n
+}
+
+// unchangedSkip leaves out a balance equal to the last one written for its
+// wallet and token, as the store does with dedup_unchanged.
+func unchangedSkip() func(storage.TokenBalance) bool {
+	last := map[string]string{}
+	return func(b storage.TokenBalance) bool {
+		key := pollKey(b.Wallet, b.TokenAddress)
+		if last[key] == b.RawBalance.String() {
+			return true
+		}
+		last[key] = b.RawBalance.String()
+		return false
+	}
+}
+
+// rawlessFetcher is a fakeFetcher whose balances lack a raw balance.
+type rawlessFetcher struct{ *fakeFetcher }
+
+func (f rawlessFetcher) GetTokenBalance(ctx context.Context, wallet common.Address, token blockchain.TokenInfo) (storage.TokenBalance, error) {
+	b, err := f.fakeFetcher.GetTokenBalance(ctx, wallet, token)
+	b.RawBalance = nil
+	return b, err
+}
+
+func TestProcessAllWallets_RowRecorder(t *testing.T) {
+	single := func(cfg *config.Config) { cfg.Tokens = cfg.Tokens[:1] }
+	tests := []struct {
+		name         string
+		setup        func(cfg *config.Config)
+		fetcher      BalanceFetcher
+		storeErr     error
+		storeSkip    func(storage.TokenBalance) bool
+		wantInserted int
+		wantSkipped  map[string]int
+	}{
+		{
+			name:         "inserted",
+			setup:        single,
+			fetcher:      &scriptedFetcher{polls: []int64{5, 7}},
+			wantInserted: 2,
+			wantSkipped:  map[string]int{},
+		},
+		{
+			name:         "query failed",
+			setup:        single,
+			fetcher:      &scriptedFetcher{polls: []int64{5, -1}},
+			wantInserted: 1,
+			wantSkipped:  map[string]int{SkipQueryFailed: 1},
+		},
+		{
+			name:         "query failed, carried forward",
+			setup:        func(cfg *config.Config) { single(cfg); cfg.CarryForwardOnFailure = true },
+			fetcher:      &scriptedFetcher{polls: []int64{5, -1}},
+			wantInserted: 2,
+			wantSkipped:  map[string]int{},
+		},
+		{
+			name:         "zero not verified",
+			setup:        func(cfg *config.Config) { single(cfg); cfg.VerifyZero = true },
+			fetcher:      &struct{ BalanceFetcher }{&scriptedFetcher{polls: []int64{5, 0, -1}}},
+			wantInserted: 1,
+			wantSkipped:  map[string]int{SkipZeroUnverified: 1},
+		},
+		{
+			name:         "no raw balance",
+			setup:        single,
+			fetcher:      rawlessFetcher{newFakeFetcher()},
+			wantInserted: 0,
+			wantSkipped:  map[string]int{SkipNoRawBalance: 2},
+		},
+		{
+			name:         "unchanged",
+			setup:        func(cfg *config.Config) { single(cfg); cfg.DedupUnchanged = true },
+			fetcher:      &scriptedFetcher{polls: []int64{5, 5}},
+			storeSkip:    unchangedSkip(),
+			wantInserted: 1,
+			wantSkipped:  map[string]int{SkipUnchanged: 1},
+		},
+		{
+			name:         "duplicate",
+			setup:        single,
+			fetcher:      &scriptedFetcher{polls: []int64{5, 7}},
+			storeSkip:    func(b storage.TokenBalance) bool { return b.RawBalance.Int64() == 7 },
+			wantInserted: 1,
+			wantSkipped:  map[string]int{SkipDuplicate: 1},
+		},
+		{
+			name:         "insert failed",
+			setup:        single,
+			fetcher:      &scriptedFetcher{polls: []int64{5, 7}},
+			storeErr:     errors.New("database down"),
+			wantInserted: 0,
+			wantSkipped:  map[string]int{SkipInsertFailed: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.setup(cfg)
+			rows := &fakeRowRecorder{skipped: map[string]int{}}
+			tr := New(cfg, tt.fetcher, &fakeStore{err: tt.storeErr, skip: tt.storeSkip})
+			tr.SetRowRecorder(rows)
+
+			_ = tr.ProcessAllWallets(context.Background())
+			_ = tr.ProcessAllWallets(context.Background())
+
+			assert.Equal(t, tt.wantInserted, rows.inserted)
+			assert.Equal(t, tt.wantSkipped, rows.skipped)
+		})
+	}
+}
+
+// captureLogs sends the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestProcessAllWallets_LogBalanceSampling(t *testing.T) {
+	polls := []int64{5, 5, 7, 7, 7, 7}
+	tests := []struct {
+		sampling string
+		want     int
+	}{
+		{"", 6},
+		{"all", 6},
+		{"none", 0},
+		{"changed", 2},
+		{"2", 3},
+		{"4", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sampling, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Tokens = cfg.Tokens[:1]
+			cfg.LogBalanceSampling = tt.sampling
+			tr := New(cfg, &scriptedFetcher{polls: slices.Clone(polls)}, &fakeStore{})
+			logs := captureLogs(t)
+
+			for range polls {
+				require.NoError(t, tr.ProcessAllWallets(context.Background()))
+			}
+
+			out := logs.String()
+			assert.Equal(t, tt.want, strings.Count(out, "Balance retrieved"))
+			assert.Equal(t, len(polls), strings.Count(out, "Processing completed successfully"), "cycle summary is always logged")
+		})
+	}
+}
+
+func TestProcessAllWallets_LogBalanceSamplingKeepsErrors(t *testing.T) {
+	cfg := testConfig()
+	cfg.LogBalanceSampling = "none"
+	fetcher := newFakeFetcher()
+	fetcher.fail = map[string]bool{"FAST": true}
+	logs := captureLogs(t)
+
+	require.NoError(t, New(cfg, fetcher, &fakeStore{}).ProcessAllWallets(context.Background()))
+
+	assert.Contains(t, logs.String(), "Token query error")
+	assert.NotContains(t, logs.String(), "Balance retrieved")
+}
+
+func TestProcessAllWallets_CarryForwardOnFailure(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(t *testing.T, enabled bool, polls []int64) []storage.TokenBalance {
+		t.Helper()
+		cfg := testConfig()
+		cfg.Tokens = cfg.Tokens[:1]
+		cfg.CarryForwardOnFailure = enabled
+		store := &fakeStore{}
+		tr := New(cfg, &scriptedFetcher{polls: polls}, store)
+		for i := range polls {
+			now := start.Add(time.Duration(i) * 5 * time.Minute)
+			tr.now = func() time.Time { return now }
+			// A cycle whose only query fails fails, carried forward or not
+			if err := tr.ProcessAllWallets(context.Background()); polls[i] < 0 {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		}
+		return store.balances
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		// A failure before any success has nothing to carry
+		balances := run(t, true, []int64{-1, 5, -1, -1, 7})
+		require.Len(t, balances, 4)
+		var raw []int64
+		var carried []bool
+		for _, b := range balances {
+			raw = append(raw, b.RawBalance.Int64())
+			carried = append(carried, b.CarriedForward)
+		}
+		assert.Equal(t, []int64{5, 5, 5, 7}, raw)
+		assert.Equal(t, []bool{false, true, true, false}, carried)
+		assert.Equal(t, start.Add(10*time.Minute), balances[1].QueriedAt, "carried rows take the cycle time")
+		assert.Equal(t, start.Add(15*time.Minute), balances[2].QueriedAt)
+		assert.Equal(t, storage.SourcePoll, balances[1].Source)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		balances := run(t, false, []int64{5, -1, 7})
+		require.Len(t, balances, 2)
+		for _, b := range balances {
+			assert.False(t, b.CarriedForward)
+		}
+	})
+}
+
+func TestReload(t *testing.T) {
+	first := newFakeFetcher()
+	store := &fakeStore{}
+	tr := New(testConfig(), first, store)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return start }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	require.Len(t, store.balances, 2)
+
+	cfg := testConfig()
+	cfg.Wallets = append(cfg.Wallets, "0x2345678901234567890123456789012345678901")
+	second := newFakeFetcher()
+	tr.Reload(cfg, second)
+	assert.Equal(t, 2, first.calls["FAST"]+first.calls["SLOW"], "nothing fetched before the next cycle")
+
+	tr.now = func() time.Time { return start.Add(5 * time.Minute) }
+	require.NoError(t, tr.ProcessAllWallets(context.Background()))
+	assert.Equal(t, 1, first.calls["FAST"], "the old fetcher is no longer used")
+	assert.Equal(t, 2, second.calls["FAST"], "the added wallet is polled")
+	assert.Equal(t, 1, second.calls["SLOW"], "the interval of the known wallet is kept across the reload")
+}